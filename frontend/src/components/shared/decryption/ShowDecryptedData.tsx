@@ -25,6 +25,7 @@ const DecryptedDataComponent: React.FC<DecryptedDataProps> = ({
   onClose,
 }) => {
   const [decryptedData, setDecryptedData] = useState<string | null>(null);
+  const [warnings, setWarnings] = useState<string[]>([]);
   const [isLoading, setIsLoading] = useState(false);
   const { toast } = useToast();
   const [passphrase, setPassphrase] = useState("");
@@ -40,7 +41,8 @@ const DecryptedDataComponent: React.FC<DecryptedDataProps> = ({
         passphrase,
       });
 
-      setDecryptedData(decrypted);
+      setDecryptedData(decrypted.data);
+      setWarnings(decrypted.warnings ?? []);
     } catch (error) {
       toast({
         variant: "destructive",
@@ -92,11 +94,18 @@ const DecryptedDataComponent: React.FC<DecryptedDataProps> = ({
             )}
           </>
         ) : (
-          <div className="p-4 bg-gray-100 rounded-md">
-            <pre className="whitespace-pre-wrap text-gray-700">
-              {decryptedData}
-            </pre>
-          </div>
+          <>
+            {warnings.map((warning) => (
+              <p key={warning} className="text-sm text-yellow-500">
+                {warning}
+              </p>
+            ))}
+            <div className="p-4 bg-gray-100 rounded-md">
+              <pre className="whitespace-pre-wrap text-gray-700">
+                {decryptedData}
+              </pre>
+            </div>
+          </>
         )}
 
         {decryptedData && (
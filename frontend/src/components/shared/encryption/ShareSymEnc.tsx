@@ -20,6 +20,7 @@ import { LoadEncryptedContent } from "@wailsjs/go/en/EnRetrieve";
 import { en, hybenc } from "@wailsjs/go/models";
 import { Eye, EyeOff, Share } from "lucide-react";
 import React from "react";
+import { getErrorMessage } from "@/lib/utils/errors";
 
 interface Props {
   data: en.KeyInfo;
@@ -161,12 +162,7 @@ export default function ShareSymEnc({ data }: Props) {
         description: "Your data and passphrase have been encrypted.",
       });
     } catch (error) {
-      const errorMessage =
-        error instanceof Error
-          ? error.message
-          : typeof error === "string"
-            ? error
-            : JSON.stringify(error);
+      const errorMessage = getErrorMessage(error);
 
       toast({
         variant: "destructive",
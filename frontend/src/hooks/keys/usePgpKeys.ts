@@ -3,6 +3,7 @@ import { pgpfs } from "@wailsjs/go/models";
 import { RetrievePgpKeys } from "@wailsjs/go/pgpfs/PgpRetrieve";
 import { LogError } from "@wailsjs/runtime/runtime";
 import React from "react";
+import { getErrorMessage } from "@/lib/utils/errors";
 
 export function usePgpKeys() {
   const [pgpKeys, setPgpKeys] = React.useState<pgpfs.PgpKeyInfo[]>([]);
@@ -14,12 +15,7 @@ export function usePgpKeys() {
 
       setPgpKeys(keys);
     } catch (error) {
-      const errorMessage =
-        error instanceof Error
-          ? error.message
-          : typeof error === "string"
-            ? error
-            : JSON.stringify(error);
+      const errorMessage = getErrorMessage(error);
       LogError("Error retrieving PGP keys: " + errorMessage);
 
       toast({
@@ -0,0 +1,21 @@
+// Every error returned by a Go method is formatted by apperr.Format into
+// { code, message } before it reaches the frontend
+export type AppError = {
+  code: string;
+  message: string;
+};
+
+export const isAppError = (error: unknown): error is AppError =>
+  typeof error === "object" &&
+  error !== null &&
+  "code" in error &&
+  "message" in error;
+
+export const errorCode = (error: unknown): string =>
+  isAppError(error) ? error.code : "internal";
+
+export const getErrorMessage = (error: unknown): string => {
+  if (isAppError(error) || error instanceof Error) return error.message;
+  if (typeof error === "string") return error;
+  return JSON.stringify(error);
+};
@@ -23,6 +23,7 @@ import {
 import { hybdec } from "@wailsjs/go/models";
 import { LogInfo } from "@wailsjs/runtime/runtime";
 import { FiCheck, FiCopy } from "react-icons/fi";
+import { getErrorMessage } from "@/lib/utils/errors";
 
 export default function Cipher() {
   const [operation, setOperation] = React.useState<
@@ -110,9 +111,7 @@ export default function Cipher() {
     } catch (error) {
       setResult({
         success: false,
-        message: `Decryption failed: ${
-          error instanceof Error ? error.message : "Unknown error"
-        }`,
+        message: `Decryption failed: ${getErrorMessage(error)}`,
       });
     }
   };
@@ -134,22 +133,21 @@ export default function Cipher() {
     } catch (error) {
       setResult({
         success: false,
-        message: `Decryption and validation failed: ${
-          error instanceof Error ? error.message : "Unknown error"
-        }`,
+        message: `Decryption and validation failed: ${getErrorMessage(error)}`,
       });
     }
   };
 
   const validateSignature = async () => {
     try {
-      const isValid = await ValidateSignature({
+      const verification = await ValidateSignature({
         data: pgpMessage,
         pubKey: pubPgpKey,
         privKey: pgpKey,
         privPassphrase: passphrase,
       });
-      LogInfo(JSON.stringify(isValid));
+      LogInfo(JSON.stringify(verification));
+      const isValid = verification.valid;
       setDecryptedData({ signature: isValid });
       setResult({
         success: isValid,
@@ -160,9 +158,7 @@ export default function Cipher() {
     } catch (error) {
       setResult({
         success: false,
-        message: `Signature validation failed: ${
-          error instanceof Error ? error.message : "Unknown error"
-        }`,
+        message: `Signature validation failed: ${getErrorMessage(error)}`,
       });
     }
   };
@@ -24,6 +24,7 @@ import EncryptionForm from "./forms/EncryptionForm";
 import KeySaveForm from "./forms/KeySaveForm";
 import KeyTypeTabs from "./utils/KeyTypeTabs";
 import Questions from "./utils/Questions";
+import { getErrorMessage } from "@/lib/utils/errors";
 
 interface Props {
   fetchKeys: () => Promise<void>;
@@ -123,12 +124,7 @@ export default function KeysGenModal({ fetchKeys }: Props) {
       });
     } catch (error) {
       LogError("Hybrid Encryption failed: " + JSON.stringify(error));
-      const errorMessage =
-        error instanceof Error
-          ? error.message
-          : typeof error === "string"
-            ? error
-            : JSON.stringify(error);
+      const errorMessage = getErrorMessage(error);
 
       toast({
         variant: "destructive",
@@ -188,8 +184,7 @@ export default function KeysGenModal({ fetchKeys }: Props) {
       fetchKeys();
     } catch (error) {
       LogError("Saving encrypted message failed: " + JSON.stringify(error));
-      const errorMessage =
-        error instanceof Error ? error.message : "Unknown error";
+      const errorMessage = getErrorMessage(error);
 
       toast({
         variant: "destructive",
@@ -21,6 +21,7 @@ import NumberOfBitsRSA from "../PGP/SelectNumberOfBitsForRSA";
 import { PacmanLoader } from "react-spinners";
 import SelectCurve from "../PGP/SelectCurve";
 import useSelectCurve from "@/lib/store/useSelectCurve";
+import { getErrorMessage } from "@/lib/utils/errors";
 
 type Props = {
   fetchPgpKeys: () => void;
@@ -63,12 +64,7 @@ export function PgpKeysGenForm({ fetchPgpKeys }: Props) {
         });
       }
     } catch (err) {
-      const errorMessage =
-        err instanceof Error
-          ? err.message
-          : typeof err === "string"
-            ? err
-            : JSON.stringify(err);
+      const errorMessage = getErrorMessage(err);
       LogError("Failed to generate keys: " + errorMessage);
       toast({
         variant: "destructive",
@@ -11,6 +11,7 @@ import { LogInfo, LogError } from "@wailsjs/runtime/runtime";
 import { usePrivateKeyDecryption } from "@/hooks/keys/usePrivateKeyDecryption";
 import { Input } from "@/components/ui/input";
 import { XIcon } from "lucide-react";
+import { getErrorMessage } from "@/lib/utils/errors";
 
 export default function KeyMoreInfo({ keyInfo }: { keyInfo: PGPInfo }) {
   const [copied, setCopied] = React.useState(false);
@@ -73,8 +74,7 @@ export default function KeyMoreInfo({ keyInfo }: { keyInfo: PGPInfo }) {
         handleCopy(keyData, successMessage);
       }
     } catch (err) {
-      const errorMessage =
-        err instanceof Error ? err.message : JSON.stringify(err);
+      const errorMessage = getErrorMessage(err);
       LogError(`Failed to process ${action}: ${errorMessage}`);
       toast({
         variant: "destructive",
@@ -16,6 +16,7 @@ import { LoadAsymEnData, RetrievePGPMsgInfo } from "@wailsjs/go/en/EnRetrieve";
 import { LogError } from "@wailsjs/runtime/runtime";
 import React from "react";
 import PGPMessageInfo from "./pgp-more-info";
+import { getErrorMessage } from "@/lib/utils/errors";
 
 interface PGPCardListProps {
   data: FileInfo[];
@@ -34,8 +35,7 @@ const handleCopy = (text: string) => {
     })
     .catch((err) => {
       LogError(`Failed to copy to clipboard: ${err}`);
-      const errorMessage =
-        err instanceof Error ? err.message : JSON.stringify(err);
+      const errorMessage = getErrorMessage(err);
       toast({
         variant: "destructive",
         className: "bg-red-500 border-0",
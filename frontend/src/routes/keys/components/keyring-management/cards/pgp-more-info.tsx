@@ -2,6 +2,7 @@ import React from "react";
 import { toast } from "@/hooks/use-toast";
 import { PGPInfo } from "@/lib/types/keys";
 import { ClipboardIcon } from "lucide-react";
+import { getErrorMessage } from "@/lib/utils/errors";
 
 interface PGPMessageInfoProps {
   msgData: PGPInfo;
@@ -19,8 +20,7 @@ const handleCopy = (text: string) => {
       });
     })
     .catch((err) => {
-      const errorMessage =
-        err instanceof Error ? err.message : JSON.stringify(err);
+      const errorMessage = getErrorMessage(err);
       toast({
         variant: "destructive",
         className: "bg-red-500 border-0",
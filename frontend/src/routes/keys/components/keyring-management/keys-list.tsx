@@ -17,6 +17,7 @@ import { LogError, LogInfo } from "@wailsjs/runtime/runtime";
 import React from "react";
 import KeyMoreInfo from "./KeyMoreInfo";
 import { PGPInfo } from "@/lib/types/keys";
+import { getErrorMessage } from "@/lib/utils/errors";
 
 export default function ListKeys({ keys }: { keys: pgpfs.PgpKeyInfo[] }) {
   const [keyMoreInfo, setKeyMoreInfo] = React.useState<PGPInfo | null>(null);
@@ -40,12 +41,7 @@ export default function ListKeys({ keys }: { keys: pgpfs.PgpKeyInfo[] }) {
           })
           .catch((err) => {
             LogError(`Failed to copy to clipboard: ${err}`);
-            const errorMessage =
-              err instanceof Error
-                ? err.message
-                : typeof err === "string"
-                  ? err
-                  : JSON.stringify(err);
+            const errorMessage = getErrorMessage(err);
 
             toast({
               variant: "destructive",
@@ -79,12 +75,7 @@ export default function ListKeys({ keys }: { keys: pgpfs.PgpKeyInfo[] }) {
           })
           .catch((err) => {
             LogError(`Failed to copy to clipboard: ${err}`);
-            const errorMessage =
-              err instanceof Error
-                ? err.message
-                : typeof err === "string"
-                  ? err
-                  : JSON.stringify(err);
+            const errorMessage = getErrorMessage(err);
 
             toast({
               variant: "destructive",
@@ -6,4 +6,4 @@ export function Decrypt(arg1:hybdec.RequestData):Promise<hybdec.ReturnType>;
 
 export function DecryptAndValidate(arg1:hybdec.RequestData):Promise<hybdec.ReturnType>;
 
-export function ValidateSignature(arg1:hybdec.RequestData):Promise<boolean>;
+export function ValidateSignature(arg1:hybdec.RequestData):Promise<hybdec.Verification>;
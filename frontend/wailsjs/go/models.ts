@@ -72,6 +72,7 @@ export namespace hybdec {
 	export class ReturnType {
 	    data: string;
 	    valid: boolean;
+	    warnings?: string[];
 	
 	    static createFrom(source: any = {}) {
 	        return new ReturnType(source);
@@ -81,8 +82,58 @@ export namespace hybdec {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.data = source["data"];
 	        this.valid = source["valid"];
+	        this.warnings = source["warnings"];
 	    }
 	}
+	export class Verification {
+	    valid: boolean;
+	    failure?: string;
+	    message?: string;
+	    signerKeyId?: string;
+	    signerFingerprint?: string;
+	    signerKeyName?: string;
+	    // Go type: time
+	    createdAt?: any;
+	    hashAlgorithm?: string;
+	    keyExpiredAtSigning?: boolean;
+	    keyRevokedAtSigning?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Verification(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.valid = source["valid"];
+	        this.failure = source["failure"];
+	        this.message = source["message"];
+	        this.signerKeyId = source["signerKeyId"];
+	        this.signerFingerprint = source["signerFingerprint"];
+	        this.signerKeyName = source["signerKeyName"];
+	        this.createdAt = this.convertValues(source["createdAt"], null);
+	        this.hashAlgorithm = source["hashAlgorithm"];
+	        this.keyExpiredAtSigning = source["keyExpiredAtSigning"];
+	        this.keyRevokedAtSigning = source["keyRevokedAtSigning"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 
 }
 
@@ -1,5 +1,6 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 import {symmetricdecryption} from '../models';
+import {hybdec} from '../models';
 
-export function DecryptAES(arg1:symmetricdecryption.DataToDecrypt):Promise<string>;
+export function DecryptAES(arg1:symmetricdecryption.DataToDecrypt):Promise<hybdec.ReturnType>;
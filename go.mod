@@ -6,12 +6,15 @@ toolchain go1.23.0
 
 require (
 	github.com/ProtonMail/go-crypto v1.1.0-beta.0-proton
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/makiuchi-d/gozxing v0.1.1
 	github.com/wailsapp/wails/v2 v2.9.2
 )
 
 require (
 	github.com/cloudflare/circl v1.3.7 // indirect
 	golang.org/x/crypto v0.27.0 // indirect
+	golang.org/x/xerrors v0.0.0-20200804184101-5ec99f83aff1 // indirect
 )
 
 require (
@@ -1,15 +1,35 @@
 package main
 
 import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/batch"
 	hybdec "MindLockr/server/cryptography/decryption/hyb_dec"
 	symmetricdecryption "MindLockr/server/cryptography/decryption/symmetric_decryption"
+	unifieddec "MindLockr/server/cryptography/decryption/unified_dec"
+	direnc "MindLockr/server/cryptography/encryption/dir_enc"
 	hybenc "MindLockr/server/cryptography/encryption/hyb_enc"
 	symmetricencryption "MindLockr/server/cryptography/encryption/symmetric_encryption"
+	"MindLockr/server/cryptography/otp"
+	"MindLockr/server/cryptography/passphrase"
 	pgpdec "MindLockr/server/cryptography/pgp/pgp_dec"
+	pgpdiscovery "MindLockr/server/cryptography/pgp/pgp_discovery"
+	pgpedit "MindLockr/server/cryptography/pgp/pgp_edit"
 	pgpgen "MindLockr/server/cryptography/pgp/pgp_gen"
+	pgpmime "MindLockr/server/cryptography/pgp/pgp_mime"
+	pgpserver "MindLockr/server/cryptography/pgp/pgp_server"
+	pgptrust "MindLockr/server/cryptography/pgp/pgp_trust"
+	"MindLockr/server/cryptography/pgpformat"
+	"MindLockr/server/cryptography/session"
+	"MindLockr/server/cryptography/shamir"
 	"MindLockr/server/filesystem"
+	"MindLockr/server/filesystem/audit"
 	"MindLockr/server/filesystem/en"
+	"MindLockr/server/filesystem/inbox"
+	"MindLockr/server/filesystem/integrity"
+	"MindLockr/server/filesystem/items"
 	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"MindLockr/server/filesystem/watcher"
+	"MindLockr/server/jobs"
 	"context"
 	"embed"
 
@@ -29,12 +49,31 @@ func main() {
 	symmetric_decryption := &symmetricdecryption.Cryptography{}
 	hyb_enc := &hybenc.HybEnc{}
 	hyb_dec := &hybdec.HybDec{}
+	unified_dec := &unifieddec.UnifiedDec{}
+	dir_enc := &direnc.DirEnc{}
+	batchFiles := &batch.Batch{}
+	backgroundJobs := &jobs.Jobs{}
 	folder := filesystem.GetFolderInstance()
 	enRetrieve := en.NewEnRetrieve(folder)
 	keyStore := &en.KeyStore{}
 	pgp_gen := &pgpgen.PgpKeysGen{}
 	pgp_get := pgpfs.NewPgpRetrieve(folder)
 	pgp_dec := &pgpdec.PgpDec{}
+	pgp_edit := &pgpedit.PgpEdit{}
+	pgp_trust := &pgptrust.PgpTrust{}
+	pgp_discovery := pgpdiscovery.NewPgpDiscovery(pgpdiscovery.NewClient())
+	pgp_server := pgpserver.NewKeyServer()
+	pgp_mime := &pgpmime.PgpMime{}
+	pgp_format := &pgpformat.PgpFormat{}
+	vaultIntegrity := integrity.NewIntegrity(folder)
+	vaultWatcher := watcher.NewWatcher(folder)
+	dropInbox := inbox.NewInbox(folder)
+	auditLog := audit.NewAudit(folder)
+	secretSharing := &shamir.SecretSharing{}
+	passphraseGen := &passphrase.Passphrase{}
+	vaultSession := &session.Session{}
+	vaultItems := items.NewItems(folder)
+	authenticator := otp.NewAuthenticator(folder)
 
 	app := NewApp()
 
@@ -89,6 +128,7 @@ func main() {
 			WebviewGpuPolicy:    linux.WebviewGpuPolicyAlways,
 			ProgramName:         "wails",
 		},
+		ErrorFormatter: apperr.Format,
 		Debug: options.Debug{
 			OpenInspectorOnStartup: false,
 		},
@@ -96,6 +136,15 @@ func main() {
 		OnStartup: func(ctx context.Context) {
 			app.startup(ctx)
 			folder.SetContext(ctx)
+			vaultIntegrity.SetContext(ctx)
+			backgroundJobs.SetContext(ctx)
+			vaultWatcher.SetContext(ctx)
+			dropInbox.SetContext(ctx)
+		},
+		OnShutdown: func(ctx context.Context) {
+			pgp_server.Stop()
+			vaultWatcher.Close()
+			dropInbox.Close()
 		},
 		Bind: []interface{}{
 			app,
@@ -107,8 +156,27 @@ func main() {
 			pgp_gen,
 			pgp_get,
 			pgp_dec,
+			pgp_edit,
+			pgp_trust,
+			pgp_discovery,
+			pgp_server,
+			pgp_mime,
+			pgp_format,
 			hyb_enc,
 			hyb_dec,
+			unified_dec,
+			dir_enc,
+			batchFiles,
+			backgroundJobs,
+			vaultIntegrity,
+			vaultWatcher,
+			dropInbox,
+			auditLog,
+			secretSharing,
+			passphraseGen,
+			vaultSession,
+			vaultItems,
+			authenticator,
 		},
 	})
 	if err != nil {
@@ -0,0 +1,78 @@
+package apperr
+
+import (
+	"errors"
+	"os"
+)
+
+// Code identifies a class of failure so the frontend can react to it and
+// show its own localized message
+type Code string
+
+const (
+	CodeWrongPassphrase    Code = "wrong_passphrase"
+	CodePassphraseRequired Code = "passphrase_required"
+	CodeWeakPassphrase     Code = "weak_passphrase"
+	CodeSessionLocked      Code = "session_locked"
+	CodeNoVaultFolder      Code = "no_vault_folder"
+	CodeKeyNotFound        Code = "key_not_found"
+	CodeInvalidKey         Code = "invalid_key"
+	CodeCorruptMessage     Code = "corrupt_message"
+	CodeSignatureInvalid   Code = "signature_invalid"
+	CodeNotFound           Code = "not_found"
+	CodeInvalidInput       Code = "invalid_input"
+	CodeInternal           Code = "internal"
+)
+
+// Error is a sentinel error with a code. Wrap it with %w to add context,
+// errors.Is and CodeOf still find it
+type Error struct {
+	Code    Code
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+var (
+	ErrWrongPassphrase    = &Error{CodeWrongPassphrase, "wrong passphrase"}
+	ErrPassphraseRequired = &Error{CodePassphraseRequired, "a passphrase is required"}
+	ErrWeakPassphrase     = &Error{CodeWeakPassphrase, "the passphrase is too weak"}
+	ErrSessionLocked      = &Error{CodeSessionLocked, "vault session is locked"}
+	ErrNoVaultFolder      = &Error{CodeNoVaultFolder, "Please initialize the folder where you want to store data"}
+	ErrKeyNotFound        = &Error{CodeKeyNotFound, "key not found"}
+	ErrInvalidKey         = &Error{CodeInvalidKey, "invalid key"}
+	ErrCorruptMessage     = &Error{CodeCorruptMessage, "the message is corrupt or not an OpenPGP message"}
+	ErrSignatureInvalid   = &Error{CodeSignatureInvalid, "signature verification failed"}
+	ErrNotFound           = &Error{CodeNotFound, "not found"}
+	ErrInvalidInput       = &Error{CodeInvalidInput, "invalid input"}
+)
+
+// CodeOf returns the code of the first coded error in the chain of err. A
+// missing file counts as not found, anything else is internal
+func CodeOf(err error) Code {
+	var coded *Error
+	if errors.As(err, &coded) {
+		return coded.Code
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return CodeNotFound
+	}
+	return CodeInternal
+}
+
+// Envelope is what the frontend receives when a bound method fails
+type Envelope struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+}
+
+// Format is the Wails error formatter, it turns every error returned by a
+// bound method into an Envelope
+func Format(err error) any {
+	return Envelope{
+		Code:    CodeOf(err),
+		Message: err.Error(),
+	}
+}
@@ -0,0 +1,150 @@
+package batch
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/pgpformat"
+	"MindLockr/server/jobs"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// operations of a batch
+const (
+	OpEncryptKey        = "encrypt-key"
+	OpEncryptPassphrase = "encrypt-passphrase"
+	OpDecrypt           = "decrypt"
+	OpVerify            = "verify"
+)
+
+// JobKind is the kind of the jobs started by StartBatch
+const JobKind = "batch"
+
+// encryptedExt is appended to the name of every encrypted file
+const encryptedExt = ".pgp"
+
+type (
+	Batch struct{}
+
+	Request struct {
+		Operation string   `json:"operation"`
+		Files     []string `json:"files"`
+		// OutputDir receives the written files, they are written next to
+		// their input when it is empty. Verification writes nothing
+		OutputDir string `json:"outputDir,omitempty"`
+		// Recipients are the names of the vault keys to encrypt to
+		Recipients []string `json:"recipients,omitempty"`
+		// Passphrase is the message password when encrypting with a
+		// passphrase, and the key passphrase or the message password when
+		// decrypting
+		Passphrase string `json:"passphrase,omitempty"`
+		// SignerKeyName is our key that signs encrypted files, optional
+		SignerKeyName    string `json:"signerKeyName,omitempty"`
+		SignerPassphrase string `json:"signerPassphrase,omitempty"`
+		// Workers is the number of files processed at once, 0 picks one
+		// per CPU
+		Workers int `json:"workers,omitempty"`
+	}
+)
+
+// StartBatch checks req and starts a job that runs the operation on every
+// file. Progress is reported through the jobs events, the per file report
+// is in the job returned by GetJob once it is finished
+func (b *Batch) StartBatch(req Request) (jobs.Job, error) {
+	if len(req.Files) == 0 {
+		return jobs.Job{}, fmt.Errorf("%w: choose the files to process", apperr.ErrInvalidInput)
+	}
+	if req.OutputDir != "" {
+		if err := os.MkdirAll(req.OutputDir, 0700); err != nil {
+			return jobs.Job{}, fmt.Errorf("failed to create %s: %w", req.OutputDir, err)
+		}
+	}
+	workers := req.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	var (
+		work    jobs.Work
+		release = func() {}
+	)
+	switch req.Operation {
+	case OpEncryptKey, OpEncryptPassphrase:
+		params, rel, err := encryptParams(req)
+		if err != nil {
+			return jobs.Job{}, err
+		}
+		release = rel
+		work = func(ctx context.Context, path string) (string, any, error) {
+			output, err := encryptFile(ctx, path, outputDir(req, path), params)
+			return output, nil, err
+		}
+	case OpDecrypt:
+		work = func(ctx context.Context, path string) (string, any, error) {
+			return decryptFile(ctx, path, outputDir(req, path), req.Passphrase)
+		}
+	case OpVerify:
+		work = func(ctx context.Context, path string) (string, any, error) {
+			detail, err := verifyFile(path)
+			return "", detail, err
+		}
+	default:
+		return jobs.Job{}, fmt.Errorf("%w: unknown operation %q", apperr.ErrInvalidInput, req.Operation)
+	}
+
+	job, err := jobs.Start(JobKind, req.Files, workers, work)
+	if err != nil {
+		release()
+		return jobs.Job{}, err
+	}
+	go func() {
+		jobs.Wait(job.ID)
+		release()
+	}()
+	return job, nil
+}
+
+// encryptParams resolves what req encrypts to, a passphrase batch never
+// encrypts to keys and a key batch never to a passphrase
+func encryptParams(req Request) (pgpformat.EncryptParams, func(), error) {
+	params := pgpformat.ParamsRequest{
+		SignerKeyName:    req.SignerKeyName,
+		SignerPassphrase: req.SignerPassphrase,
+	}
+	if req.Operation == OpEncryptKey {
+		if len(req.Recipients) == 0 {
+			return pgpformat.EncryptParams{}, func() {}, fmt.Errorf("%w: choose at least one recipient", apperr.ErrInvalidInput)
+		}
+		params.Recipients = req.Recipients
+	} else {
+		if req.Passphrase == "" {
+			return pgpformat.EncryptParams{}, func() {}, fmt.Errorf("%w: choose a passphrase", apperr.ErrPassphraseRequired)
+		}
+		params.Passphrase = req.Passphrase
+	}
+	return pgpformat.ResolveParams(params)
+}
+
+func outputDir(req Request, path string) string {
+	if req.OutputDir != "" {
+		return req.OutputDir
+	}
+	return filepath.Dir(path)
+}
+
+// ctxReader stops a copy once the job is cancelled, so cancelling does
+// not wait for a large file to be done
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr ctxReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
@@ -0,0 +1,176 @@
+package batch
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/cryptohelper"
+	hybdec "MindLockr/server/cryptography/decryption/hyb_dec"
+	unifieddec "MindLockr/server/cryptography/decryption/unified_dec"
+	"MindLockr/server/cryptography/pgpformat"
+	"MindLockr/server/filesystem/audit"
+	"MindLockr/server/filesystem/integrity"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// encryptFile streams the file at path into "<name>.pgp" in dir, keeping
+// its name and modification time in the literal data packet
+func encryptFile(ctx context.Context, path, dir string, params pgpformat.EncryptParams) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%w: %s is not a regular file", apperr.ErrInvalidInput, path)
+	}
+
+	name := filepath.Base(path)
+	out, err := pgpformat.CreateFile(dir, &pgpformat.Metadata{Filename: name + encryptedExt})
+	if err != nil {
+		return "", err
+	}
+	written := out.Name()
+
+	err = func() error {
+		// the file is not read up front, so it is always marked binary
+		plaintext, err := pgpformat.EncryptingWriter(out, pgpformat.Metadata{
+			Filename: name,
+			ModTime:  info.ModTime().Unix(),
+			Binary:   true,
+		}, params)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(plaintext, ctxReader{ctx, in}); err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", path, err)
+		}
+		if err := plaintext.Close(); err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", path, err)
+		}
+		return out.Close()
+	}()
+	if err != nil {
+		out.Close()
+		os.Remove(written)
+		return "", err
+	}
+	return written, nil
+}
+
+// decryptFile streams the decryption of the message at path into dir,
+// under the filename the message carries or the name of the input without
+// its extension. The file is removed again when the verification policy
+// rejects the signature
+func decryptFile(ctx context.Context, path, dir, passphrase string) (string, any, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer in.Close()
+
+	vaultKeys, _ := pgpfs.VaultKeys()
+	decHandle, recipient, release, err := hybdec.DecryptionHandle(in, passphrase, vaultKeys)
+	if err != nil {
+		return "", nil, err
+	}
+	defer release()
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return "", nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	fingerprint := ""
+	if recipient != nil {
+		fingerprint = recipient.Fingerprint
+	}
+	reader, err := decHandle.DecryptingReader(in, crypto.Auto)
+	audit.Record(audit.OpDecrypt, filepath.Base(path), fingerprint, err)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to decrypt %s: %w", path, cryptohelper.DecryptionError(err))
+	}
+
+	meta := pgpformat.MetadataOf(reader.GetMetadata())
+	named := &pgpformat.Metadata{Filename: strippedName(path)}
+	if meta != nil && meta.Filename != "" {
+		named = meta
+	}
+	out, err := pgpformat.CreateFile(dir, named)
+	if err != nil {
+		return "", nil, err
+	}
+	written := out.Name()
+
+	ret := hybdec.AutoReturnType{
+		ReturnType: hybdec.ReturnType{Warnings: integrity.Warnings(), Literal: meta},
+		Recipient:  recipient,
+	}
+	err = func() error {
+		if _, err := io.Copy(out, ctxReader{ctx, reader}); err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", path, cryptohelper.DecryptionError(err))
+		}
+		if err := out.Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", written, err)
+		}
+		verifyResult, err := reader.VerifySignature()
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", path, cryptohelper.DecryptionError(err))
+		}
+		return hybdec.ApplyHandleSignature(&ret, verifyResult, vaultKeys)
+	}()
+	if err != nil {
+		out.Close()
+		os.Remove(written)
+		return "", ret, err
+	}
+
+	pgpformat.RestoreModTime(written, meta)
+	return written, ret, nil
+}
+
+// strippedName is the name of an encrypted file without its extension
+func strippedName(path string) string {
+	name := filepath.Base(path)
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".pgp", ".gpg", ".asc":
+		return strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	return name
+}
+
+// verifyFile verifies a signed or cleartext signed message with the vault
+// keys, nothing is written
+func verifyFile(path string) (any, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	data := string(raw)
+	if !bytes.HasPrefix(bytes.TrimSpace(raw), []byte("-----BEGIN PGP")) {
+		data = base64.StdEncoding.EncodeToString(raw)
+	}
+
+	ud := &unifieddec.UnifiedDec{}
+	inspection, err := ud.Inspect(data)
+	if err != nil {
+		return nil, err
+	}
+	if inspection.Kind != unifieddec.KindSigned && inspection.Kind != unifieddec.KindCleartextSigned {
+		return nil, fmt.Errorf("%w: %s is not a signed message", apperr.ErrInvalidInput, path)
+	}
+
+	result, err := ud.Decrypt(unifieddec.Request{Data: data, FolderName: filepath.Base(path)})
+	result.Data = ""
+	return result, err
+}
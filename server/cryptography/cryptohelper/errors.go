@@ -0,0 +1,50 @@
+package cryptohelper
+
+import (
+	"MindLockr/server/apperr"
+	"errors"
+	"fmt"
+	"strings"
+
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// UnlockPrivateKey parses an armored private key and unlocks it, telling a
+// malformed key apart from a wrong passphrase
+func UnlockPrivateKey(armored string, passphrase []byte) (*crypto.Key, error) {
+	key, err := crypto.NewKeyFromArmored(armored)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", apperr.ErrInvalidKey, err)
+	}
+	if !key.IsPrivate() {
+		return nil, fmt.Errorf("%w: not a private key", apperr.ErrInvalidKey)
+	}
+
+	locked, err := key.IsLocked()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", apperr.ErrInvalidKey, err)
+	}
+	if !locked {
+		return key, nil
+	}
+
+	unlocked, err := key.Unlock(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", apperr.ErrWrongPassphrase, err)
+	}
+	return unlocked, nil
+}
+
+// DecryptionError classifies an error returned by a gopenpgp decryption
+// handle
+func DecryptionError(err error) error {
+	switch {
+	case errors.Is(err, pgperrors.ErrKeyIncorrect):
+		return fmt.Errorf("%w: the message was not encrypted to this key", apperr.ErrKeyNotFound)
+	case strings.Contains(err.Error(), "wrong password"):
+		return fmt.Errorf("%w: %v", apperr.ErrWrongPassphrase, err)
+	default:
+		return fmt.Errorf("%w: %v", apperr.ErrCorruptMessage, err)
+	}
+}
@@ -0,0 +1,95 @@
+package cryptohelper
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// MergeKeys adds the user IDs, subkeys and signatures of fetched that stored
+// lacks to a copy of stored. Keyservers and correspondents strip our
+// certifications and unverified user IDs, so replacing the stored copy would
+// lose them
+func MergeKeys(stored, fetched *crypto.Key) (*crypto.Key, error) {
+	if stored.GetFingerprint() != fetched.GetFingerprint() {
+		return nil, fmt.Errorf("cannot merge two different keys")
+	}
+
+	merged, err := stored.Copy()
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy key: %v", err)
+	}
+	dst, src := merged.GetEntity(), fetched.GetEntity()
+
+	dst.Revocations = mergeSignatures(dst.Revocations, src.Revocations)
+	dst.DirectSignatures = mergeSignatures(dst.DirectSignatures, src.DirectSignatures)
+
+	for name, srcIdentity := range src.Identities {
+		dstIdentity, ok := dst.Identities[name]
+		if !ok {
+			identity := *srcIdentity
+			identity.Primary = dst
+			dst.Identities[name] = &identity
+			continue
+		}
+		dstIdentity.SelfCertifications = mergeSignatures(dstIdentity.SelfCertifications, srcIdentity.SelfCertifications)
+		dstIdentity.OtherCertifications = mergeSignatures(dstIdentity.OtherCertifications, srcIdentity.OtherCertifications)
+		dstIdentity.Revocations = mergeSignatures(dstIdentity.Revocations, srcIdentity.Revocations)
+	}
+
+	for _, srcSubkey := range src.Subkeys {
+		i := subkeyIndex(dst, srcSubkey.PublicKey.Fingerprint)
+		if i < 0 {
+			subkey := srcSubkey
+			subkey.Primary = dst
+			dst.Subkeys = append(dst.Subkeys, subkey)
+			continue
+		}
+		dst.Subkeys[i].Bindings = mergeSignatures(dst.Subkeys[i].Bindings, srcSubkey.Bindings)
+		dst.Subkeys[i].Revocations = mergeSignatures(dst.Subkeys[i].Revocations, srcSubkey.Revocations)
+	}
+
+	// serialize and parse again so the merged signatures are verified
+	var buf bytes.Buffer
+	if err := dst.Serialize(&buf); err != nil {
+		return nil, fmt.Errorf("failed to serialize merged key: %v", err)
+	}
+	return crypto.NewKeyFromReader(&buf)
+}
+
+func subkeyIndex(entity *openpgp.Entity, fingerprint []byte) int {
+	for i, subkey := range entity.Subkeys {
+		if bytes.Equal(subkey.PublicKey.Fingerprint, fingerprint) {
+			return i
+		}
+	}
+	return -1
+}
+
+// mergeSignatures appends the signatures of src that are not in dst
+func mergeSignatures(dst, src []*packet.VerifiableSignature) []*packet.VerifiableSignature {
+	seen := make(map[string]bool, len(dst))
+	for _, sig := range dst {
+		seen[serializeSignature(sig.Packet)] = true
+	}
+	for _, sig := range src {
+		key := serializeSignature(sig.Packet)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		dst = append(dst, packet.NewVerifiableSig(sig.Packet))
+	}
+	return dst
+}
+
+func serializeSignature(sig *packet.Signature) string {
+	var buf bytes.Buffer
+	if err := sig.Serialize(&buf); err != nil {
+		return ""
+	}
+	return buf.String()
+}
@@ -1,6 +1,13 @@
 package hybdec
 
 import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/cryptohelper"
+	"MindLockr/server/cryptography/pgpformat"
+	"MindLockr/server/filesystem/audit"
+	"MindLockr/server/filesystem/integrity"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"MindLockr/server/filesystem/trust"
 	"fmt"
 
 	"github.com/ProtonMail/gopenpgp/v3/crypto"
@@ -18,8 +25,19 @@ type (
 	}
 
 	ReturnType struct {
-		Data  string `json:"data"`
-		Valid bool   `json:"valid"`
+		Data     string   `json:"data"`
+		Valid    bool     `json:"valid"`
+		Warnings []string `json:"warnings,omitempty"`
+		// SignerValidity is the web of trust validity of the sender's key
+		SignerValidity string `json:"signerValidity,omitempty"`
+		// Tofu reports for every email of the sender's key whether it
+		// matches the key pinned for that address
+		Tofu []trust.TofuResult `json:"tofu,omitempty"`
+		// Verification details the signature, nil when it was not checked
+		Verification *Verification `json:"verification,omitempty"`
+		// Literal is the filename and modification time the message
+		// carries, nil when it has none
+		Literal *pgpformat.Metadata `json:"literal,omitempty"`
 	}
 )
 
@@ -33,14 +51,17 @@ type (
 func (hd *HybDec) DecryptAndValidate(req RequestData) (ReturnType, error) {
 	pgp := crypto.PGP()
 
+	// warn when a public key in the vault was swapped since it was sealed
+	warnings := integrity.Warnings()
+
 	sendersPubKey, err := crypto.NewKeyFromArmored(req.PubKey)
 	if err != nil {
-		return ReturnType{}, fmt.Errorf("failed to get the pub key from armored in hyb en: %s", err)
+		return ReturnType{}, fmt.Errorf("failed to get the pub key from armored in hyb en: %w: %v", apperr.ErrInvalidKey, err)
 	}
 
-	recievers, err := crypto.NewPrivateKeyFromArmored(req.PrivKey, []byte(req.PrivKeyPassphrase))
+	recievers, err := cryptohelper.UnlockPrivateKey(req.PrivKey, []byte(req.PrivKeyPassphrase))
 	if err != nil {
-		return ReturnType{}, fmt.Errorf("failed to get the priv key from armored in hyb en: %s", err)
+		return ReturnType{}, fmt.Errorf("failed to get the priv key from armored in hyb en: %w", err)
 	}
 
 	decHandle, err := pgp.Decryption().
@@ -48,67 +69,91 @@ func (hd *HybDec) DecryptAndValidate(req RequestData) (ReturnType, error) {
 		VerificationKey(sendersPubKey).
 		New()
 	if err != nil {
-		return ReturnType{}, fmt.Errorf("failed to create decryption handle: %s", err)
+		return ReturnType{}, fmt.Errorf("failed to create decryption handle: %w", err)
 	}
 	defer decHandle.ClearPrivateParams()
 
 	decrypted, err := decHandle.Decrypt([]byte(req.PgpMessage), crypto.Armor)
+	audit.Record(audit.OpDecrypt, req.FolderName, recievers.GetFingerprint(), err)
 	if err != nil {
-		return ReturnType{}, fmt.Errorf("failed to decrypt message: %s", err)
+		return ReturnType{}, fmt.Errorf("failed to decrypt message: %w", cryptohelper.DecryptionError(err))
 	}
 
-	if sigErr := decrypted.SignatureError(); sigErr != nil {
-		return ReturnType{
-			Data:  string(decrypted.Bytes()),
-			Valid: false,
-		}, fmt.Errorf("signature verification failed: %s", sigErr)
+	verification := NewVerification(&decrypted.VerifyResult, knownVaultKeys())
+	ret := ReturnType{
+		Data:         string(decrypted.Bytes()),
+		Valid:        verification.Valid,
+		Warnings:     warnings,
+		Verification: &verification,
+		Literal:      pgpformat.MetadataOf(decrypted.Metadata()),
 	}
 
-	return ReturnType{
-		Data:  string(decrypted.Bytes()),
-		Valid: true,
-	}, nil
+	var failure error
+	if verification.Valid {
+		failure = checkSigner(&ret, sendersPubKey)
+	}
+	return ret, enforcePolicy(&ret, failure)
 }
 
 func (hd *HybDec) Decrypt(req RequestData) (ReturnType, error) {
 	pgp := crypto.PGP()
 
-	recievers, err := crypto.NewPrivateKeyFromArmored(req.PrivKey, []byte(req.PrivKeyPassphrase))
+	warnings := integrity.Warnings()
+
+	recievers, err := cryptohelper.UnlockPrivateKey(req.PrivKey, []byte(req.PrivKeyPassphrase))
+	if err != nil {
+		return ReturnType{}, fmt.Errorf("failed to get the priv key from armored in hyb en: %w", err)
+	}
+
+	// no sender key is given, any key in the vault may have signed it
+	vaultKeys := knownVaultKeys()
+	verificationKeys, err := pgpfs.VerificationKeyRing(vaultKeys)
 	if err != nil {
-		fmt.Println("Error loading receiver's private key:", err)
-		return ReturnType{}, fmt.Errorf("failed to get the priv key from armored in hyb en: %s", err)
+		return ReturnType{}, err
 	}
 
 	decHandle, err := pgp.Decryption().
 		DecryptionKey(recievers).
+		VerificationKeys(verificationKeys).
 		New()
 	if err != nil {
-		return ReturnType{}, fmt.Errorf("failed to create decryption handle: %s", err)
+		return ReturnType{}, fmt.Errorf("failed to create decryption handle: %w", err)
 	}
 	defer decHandle.ClearPrivateParams()
 
 	decrypted, err := decHandle.Decrypt([]byte(req.PgpMessage), crypto.Armor)
+	audit.Record(audit.OpDecrypt, req.FolderName, recievers.GetFingerprint(), err)
 	if err != nil {
-		fmt.Println("Error decrypting message:", err)
-		return ReturnType{}, fmt.Errorf("failed to decrypt message: %s", err)
+		return ReturnType{}, fmt.Errorf("failed to decrypt message: %w", cryptohelper.DecryptionError(err))
 	}
 
-	return ReturnType{
-		Data: string(decrypted.Bytes()),
-	}, nil
+	verification := NewVerification(&decrypted.VerifyResult, vaultKeys)
+	ret := ReturnType{
+		Data:         string(decrypted.Bytes()),
+		Valid:        verification.Valid,
+		Warnings:     warnings,
+		Verification: &verification,
+		Literal:      pgpformat.MetadataOf(decrypted.Metadata()),
+	}
+
+	var failure error
+	if verification.Valid {
+		failure = checkSigner(&ret, decrypted.SignedByKey())
+	}
+	return ret, enforcePolicy(&ret, failure)
 }
 
-func (hd *HybDec) ValidateSignature(req RequestData) (bool, error) {
+func (hd *HybDec) ValidateSignature(req RequestData) (Verification, error) {
 	pgp := crypto.PGP()
 
 	sendersPubKey, err := crypto.NewKeyFromArmored(req.PubKey)
 	if err != nil {
-		return false, fmt.Errorf("failed to load sender's public key: %s", err)
+		return Verification{}, fmt.Errorf("failed to load sender's public key: %w: %v", apperr.ErrInvalidKey, err)
 	}
 
-	recieversPrivKey, err := crypto.NewPrivateKeyFromArmored(req.PrivKey, []byte(req.PrivKeyPassphrase))
+	recieversPrivKey, err := cryptohelper.UnlockPrivateKey(req.PrivKey, []byte(req.PrivKeyPassphrase))
 	if err != nil {
-		return false, fmt.Errorf("failed to load receiver's private key: %s", err)
+		return Verification{}, fmt.Errorf("failed to load receiver's private key: %w", err)
 	}
 
 	decHandle, err := pgp.Decryption().
@@ -116,20 +161,31 @@ func (hd *HybDec) ValidateSignature(req RequestData) (bool, error) {
 		VerificationKey(sendersPubKey).
 		New()
 	if err != nil {
-		return false, fmt.Errorf("failed to create decryption handle: %s", err)
+		return Verification{}, fmt.Errorf("failed to create decryption handle: %w", err)
 	}
 	defer decHandle.ClearPrivateParams()
 
 	decrypted, err := decHandle.Decrypt([]byte(req.PgpMessage), crypto.Armor)
 	if err != nil {
-		return false, fmt.Errorf("failed to decrypt: %s", err)
+		audit.Record(audit.OpVerify, req.FolderName, sendersPubKey.GetFingerprint(), err)
+		return Verification{}, fmt.Errorf("failed to decrypt: %w", cryptohelper.DecryptionError(err))
 	}
 
-	if sigErr := decrypted.SignatureError(); sigErr != nil {
-		return false, fmt.Errorf("signature verification failed: %s", sigErr)
+	verification := NewVerification(&decrypted.VerifyResult, knownVaultKeys())
+	ret := ReturnType{Valid: verification.Valid, Verification: &verification}
+	var failure error
+	if verification.Valid {
+		failure = checkSigner(&ret, sendersPubKey)
 	}
-
-	fmt.Println("signature is valid")
-
-	return true, nil
+	err = enforcePolicy(&ret, failure)
+	if err == nil && !ret.Valid {
+		// the result is all there is to return, so an invalid signature
+		// fails whatever the policy
+		err = failure
+		if err == nil {
+			err = fmt.Errorf("%w: %s", apperr.ErrSignatureInvalid, verification.Message)
+		}
+	}
+	audit.Record(audit.OpVerify, req.FolderName, sendersPubKey.GetFingerprint(), err)
+	return verification, err
 }
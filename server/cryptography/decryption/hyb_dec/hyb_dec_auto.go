@@ -0,0 +1,160 @@
+package hybdec
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/cryptohelper"
+	"MindLockr/server/cryptography/pgpformat"
+	"MindLockr/server/cryptography/session"
+	"MindLockr/server/filesystem/audit"
+	"MindLockr/server/filesystem/integrity"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+type (
+	AutoRequest struct {
+		PgpMessage string `json:"data"`
+		// Passphrase unlocks the matching private key, it may be empty
+		// when the matching key is the unlocked session key
+		Passphrase string `json:"passphrase,omitempty"`
+		FolderName string `json:"folderName,omitempty"`
+	}
+
+	UsedKey struct {
+		KeyName     string `json:"keyName,omitempty"`
+		Fingerprint string `json:"fingerprint"`
+		KeyID       string `json:"keyId"`
+		FromSession bool   `json:"fromSession,omitempty"`
+	}
+
+	AutoReturnType struct {
+		ReturnType
+		Signed bool `json:"signed"`
+		// Recipient is the vault key that decrypted the message, nil when
+		// the message was not encrypted to a key
+		Recipient *UsedKey `json:"recipient,omitempty"`
+		// Signer is the vault key that made the signature, nil when the
+		// message is unsigned or signed by a key that is not in the vault
+		Signer *UsedKey `json:"signer,omitempty"`
+		// UnknownSignerKeyID is set when the signing key is not in the vault
+		UnknownSignerKeyID string `json:"unknownSignerKeyId,omitempty"`
+	}
+)
+
+// DecryptAuto decrypts a message with whichever private key in pgp-keys/ it
+// was encrypted to and verifies it with any public key in the vault
+func (hd *HybDec) DecryptAuto(req AutoRequest) (AutoReturnType, error) {
+	msg, err := crypto.NewPGPMessageFromArmored(req.PgpMessage)
+	if err != nil {
+		return AutoReturnType{}, fmt.Errorf("failed to load the pgp msg from armor: %w: %v", apperr.ErrCorruptMessage, err)
+	}
+
+	return DecryptMessage(msg, req.Passphrase, req.FolderName)
+}
+
+// DecryptMessage decrypts an armored or binary message with the vault keys,
+// see DecryptAuto
+func DecryptMessage(msg *crypto.PGPMessage, passphrase, folderName string) (AutoReturnType, error) {
+	keyIDs, ok := msg.EncryptionKeyIDs()
+	if !ok || len(keyIDs) == 0 {
+		return AutoReturnType{}, fmt.Errorf("%w: the message is not encrypted to a public key", apperr.ErrInvalidInput)
+	}
+
+	vaultKeys, err := pgpfs.VaultKeys()
+	if err != nil {
+		return AutoReturnType{}, err
+	}
+
+	verificationKeys, err := pgpfs.VerificationKeyRing(vaultKeys)
+	if err != nil {
+		return AutoReturnType{}, err
+	}
+
+	candidates, matchedIDs := recipientCandidates(vaultKeys, keyIDs)
+	if len(candidates) == 0 {
+		hexIDs, _ := msg.HexEncryptionKeyIDs()
+		return AutoReturnType{}, fmt.Errorf("%w: none of your keys can decrypt this message, it was encrypted to %s", apperr.ErrKeyNotFound, strings.Join(hexIDs, ", "))
+	}
+
+	var errs []error
+	for i, candidate := range candidates {
+		ret, err := decryptWith(candidate, matchedIDs[i], passphrase, folderName, msg, verificationKeys, vaultKeys)
+		if err == nil || ret.Recipient != nil {
+			return ret, err
+		}
+		errs = append(errs, err)
+	}
+	return AutoReturnType{}, errors.Join(errs...)
+}
+
+// recipientCandidates returns the vault keys with a private half that match
+// one of the recipient key IDs. A wildcard (0) recipient matches every key
+func recipientCandidates(vaultKeys []pgpfs.VaultKey, keyIDs []uint64) ([]pgpfs.VaultKey, []uint64) {
+	var candidates []pgpfs.VaultKey
+	var matched []uint64
+	for _, k := range vaultKeys {
+		if !k.HasPrivate {
+			continue
+		}
+		for _, id := range keyIDs {
+			if id == 0 || k.HasKeyID(id) {
+				candidates = append(candidates, k)
+				matched = append(matched, id)
+				break
+			}
+		}
+	}
+	return candidates, matched
+}
+
+// decryptWith decrypts with one candidate. The recipient of the result is
+// only set once the message was decrypted, errors before that let the
+// caller try the next candidate
+func decryptWith(candidate pgpfs.VaultKey, keyID uint64, passphrase, folderName string, msg *crypto.PGPMessage, verificationKeys *crypto.KeyRing, vaultKeys []pgpfs.VaultKey) (AutoReturnType, error) {
+	recipient := UsedKey{
+		KeyName:     candidate.Name,
+		Fingerprint: candidate.PublicKey.GetFingerprint(),
+		KeyID:       fmt.Sprintf("%016x", keyID),
+	}
+
+	var privKey *crypto.Key
+	var err error
+	if session.Fingerprint() == recipient.Fingerprint {
+		privKey, err = session.Current()
+		recipient.FromSession = true
+	} else {
+		privKey, err = candidate.UnlockPrivateKey(passphrase)
+	}
+	if err != nil {
+		return AutoReturnType{}, err
+	}
+	defer privKey.ClearPrivateParams()
+
+	decHandle, err := crypto.PGP().Decryption().
+		DecryptionKey(privKey).
+		VerificationKeys(verificationKeys).
+		New()
+	if err != nil {
+		return AutoReturnType{}, fmt.Errorf("failed to create decryption handle: %s", err)
+	}
+
+	decrypted, err := decHandle.Decrypt(msg.Bytes(), crypto.Bytes)
+	audit.Record(audit.OpDecrypt, folderName, recipient.Fingerprint, err)
+	if err != nil {
+		return AutoReturnType{}, fmt.Errorf("failed to decrypt message with %s: %w", candidate.Name, cryptohelper.DecryptionError(err))
+	}
+
+	ret := AutoReturnType{
+		ReturnType: ReturnType{
+			Data:     string(decrypted.Bytes()),
+			Warnings: integrity.Warnings(),
+			Literal:  pgpformat.MetadataOf(decrypted.Metadata()),
+		},
+		Recipient: &recipient,
+	}
+	return ret, ApplySignature(&ret, &decrypted.VerifyResult, vaultKeys)
+}
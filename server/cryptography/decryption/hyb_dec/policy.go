@@ -0,0 +1,174 @@
+package hybdec
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/filesystem"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const verificationPolicyFileName = "verification-policy.json"
+
+// what happens to the plaintext when its signature does not verify
+const (
+	// PolicyStrict withholds the plaintext on any failure
+	PolicyStrict = "strict"
+	// PolicyWarn returns the plaintext with a warning
+	PolicyWarn = "warn"
+	// PolicyIgnore returns the plaintext and reports the result only
+	PolicyIgnore = "ignore"
+)
+
+// FailureNotAllowed is the failure of a valid signature made by a key that
+// is not in the allowed signers of the policy
+const FailureNotAllowed = "signer-not-allowed"
+
+type VerificationPolicy struct {
+	Mode string `json:"mode"`
+	// AllowedSigners are the fingerprints of the keys whose signatures are
+	// accepted, empty accepts any key
+	AllowedSigners []string `json:"allowedSigners,omitempty"`
+}
+
+var (
+	verificationPolicyMu     sync.Mutex
+	verificationPolicyLoaded bool
+	verificationPolicy       = VerificationPolicy{Mode: PolicyWarn}
+)
+
+// GetVerificationPolicy returns the policy applied when decrypting
+func (hd *HybDec) GetVerificationPolicy() VerificationPolicy {
+	return CurrentVerificationPolicy()
+}
+
+// SetVerificationPolicy changes and persists the policy applied when
+// decrypting
+func (hd *HybDec) SetVerificationPolicy(newPolicy VerificationPolicy) error {
+	switch newPolicy.Mode {
+	case PolicyStrict, PolicyWarn, PolicyIgnore:
+	default:
+		return fmt.Errorf("%w: unknown verification policy: %q", apperr.ErrInvalidInput, newPolicy.Mode)
+	}
+
+	allowed := make([]string, 0, len(newPolicy.AllowedSigners))
+	for _, fingerprint := range newPolicy.AllowedSigners {
+		fingerprint = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(fingerprint), " ", ""))
+		if fingerprint != "" {
+			allowed = append(allowed, fingerprint)
+		}
+	}
+	newPolicy.AllowedSigners = allowed
+
+	verificationPolicyMu.Lock()
+	defer verificationPolicyMu.Unlock()
+
+	dir, err := filesystem.ConfigDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+
+	content, err := json.MarshalIndent(newPolicy, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode verification policy: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, verificationPolicyFileName), content, 0600); err != nil {
+		return fmt.Errorf("failed to write verification policy: %v", err)
+	}
+
+	verificationPolicy = newPolicy
+	verificationPolicyLoaded = true
+	return nil
+}
+
+// CurrentVerificationPolicy returns the persisted policy, warn by default
+func CurrentVerificationPolicy() VerificationPolicy {
+	verificationPolicyMu.Lock()
+	defer verificationPolicyMu.Unlock()
+
+	if verificationPolicyLoaded {
+		return verificationPolicy
+	}
+	verificationPolicyLoaded = true
+
+	dir, err := filesystem.ConfigDir()
+	if err != nil {
+		return verificationPolicy
+	}
+	content, err := os.ReadFile(filepath.Join(dir, verificationPolicyFileName))
+	if err != nil {
+		return verificationPolicy
+	}
+
+	var persisted VerificationPolicy
+	if err := json.Unmarshal(content, &persisted); err == nil && persisted.Mode != "" {
+		verificationPolicy = persisted
+	}
+	return verificationPolicy
+}
+
+// checkAllowed marks a valid signature as failed when its signer is not in
+// the allowed signers
+func (p VerificationPolicy) checkAllowed(v *Verification) {
+	if !v.Valid || len(p.AllowedSigners) == 0 {
+		return
+	}
+	signer := strings.ToUpper(v.SignerFingerprint)
+	for _, allowed := range p.AllowedSigners {
+		if allowed == signer {
+			return
+		}
+	}
+	v.Valid = false
+	v.Failure = FailureNotAllowed
+	v.Message = fmt.Sprintf("the signer %s is not an allowed signer", signer)
+}
+
+// enforcePolicy applies the verification policy to a decrypted message.
+// failure is the error of a check made after the signature verified, like a
+// rejected key pin. The plaintext is withheld and an error returned only in
+// strict mode
+func enforcePolicy(ret *ReturnType, failure error) error {
+	policy := CurrentVerificationPolicy()
+
+	v := ret.Verification
+	if v == nil {
+		v = &Verification{Failure: FailureNoSignature, Message: "the message is not signed"}
+		ret.Verification = v
+	}
+	policy.checkAllowed(v)
+	return policy.enforce(ret, failure)
+}
+
+// enforce returns or withholds the plaintext of ret by the mode of the
+// policy, ret.Verification must be set
+func (p VerificationPolicy) enforce(ret *ReturnType, failure error) error {
+	v := ret.Verification
+	if !v.Valid {
+		ret.Valid = false
+	}
+
+	if failure == nil && v.Valid {
+		return nil
+	}
+	if failure == nil {
+		failure = fmt.Errorf("%w: %s", apperr.ErrSignatureInvalid, v.Message)
+	}
+
+	switch p.Mode {
+	case PolicyIgnore:
+		return nil
+	case PolicyWarn:
+		ret.Warnings = append(ret.Warnings, failure.Error())
+		return nil
+	default:
+		ret.Data = ""
+		return failure
+	}
+}
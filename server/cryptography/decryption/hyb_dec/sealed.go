@@ -0,0 +1,39 @@
+package hybdec
+
+import (
+	"MindLockr/server/cryptography/cryptohelper"
+	"MindLockr/server/cryptography/session"
+	"fmt"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// OpenSealed decrypts a message created by session.Seal. It has to be signed
+// by the session key, the verification policy decides whether a message
+// with a missing or bad signature is still returned and with which
+// warnings. The allowed signers do not apply, the vault signs its own data
+func OpenSealed(armored []byte) (plain []byte, warnings []string, err error) {
+	key, err := session.Current()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer key.ClearPrivateParams()
+
+	decHandle, err := crypto.PGP().Decryption().DecryptionKey(key).VerificationKey(key).New()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create decryption handle: %s", err)
+	}
+
+	decrypted, err := decHandle.Decrypt(armored, crypto.Armor)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt message: %w", cryptohelper.DecryptionError(err))
+	}
+
+	v := NewVerification(&decrypted.VerifyResult, nil)
+	ret := ReturnType{Valid: v.Valid, Verification: &v}
+	if err := CurrentVerificationPolicy().enforce(&ret, nil); err != nil {
+		clear(decrypted.Bytes())
+		return nil, nil, err
+	}
+	return decrypted.Bytes(), ret.Warnings, nil
+}
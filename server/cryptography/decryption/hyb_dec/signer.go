@@ -0,0 +1,101 @@
+package hybdec
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/filesystem"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"MindLockr/server/filesystem/trust"
+	"fmt"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// checkSigner adds the web of trust validity and the key pins of a signer
+// whose signature was valid to ret. It fails when the signer's key changed
+// or was rejected for one of its addresses, the policy decides whether the
+// plaintext is still returned
+func checkSigner(ret *ReturnType, signer *crypto.Key) error {
+	ret.SignerValidity = trust.Of(signer.GetFingerprint())
+	if ret.SignerValidity != trust.ValidityFull && ret.SignerValidity != trust.ValidityUltimate {
+		ret.Warnings = append(ret.Warnings, fmt.Sprintf("the signature is valid, but the sender's key is not certified (validity: %s)", ret.SignerValidity))
+	}
+
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+	if folderPath == "" {
+		return nil
+	}
+
+	// only keys that produced a valid signature get pinned
+	tofu, err := trust.Observe(folderPath, signer)
+	if err != nil {
+		ret.Warnings = append(ret.Warnings, fmt.Sprintf("failed to check the key pins: %s", err))
+	}
+	ret.Tofu = tofu
+
+	for _, result := range tofu {
+		switch result.Status {
+		case trust.TofuConflict:
+			return keyChanged(ret, result, fmt.Sprintf("the sender's key changed for %s", result.Email))
+		case trust.TofuRejected:
+			return keyChanged(ret, result, fmt.Sprintf("the sender's key was rejected for %s", result.Email))
+		}
+	}
+	return nil
+}
+
+// keyChanged marks the signature of ret as failed because the signer's key
+// is not the one pinned for one of its addresses
+func keyChanged(ret *ReturnType, result trust.TofuResult, message string) error {
+	ret.Valid = false
+	ret.Warnings = append(ret.Warnings, result.Message)
+	if ret.Verification != nil {
+		ret.Verification.Valid = false
+		ret.Verification.Failure = FailureKeyChanged
+		ret.Verification.Message = message
+	}
+	return fmt.Errorf("%w: %s", apperr.ErrSignatureInvalid, message)
+}
+
+// ApplySignature fills the signature fields of ret from the result of a
+// decryption or verification against the vault keys and applies the
+// verification policy
+func ApplySignature(ret *AutoReturnType, result *crypto.VerifyResult, vaultKeys []pgpfs.VaultKey) error {
+	return applySignature(ret, result, vaultKeys, true)
+}
+
+// ApplySymmetricSignature is ApplySignature for a message decrypted with a
+// password. Only someone knowing the password could have written it, so a
+// missing signature is reported but does not fail a warn or ignore policy
+// without allowed signers. A signature that is there still has to verify
+func ApplySymmetricSignature(ret *AutoReturnType, result *crypto.VerifyResult, vaultKeys []pgpfs.VaultKey) error {
+	return applySignature(ret, result, vaultKeys, false)
+}
+
+func applySignature(ret *AutoReturnType, result *crypto.VerifyResult, vaultKeys []pgpfs.VaultKey, requireSignature bool) error {
+	v := NewVerification(result, vaultKeys)
+	ret.Verification = &v
+	ret.Signed = v.Failure != FailureNoSignature
+	if !ret.Signed && !requireSignature {
+		policy := CurrentVerificationPolicy()
+		if policy.Mode != PolicyStrict && len(policy.AllowedSigners) == 0 {
+			ret.Valid = false
+			return nil
+		}
+	}
+
+	var failure error
+	switch {
+	case v.Valid:
+		ret.Valid = true
+		ret.Signer = &UsedKey{
+			KeyName:     v.SignerKeyName,
+			Fingerprint: v.SignerFingerprint,
+			KeyID:       v.SignerKeyID,
+		}
+		failure = checkSigner(&ret.ReturnType, result.SignedByKey())
+	case v.Failure == FailureUnknownKey:
+		ret.UnknownSignerKeyID = v.SignerKeyID
+		v.Message = "the message is signed by a key that is not in your vault"
+	}
+	return enforcePolicy(&ret.ReturnType, failure)
+}
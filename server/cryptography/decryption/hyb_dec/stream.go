@@ -0,0 +1,132 @@
+package hybdec
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/session"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// DecryptionHandle reads the key packets at the start of a message and
+// returns a handle that decrypts it: with a vault key it was encrypted to,
+// unlocked with passphrase or the session, or with passphrase as the
+// message password. It is meant for messages too large to hold in memory,
+// the caller rewinds r and streams the message through the handle.
+// release clears a key unlocked here
+func DecryptionHandle(r io.Reader, passphrase string, vaultKeys []pgpfs.VaultKey) (handle crypto.PGPDecryption, recipient *UsedKey, release func(), err error) {
+	noop := func() {}
+
+	keyIDs, symmetric, err := keyPackets(r)
+	if err != nil {
+		return nil, nil, noop, err
+	}
+
+	verificationKeys, err := pgpfs.VerificationKeyRing(vaultKeys)
+	if err != nil {
+		return nil, nil, noop, err
+	}
+	builder := crypto.PGP().Decryption().VerificationKeys(verificationKeys)
+
+	var unlockErr error
+	for _, k := range vaultKeys {
+		if !k.HasPrivate || !matchesAny(k, keyIDs) {
+			continue
+		}
+		key, release, err := k.PrivateKey(passphrase)
+		if err != nil {
+			unlockErr = err
+			continue
+		}
+
+		decHandle, err := builder.DecryptionKey(key).New()
+		if err != nil {
+			release()
+			return nil, nil, noop, fmt.Errorf("failed to create decryption handle: %s", err)
+		}
+		recipient := &UsedKey{
+			KeyName:     k.Name,
+			Fingerprint: k.PublicKey.GetFingerprint(),
+			KeyID:       k.PublicKey.GetHexKeyID(),
+			FromSession: session.Fingerprint() == k.PublicKey.GetFingerprint(),
+		}
+		return decHandle, recipient, release, nil
+	}
+
+	switch {
+	case symmetric && passphrase == "":
+		return nil, nil, noop, fmt.Errorf("%w: this message is protected with a passphrase", apperr.ErrPassphraseRequired)
+	case symmetric:
+		decHandle, err := builder.Password([]byte(passphrase)).New()
+		if err != nil {
+			return nil, nil, noop, fmt.Errorf("failed to create decryption handle: %s", err)
+		}
+		return decHandle, nil, noop, nil
+	case unlockErr != nil:
+		return nil, nil, noop, unlockErr
+	default:
+		return nil, nil, noop, fmt.Errorf("%w: none of your keys can decrypt this message", apperr.ErrKeyNotFound)
+	}
+}
+
+// ApplyHandleSignature applies the signature of a message decrypted through
+// DecryptionHandle. ret.Recipient is the recipient the handle returned, a
+// message it decrypted with the password needs no signature
+func ApplyHandleSignature(ret *AutoReturnType, result *crypto.VerifyResult, vaultKeys []pgpfs.VaultKey) error {
+	if ret.Recipient == nil {
+		return ApplySymmetricSignature(ret, result, vaultKeys)
+	}
+	return ApplySignature(ret, result, vaultKeys)
+}
+
+const armorPrefix = "-----BEGIN PGP MESSAGE-----"
+
+// keyPackets reads the session key packets at the start of a binary or
+// armored message
+func keyPackets(r io.Reader) (keyIDs []uint64, symmetric bool, err error) {
+	buffered := bufio.NewReader(r)
+	if head, _ := buffered.Peek(len(armorPrefix)); string(head) == armorPrefix {
+		block, err := armor.Decode(buffered)
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: %v", apperr.ErrCorruptMessage, err)
+		}
+		r = block.Body
+	} else {
+		r = buffered
+	}
+
+	packets := packet.NewReader(r)
+	for {
+		p, err := packets.Next()
+		if err != nil {
+			return nil, false, fmt.Errorf("%w: not an encrypted message: %v", apperr.ErrCorruptMessage, err)
+		}
+		switch p := p.(type) {
+		case *packet.EncryptedKey:
+			keyIDs = append(keyIDs, p.KeyId)
+		case *packet.SymmetricKeyEncrypted:
+			symmetric = true
+		default:
+			if len(keyIDs) == 0 && !symmetric {
+				return nil, false, fmt.Errorf("%w: the message is not encrypted", apperr.ErrCorruptMessage)
+			}
+			return keyIDs, symmetric, nil
+		}
+	}
+}
+
+// matchesAny reports whether k is one of the recipients, a wildcard (0)
+// recipient matches every key
+func matchesAny(k pgpfs.VaultKey, keyIDs []uint64) bool {
+	for _, id := range keyIDs {
+		if id == 0 || k.HasKeyID(id) {
+			return true
+		}
+	}
+	return false
+}
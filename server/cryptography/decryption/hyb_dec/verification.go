@@ -0,0 +1,138 @@
+package hybdec
+
+import (
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	gocrypto "crypto"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// why a verification failed
+const (
+	FailureNoSignature   = "no-signature"
+	FailureUnknownKey    = "unknown-key"
+	FailureBadSignature  = "bad-signature"
+	FailureWeakAlgorithm = "weak-algorithm"
+	// FailureKeyChanged is a valid signature by a key that is not the one
+	// pinned for an address of the signer
+	FailureKeyChanged = "key-changed"
+)
+
+// hashes that no longer protect against forged signatures
+var weakHashes = map[gocrypto.Hash]bool{
+	gocrypto.MD5:       true,
+	gocrypto.SHA1:      true,
+	gocrypto.RIPEMD160: true,
+}
+
+type Verification struct {
+	Valid bool `json:"valid"`
+	// Failure is one of the Failure constants, empty when Valid
+	Failure           string    `json:"failure,omitempty"`
+	Message           string    `json:"message,omitempty"`
+	SignerKeyID       string    `json:"signerKeyId,omitempty"`
+	SignerFingerprint string    `json:"signerFingerprint,omitempty"`
+	SignerKeyName     string    `json:"signerKeyName,omitempty"`
+	CreatedAt         time.Time `json:"createdAt,omitempty"`
+	HashAlgorithm     string    `json:"hashAlgorithm,omitempty"`
+	// the state of the signer's key when the signature was made
+	KeyExpiredAtSigning bool `json:"keyExpiredAtSigning,omitempty"`
+	KeyRevokedAtSigning bool `json:"keyRevokedAtSigning,omitempty"`
+}
+
+// NewVerification describes the selected signature of result. vaultKeys is
+// used to name the signer and may be nil
+func NewVerification(result *crypto.VerifyResult, vaultKeys []pgpfs.VaultKey) Verification {
+	v := Verification{Valid: true}
+
+	sigErr := result.SignatureErrorExplicit()
+	if sigErr != nil {
+		v.Valid = false
+		v.Message = sigErr.Message
+		if sigErr.Cause != nil {
+			v.Message = fmt.Sprintf("%s: %s", sigErr.Message, sigErr.Cause)
+		}
+		switch sigErr.Status {
+		case constants.SIGNATURE_NOT_SIGNED:
+			v.Failure = FailureNoSignature
+			return v
+		case constants.SIGNATURE_NO_VERIFIER:
+			v.Failure = FailureUnknownKey
+		default:
+			v.Failure = FailureBadSignature
+		}
+	}
+
+	selected := selectedSignature(result)
+	if selected == nil || selected.Signature == nil {
+		return v
+	}
+	sig := selected.Signature
+
+	v.CreatedAt = sig.CreationTime
+	v.HashAlgorithm = sig.Hash.String()
+	if sig.IssuerKeyId != nil {
+		v.SignerKeyID = fmt.Sprintf("%016x", *sig.IssuerKeyId)
+	}
+	if weakHashes[sig.Hash] || (sigErr != nil && strings.Contains(sigErr.Message, "Insecure")) {
+		v.Valid = false
+		v.Failure = FailureWeakAlgorithm
+		if v.Message == "" {
+			v.Message = fmt.Sprintf("the signature uses the weak hash %s", v.HashAlgorithm)
+		}
+	}
+
+	signer := selected.SignedBy
+	if signer == nil && sig.IssuerKeyId != nil {
+		for _, k := range vaultKeys {
+			if k.HasKeyID(*sig.IssuerKeyId) {
+				signer = k.PublicKey
+			}
+		}
+	}
+	if signer == nil {
+		return v
+	}
+
+	v.SignerFingerprint = signer.GetFingerprint()
+	for _, k := range vaultKeys {
+		if k.PublicKey.GetFingerprint() == v.SignerFingerprint {
+			v.SignerKeyName = k.Name
+		}
+	}
+	v.KeyExpiredAtSigning = signer.IsExpired(sig.CreationTime.Unix())
+	v.KeyRevokedAtSigning = signer.IsRevoked(sig.CreationTime.Unix())
+	return v
+}
+
+// selectedSignature mirrors the selection of VerifyResult: the first valid
+// signature, else the last one made by a known key, else the last one
+func selectedSignature(result *crypto.VerifyResult) *crypto.VerifiedSignature {
+	var selected *crypto.VerifiedSignature
+	for _, sig := range result.Signatures {
+		if sig.SignedBy == nil {
+			continue
+		}
+		selected = sig
+		if sig.SignatureError == nil {
+			return sig
+		}
+	}
+	if selected == nil && len(result.Signatures) > 0 {
+		selected = result.Signatures[len(result.Signatures)-1]
+	}
+	return selected
+}
+
+// knownVaultKeys returns the vault keys, or nil when no vault is set up
+func knownVaultKeys() []pgpfs.VaultKey {
+	keys, err := pgpfs.VaultKeys()
+	if err != nil {
+		return nil
+	}
+	return keys
+}
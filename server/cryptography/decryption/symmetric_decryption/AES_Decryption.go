@@ -1,6 +1,10 @@
 package symmetricdecryption
 
 import (
+	"MindLockr/server/cryptography/cryptohelper"
+	hybdec "MindLockr/server/cryptography/decryption/hyb_dec"
+	"MindLockr/server/filesystem/audit"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
 	"fmt"
 
 	"github.com/ProtonMail/gopenpgp/v3/crypto"
@@ -13,17 +17,36 @@ type DataToDecrypt struct {
 
 type Cryptography struct{}
 
-func (c *Cryptography) DecryptAES(data DataToDecrypt) (string, error) {
+// DecryptAES decrypts a password protected message. A signature by a key in
+// the vault is checked against the verification policy, a message without
+// one is returned as it is
+func (c *Cryptography) DecryptAES(data DataToDecrypt) (hybdec.ReturnType, error) {
 	pgp := crypto.PGP()
 
-	decHandle, err := pgp.Decryption().Password([]byte(data.Passphrase)).New()
+	vaultKeys, _ := pgpfs.VaultKeys()
+	verificationKeys, err := pgpfs.VerificationKeyRing(vaultKeys)
 	if err != nil {
-		return "", fmt.Errorf("failed to create an encryption handle for aes encryption check parameters passed: %s", err)
+		return hybdec.ReturnType{}, err
+	}
+
+	decHandle, err := pgp.Decryption().
+		Password([]byte(data.Passphrase)).
+		VerificationKeys(verificationKeys).
+		New()
+	if err != nil {
+		return hybdec.ReturnType{}, fmt.Errorf("failed to create an encryption handle for aes encryption check parameters passed: %w", err)
 	}
 	decrypted, err := decHandle.Decrypt([]byte(data.EncryptedData), crypto.Armor)
+	audit.Record(audit.OpDecrypt, "symmetric", "", err)
 	if err != nil {
-		return "", fmt.Errorf("failed to decrypt the data: %s", err)
+		return hybdec.ReturnType{}, fmt.Errorf("failed to decrypt the data: %w", cryptohelper.DecryptionError(err))
 	}
 
-	return string(decrypted.Bytes()), nil
+	ret := hybdec.AutoReturnType{
+		ReturnType: hybdec.ReturnType{Data: string(decrypted.Bytes())},
+	}
+	if err := hybdec.ApplySymmetricSignature(&ret, &decrypted.VerifyResult, vaultKeys); err != nil {
+		return hybdec.ReturnType{}, err
+	}
+	return ret.ReturnType, nil
 }
@@ -0,0 +1,234 @@
+package unifieddec
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/session"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// what the input turned out to be
+const (
+	KindSymmetric       = "symmetric"
+	KindPublicKey       = "public-key"
+	KindSigned          = "signed"
+	KindCleartextSigned = "cleartext-signed"
+	KindLiteral         = "literal"
+)
+
+// what the caller has to provide before Decrypt can succeed
+const (
+	NeedNothing       = "nothing"
+	NeedPassphrase    = "passphrase"
+	NeedKeyPassphrase = "key-passphrase"
+	NeedMissingKey    = "missing-key"
+)
+
+const (
+	armorMessage   = "PGP MESSAGE"
+	armorSignature = "PGP SIGNATURE"
+	cleartextBegin = "-----BEGIN PGP SIGNED MESSAGE-----"
+	armorBegin     = "-----BEGIN PGP"
+)
+
+type Inspection struct {
+	Kind    string `json:"kind"`
+	Armored bool   `json:"armored"`
+	Needs   string `json:"needs"`
+	// PassphraseProtected is set when the message has a password packet,
+	// a message can be encrypted to both keys and a password
+	PassphraseProtected bool     `json:"passphraseProtected"`
+	RecipientKeyIDs     []string `json:"recipientKeyIds,omitempty"`
+	// RecipientKeys are the vault keys that can decrypt the message
+	RecipientKeys []string `json:"recipientKeys,omitempty"`
+	// SignerKeyIDs are only known up front for messages that are not
+	// encrypted, the signature of an encrypted message is inside it
+	SignerKeyIDs []string `json:"signerKeyIds,omitempty"`
+	SignerKeys   []string `json:"signerKeys,omitempty"`
+}
+
+// message is the parsed input
+type message struct {
+	inspection Inspection
+	raw        []byte
+	// cleartext is the full cleartext signed input
+	cleartext      []byte
+	recipientIDs   []uint64
+	recipientMatch []pgpfs.VaultKey
+	vaultKeys      []pgpfs.VaultKey
+}
+
+// Inspect tells what kind of OpenPGP input data is and what is needed to
+// decrypt or verify it. Binary messages are passed base64 encoded
+func (ud *UnifiedDec) Inspect(data string) (Inspection, error) {
+	msg, err := parseMessage(data)
+	if err != nil {
+		return Inspection{}, err
+	}
+	return msg.inspection, nil
+}
+
+func parseMessage(data string) (*message, error) {
+	// password protected and signed messages are read without a vault
+	vaultKeys, err := pgpfs.VaultKeys()
+	if errors.Is(err, apperr.ErrNoVaultFolder) {
+		vaultKeys = []pgpfs.VaultKey{}
+	} else if err != nil {
+		return nil, err
+	}
+	msg := &message{vaultKeys: vaultKeys}
+
+	trimmed := strings.TrimSpace(data)
+	switch {
+	case strings.HasPrefix(trimmed, cleartextBegin):
+		msg.cleartext = []byte(trimmed)
+		msg.inspection = Inspection{Kind: KindCleartextSigned, Armored: true, Needs: NeedNothing}
+		return msg, nil
+
+	case strings.HasPrefix(trimmed, armorBegin):
+		block, err := armor.Decode(strings.NewReader(trimmed))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the armored input: %w: %v", apperr.ErrCorruptMessage, err)
+		}
+		if block.Type == armorSignature {
+			return nil, fmt.Errorf("%w: this is a detached signature, verify it together with the signed file", apperr.ErrInvalidInput)
+		}
+		if block.Type != armorMessage {
+			return nil, fmt.Errorf("%w: expected a %s, got a %s", apperr.ErrInvalidInput, armorMessage, block.Type)
+		}
+		msg.raw, err = io.ReadAll(block.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the armored input: %w: %v", apperr.ErrCorruptMessage, err)
+		}
+		msg.inspection.Armored = true
+
+	default:
+		msg.raw, err = base64.StdEncoding.DecodeString(strings.Join(strings.Fields(trimmed), ""))
+		if err != nil {
+			return nil, fmt.Errorf("%w: the input is neither an armored nor a base64 encoded binary OpenPGP message", apperr.ErrCorruptMessage)
+		}
+	}
+
+	if err := msg.scanPackets(); err != nil {
+		return nil, err
+	}
+	msg.resolveNeeds()
+	return msg, nil
+}
+
+// scanPackets walks the packets up to the encrypted data or the literal
+// data, descending into compressed packets
+func (msg *message) scanPackets() error {
+	ins := &msg.inspection
+	var encrypted, signed bool
+
+	packets := packet.NewReader(bytes.NewReader(msg.raw))
+scan:
+	for {
+		p, err := packets.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", apperr.ErrCorruptMessage, err)
+		}
+
+		switch p := p.(type) {
+		case *packet.EncryptedKey:
+			msg.recipientIDs = append(msg.recipientIDs, p.KeyId)
+			ins.RecipientKeyIDs = append(ins.RecipientKeyIDs, fmt.Sprintf("%016x", p.KeyId))
+		case *packet.SymmetricKeyEncrypted:
+			ins.PassphraseProtected = true
+		case *packet.SymmetricallyEncrypted, *packet.AEADEncrypted:
+			encrypted = true
+			break scan
+		case *packet.Compressed:
+			if err := packets.Push(p.Body); err != nil {
+				return fmt.Errorf("failed to read the compressed data: %w: %v", apperr.ErrCorruptMessage, err)
+			}
+		case *packet.OnePassSignature:
+			signed = true
+			msg.addSigner(p.KeyId)
+		case *packet.Signature:
+			signed = true
+			if p.IssuerKeyId != nil {
+				msg.addSigner(*p.IssuerKeyId)
+			}
+		case *packet.LiteralData:
+			break scan
+		}
+	}
+
+	switch {
+	case len(msg.recipientIDs) > 0:
+		ins.Kind = KindPublicKey
+	case ins.PassphraseProtected:
+		ins.Kind = KindSymmetric
+	case encrypted:
+		return fmt.Errorf("%w: the message is encrypted but has no key or password packets", apperr.ErrCorruptMessage)
+	case signed:
+		ins.Kind = KindSigned
+	default:
+		ins.Kind = KindLiteral
+	}
+	return nil
+}
+
+func (msg *message) addSigner(keyID uint64) {
+	ins := &msg.inspection
+	ins.SignerKeyIDs = append(ins.SignerKeyIDs, fmt.Sprintf("%016x", keyID))
+	for _, k := range msg.vaultKeys {
+		if k.HasKeyID(keyID) {
+			ins.SignerKeys = append(ins.SignerKeys, k.Name)
+		}
+	}
+}
+
+// resolveNeeds matches the recipients against the vault keys
+func (msg *message) resolveNeeds() {
+	ins := &msg.inspection
+	switch ins.Kind {
+	case KindSymmetric:
+		ins.Needs = NeedPassphrase
+		return
+	case KindPublicKey:
+	default:
+		ins.Needs = NeedNothing
+		return
+	}
+
+	for _, k := range msg.vaultKeys {
+		if !k.HasPrivate {
+			continue
+		}
+		for _, id := range msg.recipientIDs {
+			if id == 0 || k.HasKeyID(id) {
+				msg.recipientMatch = append(msg.recipientMatch, k)
+				ins.RecipientKeys = append(ins.RecipientKeys, k.Name)
+				break
+			}
+		}
+	}
+
+	switch {
+	case len(msg.recipientMatch) == 0 && ins.PassphraseProtected:
+		ins.Needs = NeedPassphrase
+	case len(msg.recipientMatch) == 0:
+		ins.Needs = NeedMissingKey
+	default:
+		ins.Needs = NeedKeyPassphrase
+		for _, k := range msg.recipientMatch {
+			if k.PublicKey.GetFingerprint() == session.Fingerprint() {
+				ins.Needs = NeedNothing
+			}
+		}
+	}
+}
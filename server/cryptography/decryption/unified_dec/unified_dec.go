@@ -0,0 +1,189 @@
+package unifieddec
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/cryptohelper"
+	hybdec "MindLockr/server/cryptography/decryption/hyb_dec"
+	"MindLockr/server/cryptography/pgpformat"
+	"MindLockr/server/filesystem/audit"
+	"MindLockr/server/filesystem/integrity"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"fmt"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+type (
+	UnifiedDec struct{}
+
+	Request struct {
+		// Data is an armored message, a cleartext signed message or a
+		// base64 encoded binary message
+		Data string `json:"data"`
+		// Passphrase is the message password for symmetric messages and
+		// the key passphrase for public key messages
+		Passphrase string `json:"passphrase,omitempty"`
+		FolderName string `json:"folderName,omitempty"`
+	}
+
+	Result struct {
+		hybdec.AutoReturnType
+		Kind string `json:"kind"`
+	}
+
+	FileRequest struct {
+		Request
+		// OutputDir is the folder the decrypted file is written to
+		OutputDir string `json:"outputDir"`
+	}
+
+	FileResult struct {
+		Result
+		// Path is the file written, named after the sanitized filename of
+		// the message
+		Path string `json:"path"`
+	}
+)
+
+// Decrypt inspects data and decrypts or verifies it with the vault keys or
+// the passphrase, whichever the message needs
+func (ud *UnifiedDec) Decrypt(req Request) (Result, error) {
+	msg, err := parseMessage(req.Data)
+	if err != nil {
+		return Result{}, err
+	}
+
+	kind := msg.inspection.Kind
+	var ret hybdec.AutoReturnType
+	switch {
+	case kind == KindPublicKey && len(msg.recipientMatch) > 0:
+		ret, err = hybdec.DecryptMessage(crypto.NewPGPMessage(msg.raw), req.Passphrase, req.FolderName)
+	case kind == KindPublicKey && !msg.inspection.PassphraseProtected:
+		return Result{Kind: kind}, fmt.Errorf("%w: none of your keys can decrypt this message, it was encrypted to %v", apperr.ErrKeyNotFound, msg.inspection.RecipientKeyIDs)
+	case kind == KindPublicKey, kind == KindSymmetric:
+		kind = KindSymmetric
+		ret, err = msg.decryptSymmetric(req)
+	case kind == KindCleartextSigned:
+		ret, err = msg.verifyCleartext(req)
+	default:
+		ret, err = msg.verifyInline(req)
+	}
+	return Result{AutoReturnType: ret, Kind: kind}, err
+}
+
+// DecryptToFile decrypts like Decrypt and writes the plaintext into a file
+// in OutputDir instead of returning it. The filename of the message is
+// sanitized and never replaces an existing file
+func (ud *UnifiedDec) DecryptToFile(req FileRequest) (FileResult, error) {
+	if req.OutputDir == "" {
+		return FileResult{}, fmt.Errorf("%w: choose the folder to write the file to", apperr.ErrInvalidInput)
+	}
+
+	result, err := ud.Decrypt(req.Request)
+	if err != nil {
+		return FileResult{Result: result}, err
+	}
+
+	path, err := pgpformat.WriteFile(req.OutputDir, result.Literal, []byte(result.Data))
+	if err != nil {
+		return FileResult{Result: result}, err
+	}
+	result.Data = ""
+	return FileResult{Result: result, Path: path}, nil
+}
+
+func (msg *message) decryptSymmetric(req Request) (hybdec.AutoReturnType, error) {
+	if req.Passphrase == "" {
+		return hybdec.AutoReturnType{}, fmt.Errorf("%w: this message is protected with a passphrase", apperr.ErrPassphraseRequired)
+	}
+
+	verificationKeys, err := pgpfs.VerificationKeyRing(msg.vaultKeys)
+	if err != nil {
+		return hybdec.AutoReturnType{}, err
+	}
+
+	decHandle, err := crypto.PGP().Decryption().
+		Password([]byte(req.Passphrase)).
+		VerificationKeys(verificationKeys).
+		New()
+	if err != nil {
+		return hybdec.AutoReturnType{}, fmt.Errorf("failed to create decryption handle: %s", err)
+	}
+
+	decrypted, err := decHandle.Decrypt(msg.raw, crypto.Bytes)
+	audit.Record(audit.OpDecrypt, "symmetric", "", err)
+	if err != nil {
+		return hybdec.AutoReturnType{}, fmt.Errorf("failed to decrypt the data: %w", cryptohelper.DecryptionError(err))
+	}
+
+	ret := newResult(decrypted.Bytes(), decrypted.Metadata())
+	return ret, hybdec.ApplySymmetricSignature(&ret, &decrypted.VerifyResult, msg.vaultKeys)
+}
+
+func (msg *message) verifyInline(req Request) (hybdec.AutoReturnType, error) {
+	verifyHandle, err := msg.verifyHandle()
+	if err != nil {
+		return hybdec.AutoReturnType{}, err
+	}
+
+	verified, err := verifyHandle.VerifyInline(msg.raw, crypto.Bytes)
+	if err != nil {
+		return hybdec.AutoReturnType{}, fmt.Errorf("failed to read the signed message: %w: %v", apperr.ErrCorruptMessage, err)
+	}
+
+	ret := newResult(verified.Bytes(), verified.Metadata())
+	err = hybdec.ApplySignature(&ret, &verified.VerifyResult, msg.vaultKeys)
+	msg.auditVerify(req, ret, err)
+	return ret, err
+}
+
+func (msg *message) verifyCleartext(req Request) (hybdec.AutoReturnType, error) {
+	verifyHandle, err := msg.verifyHandle()
+	if err != nil {
+		return hybdec.AutoReturnType{}, err
+	}
+
+	verified, err := verifyHandle.VerifyCleartext(msg.cleartext)
+	if err != nil {
+		return hybdec.AutoReturnType{}, fmt.Errorf("failed to read the cleartext signed message: %w: %v", apperr.ErrCorruptMessage, err)
+	}
+
+	ret := newResult(verified.Cleartext(), nil)
+	err = hybdec.ApplySignature(&ret, &verified.VerifyResult, msg.vaultKeys)
+	msg.auditVerify(req, ret, err)
+	return ret, err
+}
+
+func (msg *message) verifyHandle() (crypto.PGPVerify, error) {
+	verificationKeys, err := pgpfs.VerificationKeyRing(msg.vaultKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	verifyHandle, err := crypto.PGP().Verify().VerificationKeys(verificationKeys).New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create verification handle: %s", err)
+	}
+	return verifyHandle, nil
+}
+
+func (msg *message) auditVerify(req Request, ret hybdec.AutoReturnType, err error) {
+	if !ret.Signed {
+		return
+	}
+	signer := ret.UnknownSignerKeyID
+	if ret.Signer != nil {
+		signer = ret.Signer.Fingerprint
+	}
+	audit.Record(audit.OpVerify, req.FolderName, signer, err)
+}
+
+func newResult(data []byte, meta *crypto.LiteralMetadata) hybdec.AutoReturnType {
+	return hybdec.AutoReturnType{
+		ReturnType: hybdec.ReturnType{
+			Data:     string(data),
+			Warnings: integrity.Warnings(),
+			Literal:  pgpformat.MetadataOf(meta),
+		},
+	}
+}
@@ -0,0 +1,95 @@
+package direnc
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// writeArchive writes the tree of sourceDir as a tar archive whose entries
+// start with the directory's name. Owners are left out, only the
+// permission bits of the modes are kept
+func writeArchive(w io.Writer, sourceDir string, includeSymlinks bool) (ArchiveSummary, error) {
+	var summary ArchiveSummary
+	tw := tar.NewWriter(w)
+	root := filepath.Base(sourceDir)
+
+	err := filepath.WalkDir(sourceDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(sourceDir, p)
+		if err != nil {
+			return err
+		}
+		name := path.Join(root, filepath.ToSlash(rel))
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		header := &tar.Header{
+			Name:    name,
+			Mode:    int64(info.Mode().Perm()),
+			ModTime: info.ModTime(),
+			Format:  tar.FormatPAX,
+		}
+
+		switch {
+		case d.IsDir():
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+			summary.Dirs++
+		case info.Mode()&fs.ModeSymlink != 0:
+			if !includeSymlinks {
+				summary.Skipped = append(summary.Skipped, name)
+				return nil
+			}
+			target, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = filepath.ToSlash(target)
+		case info.Mode().IsRegular():
+			header.Typeflag = tar.TypeReg
+			header.Size = info.Size()
+		default:
+			// devices, sockets and pipes have no content to keep
+			summary.Skipped = append(summary.Skipped, name)
+			return nil
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return fmt.Errorf("failed to archive %s: %w", name, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		n, err := io.Copy(tw, f)
+		if err != nil {
+			return fmt.Errorf("failed to archive %s: %w", name, err)
+		}
+		summary.Files++
+		summary.Bytes += n
+		return nil
+	})
+	if err != nil {
+		return ArchiveSummary{}, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return ArchiveSummary{}, fmt.Errorf("failed to finish the archive: %w", err)
+	}
+	return summary, nil
+}
@@ -0,0 +1,119 @@
+package direnc
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/pgpformat"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type (
+	DirEnc struct{}
+
+	EncryptRequest struct {
+		SourceDir string `json:"sourceDir"`
+		// OutputPath is the encrypted archive to create, it must not exist
+		// yet and must be outside of SourceDir
+		OutputPath string `json:"outputPath"`
+		// Passphrase encrypts the archive symmetrically, alone or in
+		// addition to the recipients
+		Passphrase string `json:"passphrase,omitempty"`
+		// Recipients are the names of the vault keys to encrypt to
+		Recipients []string `json:"recipients,omitempty"`
+		// SignerKeyName is our key that signs the archive, optional
+		SignerKeyName    string `json:"signerKeyName,omitempty"`
+		SignerPassphrase string `json:"signerPassphrase,omitempty"`
+		// IncludeSymlinks stores symbolic links instead of skipping them
+		IncludeSymlinks bool `json:"includeSymlinks,omitempty"`
+	}
+
+	ArchiveSummary struct {
+		Path  string `json:"path"`
+		Files int    `json:"files"`
+		Dirs  int    `json:"dirs"`
+		Bytes int64  `json:"bytes"`
+		// Skipped are the relative paths left out, like symbolic links
+		Skipped []string `json:"skipped,omitempty"`
+	}
+)
+
+// EncryptDirectory packs a directory tree into a tar archive and encrypts
+// it into a single binary OpenPGP message. Relative paths, modes and
+// modification times are kept, the tree is rooted at the directory's name
+func (de *DirEnc) EncryptDirectory(req EncryptRequest) (summary ArchiveSummary, err error) {
+	sourceDir, err := filepath.Abs(req.SourceDir)
+	if err != nil {
+		return ArchiveSummary{}, fmt.Errorf("%w: invalid source directory: %v", apperr.ErrInvalidInput, err)
+	}
+	info, err := os.Stat(sourceDir)
+	if err != nil {
+		return ArchiveSummary{}, fmt.Errorf("failed to read %s: %w", sourceDir, err)
+	}
+	if !info.IsDir() {
+		return ArchiveSummary{}, fmt.Errorf("%w: %s is not a directory", apperr.ErrInvalidInput, sourceDir)
+	}
+
+	outputPath, err := filepath.Abs(req.OutputPath)
+	if err != nil || req.OutputPath == "" {
+		return ArchiveSummary{}, fmt.Errorf("%w: invalid output path", apperr.ErrInvalidInput)
+	}
+	if rel, err := filepath.Rel(sourceDir, outputPath); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ArchiveSummary{}, fmt.Errorf("%w: the archive cannot be written inside the directory it encrypts", apperr.ErrInvalidInput)
+	}
+	if _, err := os.Lstat(outputPath); err == nil {
+		return ArchiveSummary{}, fmt.Errorf("%w: %s already exists", apperr.ErrInvalidInput, outputPath)
+	}
+
+	params, release, err := pgpformat.ResolveParams(pgpformat.ParamsRequest{
+		Passphrase:       req.Passphrase,
+		Recipients:       req.Recipients,
+		SignerKeyName:    req.SignerKeyName,
+		SignerPassphrase: req.SignerPassphrase,
+	})
+	if err != nil {
+		return ArchiveSummary{}, err
+	}
+	defer release()
+
+	// the archive is written next to its final name and only renamed once
+	// complete, so a failure never leaves a truncated archive behind
+	tmp, err := os.CreateTemp(filepath.Dir(outputPath), ".mindlockr-archive-*")
+	if err != nil {
+		return ArchiveSummary{}, fmt.Errorf("failed to create the archive: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	meta := pgpformat.Metadata{
+		Filename: filepath.Base(sourceDir) + ".tar",
+		ModTime:  info.ModTime().Unix(),
+		Binary:   true,
+	}
+	plaintext, err := pgpformat.EncryptingWriter(tmp, meta, params)
+	if err != nil {
+		return ArchiveSummary{}, err
+	}
+
+	summary, err = writeArchive(plaintext, sourceDir, req.IncludeSymlinks)
+	if err != nil {
+		return ArchiveSummary{}, err
+	}
+	if err = plaintext.Close(); err != nil {
+		return ArchiveSummary{}, fmt.Errorf("failed to encrypt the archive: %s", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return ArchiveSummary{}, fmt.Errorf("failed to write the archive: %w", err)
+	}
+	if err = os.Rename(tmp.Name(), outputPath); err != nil {
+		return ArchiveSummary{}, fmt.Errorf("failed to write the archive: %w", err)
+	}
+
+	summary.Path = outputPath
+	return summary, nil
+}
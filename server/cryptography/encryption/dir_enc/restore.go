@@ -0,0 +1,368 @@
+package direnc
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/cryptohelper"
+	hybdec "MindLockr/server/cryptography/decryption/hyb_dec"
+	"MindLockr/server/cryptography/pgpformat"
+	"MindLockr/server/filesystem/audit"
+	"MindLockr/server/filesystem/integrity"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+type (
+	DecryptRequest struct {
+		ArchivePath string `json:"archivePath"`
+		// Destination is the directory the tree is restored into
+		Destination string `json:"destination"`
+		// Passphrase is the archive passphrase, or the passphrase of the
+		// vault key the archive was encrypted to
+		Passphrase string `json:"passphrase,omitempty"`
+		// RestoreSymlinks restores symbolic links that stay inside the
+		// restored tree, the others are always skipped
+		RestoreSymlinks bool `json:"restoreSymlinks,omitempty"`
+	}
+
+	RestoreResult struct {
+		// Data of the embedded result is empty, the content is on disk
+		hybdec.AutoReturnType
+		// Entries are the paths restored at the top of Destination
+		Entries []string `json:"entries"`
+		Files   int      `json:"files"`
+		Dirs    int      `json:"dirs"`
+		Bytes   int64    `json:"bytes"`
+		// Skipped are the archive entries that were not restored
+		Skipped []string `json:"skipped,omitempty"`
+	}
+
+	restoredDir struct {
+		path    string
+		mode    os.FileMode
+		modTime time.Time
+	}
+)
+
+// DecryptDirectory decrypts an archive made by EncryptDirectory and
+// restores its tree into Destination. Entries that would land outside of
+// Destination are refused, the tree is extracted into a staging folder and
+// only moved into place once complete and its signature passed the
+// verification policy, existing files are never replaced
+func (de *DirEnc) DecryptDirectory(req DecryptRequest) (RestoreResult, error) {
+	if req.Destination == "" {
+		return RestoreResult{}, fmt.Errorf("%w: choose the folder to restore into", apperr.ErrInvalidInput)
+	}
+	destination, err := filepath.Abs(req.Destination)
+	if err != nil {
+		return RestoreResult{}, fmt.Errorf("%w: invalid destination: %v", apperr.ErrInvalidInput, err)
+	}
+	if err := os.MkdirAll(destination, 0700); err != nil {
+		return RestoreResult{}, fmt.Errorf("failed to create %s: %w", destination, err)
+	}
+
+	f, err := os.Open(req.ArchivePath)
+	if err != nil {
+		return RestoreResult{}, fmt.Errorf("failed to open the archive: %w", err)
+	}
+	defer f.Close()
+
+	vaultKeys, _ := pgpfs.VaultKeys()
+	decHandle, recipient, release, err := hybdec.DecryptionHandle(f, req.Passphrase, vaultKeys)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+	defer release()
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return RestoreResult{}, fmt.Errorf("failed to read the archive: %w", err)
+	}
+
+	fingerprint := ""
+	if recipient != nil {
+		fingerprint = recipient.Fingerprint
+	}
+	reader, err := decHandle.DecryptingReader(f, crypto.Bytes)
+	audit.Record(audit.OpDecrypt, filepath.Base(req.ArchivePath), fingerprint, err)
+	if err != nil {
+		return RestoreResult{}, fmt.Errorf("failed to decrypt the archive: %w", cryptohelper.DecryptionError(err))
+	}
+
+	staging, err := os.MkdirTemp(destination, ".mindlockr-restore-*")
+	if err != nil {
+		return RestoreResult{}, fmt.Errorf("failed to create a staging folder: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	result := RestoreResult{
+		AutoReturnType: hybdec.AutoReturnType{
+			ReturnType: hybdec.ReturnType{Warnings: integrity.Warnings()},
+			Recipient:  recipient,
+		},
+	}
+	dirs, err := extract(tar.NewReader(reader), staging, req.RestoreSymlinks, &result)
+	if err != nil {
+		return RestoreResult{}, err
+	}
+
+	verifyResult, err := reader.DiscardAllAndVerifySignature()
+	if err != nil {
+		return RestoreResult{}, fmt.Errorf("failed to decrypt the archive: %w", cryptohelper.DecryptionError(err))
+	}
+	result.Literal = pgpformat.MetadataOf(reader.GetMetadata())
+	if err := hybdec.ApplyHandleSignature(&result.AutoReturnType, verifyResult, vaultKeys); err != nil {
+		return RestoreResult{}, err
+	}
+
+	// directories get their modes and times last, writing their content
+	// changed the times and a read-only mode would have blocked it
+	for i := len(dirs) - 1; i >= 0; i-- {
+		os.Chmod(dirs[i].path, dirs[i].mode)
+		os.Chtimes(dirs[i].path, dirs[i].modTime, dirs[i].modTime)
+	}
+
+	entries, err := os.ReadDir(staging)
+	if err != nil {
+		return RestoreResult{}, fmt.Errorf("failed to read the staging folder: %w", err)
+	}
+	for _, entry := range entries {
+		if _, err := os.Lstat(filepath.Join(destination, entry.Name())); err == nil {
+			return RestoreResult{}, fmt.Errorf("%w: %s already exists in %s", apperr.ErrInvalidInput, entry.Name(), destination)
+		}
+	}
+	for _, entry := range entries {
+		target := filepath.Join(destination, entry.Name())
+		if err := os.Rename(filepath.Join(staging, entry.Name()), target); err != nil {
+			return result, fmt.Errorf("failed to move %s into place: %w", entry.Name(), err)
+		}
+		result.Entries = append(result.Entries, target)
+	}
+	return result, nil
+}
+
+// extract writes the entries of tr into staging and returns the
+// directories it created
+func extract(tr *tar.Reader, staging string, restoreSymlinks bool, result *RestoreResult) ([]restoredDir, error) {
+	var dirs []restoredDir
+	// the names of the symbolic links restored so far
+	var links []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return dirs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid archive: %v", apperr.ErrCorruptMessage, err)
+		}
+
+		name, err := entryName(header.Name)
+		if err != nil {
+			return nil, err
+		}
+		target := filepath.Join(staging, filepath.FromSlash(name))
+		if err := checkParents(staging, target); err != nil {
+			return nil, err
+		}
+		mode := os.FileMode(header.Mode).Perm()
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if info, err := os.Lstat(target); err == nil && !info.IsDir() {
+				return nil, fmt.Errorf("%w: the archive replaces %s with a directory", apperr.ErrCorruptMessage, name)
+			}
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return nil, fmt.Errorf("failed to create %s: %w", name, err)
+			}
+			dirs = append(dirs, restoredDir{path: target, mode: mode, modTime: header.ModTime})
+			result.Dirs++
+
+		case tar.TypeReg:
+			n, err := writeEntry(tr, target, mode, header.ModTime)
+			if err != nil {
+				return nil, fmt.Errorf("failed to restore %s: %w", name, err)
+			}
+			result.Files++
+			result.Bytes += n
+
+		case tar.TypeSymlink:
+			if !restoreSymlinks || !relativeLinkname(header.Linkname) {
+				result.Skipped = append(result.Skipped, name)
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+				return nil, fmt.Errorf("failed to create %s: %w", path.Dir(name), err)
+			}
+			if err := os.Symlink(filepath.FromSlash(header.Linkname), target); err != nil {
+				return nil, fmt.Errorf("failed to restore %s: %w", name, err)
+			}
+
+			// a new link can also redirect the links restored before it,
+			// like a -> . under b -> a/.., so all of them are checked again
+			links = append(links, name)
+			if !linksStayInside(staging, links) {
+				os.Remove(target)
+				links = links[:len(links)-1]
+				result.Skipped = append(result.Skipped, name)
+			}
+
+		default:
+			// hard links, devices and the like are never restored
+			result.Skipped = append(result.Skipped, name)
+		}
+	}
+}
+
+func writeEntry(r io.Reader, target string, mode os.FileMode, modTime time.Time) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return 0, err
+	}
+	// an archive naming the same file twice is refused rather than merged
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.Chmod(target, mode); err != nil {
+		return 0, err
+	}
+	return n, os.Chtimes(target, modTime, modTime)
+}
+
+// entryName validates the name of an archive entry, it must be a relative
+// path without any parent reference
+func entryName(name string) (string, error) {
+	unsafe := func() (string, error) {
+		return "", fmt.Errorf("%w: the archive holds an unsafe path %q", apperr.ErrCorruptMessage, name)
+	}
+
+	if name == "" || strings.ContainsAny(name, "\\\x00") || path.IsAbs(name) ||
+		filepath.IsAbs(filepath.FromSlash(name)) || filepath.VolumeName(filepath.FromSlash(name)) != "" {
+		return unsafe()
+	}
+	for _, element := range strings.Split(name, "/") {
+		if element == ".." {
+			return unsafe()
+		}
+	}
+
+	cleaned := path.Clean(name)
+	if cleaned == "." {
+		return unsafe()
+	}
+	return cleaned, nil
+}
+
+// checkParents makes sure every existing parent of target inside staging
+// is a real directory, a symbolic link could otherwise redirect the write
+func checkParents(staging, target string) error {
+	rel, err := filepath.Rel(staging, filepath.Dir(target))
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%w: the archive holds an unsafe path", apperr.ErrCorruptMessage)
+	}
+	if rel == "." {
+		return nil
+	}
+
+	current := staging
+	for _, element := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, element)
+		info, err := os.Lstat(current)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%w: the archive writes through %s, which is not a directory", apperr.ErrCorruptMessage, current[len(staging)+1:])
+		}
+	}
+	return nil
+}
+
+// maxLinkHops bounds how many links are followed to resolve a link, like
+// the limit of the operating system it also ends link cycles
+const maxLinkHops = 40
+
+// relativeLinkname reports whether linkname is a relative link target
+func relativeLinkname(linkname string) bool {
+	return linkname != "" && !path.IsAbs(linkname) && !strings.ContainsAny(linkname, "\\\x00") &&
+		filepath.VolumeName(filepath.FromSlash(linkname)) == ""
+}
+
+// linksStayInside reports whether every restored link resolves inside
+// staging, following the links it passes through like the system would
+func linksStayInside(staging string, links []string) bool {
+	for _, name := range links {
+		linkname, err := os.Readlink(filepath.Join(staging, filepath.FromSlash(name)))
+		if err != nil {
+			return false
+		}
+		if _, ok := resolveInside(staging, path.Dir(name), filepath.ToSlash(linkname), 0); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveInside resolves target relative to the directory dir of the tree
+// in staging and returns the resolved path relative to staging. It fails
+// as soon as the path leaves the tree
+func resolveInside(staging, dir, target string, hops int) (string, bool) {
+	if hops > maxLinkHops || !relativeLinkname(target) {
+		return "", false
+	}
+
+	var elements []string
+	if dir != "." {
+		elements = strings.Split(dir, "/")
+	}
+	for _, element := range strings.Split(target, "/") {
+		switch element {
+		case "", ".":
+			continue
+		case "..":
+			if len(elements) == 0 {
+				return "", false
+			}
+			elements = elements[:len(elements)-1]
+			continue
+		}
+
+		elements = append(elements, element)
+		current := path.Join(elements...)
+		linkname, err := os.Readlink(filepath.Join(staging, filepath.FromSlash(current)))
+		if err != nil {
+			// not a link, or nothing restored there yet
+			continue
+		}
+		resolved, ok := resolveInside(staging, path.Dir(current), filepath.ToSlash(linkname), hops+1)
+		if !ok {
+			return "", false
+		}
+		elements = nil
+		if resolved != "." {
+			elements = strings.Split(resolved, "/")
+		}
+	}
+
+	if len(elements) == 0 {
+		return ".", true
+	}
+	return path.Join(elements...), true
+}
@@ -1,8 +1,12 @@
 package hybenc
 
 import (
+	"MindLockr/server/cryptography/cryptohelper"
+	"MindLockr/server/cryptography/passphrase"
+	"MindLockr/server/cryptography/pgpformat"
 	"fmt"
 
+	"github.com/ProtonMail/gopenpgp/v3/constants"
 	"github.com/ProtonMail/gopenpgp/v3/crypto"
 )
 
@@ -15,6 +19,9 @@ type (
 		PrivKeyPassphrase string `json:"privPassphrase"`
 		PubKey            string `json:"pubKey"`
 		PrivKey           string `json:"privKey"`
+		// FilePath encrypts the file instead of Data, its name and
+		// modification time are kept in the message
+		FilePath string `json:"filePath,omitempty"`
 	}
 
 	SaveAsymmetricDataRequest struct {
@@ -42,29 +49,42 @@ func (he *HybEnc) EncryptAndSign(req RequestData) (string, error) {
 		return "", fmt.Errorf("failed to ge the pub key from armored in hyb en: %s", err)
 	}
 
-	sendersPrivKey, err := crypto.NewPrivateKeyFromArmored(req.PrivKey, []byte(req.PrivKeyPassphrase))
+	sendersPrivKey, err := cryptohelper.UnlockPrivateKey(req.PrivKey, []byte(req.PrivKeyPassphrase))
 	if err != nil {
-		return "", fmt.Errorf("failed to ge the priv key from armored in hyb en: %s", err)
+		return "", fmt.Errorf("failed to get the priv key from armored in hyb en: %w", err)
 	}
+	defer sendersPrivKey.ClearPrivateParams()
 
-	pgp := crypto.PGP()
+	params := pgpformat.EncryptParams{Signer: sendersPrivKey}
+	if params.Recipients, err = crypto.NewKeyRing(recipientPubKey); err != nil {
+		return "", fmt.Errorf("failed to create the recipient key ring: %s", err)
+	}
 
-	encHandle, err := pgp.Encryption().Password([]byte(req.Passphrase)).Recipient(recipientPubKey).SigningKey(sendersPrivKey).New()
-	if err != nil {
-		return "", fmt.Errorf("failed to create an encryption handle for aes encryption check parameters passed: %s", err)
+	// the additional symmetric passphrase is optional, but when one is given
+	// it has to satisfy the passphrase policy
+	if req.Passphrase != "" {
+		if err := passphrase.Enforce(req.Passphrase); err != nil {
+			return "", err
+		}
+		params.Password = []byte(req.Passphrase)
 	}
 
-	pgpMessage, err := encHandle.Encrypt([]byte(req.Data))
-	if err != nil {
-		return "", fmt.Errorf("failed to encrypt the plain text data into aes encryption: %s", err)
+	data, meta := []byte(req.Data), pgpformat.Metadata{}
+	if req.FilePath != "" {
+		if data, meta, err = pgpformat.ReadFile(req.FilePath); err != nil {
+			return "", err
+		}
 	}
 
-	pgpArmor, err := pgpMessage.ArmorBytes()
+	encrypted, err := pgpformat.Encrypt(data, meta, params)
 	if err != nil {
-		return "", fmt.Errorf("%s", err)
+		return "", err
 	}
 
-	encHandle.ClearPrivateParams()
+	pgpArmor, err := pgpformat.Armor(encrypted, constants.PGPMessageHeader)
+	if err != nil {
+		return "", err
+	}
 
 	return string(pgpArmor), nil
 }
@@ -1,15 +1,20 @@
 package symmetricencryption
 
 import (
+	"MindLockr/server/cryptography/passphrase"
+	"MindLockr/server/cryptography/pgpformat"
 	"fmt"
 
-	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
 )
 
 type RequestData struct {
 	Data       string `json:"data"`
 	Passphrase string `json:"passphrase"`
 	Algorithm  string `json:"algorithm"`
+	// FilePath encrypts the file instead of Data, its name and modification
+	// time are kept in the message
+	FilePath string `json:"filePath,omitempty"`
 }
 
 type DataToEncrypt struct {
@@ -20,18 +25,24 @@ type DataToEncrypt struct {
 type Cryptography struct{}
 
 func (c *Cryptography) EncryptAES(req RequestData) (string, error) {
-	pgp := crypto.PGP()
-	encHandle, err := pgp.Encryption().Password([]byte(req.Passphrase)).New()
-	if err != nil {
-		return "", fmt.Errorf("failed to create an encryption handle for aes encryption check parameters passed: %s", err)
+	if err := passphrase.Enforce(req.Passphrase); err != nil {
+		return "", err
+	}
+
+	data, meta := []byte(req.Data), pgpformat.Metadata{}
+	if req.FilePath != "" {
+		var err error
+		if data, meta, err = pgpformat.ReadFile(req.FilePath); err != nil {
+			return "", err
+		}
 	}
 
-	pgpMessage, err := encHandle.Encrypt([]byte(req.Data))
+	encrypted, err := pgpformat.Encrypt(data, meta, pgpformat.EncryptParams{Password: []byte(req.Passphrase)})
 	if err != nil {
-		return "", fmt.Errorf("failed to encrypt the plain text data into aes encryption: %s", err)
+		return "", err
 	}
 
-	armored, err := pgpMessage.ArmorBytes()
+	armored, err := pgpformat.Armor(encrypted, constants.PGPMessageHeader)
 	if err != nil {
 		return "", fmt.Errorf("failed to get armored message from the aes encryption: %s", err)
 	}
@@ -0,0 +1,82 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"time"
+)
+
+// supported HMAC algorithms, named like in otpauth URIs
+const (
+	AlgorithmSHA1   = "SHA1"
+	AlgorithmSHA256 = "SHA256"
+	AlgorithmSHA512 = "SHA512"
+)
+
+// defaults from RFC 4226 and RFC 6238
+const (
+	DefaultDigits = 6
+	DefaultPeriod = 30
+)
+
+var powers = [...]uint32{1, 10, 100, 1000, 10000, 100000, 1000000, 10000000, 100000000, 1000000000}
+
+func hashFunc(algorithm string) (func() hash.Hash, error) {
+	switch algorithm {
+	case AlgorithmSHA1, "":
+		return sha1.New, nil
+	case AlgorithmSHA256:
+		return sha256.New, nil
+	case AlgorithmSHA512:
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unsupported OTP algorithm: %q", algorithm)
+}
+
+// HOTP computes the HMAC-based one-time password for a counter value
+// (RFC 4226, section 5.3)
+func HOTP(secret []byte, counter uint64, digits int, algorithm string) (string, error) {
+	if digits < 6 || digits > 9 {
+		return "", fmt.Errorf("OTP codes must have between 6 and 9 digits, got %d", digits)
+	}
+	h, err := hashFunc(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(h, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", digits, code%powers[digits]), nil
+}
+
+// TOTP computes the time-based one-time password valid at t (RFC 6238,
+// section 4) and how long it stays valid
+func TOTP(secret []byte, t time.Time, period, digits int, algorithm string) (string, time.Duration, error) {
+	if period <= 0 {
+		return "", 0, fmt.Errorf("TOTP period must be positive, got %d", period)
+	}
+
+	unix := t.Unix()
+	counter := uint64(unix) / uint64(period)
+	code, err := HOTP(secret, counter, digits, algorithm)
+	if err != nil {
+		return "", 0, err
+	}
+
+	remaining := time.Duration(int64(period)-unix%int64(period)) * time.Second
+	return code, remaining, nil
+}
@@ -0,0 +1,253 @@
+package otp
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/session"
+	"MindLockr/server/filesystem"
+	"MindLockr/server/filesystem/audit"
+	"MindLockr/server/filesystem/sealed"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const otpDir = "otp"
+
+type (
+	Authenticator struct {
+		store *sealed.Store
+		// serializes HOTP counter updates
+		mu sync.Mutex
+	}
+
+	AddRequest struct {
+		// URI is an otpauth:// URI, QRImage a base64 PNG/JPEG of its QR
+		// code. One of both is required
+		URI     string `json:"uri,omitempty"`
+		QRImage string `json:"qrImage,omitempty"`
+	}
+
+	// record is what gets stored, encrypted to the session key
+	record struct {
+		ID        string    `json:"id"`
+		CreatedAt time.Time `json:"createdAt"`
+		Account   Account   `json:"account"`
+	}
+
+	// AccountSummary never contains the secret
+	AccountSummary struct {
+		ID        string    `json:"id"`
+		Type      string    `json:"type"`
+		Issuer    string    `json:"issuer,omitempty"`
+		Label     string    `json:"label"`
+		Algorithm string    `json:"algorithm"`
+		Digits    int       `json:"digits"`
+		Period    int       `json:"period,omitempty"`
+		CreatedAt time.Time `json:"createdAt"`
+		// Warnings are those of the verification policy about the
+		// signature of the stored account
+		Warnings []string `json:"warnings,omitempty"`
+	}
+
+	// AccountList is what ListAccounts returns. An account that cannot be
+	// decrypted does not hide the others: it is left out of Accounts and
+	// reported in Unreadable
+	AccountList struct {
+		Accounts   []AccountSummary    `json:"accounts"`
+		Unreadable []UnreadableAccount `json:"unreadable"`
+	}
+
+	UnreadableAccount struct {
+		ID    string      `json:"id"`
+		Code  apperr.Code `json:"code"`
+		Error string      `json:"error"`
+	}
+
+	Code struct {
+		Code string `json:"code"`
+		// Remaining is the number of seconds the TOTP code stays valid,
+		// HOTP codes stay valid until the next one is used
+		Remaining int       `json:"remaining,omitempty"`
+		Period    int       `json:"period,omitempty"`
+		ExpiresAt time.Time `json:"expiresAt,omitempty"`
+		Counter   uint64    `json:"counter,omitempty"`
+		Warnings  []string  `json:"warnings,omitempty"`
+	}
+
+	Export struct {
+		URI      string   `json:"uri"`
+		Warnings []string `json:"warnings,omitempty"`
+	}
+)
+
+func NewAuthenticator(folder *filesystem.Folder) *Authenticator {
+	return &Authenticator{
+		store: sealed.NewStore(folder, otpDir, "otp account"),
+	}
+}
+
+// AddAccount stores an OTP account from an otpauth URI or a QR image of one
+func (a *Authenticator) AddAccount(req AddRequest) (AccountSummary, error) {
+	uri := req.URI
+	if uri == "" {
+		if req.QRImage == "" {
+			return AccountSummary{}, fmt.Errorf("an otpauth URI or a QR code image is required")
+		}
+		decoded, err := DecodeQR(req.QRImage)
+		if err != nil {
+			return AccountSummary{}, err
+		}
+		uri = decoded
+	}
+
+	acc, err := ParseURI(uri)
+	if err != nil {
+		return AccountSummary{}, err
+	}
+
+	id, err := sealed.NewID()
+	if err != nil {
+		return AccountSummary{}, err
+	}
+
+	rec := record{ID: id, CreatedAt: time.Now().UTC(), Account: acc}
+	if err := a.write(rec); err != nil {
+		return AccountSummary{}, err
+	}
+	return summarize(rec, nil), nil
+}
+
+// ListAccounts returns every stored OTP account without their secrets
+func (a *Authenticator) ListAccounts() (AccountList, error) {
+	ids, err := a.store.IDs()
+	if err != nil {
+		return AccountList{}, err
+	}
+
+	list := AccountList{Accounts: []AccountSummary{}, Unreadable: []UnreadableAccount{}}
+	for _, id := range ids {
+		rec, warnings, err := a.read(id)
+		if errors.Is(err, session.ErrLocked) {
+			return AccountList{}, err
+		}
+		if err != nil {
+			list.Unreadable = append(list.Unreadable, UnreadableAccount{ID: id, Code: apperr.CodeOf(err), Error: err.Error()})
+			continue
+		}
+		list.Accounts = append(list.Accounts, summarize(rec, warnings))
+	}
+
+	sort.Slice(list.Accounts, func(i, j int) bool {
+		if list.Accounts[i].Issuer != list.Accounts[j].Issuer {
+			return list.Accounts[i].Issuer < list.Accounts[j].Issuer
+		}
+		return list.Accounts[i].Label < list.Accounts[j].Label
+	})
+
+	return list, nil
+}
+
+// GetCode computes the current code of an account. For HOTP accounts the
+// stored counter is advanced so that every code is only handed out once
+func (a *Authenticator) GetCode(id string) (code Code, err error) {
+	defer func() {
+		audit.Record(audit.OpDecrypt, otpDir+"/"+id, session.Fingerprint(), err)
+	}()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	rec, warnings, err := a.read(id)
+	if err != nil {
+		return Code{}, err
+	}
+
+	key, err := rec.Account.Key()
+	if err != nil {
+		return Code{}, err
+	}
+	defer clear(key)
+
+	acc := rec.Account
+	if acc.Type == TypeHOTP {
+		value, err := HOTP(key, acc.Counter, acc.Digits, acc.Algorithm)
+		if err != nil {
+			return Code{}, err
+		}
+		code = Code{Code: value, Counter: acc.Counter, Warnings: warnings}
+
+		rec.Account.Counter++
+		if err := a.write(rec); err != nil {
+			return Code{}, err
+		}
+		return code, nil
+	}
+
+	now := time.Now()
+	value, remaining, err := TOTP(key, now, acc.Period, acc.Digits, acc.Algorithm)
+	if err != nil {
+		return Code{}, err
+	}
+	return Code{
+		Code:      value,
+		Remaining: int(remaining / time.Second),
+		Period:    acc.Period,
+		ExpiresAt: now.Add(remaining).Truncate(time.Second),
+		Warnings:  warnings,
+	}, nil
+}
+
+// ExportURI returns the otpauth URI of an account, e.g. to move it to
+// another authenticator
+func (a *Authenticator) ExportURI(id string) (export Export, err error) {
+	defer func() {
+		audit.Record(audit.OpDecrypt, otpDir+"/"+id, session.Fingerprint(), err)
+	}()
+
+	rec, warnings, err := a.read(id)
+	if err != nil {
+		return Export{}, err
+	}
+	return Export{URI: rec.Account.URI(), Warnings: warnings}, nil
+}
+
+// DeleteAccount removes an OTP account from the vault
+func (a *Authenticator) DeleteAccount(id string) (err error) {
+	defer func() {
+		audit.Record(audit.OpDelete, otpDir+"/"+id, "", err)
+	}()
+
+	return a.store.Delete(id)
+}
+
+func (a *Authenticator) write(rec record) error {
+	return a.store.Write(rec.ID, rec)
+}
+
+func (a *Authenticator) read(id string) (record, []string, error) {
+	var rec record
+	warnings, err := a.store.Read(id, &rec)
+	if err != nil {
+		return record{}, nil, err
+	}
+	if rec.ID != id {
+		return record{}, nil, fmt.Errorf("otp file %s contains account %s", id, rec.ID)
+	}
+	return rec, warnings, nil
+}
+
+func summarize(rec record, warnings []string) AccountSummary {
+	return AccountSummary{
+		ID:        rec.ID,
+		Type:      rec.Account.Type,
+		Issuer:    rec.Account.Issuer,
+		Label:     rec.Account.Label,
+		Algorithm: rec.Account.Algorithm,
+		Digits:    rec.Account.Digits,
+		Period:    rec.Account.Period,
+		CreatedAt: rec.CreatedAt,
+		Warnings:  warnings,
+	}
+}
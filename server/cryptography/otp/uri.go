@@ -0,0 +1,186 @@
+package otp
+
+import (
+	"bytes"
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// OTP types of the otpauth URI scheme
+const (
+	TypeTOTP = "totp"
+	TypeHOTP = "hotp"
+)
+
+// Account is an OTP credential as described by an otpauth:// URI
+// (https://github.com/google/google-authenticator/wiki/Key-Uri-Format)
+type Account struct {
+	Type      string `json:"type"`
+	Issuer    string `json:"issuer,omitempty"`
+	Label     string `json:"label"`
+	Secret    string `json:"secret"` // base32, as in the URI
+	Algorithm string `json:"algorithm"`
+	Digits    int    `json:"digits"`
+	Period    int    `json:"period,omitempty"`
+	Counter   uint64 `json:"counter,omitempty"`
+}
+
+// ParseURI parses an otpauth:// URI
+func ParseURI(uri string) (Account, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return Account{}, fmt.Errorf("invalid otpauth URI: %v", err)
+	}
+	if u.Scheme != "otpauth" {
+		return Account{}, fmt.Errorf("not an otpauth URI: scheme is %q", u.Scheme)
+	}
+
+	acc := Account{
+		Type:      strings.ToLower(u.Host),
+		Algorithm: AlgorithmSHA1,
+		Digits:    DefaultDigits,
+	}
+	if acc.Type != TypeTOTP && acc.Type != TypeHOTP {
+		return Account{}, fmt.Errorf("unsupported OTP type: %q", u.Host)
+	}
+
+	acc.Label = strings.TrimPrefix(u.Path, "/")
+	if issuer, name, ok := strings.Cut(acc.Label, ":"); ok {
+		acc.Issuer = strings.TrimSpace(issuer)
+		acc.Label = strings.TrimSpace(name)
+	}
+
+	q := u.Query()
+	if issuer := q.Get("issuer"); issuer != "" {
+		acc.Issuer = issuer
+	}
+	acc.Secret = q.Get("secret")
+	if alg := q.Get("algorithm"); alg != "" {
+		acc.Algorithm = strings.ToUpper(alg)
+	}
+	if d := q.Get("digits"); d != "" {
+		if acc.Digits, err = strconv.Atoi(d); err != nil {
+			return Account{}, fmt.Errorf("invalid digits parameter: %q", d)
+		}
+	}
+
+	switch acc.Type {
+	case TypeTOTP:
+		acc.Period = DefaultPeriod
+		if p := q.Get("period"); p != "" {
+			if acc.Period, err = strconv.Atoi(p); err != nil {
+				return Account{}, fmt.Errorf("invalid period parameter: %q", p)
+			}
+		}
+	case TypeHOTP:
+		c := q.Get("counter")
+		if c == "" {
+			return Account{}, fmt.Errorf("hotp URI is missing the counter parameter")
+		}
+		if acc.Counter, err = strconv.ParseUint(c, 10, 64); err != nil {
+			return Account{}, fmt.Errorf("invalid counter parameter: %q", c)
+		}
+	}
+
+	if err := acc.validate(); err != nil {
+		return Account{}, err
+	}
+	return acc, nil
+}
+
+// URI formats the account back into an otpauth:// URI
+func (acc Account) URI() string {
+	label := acc.Label
+	if acc.Issuer != "" {
+		label = acc.Issuer + ":" + acc.Label
+	}
+
+	q := url.Values{}
+	q.Set("secret", acc.Secret)
+	if acc.Issuer != "" {
+		q.Set("issuer", acc.Issuer)
+	}
+	q.Set("algorithm", acc.Algorithm)
+	q.Set("digits", strconv.Itoa(acc.Digits))
+	if acc.Type == TypeHOTP {
+		q.Set("counter", strconv.FormatUint(acc.Counter, 10))
+	} else {
+		q.Set("period", strconv.Itoa(acc.Period))
+	}
+
+	u := url.URL{Scheme: "otpauth", Host: acc.Type, Path: "/" + label, RawQuery: q.Encode()}
+	return u.String()
+}
+
+// Key decodes the base32 secret, padding and case are optional
+func (acc Account) Key() ([]byte, error) {
+	secret := strings.ToUpper(strings.ReplaceAll(acc.Secret, " ", ""))
+	secret = strings.TrimRight(secret, "=")
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("OTP secret is not valid base32: %v", err)
+	}
+	return key, nil
+}
+
+func (acc Account) validate() error {
+	if acc.Secret == "" {
+		return fmt.Errorf("OTP secret is missing")
+	}
+	key, err := acc.Key()
+	if err != nil {
+		return err
+	}
+	clear(key)
+
+	if _, err := hashFunc(acc.Algorithm); err != nil {
+		return err
+	}
+	if acc.Digits < 6 || acc.Digits > 9 {
+		return fmt.Errorf("OTP codes must have between 6 and 9 digits, got %d", acc.Digits)
+	}
+	if acc.Type == TypeTOTP && acc.Period <= 0 {
+		return fmt.Errorf("TOTP period must be positive, got %d", acc.Period)
+	}
+	return nil
+}
+
+// DecodeQR reads an otpauth URI from a PNG or JPEG image of a QR code. The
+// image may be passed as raw base64 or as a data URL
+func DecodeQR(imageBase64 string) (string, error) {
+	if _, data, ok := strings.Cut(imageBase64, ";base64,"); ok {
+		imageBase64 = data
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(imageBase64))
+	if err != nil {
+		return "", fmt.Errorf("image is not valid base64: %v", err)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode image: %v", err)
+	}
+
+	bmp, err := gozxing.NewBinaryBitmapFromImage(img)
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %v", err)
+	}
+
+	result, err := qrcode.NewQRCodeReader().Decode(bmp, nil)
+	if err != nil {
+		return "", fmt.Errorf("no QR code found in image: %v", err)
+	}
+	return result.GetText(), nil
+}
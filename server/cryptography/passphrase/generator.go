@@ -0,0 +1,198 @@
+package passphrase
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+const (
+	lowercaseChars = "abcdefghijklmnopqrstuvwxyz"
+	uppercaseChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars     = "0123456789"
+	symbolChars    = "!@#$%^&*()-_=+[]{};:,.<>/?~"
+	ambiguousChars = "Il1O0o|`'\""
+)
+
+// EFF large wordlist, 7776 words (5 dice rolls per word)
+//
+//go:embed eff_large_wordlist.txt
+var effWordlist string
+
+var (
+	wordsOnce sync.Once
+	words     []string
+	wordSet   map[string]bool
+)
+
+type (
+	Passphrase struct{}
+
+	CharPolicy struct {
+		Length           int  `json:"length"`
+		Lowercase        bool `json:"lowercase"`
+		Uppercase        bool `json:"uppercase"`
+		Digits           bool `json:"digits"`
+		Symbols          bool `json:"symbols"`
+		ExcludeAmbiguous bool `json:"excludeAmbiguous"`
+	}
+
+	WordPolicy struct {
+		Words         int    `json:"words"`
+		Separator     string `json:"separator"`
+		Capitalize    bool   `json:"capitalize"`
+		IncludeNumber bool   `json:"includeNumber"`
+	}
+
+	Generated struct {
+		Passphrase string   `json:"passphrase"`
+		Strength   Strength `json:"strength"`
+	}
+)
+
+func wordlist() ([]string, map[string]bool) {
+	wordsOnce.Do(func() {
+		words = strings.Fields(effWordlist)
+		wordSet = make(map[string]bool, len(words))
+		for _, w := range words {
+			wordSet[w] = true
+		}
+	})
+	return words, wordSet
+}
+
+// GenerateRandom creates a random character passphrase, containing at least
+// one character of every enabled class
+func (p *Passphrase) GenerateRandom(policy CharPolicy) (Generated, error) {
+	if policy.Length < 8 || policy.Length > 256 {
+		return Generated{}, fmt.Errorf("length must be between 8 and 256, got %d", policy.Length)
+	}
+
+	var classes []string
+	for _, class := range []struct {
+		enabled bool
+		chars   string
+	}{
+		{policy.Lowercase, lowercaseChars},
+		{policy.Uppercase, uppercaseChars},
+		{policy.Digits, digitChars},
+		{policy.Symbols, symbolChars},
+	} {
+		if !class.enabled {
+			continue
+		}
+
+		chars := class.chars
+		if policy.ExcludeAmbiguous {
+			chars = strings.Map(func(r rune) rune {
+				if strings.ContainsRune(ambiguousChars, r) {
+					return -1
+				}
+				return r
+			}, chars)
+		}
+		classes = append(classes, chars)
+	}
+
+	if len(classes) == 0 {
+		return Generated{}, fmt.Errorf("at least one character class must be enabled")
+	}
+	if policy.Length < len(classes) {
+		return Generated{}, fmt.Errorf("length is too short to include every character class")
+	}
+
+	pool := strings.Join(classes, "")
+	out := make([]byte, policy.Length)
+
+	// one character of every class first, the rest from the whole pool
+	for i := range out {
+		chars := pool
+		if i < len(classes) {
+			chars = classes[i]
+		}
+
+		idx, err := randomInt(len(chars))
+		if err != nil {
+			return Generated{}, err
+		}
+		out[i] = chars[idx]
+	}
+
+	if err := shuffle(out); err != nil {
+		return Generated{}, err
+	}
+
+	generated := string(out)
+	return Generated{
+		Passphrase: generated,
+		Strength:   Estimate(generated),
+	}, nil
+}
+
+// GenerateWords creates a diceware style passphrase from the embedded EFF list
+func (p *Passphrase) GenerateWords(policy WordPolicy) (Generated, error) {
+	if policy.Words < 4 || policy.Words > 20 {
+		return Generated{}, fmt.Errorf("number of words must be between 4 and 20, got %d", policy.Words)
+	}
+
+	list, _ := wordlist()
+	chosen := make([]string, policy.Words)
+	for i := range chosen {
+		idx, err := randomInt(len(list))
+		if err != nil {
+			return Generated{}, err
+		}
+
+		word := list[idx]
+		if policy.Capitalize {
+			word = strings.ToUpper(word[:1]) + word[1:]
+		}
+		chosen[i] = word
+	}
+
+	if policy.IncludeNumber {
+		pos, err := randomInt(len(chosen))
+		if err != nil {
+			return Generated{}, err
+		}
+		digit, err := randomInt(10)
+		if err != nil {
+			return Generated{}, err
+		}
+		chosen[pos] += fmt.Sprintf("%d", digit)
+	}
+
+	generated := strings.Join(chosen, policy.Separator)
+	return Generated{
+		Passphrase: generated,
+		Strength:   Estimate(generated),
+	}, nil
+}
+
+// EstimateStrength exposes the estimator to the frontend
+func (p *Passphrase) EstimateStrength(passphrase string) Strength {
+	return Estimate(passphrase)
+}
+
+func randomInt(max int) (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read random number: %v", err)
+	}
+	return int(n.Int64()), nil
+}
+
+// shuffle is a Fisher-Yates shuffle backed by crypto/rand
+func shuffle(b []byte) error {
+	for i := len(b) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return err
+		}
+		b[i], b[j] = b[j], b[i]
+	}
+	return nil
+}
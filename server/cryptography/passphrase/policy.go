@@ -0,0 +1,104 @@
+package passphrase
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/filesystem"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const policyFileName = "passphrase-policy.json"
+
+// DefaultMinEntropy is roughly four random diceware words
+const DefaultMinEntropy = 50
+
+type Policy struct {
+	// MinEntropy is the lowest estimated entropy (in bits) accepted when data
+	// is encrypted or a private key is locked
+	MinEntropy float64 `json:"minEntropy"`
+}
+
+var (
+	policyMu     sync.Mutex
+	policyLoaded bool
+	policy       = Policy{MinEntropy: DefaultMinEntropy}
+)
+
+// GetPolicy returns the passphrase policy currently enforced
+func (p *Passphrase) GetPolicy() Policy {
+	return CurrentPolicy()
+}
+
+// SetPolicy changes and persists the enforced passphrase policy
+func (p *Passphrase) SetPolicy(newPolicy Policy) error {
+	if newPolicy.MinEntropy < 0 || newPolicy.MinEntropy > 256 {
+		return fmt.Errorf("minimum entropy must be between 0 and 256 bits")
+	}
+
+	policyMu.Lock()
+	defer policyMu.Unlock()
+
+	dir, err := filesystem.ConfigDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+
+	content, err := json.MarshalIndent(newPolicy, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode passphrase policy: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, policyFileName), content, 0600); err != nil {
+		return fmt.Errorf("failed to write passphrase policy: %v", err)
+	}
+
+	policy = newPolicy
+	policyLoaded = true
+	return nil
+}
+
+// CurrentPolicy returns the persisted policy, or the default one when none was
+// configured
+func CurrentPolicy() Policy {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+
+	if policyLoaded {
+		return policy
+	}
+	policyLoaded = true
+
+	dir, err := filesystem.ConfigDir()
+	if err != nil {
+		return policy
+	}
+	content, err := os.ReadFile(filepath.Join(dir, policyFileName))
+	if err != nil {
+		return policy
+	}
+
+	var persisted Policy
+	if err := json.Unmarshal(content, &persisted); err == nil {
+		policy = persisted
+	}
+	return policy
+}
+
+// Enforce rejects passphrases that are empty or weaker than the policy allows
+func Enforce(passphrase string) error {
+	if passphrase == "" {
+		return fmt.Errorf("%w: passphrase must not be empty", apperr.ErrPassphraseRequired)
+	}
+
+	min := CurrentPolicy().MinEntropy
+	strength := Estimate(passphrase)
+	if strength.Entropy < min {
+		return fmt.Errorf("%w (%s, about %.0f bits of entropy, at least %.0f required)", apperr.ErrWeakPassphrase, strength.Label, strength.Entropy, min)
+	}
+	return nil
+}
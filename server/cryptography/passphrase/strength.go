@@ -0,0 +1,292 @@
+package passphrase
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Strength labels indexed by Score
+var strengthLabels = []string{"very weak", "weak", "fair", "strong", "very strong"}
+
+var commonPasswords = map[string]bool{
+	"password": true, "passphrase": true, "123456": true, "12345678": true,
+	"123456789": true, "1234567890": true, "qwerty": true, "qwertyuiop": true,
+	"abc123": true, "111111": true, "123123": true, "letmein": true,
+	"welcome": true, "monkey": true, "dragon": true, "football": true,
+	"baseball": true, "iloveyou": true, "admin": true, "login": true,
+	"master": true, "sunshine": true, "princess": true, "shadow": true,
+	"superman": true, "trustno1": true, "passw0rd": true, "p@ssw0rd": true,
+	"p@ssword": true, "secret": true, "changeme": true, "000000": true,
+	"654321": true, "access": true, "starwars": true, "whatever": true,
+	"mindlockr": true, "correcthorsebatterystaple": true,
+}
+
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+	"qazwsxedcrfvtgbyhnujmik,ol.p;/",
+}
+
+var leetReplacer = strings.NewReplacer("0", "o", "1", "l", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s")
+
+type Strength struct {
+	Entropy  float64  `json:"entropy"`
+	Score    int      `json:"score"`
+	Label    string   `json:"label"`
+	Patterns []string `json:"patterns"`
+	Feedback []string `json:"feedback"`
+}
+
+// Estimate approximates the entropy of a passphrase in bits. The passphrase is
+// scanned for guessable patterns (dictionary words, keyboard walks, sequences,
+// repeats, years) which are charged by how many guesses they take instead of
+// by their length, everything else is charged by the size of its alphabet
+func Estimate(passphrase string) Strength {
+	strength := Strength{
+		Patterns: []string{},
+		Feedback: []string{},
+	}
+
+	if passphrase == "" {
+		strength.Patterns = append(strength.Patterns, "empty")
+		strength.Feedback = append(strength.Feedback, "a passphrase is required")
+		return finish(strength)
+	}
+
+	lower := strings.ToLower(passphrase)
+	if commonPasswords[lower] || commonPasswords[strings.TrimRightFunc(lower, unicode.IsDigit)] || commonPasswords[leetReplacer.Replace(lower)] {
+		strength.Entropy = 8
+		strength.Patterns = append(strength.Patterns, "common password")
+		strength.Feedback = append(strength.Feedback, "this is one of the most used passwords")
+		return finish(strength)
+	}
+
+	runes := []rune(passphrase)
+	lowerRunes := []rune(lower)
+	charBits := math.Log2(float64(poolSize(runes)))
+	_, dictionary := wordlist()
+
+	seen := map[string]bool{}
+	addPattern := func(pattern, feedback string) {
+		if !seen[pattern] {
+			seen[pattern] = true
+			strength.Patterns = append(strength.Patterns, pattern)
+			strength.Feedback = append(strength.Feedback, feedback)
+		}
+	}
+
+	var entropy float64
+	var words int
+	separators := map[rune]int{}
+
+	for i := 0; i < len(runes); {
+		if n := matchWord(lowerRunes[i:], dictionary); n > 0 {
+			entropy += math.Log2(float64(len(dictionary)))
+			if unicode.IsUpper(runes[i]) {
+				entropy++
+			}
+			words++
+			addPattern("dictionary word", "dictionary words are guessed quickly unless you use many of them")
+			i += n
+			continue
+		}
+		if n := matchKeyboard(lowerRunes[i:]); n > 0 {
+			entropy += math.Log2(float64(len(keyboardRows)*20)) + math.Log2(float64(n))
+			addPattern("keyboard pattern", "avoid keyboard walks like qwerty or asdf")
+			i += n
+			continue
+		}
+		if n := matchSequence(lowerRunes[i:]); n > 0 {
+			entropy += math.Log2(52) + math.Log2(float64(n))
+			addPattern("sequence", "avoid sequences like abc or 123")
+			i += n
+			continue
+		}
+		if n := matchRepeat(runes[i:]); n > 0 {
+			entropy += charBits + math.Log2(float64(n))
+			addPattern("repeat", "avoid repeated characters")
+			i += n
+			continue
+		}
+		if n := matchYear(runes[i:]); n > 0 {
+			entropy += math.Log2(200)
+			addPattern("year", "avoid years and dates that can be associated with you")
+			i += n
+			continue
+		}
+
+		if !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i]) {
+			separators[runes[i]]++
+		}
+		entropy += charBits
+		i++
+	}
+
+	// words joined by one repeated separator only add the choice of the
+	// separator once, not once per occurrence
+	if words >= 2 && len(separators) == 1 {
+		for _, count := range separators {
+			entropy -= float64(count-1) * charBits
+		}
+	}
+
+	if len(runes) < 12 && words < 4 {
+		strength.Feedback = append(strength.Feedback, "use at least 12 characters or 4 random words")
+	}
+
+	strength.Entropy = math.Round(entropy*10) / 10
+	return finish(strength)
+}
+
+func finish(strength Strength) Strength {
+	switch {
+	case strength.Entropy < 28:
+		strength.Score = 0
+	case strength.Entropy < 36:
+		strength.Score = 1
+	case strength.Entropy < 60:
+		strength.Score = 2
+	case strength.Entropy < 80:
+		strength.Score = 3
+	default:
+		strength.Score = 4
+	}
+	strength.Label = strengthLabels[strength.Score]
+	return strength
+}
+
+func poolSize(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < 128:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if symbol {
+		size += 33
+	}
+	if other {
+		size += 100
+	}
+	return size
+}
+
+// matchWord returns the length of the longest dictionary word (at least 4
+// letters) at the start of s
+func matchWord(s []rune, dictionary map[string]bool) int {
+	longest := 0
+	for n := 4; n <= len(s) && n <= 9; n++ {
+		candidate := string(s[:n])
+		if dictionary[candidate] || dictionary[leetReplacer.Replace(candidate)] {
+			longest = n
+		}
+	}
+	return longest
+}
+
+// matchKeyboard returns the length of a keyboard walk (at least 4 keys) at
+// the start of s, in either direction
+func matchKeyboard(s []rune) int {
+	longest := 0
+	for _, row := range keyboardRows {
+		reversed := reverse(row)
+		for n := 4; n <= len(s); n++ {
+			candidate := string(s[:n])
+			if strings.Contains(row, candidate) || strings.Contains(reversed, candidate) {
+				longest = max(longest, n)
+			} else {
+				break
+			}
+		}
+	}
+	return longest
+}
+
+// matchSequence returns the length of an ascending or descending run of
+// letters or digits (at least 3) at the start of s
+func matchSequence(s []rune) int {
+	if len(s) < 3 {
+		return 0
+	}
+
+	step := s[1] - s[0]
+	if step != 1 && step != -1 {
+		return 0
+	}
+	if !(unicode.IsLetter(s[0]) || unicode.IsDigit(s[0])) {
+		return 0
+	}
+
+	n := 2
+	for n < len(s) && s[n]-s[n-1] == step {
+		n++
+	}
+	if n < 3 {
+		return 0
+	}
+	return n
+}
+
+// matchRepeat returns the length of a run of the same character (at least 3)
+func matchRepeat(s []rune) int {
+	n := 1
+	for n < len(s) && s[n] == s[0] {
+		n++
+	}
+	if n < 3 {
+		return 0
+	}
+	return n
+}
+
+// matchYear matches 19xx and 20xx
+func matchYear(s []rune) int {
+	if len(s) < 4 {
+		return 0
+	}
+	year := string(s[:4])
+	if (strings.HasPrefix(year, "19") || strings.HasPrefix(year, "20")) && isDigits(year) {
+		return 4
+	}
+	return 0
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
@@ -1,6 +1,8 @@
 package pgpdec
 
 import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/filesystem/audit"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -16,21 +18,25 @@ func (pgpdec *PgpDec) DecryptPgpPrivKey(passphrase string, keyPath string) (stri
 
 	encryptedPrivKeyArmor, err := os.ReadFile(privKeyPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to read private key file: %v", err)
+		return "", fmt.Errorf("failed to read private key file: %w: %w", apperr.ErrKeyNotFound, err)
 	}
 
 	// Load the armored key into a crypto.Key object
 	encryptedKeyObj, err := crypto.NewKeyFromArmored(string(encryptedPrivKeyArmor))
 	if err != nil {
-		return "", fmt.Errorf("failed to parse armored private key: %v", err)
+		return "", fmt.Errorf("failed to parse armored private key: %w: %v", apperr.ErrInvalidKey, err)
 	}
 
 	// Unlock the key using the passphrase
 	unlockedKeyObj, err := encryptedKeyObj.Unlock([]byte(passphrase))
+	audit.Record(audit.OpKeyUnlock, filepath.Base(keyPath), encryptedKeyObj.GetFingerprint(), err)
 	if err != nil {
-		return "", fmt.Errorf("failed to unlock private key %v", err)
+		return "", fmt.Errorf("failed to unlock private key: %w: %v", apperr.ErrWrongPassphrase, err)
 	}
 
+	// while the key is unlocked use it to sign pending audit entries
+	audit.SignIfDue(unlockedKeyObj)
+
 	// Optionally, you can re-armor the unlocked key if you need the decrypted armored key
 	decryptedPrivKeyArmor, err := unlockedKeyObj.Armor()
 	if err != nil {
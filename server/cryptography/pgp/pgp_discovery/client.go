@@ -0,0 +1,280 @@
+package pgpdiscovery
+
+import (
+	"MindLockr/server/apperr"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// DefaultKeyserver is queried when no keyserver is given
+const DefaultKeyserver = "https://keys.openpgp.org"
+
+// sources of a discovered key
+const (
+	SourceWKD = "wkd"
+	SourceHKP = "hkp"
+)
+
+const (
+	requestTimeout = 15 * time.Second
+	// maxResponseSize bounds the keys a server can make us parse
+	maxResponseSize = 1 << 20
+)
+
+// Client looks up public keys through the Web Key Directory of the
+// address' domain and through HKP keyservers
+type Client struct {
+	HTTP       *http.Client
+	Keyservers []string
+}
+
+func NewClient() *Client {
+	return &Client{
+		HTTP:       &http.Client{Timeout: requestTimeout},
+		Keyservers: []string{DefaultKeyserver},
+	}
+}
+
+// Discovered is a key returned by a lookup with where it came from
+type Discovered struct {
+	Key    *crypto.Key
+	Source string
+	// Server is the keyserver or WKD host that returned the key
+	Server string
+}
+
+// Lookup returns the keys of email, from WKD when the domain publishes
+// one and from the keyservers otherwise
+func (c *Client) Lookup(ctx context.Context, email string) ([]Discovered, error) {
+	address, err := normalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	found, wkdErr := c.WKD(ctx, address)
+	if len(found) > 0 {
+		return found, nil
+	}
+
+	var errs []string
+	if wkdErr != nil {
+		errs = append(errs, wkdErr.Error())
+	}
+	for _, keyserver := range c.Keyservers {
+		keys, err := c.HKP(ctx, keyserver, address)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		keys = withEmail(keys, address)
+		if len(keys) > 0 {
+			return keys, nil
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w: no key found for %s (%s)", apperr.ErrKeyNotFound, address, strings.Join(errs, "; "))
+	}
+	return nil, fmt.Errorf("%w: no key found for %s", apperr.ErrKeyNotFound, address)
+}
+
+// WKD queries the advanced and then the direct Web Key Directory of the
+// address' domain. Only keys with a user ID for the address are returned
+func (c *Client) WKD(ctx context.Context, email string) ([]Discovered, error) {
+	address, err := normalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []string
+	for _, u := range wkdURLs(address) {
+		body, err := c.get(ctx, u)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		keys, err := parseKeys(body)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", u, err))
+			continue
+		}
+
+		host := ""
+		if parsed, err := url.Parse(u); err == nil {
+			host = parsed.Host
+		}
+		found := withEmail(discovered(keys, SourceWKD, host), address)
+		if len(found) > 0 {
+			return found, nil
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("wkd lookup failed: %s", strings.Join(errs, "; "))
+	}
+	return nil, nil
+}
+
+// HKP searches a keyserver, search is an email or a 0x prefixed key ID or
+// fingerprint
+func (c *Client) HKP(ctx context.Context, keyserver, search string) ([]Discovered, error) {
+	base, err := url.Parse(strings.TrimRight(keyserver, "/"))
+	if err != nil || (base.Scheme != "https" && base.Scheme != "http") || base.Host == "" {
+		return nil, fmt.Errorf("%w: invalid keyserver %q", apperr.ErrInvalidInput, keyserver)
+	}
+
+	query := url.Values{}
+	query.Set("op", "get")
+	query.Set("options", "mr")
+	query.Set("search", search)
+	base.Path += "/pks/lookup"
+	base.RawQuery = query.Encode()
+
+	body, err := c.get(ctx, base.String())
+	if err != nil {
+		return nil, err
+	}
+	keys, err := parseKeys(body)
+	if err != nil {
+		return nil, fmt.Errorf("%s returned an invalid key: %w", base.Host, err)
+	}
+	return discovered(keys, SourceHKP, base.Host), nil
+}
+
+func (c *Client) get(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s failed: %v", req.URL.Host, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s has no key", apperr.ErrKeyNotFound, req.URL.Host)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answered %s", req.URL.Host, res.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the answer of %s: %v", req.URL.Host, err)
+	}
+	if len(body) > maxResponseSize {
+		return nil, fmt.Errorf("the answer of %s is too large", req.URL.Host)
+	}
+	return body, nil
+}
+
+// parseKeys reads armored or binary public keys, private key material is
+// never accepted from a server
+func parseKeys(body []byte) ([]*crypto.Key, error) {
+	var entities openpgp.EntityList
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("-----BEGIN PGP")) {
+		entities, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(body))
+	} else {
+		entities, err = openpgp.ReadKeyRing(bytes.NewReader(body))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", apperr.ErrInvalidKey, err)
+	}
+
+	keys := make([]*crypto.Key, 0, len(entities))
+	for _, entity := range entities {
+		if entity.PrivateKey != nil {
+			return nil, fmt.Errorf("%w: the answer contains a private key", apperr.ErrInvalidKey)
+		}
+		key, err := crypto.NewKeyFromEntity(entity)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", apperr.ErrInvalidKey, err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func discovered(keys []*crypto.Key, source, server string) []Discovered {
+	found := make([]Discovered, 0, len(keys))
+	for _, key := range keys {
+		found = append(found, Discovered{Key: key, Source: source, Server: server})
+	}
+	return found
+}
+
+// withEmail keeps the keys that have a user ID for email
+func withEmail(found []Discovered, email string) []Discovered {
+	var kept []Discovered
+	for _, d := range found {
+		for _, identity := range d.Key.GetEntity().Identities {
+			if strings.EqualFold(identity.UserId.Email, email) {
+				kept = append(kept, d)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+func normalizeEmail(email string) (string, error) {
+	parsed, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid email address %q", apperr.ErrInvalidInput, email)
+	}
+	return parsed.Address, nil
+}
+
+// wkdURLs returns the advanced and direct method URLs of an address
+func wkdURLs(email string) []string {
+	at := strings.LastIndex(email, "@")
+	local, domain := email[:at], strings.ToLower(email[at+1:])
+
+	hash := WKDHash(local)
+	query := "?l=" + url.QueryEscape(local)
+
+	return []string{
+		fmt.Sprintf("https://openpgpkey.%s/.well-known/openpgpkey/%s/hu/%s%s", domain, domain, hash, query),
+		fmt.Sprintf("https://%s/.well-known/openpgpkey/hu/%s%s", domain, hash, query),
+	}
+}
+
+// WKDHash is the name of the key of a local part in a Web Key Directory
+func WKDHash(local string) string {
+	digest := sha1.Sum([]byte(strings.ToLower(local)))
+	return zbase32(digest[:])
+}
+
+const zbase32Alphabet = "ybndrfg8ejkmcpqxot1uwisza345h769"
+
+// zbase32 encodes data as in RFC 6189, as WKD requires for the local part
+func zbase32(data []byte) string {
+	var out strings.Builder
+	var buffer, bits uint
+	for _, b := range data {
+		buffer = buffer<<8 | uint(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out.WriteByte(zbase32Alphabet[(buffer>>bits)&31])
+		}
+	}
+	if bits > 0 {
+		out.WriteByte(zbase32Alphabet[(buffer<<(5-bits))&31])
+	}
+	return out.String()
+}
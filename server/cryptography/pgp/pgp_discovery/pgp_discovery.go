@@ -0,0 +1,255 @@
+package pgpdiscovery
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/cryptohelper"
+	pgptrust "MindLockr/server/cryptography/pgp/pgp_trust"
+	"MindLockr/server/filesystem/audit"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+type (
+	PgpDiscovery struct {
+		client *Client
+
+		mu sync.Mutex
+		// found holds the keys of the last lookups by fingerprint, only
+		// those can be imported
+		found map[string]*crypto.Key
+	}
+
+	LookupRequest struct {
+		Email string `json:"email"`
+		// Keyserver is queried instead of the default keyservers
+		Keyserver string `json:"keyserver,omitempty"`
+	}
+
+	FoundKey struct {
+		Fingerprint string             `json:"fingerprint"`
+		KeyID       string             `json:"keyId"`
+		UserIDs     []pgpfs.UserIDInfo `json:"userIds"`
+		CreatedAt   time.Time          `json:"createdAt"`
+		Revoked     bool               `json:"revoked"`
+		Expired     bool               `json:"expired"`
+		Source      string             `json:"source"`
+		Server      string             `json:"server"`
+		// KeyName is the vault key that already holds this key
+		KeyName string `json:"keyName,omitempty"`
+	}
+
+	ImportFoundRequest struct {
+		KeyName string `json:"keyName"`
+		// Fingerprint is the fingerprint the user confirmed
+		Fingerprint string `json:"fingerprint"`
+	}
+
+	RefreshResult struct {
+		KeyName     string `json:"keyName"`
+		Fingerprint string `json:"fingerprint"`
+		Updated     bool   `json:"updated"`
+		Revoked     bool   `json:"revoked"`
+		Expired     bool   `json:"expired"`
+		Error       string `json:"error,omitempty"`
+	}
+)
+
+func NewPgpDiscovery(client *Client) *PgpDiscovery {
+	return &PgpDiscovery{
+		client: client,
+		found:  make(map[string]*crypto.Key),
+	}
+}
+
+// LookupKey finds the keys of an email address. Nothing is stored, the
+// user picks one of the results and imports it with ImportFoundKey
+func (pd *PgpDiscovery) LookupKey(req LookupRequest) ([]FoundKey, error) {
+	client := pd.client
+	if req.Keyserver != "" {
+		client = &Client{HTTP: pd.client.HTTP, Keyservers: []string{req.Keyserver}}
+	}
+
+	discoveredKeys, err := client.Lookup(context.Background(), req.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	vaultKeys, _ := pgpfs.VaultKeys()
+
+	pd.mu.Lock()
+	defer pd.mu.Unlock()
+
+	found := make([]FoundKey, 0, len(discoveredKeys))
+	for _, d := range discoveredKeys {
+		fingerprint := strings.ToUpper(d.Key.GetFingerprint())
+		pd.found[fingerprint] = d.Key
+
+		now := time.Now().Unix()
+		info := FoundKey{
+			Fingerprint: fingerprint,
+			KeyID:       strings.ToUpper(d.Key.GetHexKeyID()),
+			UserIDs:     pgpfs.DescribeUserIDs(d.Key.GetEntity()),
+			CreatedAt:   d.Key.GetEntity().PrimaryKey.CreationTime,
+			Revoked:     d.Key.IsRevoked(now),
+			Expired:     d.Key.IsExpired(now),
+			Source:      d.Source,
+			Server:      d.Server,
+		}
+		if stored := findVaultKey(vaultKeys, fingerprint); stored != nil {
+			info.KeyName = stored.Name
+		}
+		found = append(found, info)
+	}
+	return found, nil
+}
+
+// ImportFoundKey stores a key returned by LookupKey. When the key is
+// already stored the new copy is merged into it
+func (pd *PgpDiscovery) ImportFoundKey(req ImportFoundRequest) (string, error) {
+	fingerprint := strings.ToUpper(strings.ReplaceAll(req.Fingerprint, " ", ""))
+
+	pd.mu.Lock()
+	key, ok := pd.found[fingerprint]
+	pd.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("%w: look the key up before importing it", apperr.ErrKeyNotFound)
+	}
+
+	vaultKeys, err := pgpfs.VaultKeys()
+	if err != nil {
+		return "", err
+	}
+	if stored := findVaultKey(vaultKeys, fingerprint); stored != nil {
+		if stored.Name != req.KeyName {
+			return "", fmt.Errorf("%w: this key is already stored as %s", apperr.ErrInvalidInput, stored.Name)
+		}
+		if key, err = cryptohelper.MergeKeys(stored.PublicKey, key); err != nil {
+			return "", err
+		}
+	}
+
+	armored, err := key.GetArmoredPublicKey()
+	if err != nil {
+		return "", fmt.Errorf("failed while extracting armored public key: %s", err)
+	}
+	return (&pgptrust.PgpTrust{}).ImportPublicKey(pgptrust.ImportRequest{
+		KeyName:   req.KeyName,
+		PublicKey: armored,
+	})
+}
+
+// RefreshKeys fetches a new copy of every public key in the vault that is
+// not one of ours, so revocations and new expiry dates reach us
+func (pd *PgpDiscovery) RefreshKeys() ([]RefreshResult, error) {
+	vaultKeys, err := pgpfs.VaultKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	results := []RefreshResult{}
+	for _, stored := range vaultKeys {
+		if stored.HasPrivate {
+			continue
+		}
+		results = append(results, pd.refreshKey(stored))
+	}
+	return results, nil
+}
+
+func (pd *PgpDiscovery) refreshKey(stored pgpfs.VaultKey) RefreshResult {
+	fingerprint := strings.ToUpper(stored.PublicKey.GetFingerprint())
+	result := RefreshResult{KeyName: stored.Name, Fingerprint: fingerprint}
+
+	fetched := pd.fetchByFingerprint(stored)
+	merged := stored.PublicKey
+	for _, key := range fetched {
+		next, err := cryptohelper.MergeKeys(merged, key)
+		if err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		merged = next
+	}
+
+	now := time.Now().Unix()
+	result.Revoked = merged.IsRevoked(now)
+	result.Expired = merged.IsExpired(now)
+	if len(fetched) == 0 {
+		result.Error = "no keyserver or key directory has this key"
+		return result
+	}
+
+	before, err := stored.PublicKey.GetPublicKey()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	after, err := merged.GetPublicKey()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if bytes.Equal(before, after) {
+		return result
+	}
+
+	armored, err := merged.GetArmoredPublicKey()
+	if err == nil {
+		err = pgpfs.SavePgpPublicKey(armored, stored.Name)
+	}
+	audit.Record(audit.OpKeyRefresh, stored.Name, fingerprint, err)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to save the refreshed key: %s", err)
+		return result
+	}
+	result.Updated = true
+	return result
+}
+
+// fetchByFingerprint asks the keyservers by fingerprint and the key
+// directories of the key's addresses, keeping the copies of this key only
+func (pd *PgpDiscovery) fetchByFingerprint(stored pgpfs.VaultKey) []*crypto.Key {
+	fingerprint := stored.PublicKey.GetFingerprint()
+	ctx := context.Background()
+
+	var candidates []Discovered
+	for _, keyserver := range pd.client.Keyservers {
+		found, err := pd.client.HKP(ctx, keyserver, "0x"+strings.ToUpper(fingerprint))
+		if err == nil {
+			candidates = append(candidates, found...)
+		}
+	}
+	for _, identity := range stored.PublicKey.GetEntity().Identities {
+		if identity.UserId.Email == "" {
+			continue
+		}
+		found, err := pd.client.WKD(ctx, identity.UserId.Email)
+		if err == nil {
+			candidates = append(candidates, found...)
+		}
+	}
+
+	var keys []*crypto.Key
+	for _, c := range candidates {
+		if c.Key.GetFingerprint() == fingerprint {
+			keys = append(keys, c.Key)
+		}
+	}
+	return keys
+}
+
+func findVaultKey(vaultKeys []pgpfs.VaultKey, fingerprint string) *pgpfs.VaultKey {
+	for i, k := range vaultKeys {
+		if strings.EqualFold(k.PublicKey.GetFingerprint(), fingerprint) {
+			return &vaultKeys[i]
+		}
+	}
+	return nil
+}
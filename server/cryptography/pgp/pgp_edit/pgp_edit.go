@@ -0,0 +1,100 @@
+package pgpedit
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/cryptohelper"
+	"MindLockr/server/filesystem"
+	"MindLockr/server/filesystem/audit"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/ProtonMail/gopenpgp/v3/profile"
+)
+
+// PgpEdit changes keys that are already stored in pgp-keys/. Every change
+// needs the passphrase of the private key, the key is locked again with the
+// same passphrase before both halves are written back
+type PgpEdit struct{}
+
+// ExportPublicKey returns the current armored public key of a stored key,
+// including every subkey, user ID and revocation added since it was generated
+func (pe *PgpEdit) ExportPublicKey(keyName string) (string, error) {
+	keyDir, err := keyFolder(keyName)
+	if err != nil {
+		return "", err
+	}
+
+	pubKeyArmor, err := os.ReadFile(filepath.Join(keyDir, "public.asc"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read public key file: %v", err)
+	}
+	return string(pubKeyArmor), nil
+}
+
+// editKey unlocks a stored key, applies edit to it and stores the result
+func editKey(keyName, passphrase string, edit func(key *crypto.Key) error) (err error) {
+	keyDir, err := keyFolder(keyName)
+	if err != nil {
+		return err
+	}
+
+	privKeyArmor, err := os.ReadFile(filepath.Join(keyDir, "private.asc"))
+	if err != nil {
+		return fmt.Errorf("failed to read private key file: %v", err)
+	}
+
+	key, err := cryptohelper.UnlockPrivateKey(string(privKeyArmor), []byte(passphrase))
+	if err != nil {
+		return fmt.Errorf("failed to unlock private key: %w", err)
+	}
+	defer key.ClearPrivateParams()
+	defer func() {
+		audit.Record(audit.OpKeyEdit, keyName, key.GetFingerprint(), err)
+	}()
+
+	if err := edit(key); err != nil {
+		return err
+	}
+
+	pubKey, err := key.GetArmoredPublicKey()
+	if err != nil {
+		return fmt.Errorf("failed while extracting armored public key: %s", err)
+	}
+
+	// lock with the profile the key was generated with
+	pgp := crypto.PGPWithProfile(profile.RFC4880())
+	if key.GetVersion() == 6 {
+		pgp = crypto.PGPWithProfile(profile.RFC9580())
+	}
+	lockedKey, err := pgp.LockKey(key, []byte(passphrase))
+	if err != nil {
+		return fmt.Errorf("error when locking the private key: %s", err)
+	}
+
+	privKey, err := lockedKey.Armor()
+	if err != nil {
+		return fmt.Errorf("failed while extracting armored private key: %s", err)
+	}
+
+	if err := pgpfs.SavePgpPrivKey(privKey, keyName); err != nil {
+		return fmt.Errorf("failed to save private key: %v", err)
+	}
+	if err := pgpfs.SavePgpPublicKey(pubKey, keyName); err != nil {
+		return fmt.Errorf("failed to save public key: %v", err)
+	}
+	return nil
+}
+
+func keyFolder(keyName string) (string, error) {
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+	if folderPath == "" {
+		return "", apperr.ErrNoVaultFolder
+	}
+	if keyName == "" || filepath.Base(keyName) != keyName {
+		return "", fmt.Errorf("invalid key name: %q", keyName)
+	}
+	return filepath.Join(folderPath, "pgp-keys", keyName), nil
+}
@@ -0,0 +1,246 @@
+package pgpedit
+
+import (
+	"MindLockr/server/apperr"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	gocrypto "crypto"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// MaxExpiryDays bounds subkey expiries. OpenPGP stores the lifetime of a
+// key in 32 bit seconds since its creation, which ends after about 136 years
+const MaxExpiryDays = 100 * 365
+
+type (
+	AddSubkeyRequest struct {
+		KeyName    string `json:"keyName"`
+		Passphrase string `json:"passphrase"`
+		// Usage is one of sign, encrypt or authenticate
+		Usage string `json:"usage"`
+		// ExpiryDays of 0 creates a subkey that does not expire
+		ExpiryDays int `json:"expiryDays"`
+	}
+
+	SubkeyExpiryRequest struct {
+		KeyName     string `json:"keyName"`
+		Passphrase  string `json:"passphrase"`
+		Fingerprint string `json:"fingerprint"`
+		// ExpiryDays counts from today, 0 removes the expiry
+		ExpiryDays int `json:"expiryDays"`
+	}
+
+	RevokeSubkeyRequest struct {
+		KeyName     string `json:"keyName"`
+		Passphrase  string `json:"passphrase"`
+		Fingerprint string `json:"fingerprint"`
+		// Reason is one of compromised, superseded or retired, anything
+		// else revokes without a reason
+		Reason      string `json:"reason"`
+		Description string `json:"description,omitempty"`
+	}
+)
+
+// AddSubkey adds a new signing, encryption or authentication subkey of the
+// same algorithm as the primary key
+func (pe *PgpEdit) AddSubkey(req AddSubkeyRequest) ([]pgpfs.SubkeyInfo, error) {
+	if err := checkExpiryDays(req.ExpiryDays); err != nil {
+		return nil, err
+	}
+
+	var subkeys []pgpfs.SubkeyInfo
+	err := editKey(req.KeyName, req.Passphrase, func(key *crypto.Key) error {
+		entity := key.GetEntity()
+		config, err := keyConfig(entity.PrimaryKey)
+		if err != nil {
+			return err
+		}
+		config.KeyLifetimeSecs = uint32(int64(req.ExpiryDays) * 24 * 60 * 60)
+
+		switch req.Usage {
+		case pgpfs.UsageEncrypt:
+			err = entity.AddEncryptionSubkey(config)
+		case pgpfs.UsageSign:
+			err = entity.AddSigningSubkey(config)
+		case pgpfs.UsageAuthenticate:
+			err = addAuthenticationSubkey(entity, config)
+		default:
+			return fmt.Errorf("unsupported subkey usage: %q", req.Usage)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to generate %s subkey: %s", req.Usage, err)
+		}
+
+		subkeys = pgpfs.DescribeSubkeys(entity)
+		return nil
+	})
+	return subkeys, err
+}
+
+// SetSubkeyExpiry replaces the binding signature of a subkey with one that
+// carries the new expiry
+func (pe *PgpEdit) SetSubkeyExpiry(req SubkeyExpiryRequest) ([]pgpfs.SubkeyInfo, error) {
+	if err := checkExpiryDays(req.ExpiryDays); err != nil {
+		return nil, err
+	}
+
+	var subkeys []pgpfs.SubkeyInfo
+	err := editKey(req.KeyName, req.Passphrase, func(key *crypto.Key) error {
+		entity := key.GetEntity()
+		sub, err := findSubkey(entity, req.Fingerprint)
+		if err != nil {
+			return err
+		}
+		config, err := keyConfig(entity.PrimaryKey)
+		if err != nil {
+			return err
+		}
+
+		sig, err := sub.LatestValidBindingSignature(time.Time{}, config)
+		if err != nil {
+			return fmt.Errorf("subkey has no valid binding signature: %s", err)
+		}
+
+		now := config.Now()
+		var lifetime uint32
+		if req.ExpiryDays > 0 {
+			// the lifetime counts from the creation of the subkey, which
+			// may be long ago
+			expiresAt := now.AddDate(0, 0, req.ExpiryDays)
+			secs := int64(expiresAt.Sub(sub.PublicKey.CreationTime) / time.Second)
+			if secs <= 0 || secs > math.MaxUint32 {
+				return fmt.Errorf("%w: the expiry is too far from the creation of the subkey", apperr.ErrInvalidInput)
+			}
+			lifetime = uint32(secs)
+		}
+		sig.KeyLifetimeSecs = &lifetime
+		sig.CreationTime = now
+
+		if err := sig.SignKey(sub.PublicKey, entity.PrivateKey, config); err != nil {
+			return fmt.Errorf("failed to sign subkey binding: %s", err)
+		}
+		if sig.EmbeddedSignature != nil {
+			sig.EmbeddedSignature.CreationTime = now
+			if err := sig.EmbeddedSignature.CrossSignKey(sub.PublicKey, entity.PrimaryKey, sub.PrivateKey, config); err != nil {
+				return fmt.Errorf("failed to cross sign subkey: %s", err)
+			}
+		}
+
+		subkeys = pgpfs.DescribeSubkeys(entity)
+		return nil
+	})
+	return subkeys, err
+}
+
+func checkExpiryDays(days int) error {
+	if days < 0 {
+		return fmt.Errorf("%w: expiry must not be negative", apperr.ErrInvalidInput)
+	}
+	if days > MaxExpiryDays {
+		return fmt.Errorf("%w: expiry must be at most %d days", apperr.ErrInvalidInput, MaxExpiryDays)
+	}
+	return nil
+}
+
+// RevokeSubkey adds a revocation signature for a subkey, e.g. after it was
+// compromised. The subkey stays in the key so that peers learn about the
+// revocation with the next import of the public key
+func (pe *PgpEdit) RevokeSubkey(req RevokeSubkeyRequest) ([]pgpfs.SubkeyInfo, error) {
+	var subkeys []pgpfs.SubkeyInfo
+	err := editKey(req.KeyName, req.Passphrase, func(key *crypto.Key) error {
+		entity := key.GetEntity()
+		sub, err := findSubkey(entity, req.Fingerprint)
+		if err != nil {
+			return err
+		}
+		config, err := keyConfig(entity.PrimaryKey)
+		if err != nil {
+			return err
+		}
+
+		if err := sub.Revoke(revocationReason(req.Reason), req.Description, config); err != nil {
+			return fmt.Errorf("failed to revoke subkey: %s", err)
+		}
+
+		subkeys = pgpfs.DescribeSubkeys(entity)
+		return nil
+	})
+	return subkeys, err
+}
+
+// addAuthenticationSubkey generates a signing capable key and binds it with
+// only the authentication flag, go-crypto has no helper for it
+func addAuthenticationSubkey(entity *openpgp.Entity, config *packet.Config) error {
+	if err := entity.AddSigningSubkey(config); err != nil {
+		return err
+	}
+
+	sub := &entity.Subkeys[len(entity.Subkeys)-1]
+	sig := sub.Bindings[0].Packet
+	sig.FlagSign = false
+	sig.FlagAuthenticate = true
+	// the primary key binding is only required for signing subkeys
+	sig.EmbeddedSignature = nil
+
+	return sig.SignKey(sub.PublicKey, entity.PrivateKey, config)
+}
+
+// keyConfig returns a config generating subkeys of the primary key's
+// algorithm and key version
+func keyConfig(primary *packet.PublicKey) (*packet.Config, error) {
+	config := sigConfig(primary)
+	config.Algorithm = primary.PubKeyAlgo
+	config.V6Keys = primary.Version == 6
+
+	switch primary.PubKeyAlgo {
+	case packet.PubKeyAlgoRSA:
+		bits, err := primary.BitLength()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read RSA key size: %s", err)
+		}
+		config.RSABits = int(bits)
+	case packet.PubKeyAlgoEdDSA:
+		config.Curve = packet.Curve25519
+	case packet.PubKeyAlgoEd25519, packet.PubKeyAlgoEd448:
+	default:
+		return nil, fmt.Errorf("adding subkeys is not supported for this key algorithm")
+	}
+	return config, nil
+}
+
+// sigConfig returns a config for self-signatures made by the primary key
+func sigConfig(primary *packet.PublicKey) *packet.Config {
+	config := &packet.Config{}
+	if primary.PubKeyAlgo == packet.PubKeyAlgoEd448 {
+		config.DefaultHash = gocrypto.SHA512
+	}
+	return config
+}
+
+func findSubkey(entity *openpgp.Entity, fingerprint string) (*openpgp.Subkey, error) {
+	for i := range entity.Subkeys {
+		sub := &entity.Subkeys[i]
+		if strings.EqualFold(fmt.Sprintf("%x", sub.PublicKey.Fingerprint), fingerprint) {
+			return sub, nil
+		}
+	}
+	return nil, fmt.Errorf("key has no subkey with fingerprint %s", fingerprint)
+}
+
+func revocationReason(reason string) packet.ReasonForRevocation {
+	switch reason {
+	case "compromised":
+		return packet.KeyCompromised
+	case "superseded":
+		return packet.KeySuperseded
+	case "retired":
+		return packet.KeyRetired
+	}
+	return packet.NoReason
+}
@@ -0,0 +1,182 @@
+package pgpedit
+
+import (
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"fmt"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+type (
+	AddUserIDRequest struct {
+		KeyName    string `json:"keyName"`
+		Passphrase string `json:"passphrase"`
+		Name       string `json:"name"`
+		Email      string `json:"email"`
+		Comment    string `json:"comment,omitempty"`
+		// Primary makes the new user ID the primary one
+		Primary bool `json:"primary"`
+	}
+
+	// UserIDRequest identifies a user ID by its full string, e.g.
+	// "Jane Doe <jane@example.com>"
+	UserIDRequest struct {
+		KeyName    string `json:"keyName"`
+		Passphrase string `json:"passphrase"`
+		UserID     string `json:"userId"`
+	}
+
+	RevokeUserIDRequest struct {
+		UserIDRequest
+		// Reason is superseded or invalid (e.g. the address was given
+		// up), anything else revokes without a reason
+		Reason      string `json:"reason"`
+		Description string `json:"description,omitempty"`
+	}
+)
+
+// AddUserID adds and self-signs a new user ID, e.g. a second email address
+func (pe *PgpEdit) AddUserID(req AddUserIDRequest) ([]pgpfs.UserIDInfo, error) {
+	if req.Name == "" && req.Email == "" {
+		return nil, fmt.Errorf("a user ID needs a name or an email")
+	}
+
+	var userIDs []pgpfs.UserIDInfo
+	err := editKey(req.KeyName, req.Passphrase, func(key *crypto.Key) error {
+		entity := key.GetEntity()
+		config := sigConfig(entity.PrimaryKey)
+		config.V6Keys = entity.PrimaryKey.Version == 6
+
+		if err := entity.AddUserId(req.Name, req.Comment, req.Email, config); err != nil {
+			return fmt.Errorf("failed to add user ID: %s", err)
+		}
+
+		if req.Primary {
+			uid := packet.NewUserId(req.Name, req.Comment, req.Email)
+			if err := setPrimaryUserID(entity, uid.Id); err != nil {
+				return err
+			}
+		}
+
+		userIDs = pgpfs.DescribeUserIDs(entity)
+		return nil
+	})
+	return userIDs, err
+}
+
+// RevokeUserID adds a certification revocation to a user ID. The last valid
+// user ID of a key cannot be revoked
+func (pe *PgpEdit) RevokeUserID(req RevokeUserIDRequest) ([]pgpfs.UserIDInfo, error) {
+	var userIDs []pgpfs.UserIDInfo
+	err := editKey(req.KeyName, req.Passphrase, func(key *crypto.Key) error {
+		entity := key.GetEntity()
+		ident, ok := entity.Identities[req.UserID]
+		if !ok {
+			return fmt.Errorf("key has no user ID %q", req.UserID)
+		}
+
+		if _, err := ident.Verify(time.Time{}, nil); err != nil {
+			return fmt.Errorf("user ID %q is already revoked or invalid", req.UserID)
+		}
+		valid := 0
+		for _, other := range entity.Identities {
+			if _, err := other.Verify(time.Time{}, nil); err == nil {
+				valid++
+			}
+		}
+		if valid < 2 {
+			return fmt.Errorf("cannot revoke the only valid user ID of a key")
+		}
+
+		config := sigConfig(entity.PrimaryKey)
+		reason := userIDRevocationReason(req.Reason)
+		revSig := newSelfSignature(entity.PrimaryKey, packet.SigTypeCertificationRevocation, config)
+		revSig.RevocationReason = &reason
+		revSig.RevocationReasonText = req.Description
+
+		if err := revSig.SignUserId(ident.Name, entity.PrimaryKey, entity.PrivateKey, config); err != nil {
+			return fmt.Errorf("failed to sign user ID revocation: %s", err)
+		}
+		sig := packet.NewVerifiableSig(revSig)
+		isValid := true
+		sig.Valid = &isValid
+		ident.Revocations = append(ident.Revocations, sig)
+
+		userIDs = pgpfs.DescribeUserIDs(entity)
+		return nil
+	})
+	return userIDs, err
+}
+
+// SetPrimaryUserID marks a user ID as the primary one, it is the one shown
+// and preferred by other OpenPGP implementations
+func (pe *PgpEdit) SetPrimaryUserID(req UserIDRequest) ([]pgpfs.UserIDInfo, error) {
+	var userIDs []pgpfs.UserIDInfo
+	err := editKey(req.KeyName, req.Passphrase, func(key *crypto.Key) error {
+		entity := key.GetEntity()
+		if err := setPrimaryUserID(entity, req.UserID); err != nil {
+			return err
+		}
+
+		userIDs = pgpfs.DescribeUserIDs(entity)
+		return nil
+	})
+	return userIDs, err
+}
+
+// setPrimaryUserID re-signs the self-signature of every valid user ID with
+// the primary flag set only on the chosen one
+func setPrimaryUserID(entity *openpgp.Entity, userID string) error {
+	target, ok := entity.Identities[userID]
+	if !ok {
+		return fmt.Errorf("key has no user ID %q", userID)
+	}
+	if _, err := target.Verify(time.Time{}, nil); err != nil {
+		return fmt.Errorf("user ID %q is revoked or invalid", userID)
+	}
+
+	config := sigConfig(entity.PrimaryKey)
+	now := config.Now()
+	for _, ident := range entity.Identities {
+		sig, err := ident.Verify(time.Time{}, config)
+		if err != nil {
+			continue
+		}
+
+		isPrimary := ident == target
+		sig.IsPrimaryId = &isPrimary
+		sig.CreationTime = now
+		if err := sig.SignUserId(ident.Name, entity.PrimaryKey, entity.PrivateKey, config); err != nil {
+			return fmt.Errorf("failed to sign user ID %q: %s", ident.Name, err)
+		}
+	}
+	return nil
+}
+
+// newSelfSignature mirrors the signature packets go-crypto creates for the
+// primary key itself
+func newSelfSignature(primary *packet.PublicKey, sigType packet.SignatureType, config *packet.Config) *packet.Signature {
+	return &packet.Signature{
+		Version:           primary.Version,
+		SigType:           sigType,
+		PubKeyAlgo:        primary.PubKeyAlgo,
+		Hash:              config.Hash(),
+		CreationTime:      config.Now(),
+		IssuerKeyId:       &primary.KeyId,
+		IssuerFingerprint: primary.Fingerprint,
+	}
+}
+
+// only these reasons are meaningful for user IDs (RFC 9580, 5.2.3.31)
+func userIDRevocationReason(reason string) packet.ReasonForRevocation {
+	switch reason {
+	case "superseded":
+		return packet.KeySuperseded
+	case "invalid":
+		return packet.UserIDNotValid
+	}
+	return packet.NoReason
+}
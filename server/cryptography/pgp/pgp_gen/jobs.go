@@ -0,0 +1,167 @@
+package pgpgen
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/passphrase"
+	"MindLockr/server/filesystem"
+	"MindLockr/server/filesystem/audit"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"MindLockr/server/jobs"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// JobKind is the kind of the jobs started by StartKeyGeneration
+const JobKind = "key-generation"
+
+// GeneratedKey is the result of one key of a generation job
+type GeneratedKey struct {
+	KeyName     string `json:"keyName"`
+	Fingerprint string `json:"fingerprint"`
+	PubKey      string `json:"pubKey"`
+}
+
+// StartKeyGeneration generates and stores a key pair for every request in
+// the background and returns the job right away. Every key is an item of
+// the job: the jobs item events tell when a key is started, finished or
+// failed, and GetJob has the generated keys once they are ready. A key
+// whose generation is cancelled is not stored
+func (pgpKeysGen *PgpKeysGen) StartKeyGeneration(reqs []RequestData) (jobs.Job, error) {
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+	if folderPath == "" {
+		return jobs.Job{}, apperr.ErrNoVaultFolder
+	}
+
+	byName := make(map[string]RequestData, len(reqs))
+	names := make([]string, 0, len(reqs))
+	for _, req := range reqs {
+		if err := checkRequest(req); err != nil {
+			return jobs.Job{}, err
+		}
+		if _, ok := byName[req.Usage]; ok {
+			return jobs.Job{}, fmt.Errorf("%w: the key name %s is used twice", apperr.ErrInvalidInput, req.Usage)
+		}
+		if keyExists(req.Usage) {
+			return jobs.Job{}, fmt.Errorf("%w: a key named %s already exists", apperr.ErrInvalidInput, req.Usage)
+		}
+		byName[req.Usage] = req
+		names = append(names, req.Usage)
+	}
+
+	return jobs.Start(JobKind, names, runtime.NumCPU(), func(ctx context.Context, name string) (string, any, error) {
+		key, err := pgpKeysGen.generateCancellable(ctx, byName[name])
+		if err != nil {
+			return "", nil, err
+		}
+		return key.Fingerprint, key, nil
+	})
+}
+
+// checkRequest validates a generation request before it is queued, so a
+// mistake fails right away instead of after the keys before it
+func checkRequest(req RequestData) error {
+	if req.Usage == "" || filepath.Base(req.Usage) != req.Usage || req.Usage == "." || req.Usage == ".." {
+		return fmt.Errorf("%w: invalid key name %q", apperr.ErrInvalidInput, req.Usage)
+	}
+	switch req.EnType {
+	case "ECC":
+		switch req.Curve {
+		case "curve25519", "curve25519-refresh", "curve448", "curve448-refresh":
+		default:
+			return fmt.Errorf("%w: unsupported curve type: %v", apperr.ErrInvalidInput, req.Curve)
+		}
+	case "RSA":
+		if req.Bits != 3072 && req.Bits != 4096 {
+			return fmt.Errorf("%w: RSA key generation requires a bit size of 3072 or 4096", apperr.ErrInvalidInput)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported encryption type: %v", apperr.ErrInvalidInput, req.EnType)
+	}
+	return passphrase.Enforce(req.Passphrase)
+}
+
+func keyExists(name string) bool {
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+	_, err := os.Stat(filepath.Join(folderPath, "pgp-keys", name))
+	return err == nil
+}
+
+// reserveKeyName creates and returns the folder of a new key. It is created
+// exclusively, so of two generations of the same name only one can store
+// its key
+func reserveKeyName(name string) (string, error) {
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+	if folderPath == "" {
+		return "", apperr.ErrNoVaultFolder
+	}
+
+	keysDir := filepath.Join(folderPath, "pgp-keys")
+	if err := os.MkdirAll(keysDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create keys directory: %w", err)
+	}
+	keyDir := filepath.Join(keysDir, name)
+	if err := os.Mkdir(keyDir, 0755); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return "", fmt.Errorf("%w: a key named %s already exists", apperr.ErrInvalidInput, name)
+		}
+		return "", fmt.Errorf("failed to create key directory: %w", err)
+	}
+	return keyDir, nil
+}
+
+// generateCancellable generates the key pair of req and stores it unless
+// ctx is cancelled first. gopenpgp cannot interrupt a generation, so a
+// cancelled generation finishes in the background and is thrown away
+func (pgpKeysGen *PgpKeysGen) generateCancellable(ctx context.Context, req RequestData) (key GeneratedKey, err error) {
+	type generated struct {
+		ret ReturnType
+		err error
+	}
+	done := make(chan generated, 1)
+	go func() {
+		var g generated
+		if req.EnType == "RSA" {
+			g.ret, g.err = pgpKeysGen.GenRSA(req)
+		} else {
+			g.ret, g.err = pgpKeysGen.GenECC(req)
+		}
+		done <- g
+	}()
+
+	var g generated
+	select {
+	case <-ctx.Done():
+		return GeneratedKey{}, ctx.Err()
+	case g = <-done:
+	}
+
+	defer func() {
+		audit.Record(audit.OpKeyGenerate, req.Usage, key.Fingerprint, err)
+	}()
+	if g.err != nil {
+		return GeneratedKey{}, g.err
+	}
+	keyDir, err := reserveKeyName(req.Usage)
+	if err != nil {
+		return GeneratedKey{}, err
+	}
+	if err := pgpfs.SavePgpPrivKey(g.ret.PrivKey, req.Usage); err != nil {
+		os.RemoveAll(keyDir)
+		return GeneratedKey{}, fmt.Errorf("failed to save private key: %w", err)
+	}
+	if err := pgpfs.SavePgpPublicKey(g.ret.PubKey, req.Usage); err != nil {
+		os.RemoveAll(keyDir)
+		return GeneratedKey{}, fmt.Errorf("failed to save public key: %w", err)
+	}
+
+	return GeneratedKey{
+		KeyName:     req.Usage,
+		Fingerprint: fingerprintOf(g.ret.PubKey),
+		PubKey:      g.ret.PubKey,
+	}, nil
+}
@@ -1,6 +1,8 @@
 package pgpgen
 
 import (
+	"MindLockr/server/cryptography/passphrase"
+	"MindLockr/server/filesystem/audit"
 	pgpfs "MindLockr/server/filesystem/pgp_fs"
 	"fmt"
 
@@ -47,7 +49,15 @@ func (pgpKeysGen *PgpKeysGen) GeneratePGPKeys(req RequestData) (ReturnType, erro
 }
 
 // generate with storing
-func (pgpKeysGen *PgpKeysGen) GenStoreRSA(req RequestData) (ReturnType, error) {
+func (pgpKeysGen *PgpKeysGen) GenStoreRSA(req RequestData) (ret ReturnType, err error) {
+	defer func() {
+		audit.Record(audit.OpKeyGenerate, req.Usage, fingerprintOf(ret.PubKey), err)
+	}()
+
+	if err := passphrase.Enforce(req.Passphrase); err != nil {
+		return ReturnType{}, err
+	}
+
 	pgp4880 := crypto.PGPWithProfile(profile.RFC4880())
 	keyGenHandle := pgp4880.KeyGeneration().AddUserId(req.Name, req.Email).New()
 
@@ -97,8 +107,6 @@ func (pgpKeysGen *PgpKeysGen) GenStoreRSA(req RequestData) (ReturnType, error) {
 		}
 	}
 
-	var err error
-
 	err = pgpfs.SavePgpPrivKey(privKey, req.Usage)
 	if err != nil {
 		return ReturnType{}, fmt.Errorf("failed to save private key: %v", err)
@@ -115,12 +123,19 @@ func (pgpKeysGen *PgpKeysGen) GenStoreRSA(req RequestData) (ReturnType, error) {
 	}, nil
 }
 
-func (pgpKeysGen *PgpKeysGen) GenStoreECC(req RequestData) (ReturnType, error) {
+func (pgpKeysGen *PgpKeysGen) GenStoreECC(req RequestData) (ret ReturnType, err error) {
+	defer func() {
+		audit.Record(audit.OpKeyGenerate, req.Usage, fingerprintOf(ret.PubKey), err)
+	}()
+
+	if err := passphrase.Enforce(req.Passphrase); err != nil {
+		return ReturnType{}, err
+	}
+
 	pgp := crypto.PGPWithProfile(profile.RFC9580())
 
 	keyGenHandle := pgp.KeyGeneration().AddUserId(req.Name, req.Email)
 	var ecKey *crypto.Key
-	var err error
 
 	switch req.Curve {
 	case "curve25519":
@@ -184,6 +199,10 @@ func (pgpKeysGen *PgpKeysGen) GenStoreECC(req RequestData) (ReturnType, error) {
 
 // generate without storing
 func (pgpKeysGen *PgpKeysGen) GenRSA(req RequestData) (ReturnType, error) {
+	if err := passphrase.Enforce(req.Passphrase); err != nil {
+		return ReturnType{}, err
+	}
+
 	pgp4880 := crypto.PGPWithProfile(profile.RFC4880())
 	keyGenHandle := pgp4880.KeyGeneration().AddUserId(req.Name, req.Email).New()
 
@@ -240,6 +259,10 @@ func (pgpKeysGen *PgpKeysGen) GenRSA(req RequestData) (ReturnType, error) {
 }
 
 func (pgpKeysGen *PgpKeysGen) GenECC(req RequestData) (ReturnType, error) {
+	if err := passphrase.Enforce(req.Passphrase); err != nil {
+		return ReturnType{}, err
+	}
+
 	pgp := crypto.PGPWithProfile(profile.RFC9580())
 
 	keyGenHandle := pgp.KeyGeneration().AddUserId(req.Name, req.Email)
@@ -295,3 +318,15 @@ func (pgpKeysGen *PgpKeysGen) GenECC(req RequestData) (ReturnType, error) {
 		PubKey:  pubKey,
 	}, nil
 }
+
+func fingerprintOf(pubKeyArmor string) string {
+	if pubKeyArmor == "" {
+		return ""
+	}
+
+	key, err := crypto.NewKeyFromArmored(pubKeyArmor)
+	if err != nil {
+		return ""
+	}
+	return key.GetFingerprint()
+}
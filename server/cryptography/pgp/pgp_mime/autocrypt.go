@@ -0,0 +1,258 @@
+package pgpmime
+
+import (
+	"MindLockr/server/apperr"
+	pgptrust "MindLockr/server/cryptography/pgp/pgp_trust"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// prefer-encrypt values of the Autocrypt Level 1 specification
+const (
+	PreferEncryptNone   = "nopreference"
+	PreferEncryptMutual = "mutual"
+)
+
+type (
+	AutocryptRequest struct {
+		KeyName string `json:"keyName"`
+		// Email is the address the header is for, the first email of the
+		// key when empty
+		Email         string `json:"email,omitempty"`
+		PreferEncrypt string `json:"preferEncrypt,omitempty"`
+	}
+
+	AutocryptMessageRequest struct {
+		Message string `json:"message"`
+		// KeyName is the vault folder an imported key is stored in
+		KeyName string `json:"keyName,omitempty"`
+	}
+
+	AutocryptInfo struct {
+		Addr          string             `json:"addr"`
+		PreferEncrypt string             `json:"preferEncrypt"`
+		Fingerprint   string             `json:"fingerprint"`
+		UserIDs       []pgpfs.UserIDInfo `json:"userIds"`
+		PublicKey     string             `json:"publicKey"`
+		// KeyName is the vault key that already holds this key
+		KeyName string `json:"keyName,omitempty"`
+	}
+)
+
+// AutocryptHeader returns the value of an Autocrypt header that publishes
+// one of our keys, to be added to outgoing mail
+func (pm *PgpMime) AutocryptHeader(req AutocryptRequest) (string, error) {
+	vaultKeys, err := pgpfs.VaultKeys()
+	if err != nil {
+		return "", err
+	}
+	k := findKey(vaultKeys, req.KeyName)
+	if k == nil {
+		return "", fmt.Errorf("%w: %s", apperr.ErrKeyNotFound, req.KeyName)
+	}
+
+	email := req.Email
+	if email == "" {
+		for _, info := range pgpfs.DescribeUserIDs(k.PublicKey.GetEntity()) {
+			if info.Email != "" {
+				email = info.Email
+				break
+			}
+		}
+	}
+	return autocryptValue(k.PublicKey, email, req.PreferEncrypt)
+}
+
+// ParseAutocrypt reads the Autocrypt header of a received message
+func (pm *PgpMime) ParseAutocrypt(req AutocryptMessageRequest) (AutocryptInfo, error) {
+	msg, err := parseEntity([]byte(req.Message))
+	if err != nil {
+		return AutocryptInfo{}, err
+	}
+	info, err := autocryptOf(msg)
+	if err != nil {
+		return AutocryptInfo{}, err
+	}
+	return *info, nil
+}
+
+// ImportAutocrypt stores the key of the Autocrypt header of a received
+// message. A key that is already in the vault is left as it is, since the
+// header only carries a stripped down copy of it
+func (pm *PgpMime) ImportAutocrypt(req AutocryptMessageRequest) (string, error) {
+	info, err := pm.ParseAutocrypt(req)
+	if err != nil {
+		return "", err
+	}
+	if info.KeyName != "" {
+		return info.Fingerprint, nil
+	}
+	if req.KeyName == "" {
+		return "", fmt.Errorf("%w: choose a name for the key", apperr.ErrInvalidInput)
+	}
+
+	return (&pgptrust.PgpTrust{}).ImportPublicKey(pgptrust.ImportRequest{
+		KeyName:   req.KeyName,
+		PublicKey: info.PublicKey,
+	})
+}
+
+func autocryptValue(key *crypto.Key, email, preferEncrypt string) (string, error) {
+	switch preferEncrypt {
+	case "", PreferEncryptNone, PreferEncryptMutual:
+	default:
+		return "", fmt.Errorf("%w: prefer-encrypt is %q or %q", apperr.ErrInvalidInput, PreferEncryptMutual, PreferEncryptNone)
+	}
+
+	minimal, err := minimalKey(key, email)
+	if err != nil {
+		return "", err
+	}
+	keyData, err := minimal.GetPublicKey()
+	if err != nil {
+		return "", fmt.Errorf("failed to export the key: %s", err)
+	}
+
+	value := "addr=" + email + ";"
+	if preferEncrypt == PreferEncryptMutual {
+		value += " prefer-encrypt=mutual;"
+	}
+	return value + " keydata=" + base64.StdEncoding.EncodeToString(keyData), nil
+}
+
+// minimalKey strips a key down to what Autocrypt recommends: the primary
+// key, the user ID of email and the current encryption subkey
+func minimalKey(key *crypto.Key, email string) (*crypto.Key, error) {
+	entity := key.GetEntity()
+
+	var identity *openpgp.Identity
+	for _, id := range entity.Identities {
+		if email != "" && strings.EqualFold(id.UserId.Email, email) {
+			identity = id
+			break
+		}
+	}
+	if identity == nil {
+		return nil, fmt.Errorf("%w: the key has no user ID for %q", apperr.ErrInvalidInput, email)
+	}
+	stripped := *identity
+	stripped.OtherCertifications = nil
+
+	encryptionKey, ok := entity.EncryptionKey(time.Now(), nil)
+	if !ok {
+		return nil, fmt.Errorf("%w: the key has no valid encryption key", apperr.ErrInvalidKey)
+	}
+
+	minimal := &openpgp.Entity{
+		PrimaryKey:       entity.PrimaryKey,
+		Revocations:      entity.Revocations,
+		DirectSignatures: entity.DirectSignatures,
+		Identities:       map[string]*openpgp.Identity{stripped.Name: &stripped},
+	}
+	for _, sub := range entity.Subkeys {
+		if sub.PublicKey.KeyId == encryptionKey.PublicKey.KeyId {
+			minimal.Subkeys = append(minimal.Subkeys, sub)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := minimal.Serialize(&buf); err != nil {
+		return nil, fmt.Errorf("failed to serialize the key: %s", err)
+	}
+	return crypto.NewKey(buf.Bytes())
+}
+
+// foldAutocrypt writes an Autocrypt header field with the key data folded
+// into lines that fit the line length limit of mail
+func foldAutocrypt(value string) string {
+	attributes, keyData, _ := strings.Cut(value, "keydata=")
+
+	var b strings.Builder
+	b.WriteString("Autocrypt: " + attributes + "keydata=")
+	for len(keyData) > 0 {
+		n := min(len(keyData), 72)
+		b.WriteString("\r\n " + keyData[:n])
+		keyData = keyData[n:]
+	}
+	return b.String()
+}
+
+// autocryptOf parses the Autocrypt header of msg. The header is ignored,
+// as the specification requires, unless there is exactly one and its
+// address is the sender's
+func autocryptOf(msg entity) (*AutocryptInfo, error) {
+	headers := msg.getAll("Autocrypt")
+	switch len(headers) {
+	case 0:
+		return nil, fmt.Errorf("%w: the message has no Autocrypt header", apperr.ErrNotFound)
+	case 1:
+	default:
+		return nil, fmt.Errorf("%w: the message has more than one Autocrypt header", apperr.ErrInvalidInput)
+	}
+
+	info := &AutocryptInfo{PreferEncrypt: PreferEncryptNone}
+	var keyData string
+	for _, attribute := range strings.Split(headers[0], ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(attribute), "=")
+		switch name {
+		case "":
+		case "addr":
+			info.Addr = strings.TrimSpace(value)
+		case "prefer-encrypt":
+			if value == PreferEncryptMutual {
+				info.PreferEncrypt = PreferEncryptMutual
+			}
+		case "keydata":
+			keyData = value
+		default:
+			// unknown attributes are only allowed when they are optional
+			if !strings.HasPrefix(name, "_") {
+				return nil, fmt.Errorf("%w: unknown critical Autocrypt attribute %q", apperr.ErrInvalidInput, name)
+			}
+		}
+	}
+	if info.Addr == "" || keyData == "" {
+		return nil, fmt.Errorf("%w: the Autocrypt header needs addr and keydata", apperr.ErrInvalidInput)
+	}
+
+	from, err := mail.ParseAddress(msg.get("From"))
+	if err != nil || !strings.EqualFold(from.Address, info.Addr) {
+		return nil, fmt.Errorf("%w: the Autocrypt address does not match the sender", apperr.ErrInvalidInput)
+	}
+
+	binary, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(keyData), ""))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid Autocrypt key data: %v", apperr.ErrInvalidKey, err)
+	}
+	key, err := crypto.NewKey(binary)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid Autocrypt key: %v", apperr.ErrInvalidKey, err)
+	}
+	if key.IsPrivate() {
+		return nil, fmt.Errorf("%w: the Autocrypt header holds a private key", apperr.ErrInvalidKey)
+	}
+
+	info.Fingerprint = strings.ToUpper(key.GetFingerprint())
+	info.UserIDs = pgpfs.DescribeUserIDs(key.GetEntity())
+	if info.PublicKey, err = key.GetArmoredPublicKey(); err != nil {
+		return nil, fmt.Errorf("failed to armor the Autocrypt key: %s", err)
+	}
+
+	if vaultKeys, err := pgpfs.VaultKeys(); err == nil {
+		for _, k := range vaultKeys {
+			if strings.EqualFold(k.PublicKey.GetFingerprint(), info.Fingerprint) {
+				info.KeyName = k.Name
+				break
+			}
+		}
+	}
+	return info, nil
+}
@@ -0,0 +1,85 @@
+package pgpmime
+
+import (
+	"encoding/base64"
+	"mime"
+	"strings"
+)
+
+type (
+	Attachment struct {
+		Filename    string `json:"filename"`
+		ContentType string `json:"contentType"`
+		Size        int    `json:"size"`
+		// Data is the base64 encoded content
+		Data string `json:"data"`
+	}
+
+	// Content is the readable part of a decrypted or verified message
+	Content struct {
+		Text        string       `json:"text"`
+		HTML        string       `json:"html,omitempty"`
+		Attachments []Attachment `json:"attachments"`
+	}
+)
+
+var wordDecoder = new(mime.WordDecoder)
+
+// readContent collects the text bodies and the attachments of e
+func readContent(e entity, content *Content) error {
+	mediaType, params := e.contentType()
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		parts, err := splitMultipart(e.body, params["boundary"])
+		if err != nil {
+			return err
+		}
+		// the signature of a nested signed part is not content
+		if mediaType == "multipart/signed" && len(parts) > 0 {
+			parts = parts[:1]
+		}
+		for _, raw := range parts {
+			part, err := parseEntity(raw)
+			if err != nil {
+				return err
+			}
+			if err := readContent(part, content); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	data, err := e.decodedBody()
+	if err != nil {
+		return err
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(e.get("Content-Disposition"))
+	filename := dispositionParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	if decoded, err := wordDecoder.DecodeHeader(filename); err == nil {
+		filename = decoded
+	}
+
+	if disposition != "attachment" && filename == "" {
+		switch {
+		case mediaType == "text/plain" && content.Text == "":
+			content.Text = strings.ReplaceAll(string(data), "\r\n", "\n")
+			return nil
+		case mediaType == "text/html" && content.HTML == "":
+			content.HTML = string(data)
+			return nil
+		}
+	}
+
+	content.Attachments = append(content.Attachments, Attachment{
+		Filename:    filename,
+		ContentType: mediaType,
+		Size:        len(data),
+		Data:        base64.StdEncoding.EncodeToString(data),
+	})
+	return nil
+}
@@ -0,0 +1,274 @@
+package pgpmime
+
+import (
+	"MindLockr/server/apperr"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"strings"
+)
+
+// headerField keeps a header field as it was written, folding included, so
+// signed content is reproduced byte for byte
+type headerField struct {
+	Name string
+	Raw  string
+}
+
+// entity is a MIME entity, a whole message or one part of a multipart body,
+// with CRLF line endings
+type entity struct {
+	header []headerField
+	body   []byte
+}
+
+// canonicalCRLF converts every line ending to CRLF
+func canonicalCRLF(data []byte) []byte {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+}
+
+func parseEntity(data []byte) (entity, error) {
+	data = canonicalCRLF(data)
+
+	var headerBlock []byte
+	var e entity
+	switch {
+	case bytes.HasPrefix(data, []byte("\r\n")):
+		e.body = data[2:]
+	default:
+		end := bytes.Index(data, []byte("\r\n\r\n"))
+		if end < 0 {
+			headerBlock = bytes.TrimSuffix(data, []byte("\r\n"))
+		} else {
+			headerBlock, e.body = data[:end], data[end+4:]
+		}
+	}
+
+	if len(headerBlock) == 0 {
+		return e, nil
+	}
+	for _, line := range strings.Split(string(headerBlock), "\r\n") {
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(e.header) == 0 {
+				return entity{}, fmt.Errorf("%w: the message starts with a folded header line", apperr.ErrInvalidInput)
+			}
+			e.header[len(e.header)-1].Raw += "\r\n" + line
+			continue
+		}
+		name, _, ok := strings.Cut(line, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return entity{}, fmt.Errorf("%w: invalid header line %q", apperr.ErrInvalidInput, line)
+		}
+		e.header = append(e.header, headerField{Name: strings.TrimSpace(name), Raw: line})
+	}
+	return e, nil
+}
+
+// get returns the unfolded value of the first field called name
+func (e entity) get(name string) string {
+	for _, f := range e.header {
+		if strings.EqualFold(f.Name, name) {
+			_, value, _ := strings.Cut(f.Raw, ":")
+			return strings.TrimSpace(strings.NewReplacer("\r\n", "", "\t", " ").Replace(value))
+		}
+	}
+	return ""
+}
+
+// getAll returns the unfolded values of every field called name
+func (e entity) getAll(name string) []string {
+	var values []string
+	for _, f := range e.header {
+		if strings.EqualFold(f.Name, name) {
+			_, value, _ := strings.Cut(f.Raw, ":")
+			values = append(values, strings.TrimSpace(strings.NewReplacer("\r\n", "", "\t", " ").Replace(value)))
+		}
+	}
+	return values
+}
+
+// set replaces the fields called name with a single field
+func (e *entity) set(name, value string) {
+	e.remove(name)
+	e.header = append(e.header, headerField{Name: name, Raw: name + ": " + value})
+}
+
+func (e *entity) remove(name string) {
+	kept := e.header[:0]
+	for _, f := range e.header {
+		if !strings.EqualFold(f.Name, name) {
+			kept = append(kept, f)
+		}
+	}
+	e.header = kept
+}
+
+// contentType returns the media type in lower case, text/plain by default
+func (e entity) contentType() (string, map[string]string) {
+	mediaType, params, err := mime.ParseMediaType(e.get("Content-Type"))
+	if err != nil {
+		return "text/plain", map[string]string{"charset": "us-ascii"}
+	}
+	return mediaType, params
+}
+
+func (e entity) bytes() []byte {
+	var buf bytes.Buffer
+	for _, f := range e.header {
+		buf.WriteString(f.Raw)
+		buf.WriteString("\r\n")
+	}
+	buf.WriteString("\r\n")
+	buf.Write(e.body)
+	return buf.Bytes()
+}
+
+func isContentField(name string) bool {
+	return strings.HasPrefix(strings.ToLower(name), "content-")
+}
+
+// split separates a message into its envelope fields and the MIME entity
+// that gets signed or encrypted
+func (e entity) split() (envelope []headerField, content entity) {
+	for _, f := range e.header {
+		switch {
+		case isContentField(f.Name):
+			content.header = append(content.header, f)
+		case strings.EqualFold(f.Name, "MIME-Version"):
+		default:
+			envelope = append(envelope, f)
+		}
+	}
+	content.body = e.body
+
+	if content.get("Content-Type") == "" {
+		content.set("Content-Type", "text/plain; charset=utf-8")
+	}
+	content.make7bit()
+	return envelope, content
+}
+
+// make7bit quoted-printable encodes a single part body that would not
+// survive a 7-bit transport unchanged, which would break its signature
+func (e *entity) make7bit() {
+	mediaType, _ := e.contentType()
+	if strings.HasPrefix(mediaType, "multipart/") {
+		return
+	}
+	switch strings.ToLower(e.get("Content-Transfer-Encoding")) {
+	case "", "7bit", "8bit":
+	default:
+		return
+	}
+	if is7bit(e.body) {
+		return
+	}
+
+	var buf bytes.Buffer
+	w := quotedprintable.NewWriter(&buf)
+	w.Write(e.body)
+	w.Close()
+	e.body = canonicalCRLF(buf.Bytes())
+	e.set("Content-Transfer-Encoding", "quoted-printable")
+}
+
+func is7bit(data []byte) bool {
+	for _, line := range bytes.Split(data, []byte("\r\n")) {
+		if len(line) > 76 || bytes.HasSuffix(line, []byte(" ")) || bytes.HasSuffix(line, []byte("\t")) {
+			return false
+		}
+		for _, c := range line {
+			if c == 0 || c >= 0x80 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func newBoundary() string {
+	random := make([]byte, 12)
+	rand.Read(random)
+	return "MindLockr-" + hex.EncodeToString(random)
+}
+
+// joinMultipart writes parts as a multipart body, the CRLF before each
+// delimiter belongs to the delimiter and not to the part
+func joinMultipart(boundary string, parts ...[]byte) []byte {
+	var buf bytes.Buffer
+	for _, part := range parts {
+		buf.WriteString("--" + boundary + "\r\n")
+		buf.Write(part)
+		buf.WriteString("\r\n")
+	}
+	buf.WriteString("--" + boundary + "--\r\n")
+	return buf.Bytes()
+}
+
+// splitMultipart returns the raw bytes of every part of a multipart body,
+// unlike mime/multipart it keeps them exactly as they were signed
+func splitMultipart(body []byte, boundary string) ([][]byte, error) {
+	if boundary == "" {
+		return nil, fmt.Errorf("%w: multipart body without a boundary", apperr.ErrCorruptMessage)
+	}
+	delimiter := []byte("\r\n--" + boundary)
+
+	start := 0
+	if !bytes.HasPrefix(body, delimiter[2:]) {
+		i := bytes.Index(body, delimiter)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: multipart body without parts", apperr.ErrCorruptMessage)
+		}
+		start = i + 2
+	}
+	rest := body[start+len(delimiter)-2:]
+
+	var parts [][]byte
+	for !bytes.HasPrefix(rest, []byte("--")) {
+		eol := bytes.Index(rest, []byte("\r\n"))
+		if eol < 0 {
+			return nil, fmt.Errorf("%w: unterminated multipart body", apperr.ErrCorruptMessage)
+		}
+		rest = rest[eol+2:]
+
+		next := bytes.Index(rest, delimiter)
+		if next < 0 {
+			return nil, fmt.Errorf("%w: unterminated multipart body", apperr.ErrCorruptMessage)
+		}
+		parts = append(parts, rest[:next])
+		rest = rest[next+len(delimiter):]
+	}
+	return parts, nil
+}
+
+// decodedBody undoes the content transfer encoding of a single part
+func (e entity) decodedBody() ([]byte, error) {
+	switch strings.ToLower(e.get("Content-Transfer-Encoding")) {
+	case "base64":
+		cleaned := strings.Map(func(r rune) rune {
+			if r == '\r' || r == '\n' || r == ' ' || r == '\t' {
+				return -1
+			}
+			return r
+		}, string(e.body))
+		data, err := base64.StdEncoding.DecodeString(cleaned)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid base64 part: %v", apperr.ErrCorruptMessage, err)
+		}
+		return data, nil
+	case "quoted-printable":
+		data, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(e.body)))
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid quoted-printable part: %v", apperr.ErrCorruptMessage, err)
+		}
+		return data, nil
+	default:
+		return e.body, nil
+	}
+}
@@ -0,0 +1,418 @@
+package pgpmime
+
+import (
+	"MindLockr/server/apperr"
+	hybdec "MindLockr/server/cryptography/decryption/hyb_dec"
+	"MindLockr/server/cryptography/pgpformat"
+	"MindLockr/server/filesystem/audit"
+	"MindLockr/server/filesystem/integrity"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"bytes"
+	gocrypto "crypto"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+type (
+	PgpMime struct{}
+
+	EmailRequest struct {
+		// Message is an RFC 5322 message, as in an .eml file
+		Message string `json:"message"`
+		Encrypt bool   `json:"encrypt"`
+		Sign    bool   `json:"sign"`
+		// Recipients are the names of the vault keys to encrypt to
+		Recipients []string `json:"recipients,omitempty"`
+		// SignerKeyName is our key that signs the message, the message is
+		// also encrypted to it so the sent copy stays readable
+		SignerKeyName string `json:"signerKeyName,omitempty"`
+		// Passphrase unlocks the signer, it may be empty when the signer
+		// is the unlocked session key
+		Passphrase string `json:"passphrase,omitempty"`
+		// Autocrypt adds an Autocrypt header with the signer's key
+		Autocrypt bool `json:"autocrypt,omitempty"`
+	}
+
+	OpenEmailRequest struct {
+		Message    string `json:"message"`
+		Passphrase string `json:"passphrase,omitempty"`
+		FolderName string `json:"folderName,omitempty"`
+	}
+
+	EmailResult struct {
+		// Data of the embedded result is the decrypted or verified MIME
+		// entity, Content is what it holds
+		hybdec.AutoReturnType
+		Encrypted bool    `json:"encrypted"`
+		Subject   string  `json:"subject"`
+		From      string  `json:"from"`
+		To        string  `json:"to"`
+		Date      string  `json:"date"`
+		Content   Content `json:"content"`
+		// Autocrypt is the sender's Autocrypt header, nil without one
+		Autocrypt *AutocryptInfo `json:"autocrypt,omitempty"`
+	}
+)
+
+// EncryptEmail turns a message into a PGP/MIME (RFC 3156) signed and/or
+// encrypted message. Signed and encrypted messages carry the signature
+// inside the encrypted part, as in section 6.2 of the RFC
+func (pm *PgpMime) EncryptEmail(req EmailRequest) (string, error) {
+	if !req.Encrypt && !req.Sign {
+		return "", fmt.Errorf("%w: choose to encrypt, sign or both", apperr.ErrInvalidInput)
+	}
+	if req.Encrypt && len(req.Recipients) == 0 {
+		return "", fmt.Errorf("%w: choose at least one recipient", apperr.ErrInvalidInput)
+	}
+	if (req.Sign || req.Autocrypt) && req.SignerKeyName == "" {
+		return "", fmt.Errorf("%w: choose the key to sign with", apperr.ErrInvalidInput)
+	}
+
+	msg, err := parseEntity([]byte(req.Message))
+	if err != nil {
+		return "", err
+	}
+	envelope, content := msg.split()
+
+	vaultKeys, err := pgpfs.VaultKeys()
+	if err != nil {
+		return "", err
+	}
+
+	var signer *pgpfs.VaultKey
+	if req.SignerKeyName != "" {
+		if signer = findKey(vaultKeys, req.SignerKeyName); signer == nil {
+			return "", fmt.Errorf("%w: %s", apperr.ErrKeyNotFound, req.SignerKeyName)
+		}
+	}
+
+	out := entity{header: envelope}
+	out.remove("Autocrypt")
+	out.set("MIME-Version", "1.0")
+
+	if req.Autocrypt {
+		from, err := mail.ParseAddress(msg.get("From"))
+		if err != nil {
+			return "", fmt.Errorf("%w: the Autocrypt header needs a From address: %v", apperr.ErrInvalidInput, err)
+		}
+		value, err := autocryptValue(signer.PublicKey, from.Address, PreferEncryptNone)
+		if err != nil {
+			return "", err
+		}
+		out.header = append(out.header, headerField{Name: "Autocrypt", Raw: foldAutocrypt(value)})
+	}
+
+	var signingKey *crypto.Key
+	if req.Sign {
+		key, release, err := signer.PrivateKey(req.Passphrase)
+		if err != nil {
+			return "", err
+		}
+		defer release()
+		signingKey = key
+	}
+
+	boundary := newBoundary()
+	if req.Encrypt {
+		recipients, err := recipientKeyRing(vaultKeys, req.Recipients, signer)
+		if err != nil {
+			return "", err
+		}
+		encrypted, err := encryptEntity(content, recipients, signingKey)
+		if err != nil {
+			return "", err
+		}
+
+		out.set("Content-Type", mime.FormatMediaType("multipart/encrypted", map[string]string{
+			"protocol": "application/pgp-encrypted",
+			"boundary": boundary,
+		}))
+		out.body = joinMultipart(boundary,
+			[]byte("Content-Type: application/pgp-encrypted\r\n"+
+				"Content-Description: PGP/MIME version identification\r\n"+
+				"\r\n"+
+				"Version: 1\r\n"),
+			append([]byte("Content-Type: application/octet-stream; name=\"encrypted.asc\"\r\n"+
+				"Content-Description: OpenPGP encrypted message\r\n"+
+				"Content-Disposition: inline; filename=\"encrypted.asc\"\r\n"+
+				"\r\n"), encrypted...),
+		)
+		return string(out.bytes()), nil
+	}
+
+	signed := content.bytes()
+	signature, micalg, err := signEntity(signed, signingKey)
+	if err != nil {
+		return "", err
+	}
+
+	out.set("Content-Type", mime.FormatMediaType("multipart/signed", map[string]string{
+		"micalg":   micalg,
+		"protocol": "application/pgp-signature",
+		"boundary": boundary,
+	}))
+	out.body = joinMultipart(boundary,
+		signed,
+		append([]byte("Content-Type: application/pgp-signature; name=\"signature.asc\"\r\n"+
+			"Content-Description: OpenPGP digital signature\r\n"+
+			"Content-Disposition: attachment; filename=\"signature.asc\"\r\n"+
+			"\r\n"), signature...),
+	)
+	return string(out.bytes()), nil
+}
+
+// DecryptEmail decrypts and/or verifies a PGP/MIME message with the vault
+// keys and returns its text and attachments
+func (pm *PgpMime) DecryptEmail(req OpenEmailRequest) (EmailResult, error) {
+	msg, err := parseEntity([]byte(req.Message))
+	if err != nil {
+		return EmailResult{}, err
+	}
+
+	result := EmailResult{
+		Subject: decodeHeader(msg.get("Subject")),
+		From:    decodeHeader(msg.get("From")),
+		To:      decodeHeader(msg.get("To")),
+		Date:    msg.get("Date"),
+	}
+	if info, err := autocryptOf(msg); err == nil {
+		result.Autocrypt = info
+	}
+
+	mediaType, params := msg.contentType()
+	var inner entity
+	switch mediaType {
+	case "multipart/encrypted":
+		result.Encrypted = true
+		inner, result.AutoReturnType, err = decryptEntity(msg, params, req)
+	case "multipart/signed":
+		inner, result.AutoReturnType, err = verifyEntity(msg, params, req)
+	default:
+		return EmailResult{}, fmt.Errorf("%w: this is not a PGP/MIME message (%s)", apperr.ErrInvalidInput, mediaType)
+	}
+	if err != nil {
+		result.Data = ""
+		return result, err
+	}
+
+	if err := readContent(inner, &result.Content); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func decryptEntity(msg entity, params map[string]string, req OpenEmailRequest) (entity, hybdec.AutoReturnType, error) {
+	if !strings.EqualFold(params["protocol"], "application/pgp-encrypted") {
+		return entity{}, hybdec.AutoReturnType{}, fmt.Errorf("%w: unsupported encryption protocol %q", apperr.ErrInvalidInput, params["protocol"])
+	}
+	parts, err := splitMultipart(msg.body, params["boundary"])
+	if err != nil {
+		return entity{}, hybdec.AutoReturnType{}, err
+	}
+	if len(parts) != 2 {
+		return entity{}, hybdec.AutoReturnType{}, fmt.Errorf("%w: an encrypted message has two parts, found %d", apperr.ErrCorruptMessage, len(parts))
+	}
+	encryptedPart, err := parseEntity(parts[1])
+	if err != nil {
+		return entity{}, hybdec.AutoReturnType{}, err
+	}
+
+	pgpMessage, err := crypto.NewPGPMessageFromArmored(string(encryptedPart.body))
+	if err != nil {
+		return entity{}, hybdec.AutoReturnType{}, fmt.Errorf("failed to load the encrypted part: %w: %v", apperr.ErrCorruptMessage, err)
+	}
+	ret, err := hybdec.DecryptMessage(pgpMessage, req.Passphrase, req.FolderName)
+	if err != nil {
+		return entity{}, ret, err
+	}
+
+	inner, err := parseEntity([]byte(ret.Data))
+	if err != nil {
+		return entity{}, ret, err
+	}
+
+	// a signed entity inside the encryption, as in section 6.1 of RFC 3156
+	innerType, innerParams := inner.contentType()
+	if innerType == "multipart/signed" && !ret.Signed {
+		signedInner, verified, err := verifyEntity(inner, innerParams, req)
+		verified.Recipient = ret.Recipient
+		verified.Data = ret.Data
+		return signedInner, verified, err
+	}
+	return inner, ret, nil
+}
+
+func verifyEntity(msg entity, params map[string]string, req OpenEmailRequest) (entity, hybdec.AutoReturnType, error) {
+	if !strings.EqualFold(params["protocol"], "application/pgp-signature") {
+		return entity{}, hybdec.AutoReturnType{}, fmt.Errorf("%w: unsupported signature protocol %q", apperr.ErrInvalidInput, params["protocol"])
+	}
+	parts, err := splitMultipart(msg.body, params["boundary"])
+	if err != nil {
+		return entity{}, hybdec.AutoReturnType{}, err
+	}
+	if len(parts) != 2 {
+		return entity{}, hybdec.AutoReturnType{}, fmt.Errorf("%w: a signed message has two parts, found %d", apperr.ErrCorruptMessage, len(parts))
+	}
+	signaturePart, err := parseEntity(parts[1])
+	if err != nil {
+		return entity{}, hybdec.AutoReturnType{}, err
+	}
+
+	vaultKeys, err := pgpfs.VaultKeys()
+	if err != nil {
+		return entity{}, hybdec.AutoReturnType{}, err
+	}
+	verificationKeys, err := pgpfs.VerificationKeyRing(vaultKeys)
+	if err != nil {
+		return entity{}, hybdec.AutoReturnType{}, err
+	}
+	verifyHandle, err := crypto.PGP().Verify().VerificationKeys(verificationKeys).New()
+	if err != nil {
+		return entity{}, hybdec.AutoReturnType{}, fmt.Errorf("failed to create verification handle: %s", err)
+	}
+
+	result, err := verifyHandle.VerifyDetached(parts[0], signaturePart.body, crypto.Auto)
+	if err != nil {
+		return entity{}, hybdec.AutoReturnType{}, fmt.Errorf("failed to read the signature: %w: %v", apperr.ErrCorruptMessage, err)
+	}
+
+	ret := hybdec.AutoReturnType{
+		ReturnType: hybdec.ReturnType{
+			Data:     string(parts[0]),
+			Warnings: integrity.Warnings(),
+		},
+	}
+	err = hybdec.ApplySignature(&ret, result, vaultKeys)
+
+	signer := ret.UnknownSignerKeyID
+	if ret.Signer != nil {
+		signer = ret.Signer.Fingerprint
+	}
+	audit.Record(audit.OpVerify, req.FolderName, signer, err)
+	if err != nil {
+		return entity{}, ret, err
+	}
+
+	inner, err := parseEntity(parts[0])
+	return inner, ret, err
+}
+
+func encryptEntity(content entity, recipients *crypto.KeyRing, signingKey *crypto.Key) ([]byte, error) {
+	encBuilder := crypto.PGP().Encryption().Recipients(recipients)
+	if signingKey != nil {
+		encBuilder = encBuilder.SigningKey(signingKey)
+	}
+	encHandle, err := encBuilder.New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create an encryption handle: %s", err)
+	}
+
+	pgpMessage, err := encHandle.Encrypt(content.bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt the message: %s", err)
+	}
+	armored, err := pgpformat.Armor(pgpMessage.Bytes(), constants.PGPMessageHeader)
+	if err != nil {
+		return nil, err
+	}
+	return canonicalCRLF(armored), nil
+}
+
+var micalgs = map[gocrypto.Hash]string{
+	gocrypto.SHA1:     "pgp-sha1",
+	gocrypto.SHA224:   "pgp-sha224",
+	gocrypto.SHA256:   "pgp-sha256",
+	gocrypto.SHA384:   "pgp-sha384",
+	gocrypto.SHA512:   "pgp-sha512",
+	gocrypto.SHA3_256: "pgp-sha3-256",
+	gocrypto.SHA3_512: "pgp-sha3-512",
+}
+
+// signEntity makes the armored detached signature of a multipart/signed
+// message and names its hash algorithm for the micalg parameter
+func signEntity(signed []byte, signingKey *crypto.Key) ([]byte, string, error) {
+	signHandle, err := crypto.PGP().Sign().SigningKey(signingKey).Detached().New()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create a signing handle: %s", err)
+	}
+	signature, err := signHandle.Sign(signed, crypto.Bytes)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to sign the message: %s", err)
+	}
+
+	p, err := packet.Read(bytes.NewReader(signature))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read the signature: %s", err)
+	}
+	sig, ok := p.(*packet.Signature)
+	if !ok {
+		return nil, "", fmt.Errorf("failed to read the signature: unexpected packet")
+	}
+	micalg, ok := micalgs[sig.Hash]
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported signature hash %s", sig.Hash)
+	}
+
+	armored, err := pgpformat.Armor(signature, constants.PGPSignatureHeader)
+	if err != nil {
+		return nil, "", err
+	}
+	return canonicalCRLF(armored), micalg, nil
+}
+
+func recipientKeyRing(vaultKeys []pgpfs.VaultKey, names []string, signer *pgpfs.VaultKey) (*crypto.KeyRing, error) {
+	keyRing, err := crypto.NewKeyRing(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key ring: %s", err)
+	}
+
+	added := map[string]bool{}
+	add := func(k *pgpfs.VaultKey) error {
+		if added[k.Name] {
+			return nil
+		}
+		added[k.Name] = true
+		if err := keyRing.AddKey(k.PublicKey); err != nil {
+			return fmt.Errorf("failed to add %s to the key ring: %s", k.Name, err)
+		}
+		return nil
+	}
+
+	for _, name := range names {
+		k := findKey(vaultKeys, name)
+		if k == nil {
+			return nil, fmt.Errorf("%w: %s", apperr.ErrKeyNotFound, name)
+		}
+		if err := add(k); err != nil {
+			return nil, err
+		}
+	}
+	if signer != nil {
+		if err := add(signer); err != nil {
+			return nil, err
+		}
+	}
+	return keyRing, nil
+}
+
+func findKey(vaultKeys []pgpfs.VaultKey, name string) *pgpfs.VaultKey {
+	for i := range vaultKeys {
+		if vaultKeys[i].Name == name {
+			return &vaultKeys[i]
+		}
+	}
+	return nil
+}
+
+func decodeHeader(value string) string {
+	if decoded, err := wordDecoder.DecodeHeader(value); err == nil {
+		return decoded
+	}
+	return value
+}
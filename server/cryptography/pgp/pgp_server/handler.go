@@ -0,0 +1,217 @@
+package pgpserver
+
+import (
+	pgpdiscovery "MindLockr/server/cryptography/pgp/pgp_discovery"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+const wkdPrefix = "/.well-known/openpgpkey/"
+
+// handler serves the published keys, read-only
+type handler struct {
+	onRequest func()
+}
+
+func (h handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.onRequest != nil {
+		h.onRequest()
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "read-only key server", http.StatusMethodNotAllowed)
+		return
+	}
+
+	keys, err := publishedKeys()
+	if err != nil {
+		http.Error(w, "no keys available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/pks/lookup":
+		serveHKP(w, r, keys)
+	case strings.HasPrefix(r.URL.Path, wkdPrefix):
+		serveWKD(w, r, keys)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// serveHKP answers the get and index operations of the HKP lookup API
+func serveHKP(w http.ResponseWriter, r *http.Request, keys []pgpfs.VaultKey) {
+	query := r.URL.Query()
+	matches := searchKeys(keys, query.Get("search"))
+
+	switch query.Get("op") {
+	case "get":
+		if len(matches) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		var armored strings.Builder
+		for _, k := range matches {
+			key, err := k.PublicKey.GetArmoredPublicKey()
+			if err != nil {
+				http.Error(w, "failed to export key", http.StatusInternalServerError)
+				return
+			}
+			armored.WriteString(key)
+			armored.WriteString("\n")
+		}
+		w.Header().Set("Content-Type", "application/pgp-keys")
+		w.Write([]byte(armored.String()))
+
+	case "index", "vindex":
+		if len(matches) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(machineReadableIndex(matches)))
+
+	default:
+		http.Error(w, "unsupported operation", http.StatusNotImplemented)
+	}
+}
+
+// searchKeys matches an email address, a 0x prefixed key ID or fingerprint
+// or a part of a user ID
+func searchKeys(keys []pgpfs.VaultKey, search string) []pgpfs.VaultKey {
+	search = strings.TrimSpace(search)
+	if search == "" {
+		return nil
+	}
+
+	var matches []pgpfs.VaultKey
+	if hex, ok := strings.CutPrefix(strings.ToLower(search), "0x"); ok {
+		for _, k := range keys {
+			fingerprint := strings.ToLower(k.PublicKey.GetFingerprint())
+			if fingerprint == hex || strings.HasSuffix(fingerprint, hex) && len(hex) >= 16 {
+				matches = append(matches, k)
+			}
+		}
+		return matches
+	}
+
+	needle := strings.ToLower(search)
+	for _, k := range keys {
+		for _, identity := range k.PublicKey.GetEntity().Identities {
+			if strings.EqualFold(identity.UserId.Email, search) || strings.Contains(strings.ToLower(identity.Name), needle) {
+				matches = append(matches, k)
+				break
+			}
+		}
+	}
+	return matches
+}
+
+// machineReadableIndex formats keys as the HKP machine readable index
+func machineReadableIndex(keys []pgpfs.VaultKey) string {
+	var out strings.Builder
+	fmt.Fprintf(&out, "info:1:%d\n", len(keys))
+	for _, k := range keys {
+		entity := k.PublicKey.GetEntity()
+		primary := entity.PrimaryKey
+		bits, _ := primary.BitLength()
+
+		flags := ""
+		if k.PublicKey.IsRevoked(0) {
+			flags = "r"
+		}
+		expires := ""
+		if selfSig, err := entity.PrimarySelfSignature(primary.CreationTime, nil); err == nil && selfSig.KeyLifetimeSecs != nil && *selfSig.KeyLifetimeSecs > 0 {
+			expires = fmt.Sprint(primary.CreationTime.Unix() + int64(*selfSig.KeyLifetimeSecs))
+		}
+
+		fmt.Fprintf(&out, "pub:%s:%d:%d:%d:%s:%s\n",
+			strings.ToUpper(k.PublicKey.GetFingerprint()), primary.PubKeyAlgo, bits, primary.CreationTime.Unix(), expires, flags)
+		for _, identity := range entity.Identities {
+			fmt.Fprintf(&out, "uid:%s:::\n", url.PathEscape(identity.Name))
+		}
+	}
+	return out.String()
+}
+
+// serveWKD answers the advanced and the direct Web Key Directory requests
+func serveWKD(w http.ResponseWriter, r *http.Request, keys []pgpfs.VaultKey) {
+	rest := strings.TrimPrefix(r.URL.Path, wkdPrefix)
+	parts := strings.Split(rest, "/")
+
+	var domain, hash string
+	switch {
+	case len(parts) == 1 && parts[0] == "policy":
+		w.WriteHeader(http.StatusOK)
+		return
+	case len(parts) == 2 && parts[1] == "policy":
+		w.WriteHeader(http.StatusOK)
+		return
+	case len(parts) == 2 && parts[0] == "hu":
+		domain, hash = requestDomain(r), parts[1]
+	case len(parts) == 3 && parts[1] == "hu":
+		domain, hash = strings.ToLower(parts[0]), parts[2]
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	var body bytes.Buffer
+	for _, k := range keys {
+		if !hasWKDAddress(k.PublicKey, domain, hash) {
+			continue
+		}
+		binary, err := k.PublicKey.GetPublicKey()
+		if err != nil {
+			http.Error(w, "failed to export key", http.StatusInternalServerError)
+			return
+		}
+		body.Write(binary)
+	}
+	if body.Len() == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(body.Bytes())
+}
+
+// requestDomain is the host of a direct WKD request, empty when the server
+// is reached by IP address, as is usual on a LAN
+func requestDomain(r *http.Request) string {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+	return strings.ToLower(host)
+}
+
+// hasWKDAddress reports whether key has a user ID whose local part hashes
+// to hash, in domain unless domain is empty
+func hasWKDAddress(key *crypto.Key, domain, hash string) bool {
+	for _, identity := range key.GetEntity().Identities {
+		email := identity.UserId.Email
+		at := strings.LastIndex(email, "@")
+		if at < 0 {
+			continue
+		}
+		if domain != "" && !strings.EqualFold(email[at+1:], domain) {
+			continue
+		}
+		if pgpdiscovery.WKDHash(email[:at]) == hash {
+			return true
+		}
+	}
+	return false
+}
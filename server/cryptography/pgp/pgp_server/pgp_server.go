@@ -0,0 +1,134 @@
+package pgpserver
+
+import (
+	"MindLockr/server/apperr"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultAddress is the standard HKP port on the loopback interface, listen
+// on 0.0.0.0 to reach the server from the local network
+const DefaultAddress = "127.0.0.1:11371"
+
+type (
+	// KeyServer is an optional read-only HKP and WKD server for the public
+	// keys of the vault on the allow-list
+	KeyServer struct {
+		mu        sync.Mutex
+		server    *http.Server
+		listener  net.Listener
+		startedAt time.Time
+		requests  atomic.Int64
+	}
+
+	StartRequest struct {
+		// Address is host:port to listen on, DefaultAddress when empty
+		Address string `json:"address"`
+	}
+
+	Status struct {
+		Running   bool      `json:"running"`
+		Address   string    `json:"address"`
+		StartedAt time.Time `json:"startedAt"`
+		Published int       `json:"published"`
+		Requests  int64     `json:"requests"`
+	}
+)
+
+func NewKeyServer() *KeyServer {
+	return &KeyServer{}
+}
+
+// Start listens on the requested address and serves the published keys
+func (ks *KeyServer) Start(req StartRequest) (Status, error) {
+	address := req.Address
+	if address == "" {
+		address = DefaultAddress
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return Status{}, fmt.Errorf("%w: invalid address: %v", apperr.ErrInvalidInput, err)
+	}
+
+	ks.mu.Lock()
+	if ks.server != nil {
+		ks.mu.Unlock()
+		return Status{}, fmt.Errorf("key server is already running on %s", ks.listener.Addr())
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		ks.mu.Unlock()
+		return Status{}, fmt.Errorf("failed to listen on %s: %v", address, err)
+	}
+
+	ks.requests.Store(0)
+	server := &http.Server{
+		Handler:           handler{onRequest: func() { ks.requests.Add(1) }},
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+	ks.server = server
+	ks.listener = listener
+	ks.startedAt = time.Now()
+	ks.mu.Unlock()
+
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			ks.mu.Lock()
+			if ks.server == server {
+				ks.server = nil
+				ks.listener = nil
+			}
+			ks.mu.Unlock()
+		}
+	}()
+
+	return ks.Status(), nil
+}
+
+// Stop shuts the server down, waiting briefly for running requests
+func (ks *KeyServer) Stop() error {
+	ks.mu.Lock()
+	server := ks.server
+	ks.server = nil
+	ks.listener = nil
+	ks.mu.Unlock()
+
+	if server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		server.Close()
+		return fmt.Errorf("failed to stop key server: %v", err)
+	}
+	return nil
+}
+
+// Status reports whether the server runs, where and how many keys it publishes
+func (ks *KeyServer) Status() Status {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+
+	status := Status{Requests: ks.requests.Load()}
+	if keys, err := publishedKeys(); err == nil {
+		status.Published = len(keys)
+	}
+	if ks.server == nil {
+		return status
+	}
+
+	status.Running = true
+	status.Address = ks.listener.Addr().String()
+	status.StartedAt = ks.startedAt
+	return status
+}
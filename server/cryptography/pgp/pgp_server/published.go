@@ -0,0 +1,163 @@
+package pgpserver
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/filesystem"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const publishedFileName = "published-keys.json"
+
+type (
+	PublishedKey struct {
+		KeyName     string   `json:"keyName"`
+		Fingerprint string   `json:"fingerprint"`
+		Emails      []string `json:"emails"`
+		Published   bool     `json:"published"`
+	}
+
+	PublishRequest struct {
+		Fingerprint string `json:"fingerprint"`
+		Published   bool   `json:"published"`
+	}
+)
+
+var publishedMu sync.Mutex
+
+// ListKeys lists the keys of the vault and whether the server publishes them
+func (ks *KeyServer) ListKeys() ([]PublishedKey, error) {
+	vaultKeys, err := pgpfs.VaultKeys()
+	if err != nil {
+		return nil, err
+	}
+	published, err := loadPublished()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]PublishedKey, 0, len(vaultKeys))
+	for _, k := range vaultKeys {
+		fingerprint := strings.ToUpper(k.PublicKey.GetFingerprint())
+		keys = append(keys, PublishedKey{
+			KeyName:     k.Name,
+			Fingerprint: fingerprint,
+			Emails:      keyEmails(k),
+			Published:   published[fingerprint],
+		})
+	}
+	return keys, nil
+}
+
+// SetPublished adds a key to or removes it from the keys the server
+// publishes, a running server picks the change up with the next request
+func (ks *KeyServer) SetPublished(req PublishRequest) error {
+	fingerprint := strings.ToUpper(strings.ReplaceAll(req.Fingerprint, " ", ""))
+	if fingerprint == "" {
+		return fmt.Errorf("%w: missing fingerprint", apperr.ErrInvalidInput)
+	}
+
+	publishedMu.Lock()
+	defer publishedMu.Unlock()
+
+	published, err := loadPublishedLocked()
+	if err != nil {
+		return err
+	}
+	if req.Published {
+		published[fingerprint] = true
+	} else {
+		delete(published, fingerprint)
+	}
+
+	list := make([]string, 0, len(published))
+	for fp := range published {
+		list = append(list, fp)
+	}
+	sort.Strings(list)
+
+	dir, err := filesystem.ConfigDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+	content, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode published keys: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, publishedFileName), content, 0600); err != nil {
+		return fmt.Errorf("failed to write published keys: %v", err)
+	}
+	return nil
+}
+
+// loadPublished returns the fingerprints of the published keys
+func loadPublished() (map[string]bool, error) {
+	publishedMu.Lock()
+	defer publishedMu.Unlock()
+	return loadPublishedLocked()
+}
+
+func loadPublishedLocked() (map[string]bool, error) {
+	dir, err := filesystem.ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	published := map[string]bool{}
+	content, err := os.ReadFile(filepath.Join(dir, publishedFileName))
+	if os.IsNotExist(err) {
+		return published, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read published keys: %v", err)
+	}
+
+	var list []string
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode published keys: %v", err)
+	}
+	for _, fp := range list {
+		published[strings.ToUpper(fp)] = true
+	}
+	return published, nil
+}
+
+// publishedKeys returns the vault keys on the allow-list
+func publishedKeys() ([]pgpfs.VaultKey, error) {
+	vaultKeys, err := pgpfs.VaultKeys()
+	if err != nil {
+		return nil, err
+	}
+	published, err := loadPublished()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []pgpfs.VaultKey
+	for _, k := range vaultKeys {
+		if published[strings.ToUpper(k.PublicKey.GetFingerprint())] {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func keyEmails(k pgpfs.VaultKey) []string {
+	emails := []string{}
+	for _, identity := range k.PublicKey.GetEntity().Identities {
+		if identity.UserId.Email != "" {
+			emails = append(emails, identity.UserId.Email)
+		}
+	}
+	sort.Strings(emails)
+	return emails
+}
@@ -0,0 +1,198 @@
+package pgptrust
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/cryptohelper"
+	"MindLockr/server/filesystem"
+	"MindLockr/server/filesystem/audit"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"MindLockr/server/filesystem/trust"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+type (
+	PgpTrust struct{}
+
+	ImportRequest struct {
+		KeyName   string `json:"keyName"`
+		PublicKey string `json:"publicKey"`
+	}
+
+	CertifyRequest struct {
+		// SignerKeyName is one of our keys in pgp-keys/
+		SignerKeyName string `json:"signerKeyName"`
+		Passphrase    string `json:"passphrase"`
+		TargetKeyName string `json:"targetKeyName"`
+		// UserIDs limits the certification to some user IDs, all valid
+		// user IDs are certified when empty
+		UserIDs []string `json:"userIds,omitempty"`
+	}
+
+	OwnerTrustRequest struct {
+		Fingerprint string `json:"fingerprint"`
+		Level       string `json:"level"`
+	}
+)
+
+// ImportPublicKey stores someone else's public key in pgp-keys/. A key that
+// is already stored may be replaced by a newer copy of itself, but never by a
+// different key, and keys with a private half are never touched
+func (pt *PgpTrust) ImportPublicKey(req ImportRequest) (fingerprint string, err error) {
+	defer func() {
+		audit.Record(audit.OpKeyImport, req.KeyName, fingerprint, err)
+	}()
+
+	keyDir, err := keyFolder(req.KeyName)
+	if err != nil {
+		return "", err
+	}
+
+	key, err := crypto.NewKeyFromArmored(req.PublicKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to load public key: %s", err)
+	}
+	if key.IsPrivate() {
+		return "", fmt.Errorf("refusing to import a private key as a public key")
+	}
+
+	if _, err := os.Stat(filepath.Join(keyDir, "private.asc")); err == nil {
+		return "", fmt.Errorf("%s is one of your own keys", req.KeyName)
+	}
+	if existing, err := os.ReadFile(filepath.Join(keyDir, "public.asc")); err == nil {
+		if existingKey, err := crypto.NewKeyFromArmored(string(existing)); err == nil {
+			if existingKey.GetFingerprint() != key.GetFingerprint() {
+				return "", fmt.Errorf("%s already holds a different key", req.KeyName)
+			}
+			// a fresh copy of the key must not drop our certifications of it
+			if key, err = cryptohelper.MergeKeys(existingKey, key); err != nil {
+				return "", fmt.Errorf("failed to merge the key with the stored copy: %w", err)
+			}
+		}
+	}
+
+	armored, err := key.GetArmoredPublicKey()
+	if err != nil {
+		return "", fmt.Errorf("failed while extracting armored public key: %s", err)
+	}
+	if err := pgpfs.SavePgpPublicKey(armored, req.KeyName); err != nil {
+		return "", fmt.Errorf("failed to save public key: %v", err)
+	}
+
+	return strings.ToUpper(key.GetFingerprint()), nil
+}
+
+// CertifyKey signs user IDs of another key with one of our keys, stating that
+// we verified they belong to its owner. The certifications are added to the
+// stored public key, so they are part of every export of it
+func (pt *PgpTrust) CertifyKey(req CertifyRequest) (certified []string, err error) {
+	if req.SignerKeyName == req.TargetKeyName {
+		return nil, fmt.Errorf("a key cannot certify itself")
+	}
+
+	signerDir, err := keyFolder(req.SignerKeyName)
+	if err != nil {
+		return nil, err
+	}
+	targetDir, err := keyFolder(req.TargetKeyName)
+	if err != nil {
+		return nil, err
+	}
+
+	privKeyArmor, err := os.ReadFile(filepath.Join(signerDir, "private.asc"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key file: %v", err)
+	}
+	signer, err := cryptohelper.UnlockPrivateKey(string(privKeyArmor), []byte(req.Passphrase))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock private key: %w", err)
+	}
+	defer signer.ClearPrivateParams()
+
+	pubKeyArmor, err := os.ReadFile(filepath.Join(targetDir, "public.asc"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key file: %v", err)
+	}
+	target, err := crypto.NewKeyFromArmored(string(pubKeyArmor))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load public key: %s", err)
+	}
+
+	defer func() {
+		audit.Record(audit.OpKeyCertify, req.TargetKeyName, target.GetFingerprint(), err)
+	}()
+
+	wanted := map[string]bool{}
+	for _, id := range req.UserIDs {
+		wanted[id] = true
+	}
+
+	config := &packet.Config{}
+	entity := target.GetEntity()
+	for name, ident := range entity.Identities {
+		if len(wanted) > 0 && !wanted[name] {
+			continue
+		}
+		if _, err := ident.Verify(config.Now(), config); err != nil {
+			continue
+		}
+		if err := ident.SignIdentity(signer.GetEntity(), config); err != nil {
+			return nil, fmt.Errorf("failed to certify %q: %s", name, err)
+		}
+		certified = append(certified, name)
+	}
+	if len(certified) == 0 {
+		return nil, fmt.Errorf("no valid user ID to certify")
+	}
+	sort.Strings(certified)
+
+	armored, err := target.GetArmoredPublicKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed while extracting armored public key: %s", err)
+	}
+	if err := pgpfs.SavePgpPublicKey(armored, req.TargetKeyName); err != nil {
+		return nil, fmt.Errorf("failed to save public key: %v", err)
+	}
+
+	return certified, nil
+}
+
+// SetOwnerTrust stores how far we trust the owner of a key to certify others
+func (pt *PgpTrust) SetOwnerTrust(req OwnerTrustRequest) error {
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+	return trust.SetOwnerTrust(folderPath, req.Fingerprint, req.Level)
+}
+
+// ListKeyValidity returns the computed validity of every key in the vault
+func (pt *PgpTrust) ListKeyValidity() ([]trust.KeyValidity, error) {
+	validity, err := trust.Current()
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]trust.KeyValidity, 0, len(validity))
+	for _, v := range validity {
+		list = append(list, v)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].KeyName < list[j].KeyName
+	})
+	return list, nil
+}
+
+func keyFolder(keyName string) (string, error) {
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+	if folderPath == "" {
+		return "", apperr.ErrNoVaultFolder
+	}
+	if keyName == "" || filepath.Base(keyName) != keyName {
+		return "", fmt.Errorf("invalid key name: %q", keyName)
+	}
+	return filepath.Join(folderPath, "pgp-keys", keyName), nil
+}
@@ -0,0 +1,23 @@
+package pgptrust
+
+import (
+	"MindLockr/server/filesystem"
+	"MindLockr/server/filesystem/trust"
+)
+
+// ListPins returns the key pinned for every correspondent's email address,
+// including key changes waiting for a decision
+func (pt *PgpTrust) ListPins() ([]trust.Pin, error) {
+	return trust.Pins(filesystem.GetFolderInstance().GetFolderPath())
+}
+
+// AcceptKeyChange pins the new key seen for an email address
+func (pt *PgpTrust) AcceptKeyChange(email string) (trust.Pin, error) {
+	return trust.ResolveConflict(filesystem.GetFolderInstance().GetFolderPath(), email, true)
+}
+
+// RejectKeyChange keeps the pinned key for an email address, messages signed
+// by the rejected key no longer verify
+func (pt *PgpTrust) RejectKeyChange(email string) (trust.Pin, error) {
+	return trust.ResolveConflict(filesystem.GetFolderInstance().GetFolderPath(), email, false)
+}
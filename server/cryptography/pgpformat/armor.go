@@ -0,0 +1,143 @@
+package pgpformat
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/filesystem"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+)
+
+const armorSettingsFileName = "armor-headers.json"
+
+type (
+	PgpFormat struct{}
+
+	// ArmorSettings are the headers written into armored messages. None are
+	// written by default, so messages do not tell which app made them
+	ArmorSettings struct {
+		Comment string `json:"comment,omitempty"`
+		// Headers are additional headers, like Charset
+		Headers map[string]string `json:"headers,omitempty"`
+	}
+)
+
+var (
+	armorMu       sync.Mutex
+	armorLoaded   bool
+	armorSettings ArmorSettings
+)
+
+// GetArmorSettings returns the armor headers currently written
+func (pf *PgpFormat) GetArmorSettings() ArmorSettings {
+	return CurrentArmorSettings()
+}
+
+// SetArmorSettings changes and persists the armor headers, empty settings
+// write no headers at all
+func (pf *PgpFormat) SetArmorSettings(settings ArmorSettings) error {
+	if err := settings.validate(); err != nil {
+		return err
+	}
+
+	armorMu.Lock()
+	defer armorMu.Unlock()
+
+	dir, err := filesystem.ConfigDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+
+	content, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode armor settings: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, armorSettingsFileName), content, 0600); err != nil {
+		return fmt.Errorf("failed to write armor settings: %v", err)
+	}
+
+	armorSettings = settings
+	armorLoaded = true
+	return nil
+}
+
+// CurrentArmorSettings returns the persisted armor settings, or none
+func CurrentArmorSettings() ArmorSettings {
+	armorMu.Lock()
+	defer armorMu.Unlock()
+
+	if armorLoaded {
+		return armorSettings
+	}
+	armorLoaded = true
+
+	dir, err := filesystem.ConfigDir()
+	if err != nil {
+		return armorSettings
+	}
+	content, err := os.ReadFile(filepath.Join(dir, armorSettingsFileName))
+	if err != nil {
+		return armorSettings
+	}
+
+	var loaded ArmorSettings
+	if err := json.Unmarshal(content, &loaded); err == nil && loaded.validate() == nil {
+		armorSettings = loaded
+	}
+	return armorSettings
+}
+
+func (s ArmorSettings) validate() error {
+	if strings.ContainsAny(s.Comment, "\r\n") {
+		return fmt.Errorf("%w: the comment must be a single line", apperr.ErrInvalidInput)
+	}
+	for key, value := range s.Headers {
+		if key == "" || strings.ContainsAny(key, ": \t\r\n") {
+			return fmt.Errorf("%w: invalid armor header name %q", apperr.ErrInvalidInput, key)
+		}
+		if strings.EqualFold(key, "Comment") {
+			return fmt.Errorf("%w: set the comment on its own", apperr.ErrInvalidInput)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("%w: the value of %s must be a single line", apperr.ErrInvalidInput, key)
+		}
+	}
+	return nil
+}
+
+func (s ArmorSettings) headers() map[string]string {
+	headers := make(map[string]string, len(s.Headers)+1)
+	for key, value := range s.Headers {
+		headers[key] = value
+	}
+	if s.Comment != "" {
+		headers["Comment"] = s.Comment
+	}
+	return headers
+}
+
+// Armor armors binary data as blockType, for example "PGP MESSAGE", with
+// the configured headers
+func Armor(data []byte, blockType string) ([]byte, error) {
+	var out bytes.Buffer
+	w, err := armor.Encode(&out, blockType, CurrentArmorSettings().headers())
+	if err != nil {
+		return nil, fmt.Errorf("failed to armor the data: %s", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to armor the data: %s", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to armor the data: %s", err)
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,120 @@
+package pgpformat
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultFilename is used when a message carries no usable filename
+const DefaultFilename = "decrypted"
+
+// windowsReserved are device names Windows refuses as filenames, with or
+// without an extension
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SafeFilename turns the filename of a message, which the sender chose,
+// into a name that stays inside the folder it is written to
+func SafeFilename(name string) string {
+	// only the last element of a path counts, whichever separator it uses
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r), strings.ContainsRune(`<>:"|?*`, r):
+			return '_'
+		case r == unicode.ReplacementChar:
+			return -1
+		}
+		return r
+	}, name)
+
+	// hidden files and trailing dots or spaces, which Windows drops
+	name = strings.TrimLeft(name, ". ")
+	name = strings.TrimRight(name, ". ")
+
+	base, _, _ := strings.Cut(name, ".")
+	if name == "" || windowsReserved[strings.ToUpper(base)] {
+		return DefaultFilename
+	}
+
+	for len(name) > 255 {
+		ext := filepath.Ext(name)
+		if len(ext) > 32 || len(ext) >= len(name) {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:255-len(ext)], "") + ext
+	}
+	return name
+}
+
+// WriteFile writes decrypted data into dir under the sanitized filename of
+// meta, never replacing an existing file, and restores its modification
+// time. It returns the path written
+func WriteFile(dir string, meta *Metadata, data []byte) (string, error) {
+	f, err := CreateFile(dir, meta)
+	if err != nil {
+		return "", err
+	}
+	path := f.Name()
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	RestoreModTime(path, meta)
+	return path, nil
+}
+
+// CreateFile creates a new file in dir under the sanitized filename of meta.
+// An existing file is never replaced, " (n)" is appended to the name
+// instead
+func CreateFile(dir string, meta *Metadata) (*os.File, error) {
+	name := DefaultFilename
+	if meta != nil && meta.Filename != "" {
+		name = SafeFilename(meta.Filename)
+	}
+
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+
+	for i := 0; ; i++ {
+		candidate := name
+		if i > 0 {
+			candidate = fmt.Sprintf("%s (%d)%s", stem, i, ext)
+		}
+		path := filepath.Join(dir, candidate)
+
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create %s: %w", path, err)
+		}
+		return f, nil
+	}
+}
+
+// RestoreModTime sets the modification time of path to the one in meta,
+// when the message has one
+func RestoreModTime(path string, meta *Metadata) {
+	if meta != nil && meta.ModTime > 0 {
+		modTime := time.Unix(meta.ModTime, 0)
+		os.Chtimes(path, modTime, modTime)
+	}
+}
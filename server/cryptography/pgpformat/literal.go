@@ -0,0 +1,132 @@
+package pgpformat
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+	"unicode/utf8"
+
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/ProtonMail/gopenpgp/v3/profile"
+)
+
+// Metadata is what the literal data packet of a message tells about the
+// encrypted content
+type Metadata struct {
+	Filename string `json:"filename,omitempty"`
+	// ModTime is the modification time in unix seconds, 0 when unknown
+	ModTime int64 `json:"modTime,omitempty"`
+	// Binary is false for UTF-8 text
+	Binary bool `json:"binary"`
+}
+
+type EncryptParams struct {
+	Recipients *crypto.KeyRing
+	// Signer is an unlocked private key, the message is unsigned when nil
+	Signer *crypto.Key
+	// Password encrypts the message symmetrically, alone or in addition to
+	// the recipients
+	Password []byte
+}
+
+// ReadFile reads a file to encrypt along with its name and modification time
+func ReadFile(path string) ([]byte, Metadata, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, Metadata{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return nil, Metadata{}, fmt.Errorf("%s is a directory", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, Metadata{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return data, Metadata{
+		Filename: filepath.Base(path),
+		ModTime:  info.ModTime().Unix(),
+		Binary:   !utf8.Valid(data),
+	}, nil
+}
+
+// MetadataOf converts the metadata of a decryption result, it returns nil
+// when the message carries neither a filename nor a modification time
+func MetadataOf(meta *crypto.LiteralMetadata) *Metadata {
+	if meta == nil || meta.Filename() == "" && meta.Time() == 0 {
+		return nil
+	}
+	return &Metadata{
+		Filename: meta.Filename(),
+		ModTime:  meta.Time(),
+		Binary:   !meta.IsUtf8(),
+	}
+}
+
+// Encrypt encrypts data into a binary message whose literal data packet
+// holds meta
+func Encrypt(data []byte, meta Metadata, params EncryptParams) ([]byte, error) {
+	var out bytes.Buffer
+	plaintext, err := EncryptingWriter(&out, meta, params)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := plaintext.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to encrypt the data: %s", err)
+	}
+	if err := plaintext.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encrypt the data: %s", err)
+	}
+	return out.Bytes(), nil
+}
+
+// EncryptingWriter returns a writer that encrypts what is written to it
+// into a binary message on out, the message is complete once the writer is
+// closed. gopenpgp always writes empty metadata, so the message is written
+// with go-crypto using the settings of the default profile
+func EncryptingWriter(out io.Writer, meta Metadata, params EncryptParams) (io.WriteCloser, error) {
+	hints := &openpgp.FileHints{
+		IsUTF8:   !meta.Binary,
+		FileName: meta.Filename,
+	}
+	if meta.ModTime > 0 {
+		hints.ModTime = time.Unix(meta.ModTime, 0)
+	}
+
+	encryptParams := &openpgp.EncryptParams{
+		Hints:  hints,
+		Config: profile.Default().EncryptionConfig(),
+	}
+	if params.Signer != nil {
+		encryptParams.Signers = []*openpgp.Entity{params.Signer.GetEntity()}
+	}
+
+	var recipients []*openpgp.Entity
+	if params.Recipients != nil {
+		for _, key := range params.Recipients.GetKeys() {
+			recipients = append(recipients, key.GetEntity())
+		}
+	}
+
+	var plaintext io.WriteCloser
+	var err error
+	switch {
+	case len(recipients) > 0:
+		if params.Password != nil {
+			encryptParams.Passwords = [][]byte{params.Password}
+		}
+		plaintext, err = openpgp.EncryptWithParams(out, recipients, nil, encryptParams)
+	case params.Password != nil:
+		plaintext, err = openpgp.SymmetricallyEncryptWithParams(params.Password, out, encryptParams)
+	default:
+		return nil, fmt.Errorf("the message needs a recipient or a password")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt the data: %s", err)
+	}
+	return plaintext, nil
+}
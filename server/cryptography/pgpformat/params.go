@@ -0,0 +1,86 @@
+package pgpformat
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/passphrase"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"fmt"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// ParamsRequest names what a message is encrypted to and signed with
+type ParamsRequest struct {
+	// Passphrase encrypts the message symmetrically, alone or in addition
+	// to the recipients
+	Passphrase string
+	// Recipients are the names of the vault keys to encrypt to
+	Recipients []string
+	// SignerKeyName is our key that signs the message, optional
+	SignerKeyName    string
+	SignerPassphrase string
+}
+
+// ResolveParams looks up the recipients and unlocks the signer of req, the
+// passphrase must satisfy the passphrase policy. release clears the
+// signer's key
+func ResolveParams(req ParamsRequest) (EncryptParams, func(), error) {
+	var params EncryptParams
+	noop := func() {}
+
+	if req.Passphrase == "" && len(req.Recipients) == 0 {
+		return params, noop, fmt.Errorf("%w: choose a passphrase or at least one recipient", apperr.ErrInvalidInput)
+	}
+	if req.Passphrase != "" {
+		if err := passphrase.Enforce(req.Passphrase); err != nil {
+			return params, noop, err
+		}
+		params.Password = []byte(req.Passphrase)
+	}
+
+	if len(req.Recipients) == 0 && req.SignerKeyName == "" {
+		return params, noop, nil
+	}
+
+	vaultKeys, err := pgpfs.VaultKeys()
+	if err != nil {
+		return params, noop, err
+	}
+	find := func(name string) (*pgpfs.VaultKey, error) {
+		for i := range vaultKeys {
+			if vaultKeys[i].Name == name {
+				return &vaultKeys[i], nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %s", apperr.ErrKeyNotFound, name)
+	}
+
+	if len(req.Recipients) > 0 {
+		if params.Recipients, err = crypto.NewKeyRing(nil); err != nil {
+			return params, noop, fmt.Errorf("failed to create key ring: %s", err)
+		}
+		for _, name := range req.Recipients {
+			k, err := find(name)
+			if err != nil {
+				return params, noop, err
+			}
+			if err := params.Recipients.AddKey(k.PublicKey); err != nil {
+				return params, noop, fmt.Errorf("failed to add %s to the key ring: %s", name, err)
+			}
+		}
+	}
+
+	if req.SignerKeyName == "" {
+		return params, noop, nil
+	}
+	signer, err := find(req.SignerKeyName)
+	if err != nil {
+		return params, noop, err
+	}
+	key, release, err := signer.PrivateKey(req.SignerPassphrase)
+	if err != nil {
+		return params, noop, err
+	}
+	params.Signer = key
+	return params, release, nil
+}
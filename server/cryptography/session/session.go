@@ -0,0 +1,210 @@
+package session
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/cryptohelper"
+	"MindLockr/server/filesystem"
+	"MindLockr/server/filesystem/audit"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// DefaultTimeout is how long a session stays unlocked without being used
+const DefaultTimeout = 5 * time.Minute
+
+// ErrLocked is returned when an operation needs an unlocked session
+var ErrLocked = apperr.ErrSessionLocked
+
+type (
+	Session struct{}
+
+	UnlockRequest struct {
+		KeyName        string `json:"keyName"`
+		Passphrase     string `json:"passphrase"`
+		TimeoutMinutes int    `json:"timeoutMinutes,omitempty"`
+	}
+
+	Status struct {
+		Unlocked    bool      `json:"unlocked"`
+		KeyName     string    `json:"keyName,omitempty"`
+		Fingerprint string    `json:"fingerprint,omitempty"`
+		ExpiresAt   time.Time `json:"expiresAt,omitempty"`
+	}
+)
+
+// the unlocked vault key is kept in memory only and cleared on lock
+var (
+	mu        sync.Mutex
+	key       *crypto.Key
+	keyName   string
+	timeout   time.Duration
+	expiresAt time.Time
+	timer     *time.Timer
+	hookOnce  sync.Once
+)
+
+// Unlock unlocks a key from pgp-keys/ and keeps it in memory until Lock is
+// called, the vault folder changes or the session was idle for too long
+func (s *Session) Unlock(req UnlockRequest) (Status, error) {
+	hookOnce.Do(func() {
+		filesystem.OnFolderChange(func(string) { lock() })
+	})
+
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+	if folderPath == "" {
+		return Status{}, apperr.ErrNoVaultFolder
+	}
+
+	privKeyArmor, err := os.ReadFile(filepath.Join(folderPath, "pgp-keys", req.KeyName, "private.asc"))
+	if err != nil {
+		return Status{}, fmt.Errorf("failed to read private key file: %w", err)
+	}
+
+	lockedKey, err := crypto.NewKeyFromArmored(string(privKeyArmor))
+	if err != nil {
+		return Status{}, fmt.Errorf("failed to parse armored private key: %w: %v", apperr.ErrInvalidKey, err)
+	}
+
+	unlockedKey, err := cryptohelper.UnlockPrivateKey(string(privKeyArmor), []byte(req.Passphrase))
+	audit.Record(audit.OpKeyUnlock, req.KeyName, lockedKey.GetFingerprint(), err)
+	if err != nil {
+		return Status{}, fmt.Errorf("failed to unlock private key: %w", err)
+	}
+	audit.SignIfDue(unlockedKey)
+
+	lock()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	key = unlockedKey
+	keyName = req.KeyName
+	timeout = DefaultTimeout
+	if req.TimeoutMinutes > 0 {
+		timeout = time.Duration(req.TimeoutMinutes) * time.Minute
+	}
+	touch()
+
+	return status(), nil
+}
+
+// Lock clears the unlocked key from memory
+func (s *Session) Lock() {
+	lock()
+}
+
+// Status reports whether a key is unlocked and until when
+func (s *Session) Status() Status {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return status()
+}
+
+// Current returns a copy of the unlocked session key and extends the
+// session. The copy belongs to the caller, who clears it once done with it:
+// locking the session only clears the session's own key, so a copy in use
+// is never cleared under the caller
+func Current() (*crypto.Key, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if key == nil {
+		return nil, ErrLocked
+	}
+	copied, err := key.Copy()
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy the session key: %v", err)
+	}
+	touch()
+	return copied, nil
+}
+
+// KeyName returns the name of the key folder of the unlocked session key
+func KeyName() string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return keyName
+}
+
+// Fingerprint returns the fingerprint of the unlocked session key, or an
+// empty string when the session is locked
+func Fingerprint() string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if key == nil {
+		return ""
+	}
+	return key.GetFingerprint()
+}
+
+func lock() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if timer != nil {
+		timer.Stop()
+		timer = nil
+	}
+	if key != nil {
+		key.ClearPrivateParams()
+	}
+	key = nil
+	keyName = ""
+	expiresAt = time.Time{}
+}
+
+// touch must be called with mu held
+func touch() {
+	expiresAt = time.Now().Add(timeout)
+	if timer != nil {
+		timer.Stop()
+	}
+	timer = time.AfterFunc(timeout, lock)
+}
+
+// status must be called with mu held
+func status() Status {
+	if key == nil {
+		return Status{}
+	}
+	return Status{
+		Unlocked:    true,
+		KeyName:     keyName,
+		Fingerprint: key.GetFingerprint(),
+		ExpiresAt:   expiresAt,
+	}
+}
+
+// Seal encrypts data to the session key and signs it with the same key, the
+// result is an armored PGP message. hybdec.OpenSealed reads it back
+func Seal(data []byte) ([]byte, error) {
+	key, err := Current()
+	if err != nil {
+		return nil, err
+	}
+	defer key.ClearPrivateParams()
+
+	encHandle, err := crypto.PGP().Encryption().Recipient(key).SigningKey(key).New()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create encryption handle: %s", err)
+	}
+
+	pgpMessage, err := encHandle.Encrypt(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt data: %s", err)
+	}
+
+	armored, err := pgpMessage.ArmorBytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to armor message: %s", err)
+	}
+	return armored, nil
+}
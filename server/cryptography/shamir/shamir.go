@@ -0,0 +1,137 @@
+package shamir
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// Split divides secret into n shares of which any k are enough to rebuild it.
+// Every byte of the secret is the constant term of its own random polynomial
+// of degree k-1 over GF(2^8). The first byte of each share is its x coordinate
+func Split(secret []byte, n, k int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("cannot split an empty secret")
+	}
+	if k < 2 {
+		return nil, fmt.Errorf("threshold must be at least 2")
+	}
+	if n < k {
+		return nil, fmt.Errorf("number of shares (%d) must not be lower than the threshold (%d)", n, k)
+	}
+	if n > 255 {
+		return nil, fmt.Errorf("at most 255 shares are supported")
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][0] = byte(i + 1)
+	}
+
+	coefficients := make([]byte, k)
+	for idx, b := range secret {
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, fmt.Errorf("failed to read random coefficients: %v", err)
+		}
+		coefficients[0] = b
+
+		for i := range shares {
+			shares[i][idx+1] = evaluate(coefficients, shares[i][0])
+		}
+	}
+
+	clear(coefficients)
+	return shares, nil
+}
+
+// Combine rebuilds the secret from at least threshold shares using Lagrange
+// interpolation at x = 0
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, fmt.Errorf("at least 2 shares are required")
+	}
+
+	length := len(shares[0])
+	if length < 2 {
+		return nil, fmt.Errorf("share is too short")
+	}
+
+	seen := make(map[byte]bool, len(shares))
+	xs := make([]byte, len(shares))
+	for i, share := range shares {
+		if len(share) != length {
+			return nil, fmt.Errorf("shares have different lengths")
+		}
+		if share[0] == 0 {
+			return nil, fmt.Errorf("share %d has an invalid index", i+1)
+		}
+		if seen[share[0]] {
+			return nil, fmt.Errorf("share %d was provided twice", share[0])
+		}
+		seen[share[0]] = true
+		xs[i] = share[0]
+	}
+
+	secret := make([]byte, length-1)
+	for idx := range secret {
+		var value byte
+		for i, xi := range xs {
+			// lagrange basis polynomial for xi evaluated at 0
+			basis := byte(1)
+			for j, xj := range xs {
+				if i == j {
+					continue
+				}
+				basis = mul(basis, div(xj, add(xi, xj)))
+			}
+			value = add(value, mul(shares[i][idx+1], basis))
+		}
+		secret[idx] = value
+	}
+
+	return secret, nil
+}
+
+// evaluate computes the polynomial at x using Horner's method
+func evaluate(coefficients []byte, x byte) byte {
+	result := coefficients[len(coefficients)-1]
+	for i := len(coefficients) - 2; i >= 0; i-- {
+		result = add(mul(result, x), coefficients[i])
+	}
+	return result
+}
+
+// arithmetic in GF(2^8) with the AES reduction polynomial x^8+x^4+x^3+x+1.
+// Share bytes are secret, so mul and inverse take the same time whatever
+// their operands: there are no branches or table lookups on the values
+
+func add(a, b byte) byte {
+	return a ^ b
+}
+
+func mul(a, b byte) byte {
+	var product byte
+	for i := 0; i < 8; i++ {
+		// -(b & 1) is 0xff when the low bit of b is set and 0 otherwise
+		product ^= a & -(b & 1)
+		a = a<<1 ^ 0x1b&-(a>>7)
+		b >>= 1
+	}
+	return product
+}
+
+func div(a, b byte) byte {
+	if b == 0 {
+		panic("shamir: division by zero")
+	}
+	return mul(a, inverse(b))
+}
+
+// inverse returns b^254 which is the multiplicative inverse of b in GF(2^8)
+func inverse(b byte) byte {
+	result := byte(1)
+	for i := 0; i < 254; i++ {
+		result = mul(result, b)
+	}
+	return result
+}
@@ -0,0 +1,381 @@
+package shamir
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/cryptohelper"
+	"MindLockr/server/cryptography/passphrase"
+	"MindLockr/server/filesystem"
+	"MindLockr/server/filesystem/audit"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+const (
+	// ShareBlockType is the armor type of a single plain share
+	ShareBlockType = "MINDLOCKR SECRET SHARE"
+
+	kindPrivateKey = "private-key"
+	kindSecret     = "secret"
+
+	// trailing digest appended to the secret before splitting so a wrong
+	// combination of shares is detected instead of yielding garbage
+	digestSize = 8
+)
+
+type (
+	SecretSharing struct{}
+
+	SplitRequest struct {
+		KeyName    string `json:"keyName,omitempty"`
+		Passphrase string `json:"passphrase,omitempty"`
+		Secret     string `json:"secret,omitempty"`
+		Shares     int    `json:"shares"`
+		Threshold  int    `json:"threshold"`
+		// Trustees holds, per share, the name of a key in pgp-keys/ the share
+		// gets encrypted to. Empty names leave the share unencrypted
+		Trustees []string `json:"trustees,omitempty"`
+	}
+
+	Share struct {
+		Index   int    `json:"index"`
+		Trustee string `json:"trustee,omitempty"`
+		Armor   string `json:"armor"`
+	}
+
+	// Unlock is a private key that can decrypt shares encrypted to a trustee
+	Unlock struct {
+		KeyName    string `json:"keyName"`
+		Passphrase string `json:"passphrase"`
+	}
+
+	CombineRequest struct {
+		Shares  []string `json:"shares"`
+		Unlocks []Unlock `json:"unlocks,omitempty"`
+		// KeyName and NewPassphrase are used when a private key is restored
+		// into the vault
+		KeyName       string `json:"keyName,omitempty"`
+		NewPassphrase string `json:"newPassphrase,omitempty"`
+	}
+)
+
+// SplitPrivateKey unlocks a key from pgp-keys/ and splits it into shares
+func (ss *SecretSharing) SplitPrivateKey(req SplitRequest) (shares []Share, err error) {
+	var fingerprint string
+	defer func() {
+		audit.Record(audit.OpKeySplit, req.KeyName, fingerprint, err)
+	}()
+
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+	if folderPath == "" {
+		return nil, apperr.ErrNoVaultFolder
+	}
+
+	privKeyArmor, err := os.ReadFile(filepath.Join(folderPath, "pgp-keys", req.KeyName, "private.asc"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key file: %v", err)
+	}
+
+	key, err := cryptohelper.UnlockPrivateKey(string(privKeyArmor), []byte(req.Passphrase))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock private key: %w", err)
+	}
+	defer key.ClearPrivateParams()
+	fingerprint = key.GetFingerprint()
+
+	serialized, err := key.Serialize()
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize private key: %v", err)
+	}
+	defer clear(serialized)
+
+	return split(serialized, kindPrivateKey, fingerprint, req)
+}
+
+// SplitSecret splits an arbitrary secret, e.g. a vault master passphrase
+func (ss *SecretSharing) SplitSecret(req SplitRequest) ([]Share, error) {
+	if req.Secret == "" {
+		return nil, fmt.Errorf("secret must not be empty")
+	}
+
+	return split([]byte(req.Secret), kindSecret, "", req)
+}
+
+// CombinePrivateKey rebuilds a private key from the shares, locks it with the
+// new passphrase and stores it in pgp-keys/<KeyName>. An existing key is
+// never replaced
+func (ss *SecretSharing) CombinePrivateKey(req CombineRequest) (fingerprint string, err error) {
+	defer func() {
+		audit.Record(audit.OpKeyRestore, req.KeyName, fingerprint, err)
+	}()
+
+	if err := checkNewKeyName(req.KeyName); err != nil {
+		return "", err
+	}
+	if err := passphrase.Enforce(req.NewPassphrase); err != nil {
+		return "", err
+	}
+
+	secret, kind, err := combine(req)
+	if err != nil {
+		return "", err
+	}
+	defer clear(secret)
+
+	if kind != kindPrivateKey {
+		return "", fmt.Errorf("shares do not belong to a private key")
+	}
+
+	key, err := crypto.NewKey(secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to load restored private key: %v", err)
+	}
+	defer key.ClearPrivateParams()
+
+	pubKey, err := key.GetArmoredPublicKey()
+	if err != nil {
+		return "", fmt.Errorf("failed while extracting armored public key: %s", err)
+	}
+
+	lockedKey, err := crypto.PGP().LockKey(key, []byte(req.NewPassphrase))
+	if err != nil {
+		return "", fmt.Errorf("error when locking the private key: %s", err)
+	}
+
+	privKey, err := lockedKey.Armor()
+	if err != nil {
+		return "", fmt.Errorf("failed while extracting armored private key: %s", err)
+	}
+
+	if err := pgpfs.SavePgpPrivKey(privKey, req.KeyName); err != nil {
+		return "", fmt.Errorf("failed to save private key: %v", err)
+	}
+	if err := pgpfs.SavePgpPublicKey(pubKey, req.KeyName); err != nil {
+		return "", fmt.Errorf("failed to save public key: %v", err)
+	}
+
+	return key.GetFingerprint(), nil
+}
+
+// checkNewKeyName makes sure a restored key gets a folder of its own in
+// pgp-keys/
+func checkNewKeyName(keyName string) error {
+	if keyName == "" {
+		return fmt.Errorf("%w: a key name is required to restore the key", apperr.ErrInvalidInput)
+	}
+	if filepath.Base(keyName) != keyName || keyName == "." || keyName == ".." {
+		return fmt.Errorf("%w: invalid key name %q", apperr.ErrInvalidInput, keyName)
+	}
+
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+	if folderPath == "" {
+		return apperr.ErrNoVaultFolder
+	}
+	if _, err := os.Lstat(filepath.Join(folderPath, "pgp-keys", keyName)); !os.IsNotExist(err) {
+		return fmt.Errorf("%w: a key named %s already exists", apperr.ErrInvalidInput, keyName)
+	}
+	return nil
+}
+
+// CombineSecret rebuilds a secret split with SplitSecret
+func (ss *SecretSharing) CombineSecret(req CombineRequest) (string, error) {
+	secret, kind, err := combine(req)
+	if err != nil {
+		return "", err
+	}
+
+	if kind != kindSecret {
+		clear(secret)
+		return "", fmt.Errorf("shares belong to a private key, restore it into the vault instead")
+	}
+
+	return string(secret), nil
+}
+
+func split(secret []byte, kind, fingerprint string, req SplitRequest) ([]Share, error) {
+	if len(req.Trustees) > req.Shares {
+		return nil, fmt.Errorf("got %d trustees for %d shares", len(req.Trustees), req.Shares)
+	}
+
+	digest := sha256.Sum256(secret)
+	payload := append(append([]byte{}, secret...), digest[:digestSize]...)
+	defer clear(payload)
+
+	rawShares, err := Split(payload, req.Shares, req.Threshold)
+	if err != nil {
+		return nil, err
+	}
+
+	setID := make([]byte, 8)
+	if _, err := rand.Read(setID); err != nil {
+		return nil, fmt.Errorf("failed to generate share set id: %v", err)
+	}
+
+	shares := make([]Share, 0, len(rawShares))
+	for i, raw := range rawShares {
+		headers := map[string]string{
+			"Share":     fmt.Sprintf("%d/%d", i+1, req.Shares),
+			"Threshold": strconv.Itoa(req.Threshold),
+			"Set":       hex.EncodeToString(setID),
+			"Kind":      kind,
+		}
+		if fingerprint != "" {
+			headers["Fingerprint"] = fingerprint
+		}
+
+		armored, err := armorShare(raw, headers)
+		clear(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		share := Share{Index: i + 1, Armor: armored}
+
+		if i < len(req.Trustees) && req.Trustees[i] != "" {
+			share.Trustee = req.Trustees[i]
+			share.Armor, err = encryptToTrustee(armored, req.Trustees[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		shares = append(shares, share)
+	}
+
+	return shares, nil
+}
+
+func combine(req CombineRequest) ([]byte, string, error) {
+	var raw [][]byte
+	var kind, setID string
+
+	for i, shareArmor := range req.Shares {
+		plain, err := decryptShare(shareArmor, req.Unlocks)
+		if err != nil {
+			return nil, "", fmt.Errorf("share %d: %v", i+1, err)
+		}
+
+		block, err := armor.Decode(strings.NewReader(plain))
+		if err != nil {
+			return nil, "", fmt.Errorf("share %d is not a valid armored share: %v", i+1, err)
+		}
+		if block.Type != ShareBlockType {
+			return nil, "", fmt.Errorf("share %d has unexpected armor type %q", i+1, block.Type)
+		}
+
+		body, err := io.ReadAll(block.Body)
+		if err != nil {
+			return nil, "", fmt.Errorf("share %d is corrupt: %v", i+1, err)
+		}
+
+		if setID == "" {
+			setID, kind = block.Header["Set"], block.Header["Kind"]
+		} else if block.Header["Set"] != setID {
+			return nil, "", fmt.Errorf("share %d belongs to a different split", i+1)
+		}
+
+		raw = append(raw, body)
+	}
+
+	payload, err := Combine(raw)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(payload) <= digestSize {
+		return nil, "", fmt.Errorf("reconstructed secret is too short")
+	}
+
+	secret := payload[:len(payload)-digestSize]
+	digest := sha256.Sum256(secret)
+	if !bytes.Equal(digest[:digestSize], payload[len(payload)-digestSize:]) {
+		clear(payload)
+		return nil, "", fmt.Errorf("shares do not reconstruct the secret, check that enough valid shares were provided")
+	}
+
+	return secret, kind, nil
+}
+
+func armorShare(raw []byte, headers map[string]string) (string, error) {
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, ShareBlockType, headers)
+	if err != nil {
+		return "", fmt.Errorf("failed to armor share: %v", err)
+	}
+	if _, err := w.Write(raw); err != nil {
+		return "", fmt.Errorf("failed to armor share: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("failed to armor share: %v", err)
+	}
+	return buf.String(), nil
+}
+
+func encryptToTrustee(shareArmor, trustee string) (string, error) {
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+	pubKeyArmor, err := os.ReadFile(filepath.Join(folderPath, "pgp-keys", trustee, "public.asc"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read public key of trustee %s: %v", trustee, err)
+	}
+
+	pubKey, err := crypto.NewKeyFromArmored(string(pubKeyArmor))
+	if err != nil {
+		return "", fmt.Errorf("failed to load public key of trustee %s: %v", trustee, err)
+	}
+
+	encHandle, err := crypto.PGP().Encryption().Recipient(pubKey).New()
+	if err != nil {
+		return "", fmt.Errorf("failed to create encryption handle: %s", err)
+	}
+
+	pgpMessage, err := encHandle.Encrypt([]byte(shareArmor))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt share to %s: %s", trustee, err)
+	}
+
+	return pgpMessage.Armor()
+}
+
+// decryptShare returns the plain share armor, decrypting it with one of the
+// provided keys when it was encrypted to a trustee
+func decryptShare(shareArmor string, unlocks []Unlock) (string, error) {
+	if !crypto.IsPGPMessage(shareArmor) {
+		return shareArmor, nil
+	}
+
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+	for _, unlock := range unlocks {
+		privKeyArmor, err := os.ReadFile(filepath.Join(folderPath, "pgp-keys", unlock.KeyName, "private.asc"))
+		if err != nil {
+			continue
+		}
+		key, err := cryptohelper.UnlockPrivateKey(string(privKeyArmor), []byte(unlock.Passphrase))
+		if err != nil {
+			continue
+		}
+
+		decHandle, err := crypto.PGP().Decryption().DecryptionKey(key).New()
+		if err != nil {
+			key.ClearPrivateParams()
+			continue
+		}
+		decrypted, err := decHandle.Decrypt([]byte(shareArmor), crypto.Armor)
+		decHandle.ClearPrivateParams()
+		key.ClearPrivateParams()
+		if err == nil {
+			return string(decrypted.Bytes()), nil
+		}
+	}
+
+	return "", fmt.Errorf("share is encrypted to a trustee and none of the provided keys can decrypt it")
+}
@@ -0,0 +1,246 @@
+package audit
+
+import (
+	"MindLockr/server/filesystem"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	auditDir    = "audit"
+	logFileName = "audit.log"
+)
+
+// operations recorded in the audit log
+const (
+	OpKeyGenerate     = "key.generate"
+	OpKeyUnlock       = "key.unlock"
+	OpKeySplit        = "key.split"
+	OpKeyRestore      = "key.restore"
+	OpKeyEdit         = "key.edit"
+	OpKeyImport       = "key.import"
+	OpKeyCertify      = "key.certify"
+	OpKeyRefresh      = "key.refresh"
+	OpDecrypt         = "decrypt"
+	OpVerify          = "verify"
+	OpDelete          = "delete"
+	OpRevisionRestore = "revision.restore"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Entry is a single audit record. It must never contain secrets, only
+// identifiers of what was touched
+type Entry struct {
+	Seq         uint64    `json:"seq"`
+	Time        time.Time `json:"time"`
+	Operation   string    `json:"operation"`
+	ItemID      string    `json:"itemId"`
+	Fingerprint string    `json:"fingerprint"`
+	Outcome     string    `json:"outcome"`
+	PrevHash    string    `json:"prevHash"`
+	Hash        string    `json:"hash"`
+}
+
+var mu sync.Mutex
+
+// logHead is the last entry appended to the log of a vault, appending does
+// not read the whole log again. size is the size of the log after that
+// entry, a log changed by anything else is read again. Guarded by mu
+type logHead struct {
+	folderPath string
+	seq        uint64
+	hash       string
+	size       int64
+}
+
+var head logHead
+
+// resetHead forgets the cached head, e.g. when another vault is opened
+func resetHead() {
+	mu.Lock()
+	defer mu.Unlock()
+	head = logHead{}
+}
+
+// maxWriteFailures is how many failed log writes are kept per vault
+const maxWriteFailures = 20
+
+var (
+	failuresMu sync.Mutex
+	// writeFailures are the log writes that failed since the app started, by
+	// vault folder. They cannot go into the log, VerifyLog reports them
+	writeFailures = map[string][]string{}
+)
+
+// Record appends an entry for the operation to the audit log of the currently
+// opened vault. A nil err is recorded as success. Failing to write the log
+// never fails the audited operation itself, the failure is reported by
+// VerifyLog instead
+func Record(operation, itemID, fingerprint string, err error) {
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+	if folderPath == "" {
+		return
+	}
+
+	outcome := OutcomeSuccess
+	if err != nil {
+		outcome = OutcomeFailure
+	}
+
+	if _, appendErr := appendEntry(folderPath, Entry{
+		Operation:   operation,
+		ItemID:      itemID,
+		Fingerprint: fingerprint,
+		Outcome:     outcome,
+	}); appendErr != nil {
+		recordFailure(folderPath, fmt.Errorf("failed to write the %s entry: %w", operation, appendErr))
+	}
+}
+
+func recordFailure(folderPath string, err error) {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+
+	failures := append(writeFailures[folderPath], fmt.Sprintf("%s: %s", time.Now().UTC().Format(time.RFC3339), err))
+	if len(failures) > maxWriteFailures {
+		failures = failures[len(failures)-maxWriteFailures:]
+	}
+	writeFailures[folderPath] = failures
+}
+
+func failuresOf(folderPath string) []string {
+	failuresMu.Lock()
+	defer failuresMu.Unlock()
+	return append([]string(nil), writeFailures[folderPath]...)
+}
+
+func appendEntry(folderPath string, entry Entry) (Entry, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	last, err := lastEntry(folderPath)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	entry.Seq = last.seq + 1
+	entry.PrevHash = last.hash
+	entry.Time = time.Now().UTC()
+	entry.Hash = entryHash(entry)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to encode audit entry: %v", err)
+	}
+
+	dir := filepath.Join(folderPath, auditDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return Entry{}, fmt.Errorf("failed to create audit directory: %v", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		head = logHead{}
+		return Entry{}, fmt.Errorf("failed to append audit entry: %v", err)
+	}
+
+	head = logHead{folderPath: folderPath, seq: entry.Seq, hash: entry.Hash}
+	if info, err := f.Stat(); err == nil {
+		head.size = info.Size()
+	} else {
+		head = logHead{}
+	}
+	return entry, nil
+}
+
+// lastEntry returns the head of the log, from the cache when nothing but
+// appendEntry wrote to the log since. mu must be held
+func lastEntry(folderPath string) (logHead, error) {
+	info, err := os.Stat(filepath.Join(folderPath, auditDir, logFileName))
+	if os.IsNotExist(err) {
+		return logHead{folderPath: folderPath}, nil
+	}
+	if err != nil {
+		return logHead{}, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	if head.folderPath == folderPath && head.size == info.Size() {
+		return head, nil
+	}
+
+	entries, err := readEntries(folderPath)
+	if err != nil {
+		return logHead{}, err
+	}
+	last := logHead{folderPath: folderPath, size: info.Size()}
+	if len(entries) > 0 {
+		last.seq = entries[len(entries)-1].Seq
+		last.hash = entries[len(entries)-1].Hash
+	}
+	return last, nil
+}
+
+// entryHash chains the entry to the previous one, every field except the hash
+// itself is covered
+func entryHash(entry Entry) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d|%s|%s|%s|%s|%s|%s",
+		entry.Seq,
+		entry.Time.UTC().Format(time.RFC3339Nano),
+		entry.Operation,
+		entry.ItemID,
+		entry.Fingerprint,
+		entry.Outcome,
+		entry.PrevHash,
+	)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func readEntries(folderPath string) ([]Entry, error) {
+	f, err := os.Open(filepath.Join(folderPath, auditDir, logFileName))
+	if os.IsNotExist(err) {
+		return []Entry{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	entries := []Entry{}
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var entry Entry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return nil, fmt.Errorf("audit log line %d is corrupt: %v", lineNo, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %v", err)
+	}
+
+	return entries, nil
+}
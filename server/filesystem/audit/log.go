@@ -0,0 +1,415 @@
+package audit
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/cryptohelper"
+	"MindLockr/server/filesystem"
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+const checkpointsFileName = "checkpoints.log"
+
+var (
+	// CheckpointInterval is the number of unsigned entries after which the
+	// next unlocked key signs the head of the log
+	CheckpointInterval = 50
+	// CheckpointMaxAge is the longest time unsigned entries are left waiting
+	// for a checkpoint
+	CheckpointMaxAge = 24 * time.Hour
+)
+
+type (
+	Audit struct {
+		folderInstance *filesystem.Folder
+	}
+
+	// Checkpoint is a signature over the hash of the log head, which in turn
+	// covers every entry before it
+	Checkpoint struct {
+		Seq               uint64    `json:"seq"`
+		Hash              string    `json:"hash"`
+		Time              time.Time `json:"time"`
+		SignerFingerprint string    `json:"signerFingerprint"`
+		Signature         string    `json:"signature"`
+	}
+
+	Filter struct {
+		Operation   string `json:"operation,omitempty"`
+		ItemID      string `json:"itemId,omitempty"`
+		Fingerprint string `json:"fingerprint,omitempty"`
+		Outcome     string `json:"outcome,omitempty"`
+		Since       string `json:"since,omitempty"` // RFC3339
+		Until       string `json:"until,omitempty"` // RFC3339
+	}
+
+	SignRequest struct {
+		KeyName    string `json:"keyName"`
+		Passphrase string `json:"passphrase"`
+	}
+
+	VerifyResult struct {
+		Valid           bool     `json:"valid"`
+		Entries         int      `json:"entries"`
+		Checkpoints     int      `json:"checkpoints"`
+		LastSignedSeq   uint64   `json:"lastSignedSeq"`
+		UnsignedEntries int      `json:"unsignedEntries"`
+		Problems        []string `json:"problems"`
+	}
+)
+
+func NewAudit(folder *filesystem.Folder) *Audit {
+	filesystem.OnFolderChange(func(string) { resetHead() })
+
+	return &Audit{
+		folderInstance: folder,
+	}
+}
+
+// ListEntries returns the audit entries matching the filter, oldest first
+func (a *Audit) ListEntries(filter Filter) ([]Entry, error) {
+	folderPath := a.folderInstance.GetFolderPath()
+	if folderPath == "" {
+		return nil, apperr.ErrNoVaultFolder
+	}
+
+	var since, until time.Time
+	var err error
+	if filter.Since != "" {
+		if since, err = time.Parse(time.RFC3339, filter.Since); err != nil {
+			return nil, fmt.Errorf("invalid since time: %v", err)
+		}
+	}
+	if filter.Until != "" {
+		if until, err = time.Parse(time.RFC3339, filter.Until); err != nil {
+			return nil, fmt.Errorf("invalid until time: %v", err)
+		}
+	}
+
+	mu.Lock()
+	entries, err := readEntries(folderPath)
+	mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	matching := []Entry{}
+	for _, entry := range entries {
+		if filter.Operation != "" && entry.Operation != filter.Operation {
+			continue
+		}
+		if filter.ItemID != "" && entry.ItemID != filter.ItemID {
+			continue
+		}
+		if filter.Fingerprint != "" && !strings.EqualFold(entry.Fingerprint, filter.Fingerprint) {
+			continue
+		}
+		if filter.Outcome != "" && entry.Outcome != filter.Outcome {
+			continue
+		}
+		if !since.IsZero() && entry.Time.Before(since) {
+			continue
+		}
+		if !until.IsZero() && entry.Time.After(until) {
+			continue
+		}
+		matching = append(matching, entry)
+	}
+
+	return matching, nil
+}
+
+// VerifyLog walks the hash chain and checks every checkpoint signature against
+// the public keys stored in the vault. Log writes that failed since the app
+// started are reported as problems too
+func (a *Audit) VerifyLog() (VerifyResult, error) {
+	folderPath := a.folderInstance.GetFolderPath()
+	if folderPath == "" {
+		return VerifyResult{}, apperr.ErrNoVaultFolder
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	entries, err := readEntries(folderPath)
+	if err != nil {
+		return VerifyResult{}, err
+	}
+	checkpoints, err := readCheckpoints(folderPath)
+	if err != nil {
+		return VerifyResult{}, err
+	}
+
+	result := VerifyResult{
+		Entries:     len(entries),
+		Checkpoints: len(checkpoints),
+		Problems:    []string{},
+	}
+
+	prevHash := ""
+	hashes := make(map[uint64]string, len(entries))
+	for i, entry := range entries {
+		if entry.Seq != uint64(i+1) {
+			result.Problems = append(result.Problems, fmt.Sprintf("entry %d has sequence number %d", i+1, entry.Seq))
+		}
+		if entry.PrevHash != prevHash {
+			result.Problems = append(result.Problems, fmt.Sprintf("entry %d is not chained to the previous entry", entry.Seq))
+		}
+		if entryHash(entry) != entry.Hash {
+			result.Problems = append(result.Problems, fmt.Sprintf("entry %d was modified", entry.Seq))
+		}
+		prevHash = entry.Hash
+		hashes[entry.Seq] = entry.Hash
+	}
+
+	keys, err := vaultPublicKeys(folderPath)
+	if err != nil {
+		return VerifyResult{}, err
+	}
+
+	for _, cp := range checkpoints {
+		hash, ok := hashes[cp.Seq]
+		if !ok {
+			result.Problems = append(result.Problems, fmt.Sprintf("checkpoint %d points to a missing entry", cp.Seq))
+			continue
+		}
+		if hash != cp.Hash {
+			result.Problems = append(result.Problems, fmt.Sprintf("checkpoint %d does not match the log", cp.Seq))
+			continue
+		}
+
+		key, ok := keys[cp.SignerFingerprint]
+		if !ok {
+			result.Problems = append(result.Problems, fmt.Sprintf("checkpoint %d was signed by unknown key %s", cp.Seq, cp.SignerFingerprint))
+			continue
+		}
+		if err := verifyCheckpoint(cp, key); err != nil {
+			result.Problems = append(result.Problems, fmt.Sprintf("checkpoint %d: %s", cp.Seq, err))
+			continue
+		}
+
+		if cp.Seq > result.LastSignedSeq {
+			result.LastSignedSeq = cp.Seq
+		}
+	}
+
+	// entries or checkpoints that could not be written are missing from the log
+	result.Problems = append(result.Problems, failuresOf(folderPath)...)
+
+	result.UnsignedEntries = len(entries) - int(result.LastSignedSeq)
+	result.Valid = len(result.Problems) == 0
+
+	return result, nil
+}
+
+// SignCheckpoint signs the current head of the log with the given vault key
+func (a *Audit) SignCheckpoint(req SignRequest) (Checkpoint, error) {
+	folderPath := a.folderInstance.GetFolderPath()
+	if folderPath == "" {
+		return Checkpoint{}, apperr.ErrNoVaultFolder
+	}
+
+	privKeyArmor, err := os.ReadFile(filepath.Join(folderPath, "pgp-keys", req.KeyName, "private.asc"))
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to read private key file: %v", err)
+	}
+
+	key, err := cryptohelper.UnlockPrivateKey(string(privKeyArmor), []byte(req.Passphrase))
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to unlock private key: %w", err)
+	}
+	defer key.ClearPrivateParams()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	return signHead(folderPath, key)
+}
+
+// SignIfDue is called whenever a private key gets unlocked and adds a
+// checkpoint with that key once enough unsigned entries have piled up. Like
+// Record it never fails the caller, VerifyLog reports a failed checkpoint
+func SignIfDue(key *crypto.Key) {
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+	if folderPath == "" {
+		return
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	entries, err := readEntries(folderPath)
+	if err != nil || len(entries) == 0 {
+		return
+	}
+	checkpoints, err := readCheckpoints(folderPath)
+	if err != nil {
+		return
+	}
+
+	head := entries[len(entries)-1]
+	var lastSeq uint64
+	var lastTime time.Time
+	if len(checkpoints) > 0 {
+		lastSeq = checkpoints[len(checkpoints)-1].Seq
+		lastTime = checkpoints[len(checkpoints)-1].Time
+	}
+
+	unsigned := head.Seq - lastSeq
+	if unsigned == 0 {
+		return
+	}
+	if unsigned < uint64(CheckpointInterval) && time.Since(lastTime) < CheckpointMaxAge {
+		return
+	}
+
+	// the signing handle clears the key it signed with, so sign with a copy to
+	// leave the caller's unlocked key intact
+	signingKey, err := key.Copy()
+	if err != nil {
+		recordFailure(folderPath, fmt.Errorf("failed to copy the key for a checkpoint: %w", err))
+		return
+	}
+	defer signingKey.ClearPrivateParams()
+
+	if _, err := signHead(folderPath, signingKey); err != nil {
+		recordFailure(folderPath, fmt.Errorf("failed to sign a checkpoint: %w", err))
+	}
+}
+
+func signHead(folderPath string, key *crypto.Key) (Checkpoint, error) {
+	entries, err := readEntries(folderPath)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	if len(entries) == 0 {
+		return Checkpoint{}, fmt.Errorf("audit log is empty")
+	}
+
+	head := entries[len(entries)-1]
+	cp := Checkpoint{
+		Seq:               head.Seq,
+		Hash:              head.Hash,
+		Time:              time.Now().UTC(),
+		SignerFingerprint: key.GetFingerprint(),
+	}
+
+	signHandle, err := crypto.PGP().Sign().SigningKey(key).Detached().New()
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to create signing handle: %s", err)
+	}
+	defer signHandle.ClearPrivateParams()
+
+	signature, err := signHandle.Sign(checkpointData(cp), crypto.Armor)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to sign checkpoint: %s", err)
+	}
+	cp.Signature = string(signature)
+
+	line, err := json.Marshal(cp)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to encode checkpoint: %v", err)
+	}
+
+	f, err := os.OpenFile(filepath.Join(folderPath, auditDir, checkpointsFileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to open checkpoints file: %v", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to append checkpoint: %v", err)
+	}
+
+	return cp, nil
+}
+
+func checkpointData(cp Checkpoint) []byte {
+	return []byte(fmt.Sprintf("mindlockr-audit-checkpoint|%d|%s|%s", cp.Seq, cp.Hash, cp.Time.UTC().Format(time.RFC3339Nano)))
+}
+
+func verifyCheckpoint(cp Checkpoint, key *crypto.Key) error {
+	verifyHandle, err := crypto.PGP().Verify().VerificationKey(key).New()
+	if err != nil {
+		return fmt.Errorf("failed to create verification handle: %s", err)
+	}
+
+	result, err := verifyHandle.VerifyDetached(checkpointData(cp), []byte(cp.Signature), crypto.Armor)
+	if err != nil {
+		return fmt.Errorf("failed to verify signature: %s", err)
+	}
+	if sigErr := result.SignatureError(); sigErr != nil {
+		return fmt.Errorf("invalid signature: %s", sigErr)
+	}
+	return nil
+}
+
+func readCheckpoints(folderPath string) ([]Checkpoint, error) {
+	f, err := os.Open(filepath.Join(folderPath, auditDir, checkpointsFileName))
+	if os.IsNotExist(err) {
+		return []Checkpoint{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoints file: %v", err)
+	}
+	defer f.Close()
+
+	checkpoints := []Checkpoint{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var cp Checkpoint
+		if err := json.Unmarshal([]byte(line), &cp); err != nil {
+			return nil, fmt.Errorf("checkpoints file is corrupt: %v", err)
+		}
+		checkpoints = append(checkpoints, cp)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read checkpoints file: %v", err)
+	}
+
+	return checkpoints, nil
+}
+
+// vaultPublicKeys indexes the public keys in pgp-keys/ by fingerprint
+func vaultPublicKeys(folderPath string) (map[string]*crypto.Key, error) {
+	keys := make(map[string]*crypto.Key)
+
+	keyFolders, err := os.ReadDir(filepath.Join(folderPath, "pgp-keys"))
+	if os.IsNotExist(err) {
+		return keys, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PGP keys folder: %v", err)
+	}
+
+	for _, keyFolder := range keyFolders {
+		if !keyFolder.IsDir() {
+			continue
+		}
+
+		pubKeyArmor, err := os.ReadFile(filepath.Join(folderPath, "pgp-keys", keyFolder.Name(), "public.asc"))
+		if err != nil {
+			continue
+		}
+		key, err := crypto.NewKeyFromArmored(string(pubKeyArmor))
+		if err != nil {
+			continue
+		}
+		keys[key.GetFingerprint()] = key
+	}
+
+	return keys, nil
+}
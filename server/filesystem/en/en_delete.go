@@ -1 +1,48 @@
 package en
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/filesystem"
+	"MindLockr/server/filesystem/audit"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DeleteSymEn removes a symmetrically encrypted item from sym_lockr/ along
+// with its history
+func (ks *KeyStore) DeleteSymEn(fileName string) error {
+	return deleteVaultFile("sym_lockr", fileName)
+}
+
+// DeleteHybEn removes a hybrid encrypted message from hyb_lockr/ along with
+// its history
+func (ks *KeyStore) DeleteHybEn(fileName string) error {
+	return deleteVaultFile("hyb_lockr", fileName)
+}
+
+func deleteVaultFile(dir, fileName string) (err error) {
+	itemID := filepath.ToSlash(filepath.Join(dir, fileName))
+	defer func() {
+		audit.Record(audit.OpDelete, itemID, "", err)
+	}()
+
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+	if folderPath == "" {
+		return apperr.ErrNoVaultFolder
+	}
+
+	if fileName == "" || filepath.Base(fileName) != fileName {
+		return fmt.Errorf("%w: invalid file name: %q", apperr.ErrInvalidInput, fileName)
+	}
+
+	if err := os.Remove(filepath.Join(folderPath, dir, fileName)); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", itemID, err)
+	}
+
+	// prior revisions would keep the deleted content around
+	if err := os.RemoveAll(filepath.Join(folderPath, historyDir, dir, fileName)); err != nil {
+		return fmt.Errorf("deleted %s, but failed to remove its history: %v", itemID, err)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package en
 
 import (
+	"MindLockr/server/apperr"
 	"MindLockr/server/filesystem"
 	"fmt"
 	"os"
@@ -51,7 +52,7 @@ func (kr *EnRetrieve) LoadEncryptedContent(keyName string) (string, error) {
 
 	content, err := os.ReadFile(keyFilePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to read key file: %v", err)
+		return "", fmt.Errorf("failed to read key file: %w", err)
 	}
 
 	return string(content), nil
@@ -62,7 +63,7 @@ func (kr *EnRetrieve) LoadAsymEnData(dataFilePath string) (string, error) {
 
 	content, err := os.ReadFile(dataPath)
 	if err != nil {
-		return "", fmt.Errorf("Error ocurred when reading content from file path: %v", err)
+		return "", fmt.Errorf("Error ocurred when reading content from file path: %w", err)
 	}
 
 	return string(content), nil
@@ -81,7 +82,7 @@ func (kr *EnRetrieve) RetrieveSymEn() ([]KeyInfo, error) {
 
 	files, err := os.ReadDir(keysBaseFolderPath)
 	if err != nil {
-		return nil, fmt.Errorf("Error reading keys folder: %v", err)
+		return nil, fmt.Errorf("Error reading keys folder: %w", err)
 	}
 
 	for _, file := range files {
@@ -107,7 +108,7 @@ func (kr *EnRetrieve) RetrieveAsymEn() ([]FileInfo, error) {
 	var files []FileInfo
 	fileEntries, err := os.ReadDir(keysBaseFolderPath)
 	if err != nil {
-		return nil, fmt.Errorf("Error reading files in asymmetric folder: %v", err)
+		return nil, fmt.Errorf("Error reading files in asymmetric folder: %w", err)
 	}
 
 	for _, fileEntry := range fileEntries {
@@ -134,12 +135,12 @@ func (kr *EnRetrieve) RetrievePGPMsgInfo(path string) (map[string]string, error)
 
 	msgArmor, err := os.ReadFile(msgPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read message file: %v", err)
+		return nil, fmt.Errorf("failed to read message file: %w", err)
 	}
 
 	pgpMsg, err := crypto.NewPGPMessageFromArmored(string(msgArmor))
 	if err != nil {
-		return nil, fmt.Errorf("failed to load the pgp msg from armor: %s", err)
+		return nil, fmt.Errorf("failed to load the pgp msg from armor: %w: %v", apperr.ErrCorruptMessage, err)
 	}
 
 	msgInfo := make(map[string]string)
@@ -151,7 +152,7 @@ func (kr *EnRetrieve) RetrievePGPMsgInfo(path string) (map[string]string, error)
 	}
 	numOfPackets, err := pgpMsg.GetNumberOfKeyPackets()
 	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve number of packets: %s", err)
+		return nil, fmt.Errorf("failed to retrieve number of packets: %w: %v", apperr.ErrCorruptMessage, err)
 	}
 	msgInfo["packets"] = fmt.Sprintf("%d", numOfPackets)
 
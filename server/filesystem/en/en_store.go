@@ -1,6 +1,7 @@
 package en
 
 import (
+	"MindLockr/server/apperr"
 	"MindLockr/server/filesystem"
 	"fmt"
 	"os"
@@ -10,15 +11,20 @@ import (
 type KeyStore struct{}
 
 func (ks *KeyStore) SaveSymEn(folderPath, fileName, keyContent string) error {
+	if folderPath == "" {
+		return apperr.ErrNoVaultFolder
+	}
+
 	keysDir := filepath.Join(folderPath, "sym_lockr")
 	if err := os.MkdirAll(keysDir, os.ModePerm); err != nil {
-		return fmt.Errorf("failed to create sym_lockr/%s directory: ", err)
+		return fmt.Errorf("failed to create sym_lockr directory: %w", err)
 	}
 
 	keyFilePath := filepath.Join(keysDir, fileName+".key")
 
-	if err := os.WriteFile(keyFilePath, []byte(keyContent), 0644); err != nil {
-		return fmt.Errorf("failed to write key to file: %v", err)
+	// the previous content is kept in history/
+	if err := writeWithHistory(keyFilePath, []byte(keyContent)); err != nil {
+		return fmt.Errorf("failed to write key to file: %w", err)
 	}
 	return nil
 }
@@ -33,20 +39,19 @@ func (ks *KeyStore) SaveHybEn(req HybridRequestData) error {
 	folderPath := folderInstance.GetFolderPath()
 
 	if folderPath == "" {
-		return fmt.Errorf("Please initialize the folder where you want to store data")
+		return apperr.ErrNoVaultFolder
 	}
 
 	messageDir := filepath.Join(folderPath, "hyb_lockr")
 	if err := os.MkdirAll(messageDir, os.ModePerm); err != nil {
-		return fmt.Errorf("failed to create message directory: %v", err)
+		return fmt.Errorf("failed to create message directory: %w", err)
 	}
 
 	messageFilePath := filepath.Join(messageDir, req.FileName+".asc")
 
-	if err := os.WriteFile(messageFilePath, []byte(req.MsgArmor), 0644); err != nil {
-		return fmt.Errorf("failed to write PGP message to file: %v", err)
+	if err := writeWithHistory(messageFilePath, []byte(req.MsgArmor)); err != nil {
+		return fmt.Errorf("failed to write PGP message to file: %w", err)
 	}
 
-	fmt.Printf("PGP message saved successfully in %s\n", messageFilePath)
 	return nil
 }
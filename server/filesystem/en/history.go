@@ -0,0 +1,411 @@
+package en
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/cryptohelper"
+	hybdec "MindLockr/server/cryptography/decryption/hyb_dec"
+	"MindLockr/server/cryptography/session"
+	"MindLockr/server/filesystem"
+	"MindLockr/server/filesystem/audit"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+const historyDir = "history"
+
+// CurrentRevision identifies the live version of an item in revision requests
+const CurrentRevision = "current"
+
+// stores items can be saved to
+const (
+	StoreSymmetric = "symmetric"
+	StoreHybrid    = "hybrid"
+)
+
+type (
+	Revision struct {
+		ID      string    `json:"id"`
+		SavedAt time.Time `json:"savedAt"`
+		Size    int64     `json:"size"`
+		Current bool      `json:"current"`
+	}
+
+	// RevisionRequest identifies an item by its store and its file name as
+	// returned by RetrieveSymEn and RetrieveAsymEn
+	RevisionRequest struct {
+		Store    string `json:"store"`
+		FileName string `json:"fileName"`
+	}
+
+	// RevisionAccess holds what is needed to decrypt a revision. Symmetric
+	// items need the Passphrase, hybrid items a key from pgp-keys/ or, when
+	// KeyName is empty, the unlocked session key
+	RevisionAccess struct {
+		Passphrase        string `json:"passphrase,omitempty"`
+		KeyName           string `json:"keyName,omitempty"`
+		PrivKeyPassphrase string `json:"privPassphrase,omitempty"`
+	}
+
+	DecryptRevisionRequest struct {
+		RevisionRequest
+		RevisionAccess
+		RevisionID string `json:"revisionId"`
+	}
+
+	DiffRevisionsRequest struct {
+		RevisionRequest
+		RevisionAccess
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+
+	// RevisionDiff is what DiffRevisions returns, Warnings are those of the
+	// verification policy about both revisions
+	RevisionDiff struct {
+		Lines    []DiffLine `json:"lines"`
+		Warnings []string   `json:"warnings,omitempty"`
+	}
+
+	RestoreRevisionRequest struct {
+		RevisionRequest
+		RevisionID string `json:"revisionId"`
+	}
+)
+
+// ListRevisions returns the live version of an item followed by its prior
+// revisions, newest first
+func (ks *KeyStore) ListRevisions(req RevisionRequest) ([]Revision, error) {
+	itemPath, revDir, err := revisionPaths(req)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := []Revision{}
+	if info, err := os.Stat(itemPath); err == nil {
+		revisions = append(revisions, Revision{
+			ID:      CurrentRevision,
+			SavedAt: info.ModTime().UTC(),
+			Size:    info.Size(),
+			Current: true,
+		})
+	}
+
+	prior, err := listRevisionFiles(revDir)
+	if err != nil {
+		return nil, err
+	}
+	return append(revisions, prior...), nil
+}
+
+// DecryptRevision decrypts a single revision of an item and checks its
+// signature against the verification policy
+func (ks *KeyStore) DecryptRevision(req DecryptRevisionRequest) (hybdec.ReturnType, error) {
+	plain, ret, err := decryptRevision(req.RevisionRequest, req.RevisionAccess, req.RevisionID)
+	if err != nil {
+		return hybdec.ReturnType{}, err
+	}
+	ret.Data = string(plain)
+	return ret, nil
+}
+
+// DiffRevisions decrypts two revisions of an item and returns a line diff
+// going from the From revision to the To revision
+func (ks *KeyStore) DiffRevisions(req DiffRevisionsRequest) (RevisionDiff, error) {
+	from, fromRet, err := decryptRevision(req.RevisionRequest, req.RevisionAccess, req.From)
+	if err != nil {
+		return RevisionDiff{}, err
+	}
+	defer clear(from)
+
+	to, toRet, err := decryptRevision(req.RevisionRequest, req.RevisionAccess, req.To)
+	if err != nil {
+		return RevisionDiff{}, err
+	}
+	defer clear(to)
+
+	return RevisionDiff{
+		Lines:    diffLines(string(from), string(to)),
+		Warnings: append(fromRet.Warnings, toRet.Warnings...),
+	}, nil
+}
+
+// RestoreRevision makes an older revision the live version of an item, the
+// version it replaces is kept as a revision as well
+func (ks *KeyStore) RestoreRevision(req RestoreRevisionRequest) (err error) {
+	defer func() {
+		audit.Record(audit.OpRevisionRestore, revisionItemID(req.RevisionRequest, req.RevisionID), "", err)
+	}()
+
+	if req.RevisionID == CurrentRevision {
+		return fmt.Errorf("%w: the current revision is already live", apperr.ErrInvalidInput)
+	}
+
+	itemPath, revDir, err := revisionPaths(req.RevisionRequest)
+	if err != nil {
+		return err
+	}
+
+	content, err := readRevision(itemPath, revDir, req.RevisionID)
+	if err != nil {
+		return err
+	}
+
+	return writeWithHistory(itemPath, content)
+}
+
+// writeWithHistory replaces the file at itemPath and keeps its previous
+// content as a revision according to the history policy
+func writeWithHistory(itemPath string, content []byte) error {
+	// <vault>/<store dir>/<file> keeps its revisions in
+	// <vault>/history/<store dir>/<file>/
+	storePath := filepath.Dir(itemPath)
+	revDir := filepath.Join(filepath.Dir(storePath), historyDir, filepath.Base(storePath), filepath.Base(itemPath))
+
+	policy := currentHistoryPolicy()
+	previous, err := os.ReadFile(itemPath)
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return fmt.Errorf("failed to read previous version: %v", err)
+	case !bytes.Equal(previous, content):
+		if err := keepRevision(itemPath, revDir, previous); err != nil {
+			return err
+		}
+	}
+
+	if err := os.WriteFile(itemPath, content, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", filepath.Base(itemPath), err)
+	}
+
+	return pruneRevisions(revDir, policy)
+}
+
+// keepRevision stores the previous content of the item at itemPath in
+// revDir. A revision is named after the time its content was saved, which is
+// the modification time of the item it replaces
+func keepRevision(itemPath, revDir string, previous []byte) error {
+	info, err := os.Stat(itemPath)
+	if err != nil {
+		return fmt.Errorf("failed to read previous version: %v", err)
+	}
+	if err := os.MkdirAll(revDir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create history directory: %v", err)
+	}
+
+	// a revision restored from history and saved again within the same
+	// clock tick gets the next free name
+	for savedAt := info.ModTime().UnixNano(); ; savedAt++ {
+		revPath := filepath.Join(revDir, strconv.FormatInt(savedAt, 10)+".rev")
+		f, err := os.OpenFile(revPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to keep previous version: %v", err)
+		}
+		_, err = f.Write(previous)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(revPath)
+			return fmt.Errorf("failed to keep previous version: %v", err)
+		}
+		return nil
+	}
+}
+
+func pruneRevisions(revDir string, policy HistoryPolicy) error {
+	revisions, err := listRevisionFiles(revDir)
+	if err != nil {
+		return err
+	}
+
+	cutoff := time.Time{}
+	if policy.MaxAgeDays > 0 {
+		cutoff = time.Now().AddDate(0, 0, -policy.MaxAgeDays)
+	}
+
+	for i, rev := range revisions {
+		if (policy.MaxRevisions == 0 || i < policy.MaxRevisions) && rev.SavedAt.After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(revDir, rev.ID+".rev")); err != nil {
+			return fmt.Errorf("failed to prune revision %s: %v", rev.ID, err)
+		}
+	}
+	return nil
+}
+
+// listRevisionFiles returns the revisions in revDir, newest first
+func listRevisionFiles(revDir string) ([]Revision, error) {
+	revisions := []Revision{}
+
+	entries, err := os.ReadDir(revDir)
+	if os.IsNotExist(err) {
+		return revisions, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %v", err)
+	}
+
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".rev")
+		if entry.IsDir() || !ok {
+			continue
+		}
+		nanos, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat revision %s: %v", id, err)
+		}
+		revisions = append(revisions, Revision{
+			ID:      id,
+			SavedAt: time.Unix(0, nanos).UTC(),
+			Size:    info.Size(),
+		})
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].SavedAt.After(revisions[j].SavedAt)
+	})
+	return revisions, nil
+}
+
+// decryptRevision decrypts a revision and applies the verification policy
+// to its signature, the returned result carries no data
+func decryptRevision(req RevisionRequest, access RevisionAccess, revisionID string) (plain []byte, ret hybdec.ReturnType, err error) {
+	defer func() {
+		audit.Record(audit.OpDecrypt, revisionItemID(req, revisionID), "", err)
+	}()
+
+	itemPath, revDir, err := revisionPaths(req)
+	if err != nil {
+		return nil, ret, err
+	}
+
+	content, err := readRevision(itemPath, revDir, revisionID)
+	if err != nil {
+		return nil, ret, err
+	}
+
+	vaultKeys, _ := pgpfs.VaultKeys()
+	verificationKeys, err := pgpfs.VerificationKeyRing(vaultKeys)
+	if err != nil {
+		return nil, ret, err
+	}
+	builder := crypto.PGP().Decryption().VerificationKeys(verificationKeys)
+
+	var decHandle crypto.PGPDecryption
+	switch req.Store {
+	case StoreSymmetric:
+		decHandle, err = builder.Password([]byte(access.Passphrase)).New()
+	case StoreHybrid:
+		var key *crypto.Key
+		key, err = revisionKey(access)
+		if err != nil {
+			return nil, ret, err
+		}
+		decHandle, err = builder.DecryptionKey(key).New()
+		defer key.ClearPrivateParams()
+	}
+	if err != nil {
+		return nil, ret, fmt.Errorf("failed to create decryption handle: %s", err)
+	}
+
+	decrypted, err := decHandle.Decrypt(content, crypto.Armor)
+	if err != nil {
+		return nil, ret, fmt.Errorf("failed to decrypt revision %s: %w", revisionID, cryptohelper.DecryptionError(err))
+	}
+
+	checked := hybdec.AutoReturnType{}
+	if req.Store == StoreSymmetric {
+		err = hybdec.ApplySymmetricSignature(&checked, &decrypted.VerifyResult, vaultKeys)
+	} else {
+		err = hybdec.ApplySignature(&checked, &decrypted.VerifyResult, vaultKeys)
+	}
+	if err != nil {
+		clear(decrypted.Bytes())
+		return nil, ret, err
+	}
+	return decrypted.Bytes(), checked.ReturnType, nil
+}
+
+func revisionKey(access RevisionAccess) (*crypto.Key, error) {
+	if access.KeyName == "" {
+		return session.Current()
+	}
+
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+	privKeyArmor, err := os.ReadFile(filepath.Join(folderPath, "pgp-keys", access.KeyName, "private.asc"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key file: %w: %w", apperr.ErrKeyNotFound, err)
+	}
+
+	key, err := cryptohelper.UnlockPrivateKey(string(privKeyArmor), []byte(access.PrivKeyPassphrase))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock private key: %w", err)
+	}
+	return key, nil
+}
+
+func readRevision(itemPath, revDir, revisionID string) ([]byte, error) {
+	path := itemPath
+	if revisionID != CurrentRevision {
+		if _, err := strconv.ParseInt(revisionID, 10, 64); err != nil {
+			return nil, fmt.Errorf("%w: invalid revision id: %q", apperr.ErrInvalidInput, revisionID)
+		}
+		path = filepath.Join(revDir, revisionID+".rev")
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read revision %s: %w", revisionID, err)
+	}
+	return content, nil
+}
+
+func revisionPaths(req RevisionRequest) (itemPath, revDir string, err error) {
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+	if folderPath == "" {
+		return "", "", apperr.ErrNoVaultFolder
+	}
+
+	dir, err := storeDir(req.Store)
+	if err != nil {
+		return "", "", err
+	}
+
+	if req.FileName == "" || filepath.Base(req.FileName) != req.FileName || strings.HasPrefix(req.FileName, ".") {
+		return "", "", fmt.Errorf("%w: invalid file name: %q", apperr.ErrInvalidInput, req.FileName)
+	}
+
+	return filepath.Join(folderPath, dir, req.FileName), filepath.Join(folderPath, historyDir, dir, req.FileName), nil
+}
+
+func storeDir(store string) (string, error) {
+	switch store {
+	case StoreSymmetric:
+		return "sym_lockr", nil
+	case StoreHybrid:
+		return "hyb_lockr", nil
+	}
+	return "", fmt.Errorf("%w: unknown store: %q", apperr.ErrInvalidInput, store)
+}
+
+func revisionItemID(req RevisionRequest, revisionID string) string {
+	dir, _ := storeDir(req.Store)
+	return dir + "/" + req.FileName + "@" + revisionID
+}
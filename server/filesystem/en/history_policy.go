@@ -0,0 +1,93 @@
+package en
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/filesystem"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const historyPolicyFileName = "history-policy.json"
+
+// DefaultMaxRevisions is how many prior revisions of an item are kept unless
+// configured otherwise
+const DefaultMaxRevisions = 20
+
+type HistoryPolicy struct {
+	// MaxRevisions is the number of prior revisions kept per item, 0 keeps
+	// every revision
+	MaxRevisions int `json:"maxRevisions"`
+	// MaxAgeDays drops revisions older than that many days, 0 keeps them
+	// regardless of their age
+	MaxAgeDays int `json:"maxAgeDays"`
+}
+
+var (
+	historyPolicyMu     sync.Mutex
+	historyPolicyLoaded bool
+	historyPolicy       = HistoryPolicy{MaxRevisions: DefaultMaxRevisions}
+)
+
+// GetHistoryPolicy returns the retention policy of item revisions
+func (ks *KeyStore) GetHistoryPolicy() HistoryPolicy {
+	return currentHistoryPolicy()
+}
+
+// SetHistoryPolicy changes and persists the retention policy of item
+// revisions, it is applied the next time an item is saved
+func (ks *KeyStore) SetHistoryPolicy(newPolicy HistoryPolicy) error {
+	if newPolicy.MaxRevisions < 0 || newPolicy.MaxAgeDays < 0 {
+		return fmt.Errorf("%w: history retention limits must not be negative", apperr.ErrInvalidInput)
+	}
+
+	historyPolicyMu.Lock()
+	defer historyPolicyMu.Unlock()
+
+	dir, err := filesystem.ConfigDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+
+	content, err := json.MarshalIndent(newPolicy, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode history policy: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, historyPolicyFileName), content, 0600); err != nil {
+		return fmt.Errorf("failed to write history policy: %v", err)
+	}
+
+	historyPolicy = newPolicy
+	historyPolicyLoaded = true
+	return nil
+}
+
+func currentHistoryPolicy() HistoryPolicy {
+	historyPolicyMu.Lock()
+	defer historyPolicyMu.Unlock()
+
+	if historyPolicyLoaded {
+		return historyPolicy
+	}
+	historyPolicyLoaded = true
+
+	dir, err := filesystem.ConfigDir()
+	if err != nil {
+		return historyPolicy
+	}
+	content, err := os.ReadFile(filepath.Join(dir, historyPolicyFileName))
+	if err != nil {
+		return historyPolicy
+	}
+
+	var persisted HistoryPolicy
+	if err := json.Unmarshal(content, &persisted); err == nil {
+		historyPolicy = persisted
+	}
+	return historyPolicy
+}
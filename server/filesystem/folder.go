@@ -1,8 +1,10 @@
 package filesystem
 
 import (
+	"MindLockr/server/apperr"
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
@@ -15,10 +17,14 @@ type FolderPathFromClient struct {
 }
 
 func (f *Folder) UpdateFolderPath(folderPath string) {
+	f.mu.Lock()
 	f.folderPath = folderPath
+	f.mu.Unlock()
+	notifyFolderChange(folderPath)
 }
 
 type Folder struct {
+	mu         sync.RWMutex
 	folderPath string
 	ctx        context.Context // wails app runtime context
 }
@@ -26,8 +32,22 @@ type Folder struct {
 var (
 	folderInstance *Folder
 	once           sync.Once
+
+	hooksMu           sync.Mutex
+	folderChangeHooks []func(folderPath string)
 )
 
+// ConfigDir returns the per user application config directory used for
+// settings that must live outside of the vault. It is a variable so it can be
+// redirected (e.g. in tests)
+var ConfigDir = func() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate user config directory: %v", err)
+	}
+	return filepath.Join(dir, "MindLockr"), nil
+}
+
 // GetFolderInstance returns the existing Folder instance or creates one if it doesn't exist
 func GetFolderInstance() *Folder {
 	once.Do(func() {
@@ -36,6 +56,22 @@ func GetFolderInstance() *Folder {
 	return folderInstance
 }
 
+// OnFolderChange registers fn to be called every time the vault folder path is
+// changed from the client. Hooks run synchronously so they must not block
+func OnFolderChange(fn func(folderPath string)) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	folderChangeHooks = append(folderChangeHooks, fn)
+}
+
+func notifyFolderChange(folderPath string) {
+	hooksMu.Lock()
+	defer hooksMu.Unlock()
+	for _, fn := range folderChangeHooks {
+		fn(folderPath)
+	}
+}
+
 // SetContext sets the context for the Folder struct
 func (f *Folder) SetContext(ctx context.Context) {
 	f.ctx = ctx
@@ -56,23 +92,29 @@ func (f *Folder) SelectFolder() (string, error) {
 	}
 
 	// Set the selected folder path
+	f.mu.Lock()
 	f.folderPath = folder
+	f.mu.Unlock()
+	notifyFolderChange(folder)
 	return folder, nil
 }
 
 // GetFolderPath returns the currently selected folder path
 func (f *Folder) GetFolderPath() string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
 	return f.folderPath
 }
 
 // ListFiles returns a list of all files in the folder
 func (f *Folder) ListFiles() ([]string, error) {
-	if f.folderPath == "" {
-		return nil, errors.New("no folder selected")
+	folderPath := f.GetFolderPath()
+	if folderPath == "" {
+		return nil, apperr.ErrNoVaultFolder
 	}
 
 	var files []string
-	err := filepath.Walk(f.folderPath, func(path string, info os.FileInfo, err error) error {
+	err := filepath.Walk(folderPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -87,20 +129,22 @@ func (f *Folder) ListFiles() ([]string, error) {
 
 // CreateFile creates a new file in the selected folder
 func (f *Folder) CreateFile(filename, content string) error {
-	if f.folderPath == "" {
-		return errors.New("no folder selected")
+	folderPath := f.GetFolderPath()
+	if folderPath == "" {
+		return apperr.ErrNoVaultFolder
 	}
 
-	filePath := filepath.Join(f.folderPath, filename)
+	filePath := filepath.Join(folderPath, filename)
 	return os.WriteFile(filePath, []byte(content), 0644)
 }
 
 // RemoveFile removes a file in the folder
 func (f *Folder) RemoveFile(filename string) error {
-	if f.folderPath == "" {
-		return errors.New("no folder selected")
+	folderPath := f.GetFolderPath()
+	if folderPath == "" {
+		return apperr.ErrNoVaultFolder
 	}
 
-	filePath := filepath.Join(f.folderPath, filename)
+	filePath := filepath.Join(folderPath, filename)
 	return os.Remove(filePath)
 }
@@ -0,0 +1,331 @@
+package inbox
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/passphrase"
+	"MindLockr/server/filesystem"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const settingsFileName = "inbox.json"
+
+// Settle is how long a dropped file has to stay unchanged before it is
+// encrypted, so files still being written are left alone. It is a
+// variable so it can be shortened (e.g. in tests)
+var Settle = 2 * time.Second
+
+type (
+	// Inbox watches a drop folder and moves every file dropped into it into
+	// the vault, encrypted
+	Inbox struct {
+		folderInstance *filesystem.Folder
+		mu             sync.Mutex
+		procMu         sync.Mutex      // one file is encrypted at a time
+		ctx            context.Context // wails app runtime context
+		fsw            *fsnotify.Watcher
+		dir            string
+		passphrase     string // only kept in memory while running
+		timers         map[string]*time.Timer
+		closed         bool
+	}
+
+	// Settings are persisted in the config directory, the passphrase never
+	// is: with UsePassphrase the inbox has to be started by hand
+	Settings struct {
+		Dir string `json:"dir"`
+		// Recipient is the name of the vault key files are encrypted to
+		Recipient     string `json:"recipient,omitempty"`
+		UsePassphrase bool   `json:"usePassphrase,omitempty"`
+		// Enabled starts the inbox whenever the vault is opened, only for
+		// a recipient key
+		Enabled bool `json:"enabled,omitempty"`
+	}
+
+	StartRequest struct {
+		// Passphrase encrypts the files when the inbox uses a passphrase
+		Passphrase string `json:"passphrase,omitempty"`
+	}
+
+	Status struct {
+		Running   bool   `json:"running"`
+		Dir       string `json:"dir,omitempty"`
+		Pending   int    `json:"pending"`
+		Processed int    `json:"processed"`
+		Failed    int    `json:"failed"`
+	}
+)
+
+var (
+	settingsMu     sync.Mutex
+	settingsLoaded bool
+	settings       Settings
+)
+
+// NewInbox creates the inbox and starts it whenever a vault is opened and
+// the inbox is enabled
+func NewInbox(folder *filesystem.Folder) *Inbox {
+	in := &Inbox{folderInstance: folder}
+	filesystem.OnFolderChange(func(folderPath string) {
+		current := CurrentSettings()
+		if folderPath == "" || !current.Enabled || current.UsePassphrase {
+			in.Stop()
+			return
+		}
+		// hooks must not block, files waiting in the inbox are encrypted
+		// right away
+		go in.Start(StartRequest{})
+	})
+	return in
+}
+
+// SetContext sets the wails runtime context used for emitting the log
+func (in *Inbox) SetContext(ctx context.Context) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.ctx = ctx
+}
+
+// GetSettings returns the inbox settings
+func (in *Inbox) GetSettings() Settings {
+	return CurrentSettings()
+}
+
+// SetSettings checks and persists new settings. A running inbox is
+// stopped, it has to be started again with the new settings
+func (in *Inbox) SetSettings(newSettings Settings) error {
+	if err := newSettings.validate(in.folderInstance.GetFolderPath()); err != nil {
+		return err
+	}
+	in.Stop()
+
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	dir, err := filesystem.ConfigDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+
+	content, err := json.MarshalIndent(newSettings, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode inbox settings: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, settingsFileName), content, 0600); err != nil {
+		return fmt.Errorf("failed to write inbox settings: %v", err)
+	}
+
+	settings = newSettings
+	settingsLoaded = true
+	return nil
+}
+
+// CurrentSettings returns the persisted inbox settings
+func CurrentSettings() Settings {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	if settingsLoaded {
+		return settings
+	}
+	settingsLoaded = true
+
+	dir, err := filesystem.ConfigDir()
+	if err != nil {
+		return settings
+	}
+	content, err := os.ReadFile(filepath.Join(dir, settingsFileName))
+	if err != nil {
+		return settings
+	}
+	json.Unmarshal(content, &settings)
+	return settings
+}
+
+func (s Settings) validate(vault string) error {
+	if s.Dir == "" || !filepath.IsAbs(s.Dir) {
+		return fmt.Errorf("%w: choose the inbox folder", apperr.ErrInvalidInput)
+	}
+	info, err := os.Stat(s.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", s.Dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: %s is not a folder", apperr.ErrInvalidInput, s.Dir)
+	}
+
+	// the inbox must not pick up what it writes into the vault
+	if vault != "" {
+		rel, err := filepath.Rel(vault, s.Dir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("%w: the inbox folder must be outside of the vault", apperr.ErrInvalidInput)
+		}
+	}
+
+	if s.UsePassphrase {
+		if s.Enabled {
+			return fmt.Errorf("%w: an inbox that uses a passphrase cannot start on its own", apperr.ErrInvalidInput)
+		}
+		return nil
+	}
+	if s.Recipient == "" {
+		return fmt.Errorf("%w: choose the key files are encrypted to", apperr.ErrInvalidInput)
+	}
+	vaultKeys, err := pgpfs.VaultKeys()
+	if err != nil {
+		return err
+	}
+	for _, k := range vaultKeys {
+		if k.Name == s.Recipient {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", apperr.ErrKeyNotFound, s.Recipient)
+}
+
+// Start watches the inbox folder and encrypts the files already in it
+func (in *Inbox) Start(req StartRequest) (Status, error) {
+	current := CurrentSettings()
+	if current.Dir == "" {
+		return Status{}, fmt.Errorf("%w: the inbox is not set up", apperr.ErrInvalidInput)
+	}
+	if in.folderInstance.GetFolderPath() == "" {
+		return Status{}, apperr.ErrNoVaultFolder
+	}
+	if current.UsePassphrase {
+		if req.Passphrase == "" {
+			return Status{}, fmt.Errorf("%w: the inbox encrypts with a passphrase", apperr.ErrPassphraseRequired)
+		}
+		if err := passphrase.Enforce(req.Passphrase); err != nil {
+			return Status{}, err
+		}
+	}
+
+	in.mu.Lock()
+	if in.closed {
+		in.mu.Unlock()
+		return Status{}, nil
+	}
+	in.stopLocked()
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		in.mu.Unlock()
+		return Status{}, fmt.Errorf("failed to watch the inbox: %v", err)
+	}
+	if err := fsw.Add(current.Dir); err != nil {
+		fsw.Close()
+		in.mu.Unlock()
+		return Status{}, fmt.Errorf("failed to watch %s: %w", current.Dir, err)
+	}
+	in.fsw, in.dir, in.passphrase = fsw, current.Dir, req.Passphrase
+	in.timers = map[string]*time.Timer{}
+	go in.loop(fsw)
+
+	entries, _ := os.ReadDir(current.Dir)
+	for _, entry := range entries {
+		in.scheduleLocked(filepath.Join(current.Dir, entry.Name()))
+	}
+	in.mu.Unlock()
+
+	return in.Status(), nil
+}
+
+// Stop stops watching the inbox, files dropped meanwhile are encrypted on
+// the next start
+func (in *Inbox) Stop() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.stopLocked()
+}
+
+// Close stops the inbox for good, it is called when the app shuts down
+func (in *Inbox) Close() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.closed = true
+	in.stopLocked()
+}
+
+func (in *Inbox) stopLocked() {
+	for _, timer := range in.timers {
+		timer.Stop()
+	}
+	if in.fsw != nil {
+		in.fsw.Close()
+	}
+	in.fsw, in.dir, in.passphrase, in.timers = nil, "", "", nil
+}
+
+// Status tells whether the inbox is running and how many files it handled
+func (in *Inbox) Status() Status {
+	processed, failed := logCounts()
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return Status{
+		Running:   in.fsw != nil,
+		Dir:       in.dir,
+		Pending:   len(in.timers),
+		Processed: processed,
+		Failed:    failed,
+	}
+}
+
+func (in *Inbox) loop(fsw *fsnotify.Watcher) {
+	for {
+		select {
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return
+			}
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) {
+				in.mu.Lock()
+				if in.fsw == fsw {
+					in.scheduleLocked(event.Name)
+				}
+				in.mu.Unlock()
+			}
+		case _, ok := <-fsw.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// scheduleLocked encrypts path once it stopped changing for Settle, every
+// new event of the file starts the wait over
+func (in *Inbox) scheduleLocked(path string) {
+	if strings.HasPrefix(filepath.Base(path), ".") {
+		return
+	}
+	if timer, ok := in.timers[path]; ok {
+		timer.Reset(Settle)
+		return
+	}
+	fsw := in.fsw
+	in.timers[path] = time.AfterFunc(Settle, func() {
+		in.mu.Lock()
+		if in.fsw != fsw {
+			in.mu.Unlock()
+			return
+		}
+		delete(in.timers, path)
+		pass := in.passphrase
+		in.mu.Unlock()
+
+		in.process(path, pass)
+	})
+}
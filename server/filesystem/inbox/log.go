@@ -0,0 +1,127 @@
+package inbox
+
+import (
+	"MindLockr/server/filesystem"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	logFileName = "inbox-log.json"
+	// maxLogEntries is how many processed files the log remembers
+	maxLogEntries = 500
+
+	// ProcessedEvent is emitted to the frontend with the LogEntry of every
+	// file the inbox handled
+	ProcessedEvent = "inbox:processed"
+)
+
+// outcome of a dropped file
+const (
+	StatusEncrypted = "encrypted"
+	StatusFailed    = "failed"
+)
+
+type LogEntry struct {
+	Time time.Time `json:"time"`
+	// Source is the dropped file, it is gone once encrypted
+	Source string `json:"source"`
+	// Output is the encrypted message in the vault
+	Output string `json:"output,omitempty"`
+	Size   int64  `json:"size"`
+	// SHA256 is the checksum of the plaintext
+	SHA256 string `json:"sha256,omitempty"`
+	// EncryptedTo is the recipient key name, or "passphrase"
+	EncryptedTo string `json:"encryptedTo"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+}
+
+var (
+	logMu     sync.Mutex
+	logLoaded bool
+	logItems  []LogEntry
+)
+
+// GetLog returns the files the inbox handled, newest first
+func (in *Inbox) GetLog() []LogEntry {
+	logMu.Lock()
+	defer logMu.Unlock()
+	loadLogLocked()
+
+	entries := make([]LogEntry, len(logItems))
+	for i, entry := range logItems {
+		entries[len(logItems)-1-i] = entry
+	}
+	return entries
+}
+
+// ClearLog empties the log
+func (in *Inbox) ClearLog() error {
+	logMu.Lock()
+	defer logMu.Unlock()
+	logItems, logLoaded = nil, true
+	return saveLogLocked()
+}
+
+func appendLog(entry LogEntry) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	loadLogLocked()
+
+	logItems = append(logItems, entry)
+	if len(logItems) > maxLogEntries {
+		logItems = logItems[len(logItems)-maxLogEntries:]
+	}
+	// the entry is still emitted when the log cannot be written
+	saveLogLocked()
+}
+
+func logCounts() (processed, failed int) {
+	logMu.Lock()
+	defer logMu.Unlock()
+	loadLogLocked()
+	for _, entry := range logItems {
+		if entry.Status == StatusEncrypted {
+			processed++
+		} else {
+			failed++
+		}
+	}
+	return processed, failed
+}
+
+func loadLogLocked() {
+	if logLoaded {
+		return
+	}
+	logLoaded = true
+
+	dir, err := filesystem.ConfigDir()
+	if err != nil {
+		return
+	}
+	content, err := os.ReadFile(filepath.Join(dir, logFileName))
+	if err != nil {
+		return
+	}
+	json.Unmarshal(content, &logItems)
+}
+
+func saveLogLocked() error {
+	dir, err := filesystem.ConfigDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(logItems, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, logFileName), content, 0600)
+}
@@ -0,0 +1,182 @@
+package inbox
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/pgpformat"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v3/constants"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// storeDir is the vault store the encrypted files go to, so they are
+// listed with the other PGP messages
+const storeDir = "hyb_lockr"
+
+// errChanged is returned by secureRemove when the file was written to after
+// it was read
+var errChanged = errors.New("the file changed while it was encrypted")
+
+// process encrypts the file at path into the vault and securely removes
+// the original. The outcome is added to the log either way, except when the
+// file is still being written: then the message is dropped and the file
+// encrypted again once it settles
+func (in *Inbox) process(path, pass string) {
+	in.procMu.Lock()
+	defer in.procMu.Unlock()
+
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		// removed meanwhile, or a folder or link that is not ours to move
+		return
+	}
+
+	entry := LogEntry{
+		Time:   time.Now(),
+		Source: path,
+		Size:   info.Size(),
+	}
+	vault := in.folderInstance.GetFolderPath()
+	entry.Output, entry.SHA256, entry.EncryptedTo, err = encryptIntoVault(vault, path, pass)
+	if err == nil {
+		err = secureRemove(path, info)
+		if errors.Is(err, errChanged) {
+			os.Remove(entry.Output)
+			in.mu.Lock()
+			if in.fsw != nil {
+				in.scheduleLocked(path)
+			}
+			in.mu.Unlock()
+			return
+		}
+		if err != nil {
+			err = fmt.Errorf("encrypted into %s, but the original could not be removed: %w", entry.Output, err)
+		}
+	}
+	entry.Status = StatusEncrypted
+	if err != nil {
+		entry.Status, entry.Error = StatusFailed, err.Error()
+	}
+
+	appendLog(entry)
+
+	in.mu.Lock()
+	ctx := in.ctx
+	in.mu.Unlock()
+	if ctx != nil {
+		runtime.EventsEmit(ctx, ProcessedEvent, entry)
+	}
+}
+
+// encryptIntoVault writes path as an armored message into vault, with
+// its name and modification time in the literal data packet. The message
+// is synced to disk before the original may be removed
+func encryptIntoVault(vault, path, pass string) (output, sum, encryptedTo string, err error) {
+	current := CurrentSettings()
+	req := pgpformat.ParamsRequest{Passphrase: pass}
+	encryptedTo = "passphrase"
+	if !current.UsePassphrase {
+		req = pgpformat.ParamsRequest{Recipients: []string{current.Recipient}}
+		encryptedTo = current.Recipient
+	}
+	params, release, err := pgpformat.ResolveParams(req)
+	if err != nil {
+		return "", "", encryptedTo, err
+	}
+	defer release()
+
+	data, meta, err := pgpformat.ReadFile(path)
+	if err != nil {
+		return "", "", encryptedTo, err
+	}
+	digest := sha256.Sum256(data)
+	sum = hex.EncodeToString(digest[:])
+
+	encrypted, err := pgpformat.Encrypt(data, meta, params)
+	if err != nil {
+		return "", sum, encryptedTo, err
+	}
+	armored, err := pgpformat.Armor(encrypted, constants.PGPMessageHeader)
+	if err != nil {
+		return "", sum, encryptedTo, err
+	}
+
+	if vault == "" {
+		return "", sum, encryptedTo, apperr.ErrNoVaultFolder
+	}
+	dir := filepath.Join(vault, storeDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return "", sum, encryptedTo, fmt.Errorf("failed to create %s: %v", storeDir, err)
+	}
+	f, err := pgpformat.CreateFile(dir, &pgpformat.Metadata{Filename: meta.Filename + ".asc"})
+	if err != nil {
+		return "", sum, encryptedTo, err
+	}
+	output = f.Name()
+
+	_, err = f.Write(armored)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(output)
+		return "", sum, encryptedTo, fmt.Errorf("failed to write %s: %w", output, err)
+	}
+	return output, sum, encryptedTo, nil
+}
+
+// secureRemove overwrites the content of a file with random data before
+// removing it, and renames it so its name does not linger in the folder.
+// On SSDs and copy-on-write or journaling filesystems old blocks may
+// survive anyway, this only makes recovery harder. read is the state of the
+// file when it was read, errChanged is returned and the file left as is when
+// it was replaced or written to since
+func secureRemove(path string, read os.FileInfo) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err == nil && !unchanged(read, info) {
+		f.Close()
+		return errChanged
+	}
+	if err == nil {
+		_, err = io.CopyN(f, rand.Reader, info.Size())
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if err == nil {
+		err = f.Truncate(0)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to overwrite %s: %w", path, err)
+	}
+
+	name := make([]byte, 12)
+	rand.Read(name)
+	renamed := filepath.Join(filepath.Dir(path), "."+hex.EncodeToString(name))
+	if os.Rename(path, renamed) == nil {
+		path = renamed
+	}
+	return os.Remove(path)
+}
+
+func unchanged(before, after os.FileInfo) bool {
+	return os.SameFile(before, after) && before.Size() == after.Size() && before.ModTime().Equal(after.ModTime())
+}
@@ -0,0 +1,546 @@
+package integrity
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/cryptohelper"
+	"MindLockr/server/filesystem"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+const (
+	manifestDir      = "integrity"
+	manifestFileName = "manifest.json"
+	signatureName    = "manifest.json.sig"
+
+	// WarningEvent is emitted to the frontend when a check finds tampered keys
+	WarningEvent = "integrity:warning"
+)
+
+// TrackedDirs are the vault sub folders (relative to the vault root) that are
+// covered by the signed manifest
+var TrackedDirs = []string{"pgp-keys", "sym_lockr", "hyb_lockr", "items", "otp", "history", "trust"}
+
+// maxCachedFingerprints bounds the fingerprint cache, it is emptied when full
+const maxCachedFingerprints = 1024
+
+var (
+	cacheMu sync.Mutex
+	// verifiedManifest identifies the last manifest whose signature
+	// verified, see manifestID
+	verifiedManifest string
+	// fingerprints maps the SHA-256 of a public key file to its fingerprint
+	fingerprints = map[string]string{}
+)
+
+type (
+	Integrity struct {
+		folderInstance *filesystem.Folder
+		ctx            context.Context // wails app runtime context
+	}
+
+	SealRequest struct {
+		KeyName    string `json:"keyName"`
+		Passphrase string `json:"passphrase"`
+	}
+
+	FileEntry struct {
+		Path        string `json:"path"`
+		SHA256      string `json:"sha256"`
+		Size        int64  `json:"size"`
+		Fingerprint string `json:"fingerprint,omitempty"`
+	}
+
+	Manifest struct {
+		Version           int         `json:"version"`
+		SealedAt          time.Time   `json:"sealedAt"`
+		SignerKey         string      `json:"signerKey"`
+		SignerFingerprint string      `json:"signerFingerprint"`
+		Files             []FileEntry `json:"files"`
+	}
+
+	KeyWarning struct {
+		KeyName             string `json:"keyName"`
+		Path                string `json:"path"`
+		ExpectedFingerprint string `json:"expectedFingerprint"`
+		ActualFingerprint   string `json:"actualFingerprint"`
+	}
+
+	VerifyReport struct {
+		Sealed            bool         `json:"sealed"`
+		SignatureValid    bool         `json:"signatureValid"`
+		SignerKey         string       `json:"signerKey"`
+		SignerFingerprint string       `json:"signerFingerprint"`
+		SealedAt          time.Time    `json:"sealedAt"`
+		Added             []string     `json:"added"`
+		Missing           []string     `json:"missing"`
+		Modified          []string     `json:"modified"`
+		ReplacedKeys      []KeyWarning `json:"replacedKeys"`
+		Warnings          []string     `json:"warnings"`
+	}
+)
+
+func NewIntegrity(folder *filesystem.Folder) *Integrity {
+	integrity := &Integrity{
+		folderInstance: folder,
+	}
+
+	// every time a vault gets opened check that none of the public keys were swapped
+	filesystem.OnFolderChange(func(folderPath string) {
+		go integrity.checkOnOpen(folderPath)
+	})
+
+	return integrity
+}
+
+// SetContext sets the wails runtime context used for emitting warnings
+func (in *Integrity) SetContext(ctx context.Context) {
+	in.ctx = ctx
+}
+
+// SealVault hashes every tracked vault file and signs the resulting manifest
+// with the designated vault key. The vault key fingerprint gets pinned outside
+// of the vault so that a swapped signer key is detected as well
+func (in *Integrity) SealVault(req SealRequest) (VerifyReport, error) {
+	folderPath := in.folderInstance.GetFolderPath()
+	if folderPath == "" {
+		return VerifyReport{}, apperr.ErrNoVaultFolder
+	}
+
+	signerFolder := filepath.Join(folderPath, "pgp-keys", req.KeyName)
+	privKeyArmor, err := os.ReadFile(filepath.Join(signerFolder, "private.asc"))
+	if err != nil {
+		return VerifyReport{}, fmt.Errorf("failed to read vault key: %v", err)
+	}
+
+	signerKey, err := cryptohelper.UnlockPrivateKey(string(privKeyArmor), []byte(req.Passphrase))
+	if err != nil {
+		return VerifyReport{}, fmt.Errorf("failed to unlock vault key: %w", err)
+	}
+	defer signerKey.ClearPrivateParams()
+
+	pinned, err := pinnedFingerprint(folderPath)
+	if err != nil {
+		return VerifyReport{}, err
+	}
+	if pinned != "" && pinned != signerKey.GetFingerprint() {
+		return VerifyReport{}, fmt.Errorf("vault is pinned to key %s, refusing to reseal with %s", pinned, signerKey.GetFingerprint())
+	}
+
+	files, err := hashVault(folderPath)
+	if err != nil {
+		return VerifyReport{}, err
+	}
+
+	manifest := Manifest{
+		Version:           1,
+		SealedAt:          time.Now().UTC(),
+		SignerKey:         req.KeyName,
+		SignerFingerprint: signerKey.GetFingerprint(),
+		Files:             files,
+	}
+
+	manifestBytes, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return VerifyReport{}, fmt.Errorf("failed to encode manifest: %v", err)
+	}
+
+	signHandle, err := crypto.PGP().Sign().SigningKey(signerKey).Detached().New()
+	if err != nil {
+		return VerifyReport{}, fmt.Errorf("failed to create signing handle: %s", err)
+	}
+	defer signHandle.ClearPrivateParams()
+
+	signature, err := signHandle.Sign(manifestBytes, crypto.Armor)
+	if err != nil {
+		return VerifyReport{}, fmt.Errorf("failed to sign manifest: %s", err)
+	}
+
+	dir := filepath.Join(folderPath, manifestDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return VerifyReport{}, fmt.Errorf("failed to create integrity directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, manifestFileName), manifestBytes, 0644); err != nil {
+		return VerifyReport{}, fmt.Errorf("failed to write manifest: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, signatureName), signature, 0644); err != nil {
+		return VerifyReport{}, fmt.Errorf("failed to write manifest signature: %v", err)
+	}
+
+	if pinned == "" {
+		if err := pinFingerprint(folderPath, manifest.SignerFingerprint); err != nil {
+			return VerifyReport{}, err
+		}
+	}
+
+	return in.VerifyVault()
+}
+
+// VerifyVault checks the manifest signature and compares the manifest with the
+// current content of the vault
+func (in *Integrity) VerifyVault() (VerifyReport, error) {
+	folderPath := in.folderInstance.GetFolderPath()
+	if folderPath == "" {
+		return VerifyReport{}, apperr.ErrNoVaultFolder
+	}
+
+	report := VerifyReport{
+		Added:        []string{},
+		Missing:      []string{},
+		Modified:     []string{},
+		ReplacedKeys: []KeyWarning{},
+		Warnings:     []string{},
+	}
+
+	manifest, manifestBytes, err := readManifest(folderPath)
+	if err != nil {
+		return VerifyReport{}, err
+	}
+	if manifest == nil {
+		report.Warnings = append(report.Warnings, "vault has not been sealed yet")
+		return report, nil
+	}
+
+	report.Sealed = true
+	report.SignerKey = manifest.SignerKey
+	report.SignerFingerprint = manifest.SignerFingerprint
+	report.SealedAt = manifest.SealedAt
+
+	if err := verifyManifestSignature(folderPath, manifest, manifestBytes); err != nil {
+		report.Warnings = append(report.Warnings, err.Error())
+	} else {
+		report.SignatureValid = true
+	}
+
+	current, err := hashVault(folderPath)
+	if err != nil {
+		return VerifyReport{}, err
+	}
+
+	expected := make(map[string]FileEntry, len(manifest.Files))
+	for _, entry := range manifest.Files {
+		expected[entry.Path] = entry
+	}
+
+	for _, entry := range current {
+		sealed, ok := expected[entry.Path]
+		if !ok {
+			report.Added = append(report.Added, entry.Path)
+			continue
+		}
+		delete(expected, entry.Path)
+
+		if sealed.SHA256 != entry.SHA256 {
+			report.Modified = append(report.Modified, entry.Path)
+		}
+		if sealed.Fingerprint != "" && sealed.Fingerprint != entry.Fingerprint {
+			report.ReplacedKeys = append(report.ReplacedKeys, keyWarning(sealed, entry.Fingerprint))
+		}
+	}
+
+	for path := range expected {
+		report.Missing = append(report.Missing, path)
+	}
+	sort.Strings(report.Missing)
+
+	return report, nil
+}
+
+// CheckPublicKeys compares the fingerprints of every public key in pgp-keys/
+// against the sealed manifest and returns the keys that were replaced
+func (in *Integrity) CheckPublicKeys() ([]KeyWarning, error) {
+	return CheckPublicKeys(in.folderInstance.GetFolderPath())
+}
+
+// CheckPublicKeys is the package level variant used by the decryption paths
+// before they trust a key from the vault
+func CheckPublicKeys(folderPath string) ([]KeyWarning, error) {
+	warnings := []KeyWarning{}
+	if folderPath == "" {
+		return warnings, nil
+	}
+
+	manifest, manifestBytes, err := readManifest(folderPath)
+	if err != nil || manifest == nil {
+		return warnings, err
+	}
+
+	if err := verifyManifestCached(folderPath, manifest, manifestBytes); err != nil {
+		return warnings, err
+	}
+
+	for _, entry := range manifest.Files {
+		if entry.Fingerprint == "" {
+			continue
+		}
+
+		actual, err := fingerprintOf(filepath.Join(folderPath, filepath.FromSlash(entry.Path)))
+		if err != nil {
+			actual = ""
+		}
+		if actual != entry.Fingerprint {
+			warnings = append(warnings, keyWarning(entry, actual))
+		}
+	}
+
+	return warnings, nil
+}
+
+// Warnings returns human readable warnings for replaced public keys, it never
+// fails so it can be attached to decryption results
+func Warnings() []string {
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+
+	keyWarnings, err := CheckPublicKeys(folderPath)
+	if err != nil {
+		return []string{fmt.Sprintf("vault integrity could not be verified: %s", err)}
+	}
+
+	var warnings []string
+	for _, w := range keyWarnings {
+		warnings = append(warnings, fmt.Sprintf("public key %s was replaced (expected %s, found %s)", w.KeyName, w.ExpectedFingerprint, w.ActualFingerprint))
+	}
+	return warnings
+}
+
+func (in *Integrity) checkOnOpen(folderPath string) {
+	if in.ctx == nil {
+		return
+	}
+
+	warnings, err := CheckPublicKeys(folderPath)
+	if err != nil {
+		runtime.EventsEmit(in.ctx, WarningEvent, []string{err.Error()})
+		return
+	}
+	if len(warnings) > 0 {
+		runtime.EventsEmit(in.ctx, WarningEvent, warnings)
+	}
+}
+
+func keyWarning(entry FileEntry, actual string) KeyWarning {
+	return KeyWarning{
+		KeyName:             filepath.Base(filepath.Dir(filepath.FromSlash(entry.Path))),
+		Path:                entry.Path,
+		ExpectedFingerprint: entry.Fingerprint,
+		ActualFingerprint:   actual,
+	}
+}
+
+func readManifest(folderPath string) (*Manifest, []byte, error) {
+	manifestBytes, err := os.ReadFile(filepath.Join(folderPath, manifestDir, manifestFileName))
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+
+	var manifest Manifest
+	if err := json.Unmarshal(manifestBytes, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode manifest: %v", err)
+	}
+
+	return &manifest, manifestBytes, nil
+}
+
+// verifyManifestCached verifies the manifest signature unless the same
+// manifest verified before. Decrypting checks the public keys every time,
+// only a change of the manifest, its signature, the signer's public key or
+// the pin verifies it again
+func verifyManifestCached(folderPath string, manifest *Manifest, manifestBytes []byte) error {
+	id, err := manifestID(folderPath, manifest, manifestBytes)
+	if err != nil {
+		return err
+	}
+
+	cacheMu.Lock()
+	verified := verifiedManifest == id
+	cacheMu.Unlock()
+	if verified {
+		return nil
+	}
+
+	if err := verifyManifestSignature(folderPath, manifest, manifestBytes); err != nil {
+		return err
+	}
+
+	cacheMu.Lock()
+	verifiedManifest = id
+	cacheMu.Unlock()
+	return nil
+}
+
+// manifestID hashes everything the manifest signature check depends on
+func manifestID(folderPath string, manifest *Manifest, manifestBytes []byte) (string, error) {
+	pinned, err := pinnedFingerprint(folderPath)
+	if err != nil {
+		return "", err
+	}
+	// unreadable files are left to verifyManifestSignature to report
+	signature, _ := os.ReadFile(filepath.Join(folderPath, manifestDir, signatureName))
+	pubKeyArmor, _ := os.ReadFile(filepath.Join(folderPath, "pgp-keys", manifest.SignerKey, "public.asc"))
+
+	h := sha256.New()
+	for _, part := range [][]byte{[]byte(folderPath), []byte(pinned), manifestBytes, signature, pubKeyArmor} {
+		fmt.Fprintf(h, "%d:", len(part))
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func verifyManifestSignature(folderPath string, manifest *Manifest, manifestBytes []byte) error {
+	pinned, err := pinnedFingerprint(folderPath)
+	if err != nil {
+		return err
+	}
+	if pinned == "" {
+		return fmt.Errorf("vault key is not pinned on this machine")
+	}
+	if pinned != manifest.SignerFingerprint {
+		return fmt.Errorf("manifest was signed by %s but the vault is pinned to %s", manifest.SignerFingerprint, pinned)
+	}
+
+	signature, err := os.ReadFile(filepath.Join(folderPath, manifestDir, signatureName))
+	if err != nil {
+		return fmt.Errorf("failed to read manifest signature: %v", err)
+	}
+
+	pubKeyArmor, err := os.ReadFile(filepath.Join(folderPath, "pgp-keys", manifest.SignerKey, "public.asc"))
+	if err != nil {
+		return fmt.Errorf("failed to read vault public key: %v", err)
+	}
+
+	pubKey, err := crypto.NewKeyFromArmored(string(pubKeyArmor))
+	if err != nil {
+		return fmt.Errorf("failed to load vault public key: %v", err)
+	}
+	if pubKey.GetFingerprint() != pinned {
+		return fmt.Errorf("vault public key was replaced (expected %s, found %s)", pinned, pubKey.GetFingerprint())
+	}
+
+	verifyHandle, err := crypto.PGP().Verify().VerificationKey(pubKey).New()
+	if err != nil {
+		return fmt.Errorf("failed to create verification handle: %s", err)
+	}
+
+	result, err := verifyHandle.VerifyDetached(manifestBytes, signature, crypto.Armor)
+	if err != nil {
+		return fmt.Errorf("failed to verify manifest: %s", err)
+	}
+	if sigErr := result.SignatureError(); sigErr != nil {
+		return fmt.Errorf("manifest signature is invalid: %s", sigErr)
+	}
+
+	return nil
+}
+
+func hashVault(folderPath string) ([]FileEntry, error) {
+	files := []FileEntry{}
+
+	for _, dir := range TrackedDirs {
+		root := filepath.Join(folderPath, dir)
+		if _, err := os.Stat(root); os.IsNotExist(err) {
+			continue
+		}
+
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || strings.HasPrefix(info.Name(), ".") {
+				return nil
+			}
+
+			sum, err := hashFile(path)
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(folderPath, path)
+			if err != nil {
+				return err
+			}
+
+			entry := FileEntry{
+				Path:   filepath.ToSlash(rel),
+				SHA256: sum,
+				Size:   info.Size(),
+			}
+
+			if dir == "pgp-keys" && info.Name() == "public.asc" {
+				entry.Fingerprint, _ = fingerprintOf(path)
+			}
+
+			files = append(files, entry)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash vault files in %s: %v", dir, err)
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+
+	return files, nil
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// fingerprintOf returns the fingerprint of a public key file, a file with
+// the same content as one parsed before is not parsed again
+func fingerprintOf(pubKeyPath string) (string, error) {
+	pubKeyArmor, err := os.ReadFile(pubKeyPath)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(pubKeyArmor)
+	id := hex.EncodeToString(sum[:])
+
+	cacheMu.Lock()
+	fingerprint, ok := fingerprints[id]
+	cacheMu.Unlock()
+	if ok {
+		return fingerprint, nil
+	}
+
+	key, err := crypto.NewKeyFromArmored(string(pubKeyArmor))
+	if err != nil {
+		return "", err
+	}
+
+	cacheMu.Lock()
+	if len(fingerprints) >= maxCachedFingerprints {
+		fingerprints = map[string]string{}
+	}
+	fingerprints[id] = key.GetFingerprint()
+	cacheMu.Unlock()
+	return key.GetFingerprint(), nil
+}
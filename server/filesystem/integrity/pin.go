@@ -0,0 +1,95 @@
+package integrity
+
+import (
+	"MindLockr/server/filesystem"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// pins maps absolute vault paths to the fingerprint of their designated vault
+// key. It is stored in the user config directory instead of the vault itself
+// so whoever can write to the vault cannot also replace the pin
+type pins map[string]string
+
+var pinsMu sync.Mutex
+
+func pinsPath() (string, error) {
+	dir, err := filesystem.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "integrity-pins.json"), nil
+}
+
+func loadPins() (pins, error) {
+	path, err := pinsPath()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return pins{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read integrity pins: %v", err)
+	}
+
+	p := pins{}
+	if err := json.Unmarshal(content, &p); err != nil {
+		return nil, fmt.Errorf("failed to decode integrity pins: %v", err)
+	}
+	return p, nil
+}
+
+func pinnedFingerprint(folderPath string) (string, error) {
+	pinsMu.Lock()
+	defer pinsMu.Unlock()
+
+	p, err := loadPins()
+	if err != nil {
+		return "", err
+	}
+
+	abs, err := filepath.Abs(folderPath)
+	if err != nil {
+		return "", err
+	}
+	return p[abs], nil
+}
+
+func pinFingerprint(folderPath, fingerprint string) error {
+	pinsMu.Lock()
+	defer pinsMu.Unlock()
+
+	p, err := loadPins()
+	if err != nil {
+		return err
+	}
+
+	abs, err := filepath.Abs(folderPath)
+	if err != nil {
+		return err
+	}
+	p[abs] = fingerprint
+
+	path, err := pinsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+
+	content, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode integrity pins: %v", err)
+	}
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		return fmt.Errorf("failed to write integrity pins: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package items
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/session"
+	"MindLockr/server/filesystem"
+	"MindLockr/server/filesystem/audit"
+	"MindLockr/server/filesystem/sealed"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+const itemsDir = "items"
+
+// supported item types
+const (
+	TypeLogin    = "login"
+	TypeCard     = "card"
+	TypeIdentity = "identity"
+	TypeWallet   = "wallet"
+	TypeNote     = "note"
+)
+
+type (
+	Items struct {
+		store *sealed.Store
+	}
+
+	Login struct {
+		URL      string `json:"url"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Notes    string `json:"notes,omitempty"`
+	}
+
+	Card struct {
+		Cardholder  string `json:"cardholder"`
+		Number      string `json:"number"`
+		ExpiryMonth int    `json:"expiryMonth"`
+		ExpiryYear  int    `json:"expiryYear"`
+		CVV         string `json:"cvv,omitempty"`
+		PIN         string `json:"pin,omitempty"`
+		Notes       string `json:"notes,omitempty"`
+	}
+
+	Identity struct {
+		// DocumentType is one of passport, id-card, driver-license,
+		// residence-permit or other
+		DocumentType   string `json:"documentType"`
+		DocumentNumber string `json:"documentNumber"`
+		FullName       string `json:"fullName"`
+		Nationality    string `json:"nationality,omitempty"`
+		IssuingCountry string `json:"issuingCountry,omitempty"`
+		DateOfBirth    string `json:"dateOfBirth,omitempty"` // YYYY-MM-DD
+		IssueDate      string `json:"issueDate,omitempty"`   // YYYY-MM-DD
+		ExpiryDate     string `json:"expiryDate,omitempty"`  // YYYY-MM-DD
+		Notes          string `json:"notes,omitempty"`
+	}
+
+	Wallet struct {
+		Name       string   `json:"name"`
+		Network    string   `json:"network,omitempty"`
+		SeedPhrase string   `json:"seedPhrase"`
+		Passphrase string   `json:"passphrase,omitempty"` // optional BIP39 passphrase ("25th word")
+		Addresses  []string `json:"addresses,omitempty"`
+		Notes      string   `json:"notes,omitempty"`
+	}
+
+	Note struct {
+		Content string `json:"content"`
+	}
+
+	Item struct {
+		ID        string    `json:"id"`
+		Type      string    `json:"type"`
+		Title     string    `json:"title"`
+		Tags      []string  `json:"tags,omitempty"`
+		CreatedAt time.Time `json:"createdAt"`
+		UpdatedAt time.Time `json:"updatedAt"`
+		Login     *Login    `json:"login,omitempty"`
+		Card      *Card     `json:"card,omitempty"`
+		Identity  *Identity `json:"identity,omitempty"`
+		Wallet    *Wallet   `json:"wallet,omitempty"`
+		Note      *Note     `json:"note,omitempty"`
+		// Warnings are those of the verification policy about the
+		// signature of the stored item, they are never stored
+		Warnings []string `json:"warnings,omitempty"`
+	}
+
+	// ItemSummary is what listings return, it never contains secret fields
+	ItemSummary struct {
+		ID        string    `json:"id"`
+		Type      string    `json:"type"`
+		Title     string    `json:"title"`
+		Subtitle  string    `json:"subtitle,omitempty"`
+		Tags      []string  `json:"tags,omitempty"`
+		UpdatedAt time.Time `json:"updatedAt"`
+		Expired   bool      `json:"expired"`
+		Warnings  []string  `json:"warnings,omitempty"`
+	}
+
+	// ItemList is what ListItems returns. An item that cannot be decrypted,
+	// like one sealed to another key, does not hide the others: it is left
+	// out of Items and reported in Unreadable
+	ItemList struct {
+		Items      []ItemSummary    `json:"items"`
+		Unreadable []UnreadableItem `json:"unreadable"`
+	}
+
+	UnreadableItem struct {
+		ID    string      `json:"id"`
+		Code  apperr.Code `json:"code"`
+		Error string      `json:"error"`
+	}
+)
+
+func NewItems(folder *filesystem.Folder) *Items {
+	return &Items{
+		store: sealed.NewStore(folder, itemsDir, "item"),
+	}
+}
+
+// CreateItem validates and stores a new item encrypted to the session key
+func (it *Items) CreateItem(item Item) (Item, error) {
+	id, err := sealed.NewID()
+	if err != nil {
+		return Item{}, err
+	}
+
+	now := time.Now().UTC()
+	item.ID = id
+	item.CreatedAt = now
+	item.UpdatedAt = now
+	item.Warnings = nil
+
+	if err := validate(&item); err != nil {
+		return Item{}, err
+	}
+	if err := it.write(item); err != nil {
+		return Item{}, err
+	}
+	return item, nil
+}
+
+// UpdateItem replaces an existing item, its type cannot change
+func (it *Items) UpdateItem(item Item) (Item, error) {
+	existing, err := it.read(item.ID)
+	if err != nil {
+		return Item{}, err
+	}
+	if existing.Type != item.Type {
+		return Item{}, fmt.Errorf("cannot change the type of item %s from %s to %s", item.ID, existing.Type, item.Type)
+	}
+
+	item.CreatedAt = existing.CreatedAt
+	item.UpdatedAt = time.Now().UTC()
+	item.Warnings = nil
+
+	if err := validate(&item); err != nil {
+		return Item{}, err
+	}
+	if err := it.write(item); err != nil {
+		return Item{}, err
+	}
+	return item, nil
+}
+
+// GetItem decrypts a single item
+func (it *Items) GetItem(id string) (Item, error) {
+	item, err := it.read(id)
+	audit.Record(audit.OpDecrypt, itemsDir+"/"+id, session.Fingerprint(), err)
+	return item, err
+}
+
+// ListItems returns summaries of every item, optionally only of one type
+func (it *Items) ListItems(itemType string) (ItemList, error) {
+	ids, err := it.store.IDs()
+	if err != nil {
+		return ItemList{}, err
+	}
+
+	list := ItemList{Items: []ItemSummary{}, Unreadable: []UnreadableItem{}}
+	for _, id := range ids {
+		item, err := it.read(id)
+		if errors.Is(err, session.ErrLocked) {
+			return ItemList{}, err
+		}
+		if err != nil {
+			list.Unreadable = append(list.Unreadable, UnreadableItem{ID: id, Code: apperr.CodeOf(err), Error: err.Error()})
+			continue
+		}
+		if itemType != "" && item.Type != itemType {
+			continue
+		}
+		list.Items = append(list.Items, summarize(item))
+	}
+
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].UpdatedAt.After(list.Items[j].UpdatedAt)
+	})
+
+	return list, nil
+}
+
+// DeleteItem removes an item from the vault
+func (it *Items) DeleteItem(id string) (err error) {
+	defer func() {
+		audit.Record(audit.OpDelete, itemsDir+"/"+id, "", err)
+	}()
+
+	return it.store.Delete(id)
+}
+
+func (it *Items) write(item Item) error {
+	return it.store.Write(item.ID, item)
+}
+
+func (it *Items) read(id string) (Item, error) {
+	var item Item
+	warnings, err := it.store.Read(id, &item)
+	if err != nil {
+		return Item{}, err
+	}
+	if item.ID != id {
+		return Item{}, fmt.Errorf("item file %s contains item %s", id, item.ID)
+	}
+	item.Warnings = warnings
+	return item, nil
+}
+
+func summarize(item Item) ItemSummary {
+	summary := ItemSummary{
+		ID:        item.ID,
+		Type:      item.Type,
+		Title:     item.Title,
+		Tags:      item.Tags,
+		UpdatedAt: item.UpdatedAt,
+		Warnings:  item.Warnings,
+	}
+
+	now := time.Now()
+	switch {
+	case item.Login != nil:
+		summary.Subtitle = item.Login.Username
+	case item.Card != nil:
+		if n := len(item.Card.Number); n >= 4 {
+			summary.Subtitle = "•••• " + item.Card.Number[n-4:]
+		}
+		// a card is valid through the last day of its expiry month
+		expiry := time.Date(item.Card.ExpiryYear, time.Month(item.Card.ExpiryMonth)+1, 1, 0, 0, 0, 0, time.UTC)
+		summary.Expired = !now.Before(expiry)
+	case item.Identity != nil:
+		summary.Subtitle = item.Identity.FullName
+		if expiry, err := time.Parse(dateLayout, item.Identity.ExpiryDate); err == nil {
+			summary.Expired = now.After(expiry.AddDate(0, 0, 1))
+		}
+	case item.Wallet != nil:
+		summary.Subtitle = item.Wallet.Network
+	}
+
+	return summary
+}
@@ -0,0 +1,211 @@
+package items
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BIP39 english wordlist (https://github.com/bitcoin/bips/blob/master/bip-0039/english.txt)
+//
+//go:embed bip39_english.txt
+var bip39English string
+
+var (
+	bip39Once  sync.Once
+	bip39Index map[string]int
+)
+
+const dateLayout = "2006-01-02"
+
+func bip39Words() map[string]int {
+	bip39Once.Do(func() {
+		words := strings.Fields(bip39English)
+		bip39Index = make(map[string]int, len(words))
+		for i, w := range words {
+			bip39Index[w] = i
+		}
+	})
+	return bip39Index
+}
+
+// ValidateBIP39 checks the word count, the words and the checksum of a BIP39
+// mnemonic. Every word encodes 11 bits, the last ENT/32 bits of the sequence
+// are the first bits of sha256(entropy)
+func ValidateBIP39(mnemonic string) error {
+	words := strings.Fields(strings.ToLower(mnemonic))
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+	default:
+		return fmt.Errorf("seed phrase must have 12, 15, 18, 21 or 24 words, got %d", len(words))
+	}
+
+	index := bip39Words()
+	bits := make([]bool, 0, len(words)*11)
+	for i, word := range words {
+		idx, ok := index[word]
+		if !ok {
+			return fmt.Errorf("word %d (%q) is not in the BIP39 wordlist", i+1, word)
+		}
+		for b := 10; b >= 0; b-- {
+			bits = append(bits, idx&(1<<b) != 0)
+		}
+	}
+
+	checksumBits := len(bits) / 33
+	entropyBits := len(bits) - checksumBits
+
+	entropy := make([]byte, entropyBits/8)
+	for i := 0; i < entropyBits; i++ {
+		if bits[i] {
+			entropy[i/8] |= 1 << (7 - i%8)
+		}
+	}
+
+	hash := sha256.Sum256(entropy)
+	for i := 0; i < checksumBits; i++ {
+		expected := hash[i/8]&(1<<(7-i%8)) != 0
+		if bits[entropyBits+i] != expected {
+			return fmt.Errorf("seed phrase checksum is invalid, check the words and their order")
+		}
+	}
+
+	return nil
+}
+
+// luhnValid implements the Luhn checksum used by payment card numbers
+func luhnValid(number string) bool {
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		d := int(number[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func normalizeCardNumber(number string) (string, error) {
+	digits := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' {
+			return -1
+		}
+		return r
+	}, number)
+
+	if len(digits) < 12 || len(digits) > 19 {
+		return "", fmt.Errorf("card number must have between 12 and 19 digits")
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return "", fmt.Errorf("card number may only contain digits")
+		}
+	}
+	if !luhnValid(digits) {
+		return "", fmt.Errorf("card number checksum is invalid")
+	}
+	return digits, nil
+}
+
+func validateDate(field, value string) error {
+	if value == "" {
+		return nil
+	}
+	if _, err := time.Parse(dateLayout, value); err != nil {
+		return fmt.Errorf("%s must be formatted as YYYY-MM-DD", field)
+	}
+	return nil
+}
+
+// validate checks the item against the schema of its type and normalizes it
+func validate(item *Item) error {
+	if strings.TrimSpace(item.Title) == "" {
+		return fmt.Errorf("title is required")
+	}
+
+	var payloads int
+	for _, set := range []bool{item.Login != nil, item.Card != nil, item.Identity != nil, item.Wallet != nil, item.Note != nil} {
+		if set {
+			payloads++
+		}
+	}
+	if payloads != 1 {
+		return fmt.Errorf("an item must contain exactly the fields of its type")
+	}
+
+	switch item.Type {
+	case TypeLogin:
+		if item.Login == nil {
+			return fmt.Errorf("login fields are missing")
+		}
+		if item.Login.Username == "" && item.Login.Password == "" {
+			return fmt.Errorf("a login needs a username or a password")
+		}
+
+	case TypeCard:
+		if item.Card == nil {
+			return fmt.Errorf("card fields are missing")
+		}
+		number, err := normalizeCardNumber(item.Card.Number)
+		if err != nil {
+			return err
+		}
+		item.Card.Number = number
+		if item.Card.ExpiryMonth < 1 || item.Card.ExpiryMonth > 12 {
+			return fmt.Errorf("expiry month must be between 1 and 12")
+		}
+		if item.Card.ExpiryYear < 2000 || item.Card.ExpiryYear > 2100 {
+			return fmt.Errorf("expiry year is invalid")
+		}
+
+	case TypeIdentity:
+		if item.Identity == nil {
+			return fmt.Errorf("identity fields are missing")
+		}
+		switch item.Identity.DocumentType {
+		case "passport", "id-card", "driver-license", "residence-permit", "other":
+		default:
+			return fmt.Errorf("unsupported document type: %q", item.Identity.DocumentType)
+		}
+		if item.Identity.DocumentNumber == "" {
+			return fmt.Errorf("document number is required")
+		}
+		for field, value := range map[string]string{
+			"date of birth": item.Identity.DateOfBirth,
+			"issue date":    item.Identity.IssueDate,
+			"expiry date":   item.Identity.ExpiryDate,
+		} {
+			if err := validateDate(field, value); err != nil {
+				return err
+			}
+		}
+
+	case TypeWallet:
+		if item.Wallet == nil {
+			return fmt.Errorf("wallet fields are missing")
+		}
+		item.Wallet.SeedPhrase = strings.Join(strings.Fields(strings.ToLower(item.Wallet.SeedPhrase)), " ")
+		if err := ValidateBIP39(item.Wallet.SeedPhrase); err != nil {
+			return err
+		}
+
+	case TypeNote:
+		if item.Note == nil {
+			return fmt.Errorf("note fields are missing")
+		}
+
+	default:
+		return fmt.Errorf("unsupported item type: %q", item.Type)
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package pgpfs
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/cryptohelper"
+	"MindLockr/server/cryptography/session"
+	"MindLockr/server/filesystem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// VaultKey is a key stored in pgp-keys/
+type VaultKey struct {
+	Name       string
+	Dir        string
+	PublicKey  *crypto.Key
+	HasPrivate bool
+}
+
+// VaultKeys loads the public half of every key in pgp-keys/, sorted by name.
+// Folders without a readable public key are skipped
+func VaultKeys() ([]VaultKey, error) {
+	folderPath := filesystem.GetFolderInstance().GetFolderPath()
+	if folderPath == "" {
+		return nil, apperr.ErrNoVaultFolder
+	}
+
+	keysDir := filepath.Join(folderPath, "pgp-keys")
+	entries, err := os.ReadDir(keysDir)
+	if os.IsNotExist(err) {
+		return []VaultKey{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading PGP keys folder: %v", err)
+	}
+
+	keys := []VaultKey{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(keysDir, entry.Name())
+
+		pubKeyArmor, err := os.ReadFile(filepath.Join(dir, "public.asc"))
+		if err != nil {
+			continue
+		}
+		pubKey, err := crypto.NewKeyFromArmored(string(pubKeyArmor))
+		if err != nil {
+			continue
+		}
+
+		_, err = os.Stat(filepath.Join(dir, "private.asc"))
+		keys = append(keys, VaultKey{
+			Name:       entry.Name(),
+			Dir:        dir,
+			PublicKey:  pubKey,
+			HasPrivate: err == nil,
+		})
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+	return keys, nil
+}
+
+// HasKeyID reports whether keyID is the ID of the primary key or of one of
+// the subkeys of key
+func (k VaultKey) HasKeyID(keyID uint64) bool {
+	entity := k.PublicKey.GetEntity()
+	if entity.PrimaryKey.KeyId == keyID {
+		return true
+	}
+	for _, sub := range entity.Subkeys {
+		if sub.PublicKey.KeyId == keyID {
+			return true
+		}
+	}
+	return false
+}
+
+// UnlockPrivateKey reads and unlocks the private half of the key
+func (k VaultKey) UnlockPrivateKey(passphrase string) (*crypto.Key, error) {
+	privKeyArmor, err := os.ReadFile(filepath.Join(k.Dir, "private.asc"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key file: %w", err)
+	}
+
+	key, err := cryptohelper.UnlockPrivateKey(string(privKeyArmor), []byte(passphrase))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unlock private key %s: %w", k.Name, err)
+	}
+	return key, nil
+}
+
+// PrivateKey returns the private half of the key, a copy of the session key
+// when it is the one unlocked and otherwise the key unlocked with
+// passphrase. release clears the key either way
+func (k VaultKey) PrivateKey(passphrase string) (key *crypto.Key, release func(), err error) {
+	if !k.HasPrivate {
+		return nil, nil, fmt.Errorf("%w: %s has no private key", apperr.ErrInvalidKey, k.Name)
+	}
+	if session.Fingerprint() == k.PublicKey.GetFingerprint() {
+		key, err = session.Current()
+	} else {
+		key, err = k.UnlockPrivateKey(passphrase)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, func() { key.ClearPrivateParams() }, nil
+}
+
+// VerificationKeyRing returns a key ring of every public key in keys
+func VerificationKeyRing(keys []VaultKey) (*crypto.KeyRing, error) {
+	keyRing, err := crypto.NewKeyRing(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key ring: %s", err)
+	}
+	for _, k := range keys {
+		if err := keyRing.AddKey(k.PublicKey); err != nil {
+			return nil, fmt.Errorf("failed to add %s to the key ring: %s", k.Name, err)
+		}
+	}
+	return keyRing, nil
+}
@@ -1,11 +1,14 @@
 package pgpfs
 
 import (
+	"MindLockr/server/apperr"
 	"MindLockr/server/cryptography/cryptohelper"
 	"MindLockr/server/filesystem"
+	"MindLockr/server/filesystem/trust"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/ProtonMail/gopenpgp/v3/crypto"
@@ -27,6 +30,7 @@ type PgpKeyInfo struct {
 	PrivateKey string `json:"privateKey"`
 	FolderPath string `json:"folderPath"`
 	Type       string `json:"type"`
+	Validity   string `json:"validity"`
 }
 
 func (kr *PgpRetrieve) RetrievePgpKeys() ([]PgpKeyInfo, error) {
@@ -43,9 +47,12 @@ func (kr *PgpRetrieve) getPgpKeysFromDirectory(basePath string) ([]PgpKeyInfo, e
 
 	keyFolders, err := os.ReadDir(basePath)
 	if err != nil {
-		return []PgpKeyInfo{}, fmt.Errorf("Error reading PGP keys folder: %v", err)
+		return []PgpKeyInfo{}, fmt.Errorf("Error reading PGP keys folder: %w", err)
 	}
 
+	// a broken trust store must not hide the keys, they are listed as unknown
+	validity, _ := trust.Compute(filepath.Dir(basePath))
+
 	for _, keyFolder := range keyFolders {
 		if keyFolder.IsDir() {
 			keyName := keyFolder.Name()
@@ -53,18 +60,18 @@ func (kr *PgpRetrieve) getPgpKeysFromDirectory(basePath string) ([]PgpKeyInfo, e
 
 			pubKeyArmor, err := kr.RetrievePgpPubKey(keyFolderPath)
 			if err != nil {
-				return nil, fmt.Errorf("Failed to get public key in getPgpKeysFromDirectory: %s", err)
+				return nil, fmt.Errorf("Failed to get public key in getPgpKeysFromDirectory: %w", err)
 			}
 
 			loadedPubKey, err := crypto.NewKeyFromArmored(pubKeyArmor)
 			if err != nil {
-				return nil, fmt.Errorf("Failed to load public key %v", err)
+				return nil, fmt.Errorf("Failed to load public key %w: %v", apperr.ErrInvalidKey, err)
 			}
 
 			alg := loadedPubKey.GetEntity().PrimaryKey.PubKeyAlgo
 			stringAlg, err := cryptohelper.DetectPGPType(alg)
 			if err != nil {
-				return nil, fmt.Errorf("Failed to detect PGP type %v", err)
+				return nil, fmt.Errorf("Failed to detect PGP type %w", err)
 			}
 
 			pgpKeys = append(pgpKeys, PgpKeyInfo{
@@ -72,6 +79,7 @@ func (kr *PgpRetrieve) getPgpKeysFromDirectory(basePath string) ([]PgpKeyInfo, e
 				PublicKey:  pubKeyArmor,
 				FolderPath: keyFolderPath,
 				Type:       stringAlg,
+				Validity:   validityOf(validity, loadedPubKey.GetFingerprint()),
 			})
 		}
 	}
@@ -84,7 +92,7 @@ func (kr *PgpRetrieve) RetrievePgpPubKey(keyFolderPath string) (string, error) {
 
 	pubKeyArmor, err := os.ReadFile(pubKeyPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to read public key file: %v", err)
+		return "", fmt.Errorf("failed to read public key file: %w: %w", apperr.ErrKeyNotFound, err)
 	}
 
 	return string(pubKeyArmor), nil
@@ -95,7 +103,7 @@ func (kr *PgpRetrieve) RetrievePgpPrivKey(keyFolderPath string) (string, error)
 
 	encryptedPrivKeyHex, err := os.ReadFile(privKeyPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to read private key file: %v", err)
+		return "", fmt.Errorf("failed to read private key file: %w: %w", apperr.ErrKeyNotFound, err)
 	}
 
 	return string(encryptedPrivKeyHex), nil
@@ -106,12 +114,12 @@ func (kr *PgpRetrieve) RetrievePgpFingerprint(keyFolderPath string) (string, err
 
 	pubKeyArmor, err := os.ReadFile(pubKeyPath)
 	if err != nil {
-		return "", fmt.Errorf("failed to read public key file: %v", err)
+		return "", fmt.Errorf("failed to read public key file: %w: %w", apperr.ErrKeyNotFound, err)
 	}
 
 	loadedPubKey, err := crypto.NewKeyFromArmored(string(pubKeyArmor))
 	if err != nil {
-		return "", fmt.Errorf("failed to load public key: %v", err)
+		return "", fmt.Errorf("failed to load public key: %w: %v", apperr.ErrInvalidKey, err)
 	}
 
 	fingerprint := loadedPubKey.GetFingerprint()
@@ -123,20 +131,22 @@ func (kr *PgpRetrieve) RetrieveKeyMoreInfo(keyFolderPath string) (map[string]str
 	pubKeyPath := filepath.Join(keyFolderPath, "public.asc")
 	pubKeyArmor, err := os.ReadFile(pubKeyPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read public key file: %v", err)
+		return nil, fmt.Errorf("failed to read public key file: %w: %w", apperr.ErrKeyNotFound, err)
 	}
 
 	loadedKey, err := crypto.NewKeyFromArmored(string(pubKeyArmor))
 	if err != nil {
-		return nil, fmt.Errorf("failed to load public key %v", err)
+		return nil, fmt.Errorf("failed to load public key %w: %v", apperr.ErrInvalidKey, err)
 	}
 
 	moreInfo := make(map[string]string)
-	identities := loadedKey.GetEntity().Identities
-	for _, identity := range identities {
-		moreInfo["Name"] = identity.Name
-		moreInfo["Email"] = identity.UserId.Email
-		break
+	userIDs := DescribeUserIDs(loadedKey.GetEntity())
+	if len(userIDs) > 0 {
+		moreInfo["Name"] = userIDs[0].Name
+		moreInfo["Email"] = userIDs[0].Email
+	}
+	for i, userID := range userIDs {
+		moreInfo[fmt.Sprintf("User ID %d", i+1)] = userID.UserID + " · " + userID.State
 	}
 
 	moreInfo["Fingerprint"] = loadedKey.GetFingerprint()
@@ -155,8 +165,26 @@ func (kr *PgpRetrieve) RetrieveKeyMoreInfo(keyFolderPath string) (map[string]str
 		moreInfo["Key Type"] = "Unknown"
 	}
 
-	moreInfo["Key Validity"] = "Fully Valid"
+	validity, _ := trust.Current()
+	moreInfo["Key Validity"] = validityOf(validity, loadedKey.GetFingerprint())
+	if v, ok := validity[strings.ToUpper(loadedKey.GetFingerprint())]; ok {
+		moreInfo["Owner Trust"] = v.OwnerTrust
+		moreInfo["Certifications"] = fmt.Sprintf("%d", len(v.CertifiedBy))
+	}
 	moreInfo["Path"] = keyFolderPath
 
+	subkeys := DescribeSubkeys(loadedKey.GetEntity())
+	moreInfo["Subkeys"] = fmt.Sprintf("%d", len(subkeys))
+	for i, subkey := range subkeys {
+		moreInfo[fmt.Sprintf("Subkey %d", i+1)] = describeSubkey(subkey)
+	}
+
 	return moreInfo, nil
 }
+
+func validityOf(validity map[string]trust.KeyValidity, fingerprint string) string {
+	if v, ok := validity[strings.ToUpper(fingerprint)]; ok {
+		return v.Validity
+	}
+	return trust.ValidityUnknown
+}
@@ -1,6 +1,7 @@
 package pgpfs
 
 import (
+	"MindLockr/server/apperr"
 	"MindLockr/server/filesystem"
 	"fmt"
 	"os"
@@ -12,14 +13,14 @@ func SavePgpPrivKey(privKeyArmor string, keyName string) error {
 	folderPath := folderInstance.GetFolderPath()
 
 	if folderPath == "" {
-		return fmt.Errorf("Please initialize the folder where you want to store private key")
+		return apperr.ErrNoVaultFolder
 	}
 
 	keysDir := filepath.Join(folderPath, "pgp-keys", keyName)
 
 	err := os.MkdirAll(keysDir, 0755)
 	if err != nil {
-		return fmt.Errorf("failed to create keys directory: %v", err)
+		return fmt.Errorf("failed to create keys directory: %w", err)
 	}
 
 	keyFilePath := filepath.Join(keysDir, "private.asc")
@@ -27,7 +28,7 @@ func SavePgpPrivKey(privKeyArmor string, keyName string) error {
 	// Write the private key without specifying file permissions
 	err = os.WriteFile(keyFilePath, []byte(privKeyArmor), 0644)
 	if err != nil {
-		return fmt.Errorf("failed to write private key to file: %v", err)
+		return fmt.Errorf("failed to write private key to file: %w", err)
 	}
 
 	return nil
@@ -38,21 +39,21 @@ func SavePgpPublicKey(pubKeyArmor string, keyName string) error {
 	folderPath := folderInstance.GetFolderPath()
 
 	if folderPath == "" {
-		return fmt.Errorf("Please initialize the folder where you want to store public key")
+		return apperr.ErrNoVaultFolder
 	}
 
 	keysDir := filepath.Join(folderPath, "pgp-keys", keyName)
 
 	err := os.MkdirAll(keysDir, 0755)
 	if err != nil {
-		return fmt.Errorf("failed to create keys directory: %v", err)
+		return fmt.Errorf("failed to create keys directory: %w", err)
 	}
 
 	keyFilePath := filepath.Join(keysDir, "public.asc")
 
 	err = os.WriteFile(keyFilePath, []byte(pubKeyArmor), 0644)
 	if err != nil {
-		return fmt.Errorf("failed to write public key to file: %v", err)
+		return fmt.Errorf("failed to write public key to file: %w", err)
 	}
 
 	return nil
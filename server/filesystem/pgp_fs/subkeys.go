@@ -0,0 +1,132 @@
+package pgpfs
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/cryptohelper"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// states of a subkey
+const (
+	SubkeyValid   = "valid"
+	SubkeyExpired = "expired"
+	SubkeyRevoked = "revoked"
+	SubkeyInvalid = "invalid"
+)
+
+// usages of a subkey, from the key flags of its binding signature
+const (
+	UsageSign         = "sign"
+	UsageEncrypt      = "encrypt"
+	UsageAuthenticate = "authenticate"
+)
+
+type SubkeyInfo struct {
+	Fingerprint string    `json:"fingerprint"`
+	KeyID       string    `json:"keyId"`
+	Algorithm   string    `json:"algorithm"`
+	Bits        int       `json:"bits,omitempty"`
+	Usages      []string  `json:"usages"`
+	CreatedAt   time.Time `json:"createdAt"`
+	// ExpiresAt is zero when the subkey does not expire
+	ExpiresAt        time.Time `json:"expiresAt,omitempty"`
+	State            string    `json:"state"`
+	RevocationReason string    `json:"revocationReason,omitempty"`
+}
+
+// RetrieveSubkeys lists the subkeys of the key stored in keyFolderPath
+func (kr *PgpRetrieve) RetrieveSubkeys(keyFolderPath string) ([]SubkeyInfo, error) {
+	pubKeyArmor, err := kr.RetrievePgpPubKey(keyFolderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	loadedKey, err := crypto.NewKeyFromArmored(pubKeyArmor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load public key %w: %v", apperr.ErrInvalidKey, err)
+	}
+	return DescribeSubkeys(loadedKey.GetEntity()), nil
+}
+
+// DescribeSubkeys returns the usage and state of every subkey of entity
+func DescribeSubkeys(entity *openpgp.Entity) []SubkeyInfo {
+	now := time.Now()
+	subkeys := make([]SubkeyInfo, 0, len(entity.Subkeys))
+
+	for i := range entity.Subkeys {
+		sub := &entity.Subkeys[i]
+
+		info := SubkeyInfo{
+			Fingerprint: strings.ToUpper(hex.EncodeToString(sub.PublicKey.Fingerprint)),
+			KeyID:       strings.ToUpper(sub.PublicKey.KeyIdString()),
+			Algorithm:   "Unknown",
+			CreatedAt:   sub.PublicKey.CreationTime.UTC(),
+			Usages:      []string{},
+			State:       SubkeyValid,
+		}
+		if alg, err := cryptohelper.DetectPGPType(sub.PublicKey.PubKeyAlgo); err == nil {
+			info.Algorithm = alg
+		}
+		if sub.PublicKey.PubKeyAlgo == packet.PubKeyAlgoRSA {
+			if bits, err := sub.PublicKey.BitLength(); err == nil {
+				info.Bits = int(bits)
+			}
+		}
+
+		sig, err := sub.LatestValidBindingSignature(time.Time{}, nil)
+		if err != nil {
+			info.State = SubkeyInvalid
+			subkeys = append(subkeys, info)
+			continue
+		}
+
+		if sig.FlagSign {
+			info.Usages = append(info.Usages, UsageSign)
+		}
+		if sig.FlagEncryptCommunications || sig.FlagEncryptStorage {
+			info.Usages = append(info.Usages, UsageEncrypt)
+		}
+		if sig.FlagAuthenticate {
+			info.Usages = append(info.Usages, UsageAuthenticate)
+		}
+		if sig.KeyLifetimeSecs != nil && *sig.KeyLifetimeSecs > 0 {
+			info.ExpiresAt = info.CreatedAt.Add(time.Duration(*sig.KeyLifetimeSecs) * time.Second)
+		}
+
+		switch {
+		case sub.Revoked(sig, now):
+			info.State = SubkeyRevoked
+			for _, rev := range sub.Revocations {
+				if rev.Valid != nil && *rev.Valid {
+					info.RevocationReason = rev.Packet.RevocationReasonText
+				}
+			}
+		case sub.Expired(sig, now):
+			info.State = SubkeyExpired
+		}
+
+		subkeys = append(subkeys, info)
+	}
+
+	return subkeys
+}
+
+// describeSubkey formats a subkey for RetrieveKeyMoreInfo
+func describeSubkey(info SubkeyInfo) string {
+	parts := []string{info.KeyID, info.Algorithm}
+	if len(info.Usages) > 0 {
+		parts = append(parts, strings.Join(info.Usages, "/"))
+	}
+	if !info.ExpiresAt.IsZero() {
+		parts = append(parts, "expires "+info.ExpiresAt.Format("2006-01-02"))
+	}
+	parts = append(parts, info.State)
+	return strings.Join(parts, " · ")
+}
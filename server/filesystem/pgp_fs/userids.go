@@ -0,0 +1,96 @@
+package pgpfs
+
+import (
+	"MindLockr/server/apperr"
+	"fmt"
+	"sort"
+	"time"
+
+	openpgp "github.com/ProtonMail/go-crypto/openpgp/v2"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// states of a user ID, from its self-signatures
+const (
+	UserIDValid   = "valid"
+	UserIDExpired = "expired"
+	UserIDRevoked = "revoked"
+	UserIDInvalid = "invalid"
+)
+
+type UserIDInfo struct {
+	UserID  string `json:"userId"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Comment string `json:"comment,omitempty"`
+	Primary bool   `json:"primary"`
+	State   string `json:"state"`
+	// SelfSignedAt is the creation time of the self-signature in effect
+	SelfSignedAt     time.Time `json:"selfSignedAt,omitempty"`
+	RevocationReason string    `json:"revocationReason,omitempty"`
+	// Certifications counts the signatures of other keys on this user ID
+	Certifications int `json:"certifications"`
+}
+
+// RetrieveUserIDs lists the user IDs of the key stored in keyFolderPath
+func (kr *PgpRetrieve) RetrieveUserIDs(keyFolderPath string) ([]UserIDInfo, error) {
+	pubKeyArmor, err := kr.RetrievePgpPubKey(keyFolderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	loadedKey, err := crypto.NewKeyFromArmored(pubKeyArmor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load public key %w: %v", apperr.ErrInvalidKey, err)
+	}
+	return DescribeUserIDs(loadedKey.GetEntity()), nil
+}
+
+// DescribeUserIDs returns every user ID of entity with the state of its
+// self-signature, the primary user ID first
+func DescribeUserIDs(entity *openpgp.Entity) []UserIDInfo {
+	now := time.Now()
+	_, primary := entity.PrimaryIdentity(now, nil)
+
+	userIDs := make([]UserIDInfo, 0, len(entity.Identities))
+	for _, ident := range entity.Identities {
+		info := UserIDInfo{
+			UserID:         ident.Name,
+			Name:           ident.UserId.Name,
+			Email:          ident.UserId.Email,
+			Comment:        ident.UserId.Comment,
+			Primary:        ident == primary,
+			State:          UserIDValid,
+			Certifications: len(ident.OtherCertifications),
+		}
+
+		sig, err := ident.LatestValidSelfCertification(time.Time{}, nil)
+		switch {
+		case err != nil:
+			info.State = UserIDInvalid
+		case ident.Revoked(sig, now, nil):
+			info.State = UserIDRevoked
+			info.SelfSignedAt = sig.CreationTime.UTC()
+			for _, rev := range ident.Revocations {
+				if rev.Valid != nil && *rev.Valid {
+					info.RevocationReason = rev.Packet.RevocationReasonText
+				}
+			}
+		case sig.SigExpired(now):
+			info.State = UserIDExpired
+			info.SelfSignedAt = sig.CreationTime.UTC()
+		default:
+			info.SelfSignedAt = sig.CreationTime.UTC()
+		}
+
+		userIDs = append(userIDs, info)
+	}
+
+	sort.Slice(userIDs, func(i, j int) bool {
+		if userIDs[i].Primary != userIDs[j].Primary {
+			return userIDs[i].Primary
+		}
+		return userIDs[i].UserID < userIDs[j].UserID
+	})
+	return userIDs
+}
@@ -0,0 +1,152 @@
+package sealed
+
+import (
+	"MindLockr/server/apperr"
+	hybdec "MindLockr/server/cryptography/decryption/hyb_dec"
+	"MindLockr/server/cryptography/session"
+	"MindLockr/server/filesystem"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Store keeps JSON records sealed to the session key in a folder of the
+// vault, one <id>.asc file per record
+type Store struct {
+	folderInstance *filesystem.Folder
+	dir            string
+	// kind names a record in errors, like "item"
+	kind string
+}
+
+// NewStore creates a store for the records in <vault>/<dir>
+func NewStore(folder *filesystem.Folder, dir, kind string) *Store {
+	return &Store{
+		folderInstance: folder,
+		dir:            dir,
+		kind:           kind,
+	}
+}
+
+// NewID returns a random record id
+func NewID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate id: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Write encodes v as JSON, seals it to the session key and stores it under id
+func (s *Store) Write(id string, v any) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+
+	plain, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", s.kind, err)
+	}
+	defer clear(plain)
+
+	armored, err := session.Seal(plain)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create %s directory: %v", s.dir, err)
+	}
+	if err := os.WriteFile(path, armored, 0644); err != nil {
+		return fmt.Errorf("failed to write %s to file: %v", s.kind, err)
+	}
+	return nil
+}
+
+// Read opens the record stored under id and decodes it into v. The
+// warnings are those of the verification policy about its signature
+func (s *Store) Read(id string, v any) ([]string, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+
+	armored, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s %s: %w", s.kind, id, err)
+	}
+
+	plain, warnings, err := hybdec.OpenSealed(armored)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s: %w", s.kind, id, err)
+	}
+	defer clear(plain)
+
+	if err := json.Unmarshal(plain, v); err != nil {
+		return nil, fmt.Errorf("failed to decode %s %s: %v", s.kind, id, err)
+	}
+	return warnings, nil
+}
+
+// Delete removes the record stored under id
+func (s *Store) Delete(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to delete %s %s: %w", s.kind, id, err)
+	}
+	return nil
+}
+
+// IDs returns the ids of every stored record, none when the folder does not
+// exist yet
+func (s *Store) IDs() ([]string, error) {
+	dir, err := s.folder()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return ids, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s folder: %v", s.dir, err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".asc" {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(entry.Name(), ".asc"))
+	}
+	return ids, nil
+}
+
+func (s *Store) folder() (string, error) {
+	folderPath := s.folderInstance.GetFolderPath()
+	if folderPath == "" {
+		return "", apperr.ErrNoVaultFolder
+	}
+	return filepath.Join(folderPath, s.dir), nil
+}
+
+func (s *Store) path(id string) (string, error) {
+	if _, err := hex.DecodeString(id); err != nil || len(id) != 32 {
+		return "", fmt.Errorf("%w: invalid %s id: %q", apperr.ErrInvalidInput, s.kind, id)
+	}
+
+	dir, err := s.folder()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, id+".asc"), nil
+}
@@ -0,0 +1,100 @@
+package trust
+
+import (
+	"MindLockr/server/apperr"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	trustDir           = "trust"
+	ownerTrustFileName = "ownertrust.json"
+)
+
+// owner trust levels, how far the owner of a key is trusted to certify the
+// keys of others. Keys with a private half in the vault are always ultimately
+// trusted
+const (
+	OwnerTrustUnknown  = "unknown"
+	OwnerTrustNever    = "never"
+	OwnerTrustMarginal = "marginal"
+	OwnerTrustFull     = "full"
+	OwnerTrustUltimate = "ultimate"
+)
+
+var storeMu sync.Mutex
+
+// OwnerTrust returns the owner trust levels stored in the vault, keyed by
+// the upper case fingerprint
+func OwnerTrust(folderPath string) (map[string]string, error) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	return readOwnerTrust(folderPath)
+}
+
+// SetOwnerTrust stores the owner trust level of a key
+func SetOwnerTrust(folderPath, fingerprint, level string) error {
+	switch level {
+	case OwnerTrustUnknown, OwnerTrustNever, OwnerTrustMarginal, OwnerTrustFull:
+	case OwnerTrustUltimate:
+		return fmt.Errorf("only keys with a private key in the vault are ultimately trusted")
+	default:
+		return fmt.Errorf("unknown owner trust level: %q", level)
+	}
+	if fingerprint == "" {
+		return fmt.Errorf("fingerprint is required")
+	}
+
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	levels, err := readOwnerTrust(folderPath)
+	if err != nil {
+		return err
+	}
+
+	fingerprint = strings.ToUpper(fingerprint)
+	if level == OwnerTrustUnknown {
+		delete(levels, fingerprint)
+	} else {
+		levels[fingerprint] = level
+	}
+
+	content, err := json.MarshalIndent(levels, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode owner trust: %v", err)
+	}
+
+	dir := filepath.Join(folderPath, trustDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create trust directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ownerTrustFileName), content, 0644); err != nil {
+		return fmt.Errorf("failed to write owner trust: %v", err)
+	}
+	return nil
+}
+
+func readOwnerTrust(folderPath string) (map[string]string, error) {
+	if folderPath == "" {
+		return nil, apperr.ErrNoVaultFolder
+	}
+
+	levels := map[string]string{}
+	content, err := os.ReadFile(filepath.Join(folderPath, trustDir, ownerTrustFileName))
+	if os.IsNotExist(err) {
+		return levels, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read owner trust: %v", err)
+	}
+	if err := json.Unmarshal(content, &levels); err != nil {
+		return nil, fmt.Errorf("failed to decode owner trust: %v", err)
+	}
+	return levels, nil
+}
@@ -0,0 +1,245 @@
+package trust
+
+import (
+	"MindLockr/server/apperr"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+const tofuFileName = "tofu.json"
+
+// trust on first use states of a key seen for an email address
+const (
+	// TofuNew means the address was never seen before, the key is pinned now
+	TofuNew = "new"
+	// TofuPinned means the key matches the one pinned for the address
+	TofuPinned = "pinned"
+	// TofuConflict means the address is pinned to a different key
+	TofuConflict = "conflict"
+	// TofuRejected means the key was seen before and explicitly rejected
+	TofuRejected = "rejected"
+)
+
+type (
+	Pin struct {
+		Email       string    `json:"email"`
+		Fingerprint string    `json:"fingerprint"`
+		PinnedSince time.Time `json:"pinnedSince"`
+		// Pending is the fingerprint of the first different key seen for
+		// the address that was neither accepted nor rejected yet
+		Pending    string    `json:"pending,omitempty"`
+		PendingAt  time.Time `json:"pendingAt,omitempty"`
+		Rejected   []string  `json:"rejected,omitempty"`
+		Superseded []string  `json:"superseded,omitempty"`
+	}
+
+	TofuResult struct {
+		Email             string    `json:"email"`
+		Status            string    `json:"status"`
+		Fingerprint       string    `json:"fingerprint"`
+		PinnedFingerprint string    `json:"pinnedFingerprint"`
+		PinnedSince       time.Time `json:"pinnedSince"`
+		Message           string    `json:"message"`
+	}
+)
+
+// Observe checks the key of a correspondent against the pins of every email
+// address in its valid user IDs. Unknown addresses are pinned to the key,
+// a different key for a pinned address is recorded as a pending conflict
+// unless another key change is pending already. The pins are written only
+// when one of them changed, seeing a pinned key again leaves them untouched
+func Observe(folderPath string, key *crypto.Key) ([]TofuResult, error) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	pins, err := readPins(folderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	fp := strings.ToUpper(key.GetFingerprint())
+
+	changed := false
+	results := []TofuResult{}
+	for _, email := range keyEmails(key) {
+		pin, ok := pins[email]
+		switch {
+		case !ok:
+			pin = &Pin{Email: email, Fingerprint: fp, PinnedSince: now}
+			pins[email] = pin
+			changed = true
+		case pin.Fingerprint != fp && pin.Pending == "" && !contains(pin.Rejected, fp):
+			pin.Pending = fp
+			pin.PendingAt = now
+			changed = true
+		}
+
+		results = append(results, tofuResult(pin, fp, !ok))
+	}
+
+	if !changed {
+		return results, nil
+	}
+	if err := writePins(folderPath, pins); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// Pins returns every pinned address, sorted by email
+func Pins(folderPath string) ([]Pin, error) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	pins, err := readPins(folderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]Pin, 0, len(pins))
+	for _, pin := range pins {
+		list = append(list, *pin)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Email < list[j].Email
+	})
+	return list, nil
+}
+
+// ResolveConflict accepts or rejects the key pending for an address. An
+// accepted key replaces the pin, a rejected one is reported as rejected
+// whenever it is seen again
+func ResolveConflict(folderPath, email string, accept bool) (Pin, error) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	pins, err := readPins(folderPath)
+	if err != nil {
+		return Pin{}, err
+	}
+
+	pin, ok := pins[strings.ToLower(email)]
+	if !ok {
+		return Pin{}, fmt.Errorf("no key is pinned for %s", email)
+	}
+	if pin.Pending == "" {
+		return Pin{}, fmt.Errorf("the key of %s did not change", email)
+	}
+
+	now := time.Now().UTC()
+	if accept {
+		pin.Superseded = append(pin.Superseded, pin.Fingerprint)
+		pin.Fingerprint = pin.Pending
+		pin.PinnedSince = now
+	} else {
+		pin.Rejected = append(pin.Rejected, pin.Pending)
+	}
+	pin.Pending = ""
+	pin.PendingAt = time.Time{}
+
+	if err := writePins(folderPath, pins); err != nil {
+		return Pin{}, err
+	}
+	return *pin, nil
+}
+
+func tofuResult(pin *Pin, fp string, first bool) TofuResult {
+	result := TofuResult{
+		Email:             pin.Email,
+		Fingerprint:       fp,
+		PinnedFingerprint: pin.Fingerprint,
+		PinnedSince:       pin.PinnedSince,
+	}
+
+	since := pin.PinnedSince.Local().Format("2006-01-02")
+	switch {
+	case first:
+		result.Status = TofuNew
+		result.Message = fmt.Sprintf("first message from %s, key pinned now", pin.Email)
+	case pin.Fingerprint == fp:
+		result.Status = TofuPinned
+		result.Message = fmt.Sprintf("verified, key pinned since %s", since)
+	case contains(pin.Rejected, fp):
+		result.Status = TofuRejected
+		result.Message = fmt.Sprintf("key changed: this key for %s was rejected, the pinned key is %s", pin.Email, pin.Fingerprint)
+	case pin.Pending != fp:
+		result.Status = TofuConflict
+		result.Message = fmt.Sprintf("key changed: %s is pinned to %s since %s and the change to %s is not resolved yet", pin.Email, pin.Fingerprint, since, pin.Pending)
+	default:
+		result.Status = TofuConflict
+		result.Message = fmt.Sprintf("key changed: %s is pinned to %s since %s", pin.Email, pin.Fingerprint, since)
+	}
+	return result
+}
+
+// keyEmails returns the lower cased emails of the valid user IDs of key
+func keyEmails(key *crypto.Key) []string {
+	now := time.Now()
+	seen := map[string]bool{}
+	emails := []string{}
+	for _, ident := range key.GetEntity().Identities {
+		if _, err := ident.Verify(now, nil); err != nil {
+			continue
+		}
+		email := strings.ToLower(strings.TrimSpace(ident.UserId.Email))
+		if email == "" || seen[email] {
+			continue
+		}
+		seen[email] = true
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+	return emails
+}
+
+func readPins(folderPath string) (map[string]*Pin, error) {
+	if folderPath == "" {
+		return nil, apperr.ErrNoVaultFolder
+	}
+
+	pins := map[string]*Pin{}
+	content, err := os.ReadFile(filepath.Join(folderPath, trustDir, tofuFileName))
+	if os.IsNotExist(err) {
+		return pins, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key pins: %v", err)
+	}
+	if err := json.Unmarshal(content, &pins); err != nil {
+		return nil, fmt.Errorf("failed to decode key pins: %v", err)
+	}
+	return pins, nil
+}
+
+func writePins(folderPath string, pins map[string]*Pin) error {
+	content, err := json.MarshalIndent(pins, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode key pins: %v", err)
+	}
+
+	dir := filepath.Join(folderPath, trustDir)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create trust directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, tofuFileName), content, 0644); err != nil {
+		return fmt.Errorf("failed to write key pins: %v", err)
+	}
+	return nil
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
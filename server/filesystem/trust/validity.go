@@ -0,0 +1,230 @@
+package trust
+
+import (
+	"MindLockr/server/filesystem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// key validity levels, how sure we are that a key belongs to the person
+// named in its user IDs
+const (
+	ValidityUnknown  = "unknown"
+	ValidityMarginal = "marginal"
+	ValidityFull     = "full"
+	ValidityUltimate = "ultimate"
+	ValidityRevoked  = "revoked"
+	ValidityExpired  = "expired"
+)
+
+const (
+	// MarginalsNeeded is how many marginally trusted certifiers make a key
+	// fully valid, as in GnuPG's classic trust model
+	MarginalsNeeded = 3
+	// MaxDepth limits how long certification chains may get
+	MaxDepth = 5
+)
+
+type KeyValidity struct {
+	KeyName     string `json:"keyName"`
+	Fingerprint string `json:"fingerprint"`
+	Validity    string `json:"validity"`
+	OwnerTrust  string `json:"ownerTrust"`
+	// CertifiedBy lists the fingerprints of vault keys with a valid
+	// certification on one of the key's user IDs
+	CertifiedBy []string `json:"certifiedBy"`
+}
+
+type node struct {
+	KeyValidity
+	key     *crypto.Key
+	usable  bool
+	signers map[string]bool
+}
+
+// Compute derives the validity of every key in pgp-keys/ from the
+// certifications between them and the owner trust stored in the vault
+func Compute(folderPath string) (map[string]KeyValidity, error) {
+	ownerTrust, err := OwnerTrust(folderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, err := loadKeys(folderPath)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	byKeyID := map[uint64]*node{}
+	for fp, n := range nodes {
+		entity := n.key.GetEntity()
+		byKeyID[entity.PrimaryKey.KeyId] = n
+
+		if level, ok := ownerTrust[fp]; ok && n.OwnerTrust != OwnerTrustUltimate {
+			n.OwnerTrust = level
+		}
+		switch {
+		case n.key.IsRevoked(now.Unix()):
+			n.Validity = ValidityRevoked
+		case n.key.IsExpired(now.Unix()):
+			n.Validity = ValidityExpired
+		default:
+			n.usable = true
+		}
+	}
+
+	// collect the valid certifications of vault keys on valid user IDs
+	for _, n := range nodes {
+		entity := n.key.GetEntity()
+		for _, ident := range entity.Identities {
+			if _, err := ident.Verify(now, nil); err != nil {
+				continue
+			}
+			for _, cert := range ident.OtherCertifications {
+				sig := cert.Packet
+				if sig.IssuerKeyId == nil || sig.SigExpired(now) {
+					continue
+				}
+				signer, ok := byKeyID[*sig.IssuerKeyId]
+				if !ok || signer == n || !signer.usable {
+					continue
+				}
+				if err := signer.key.GetEntity().PrimaryKey.VerifyUserIdSignature(ident.Name, entity.PrimaryKey, sig); err != nil {
+					continue
+				}
+				if sig.SigType < packet.SigTypeGenericCert || sig.SigType > packet.SigTypePositiveCert {
+					continue
+				}
+				n.signers[signer.Fingerprint] = true
+			}
+		}
+	}
+
+	// propagate validity from the ultimately trusted keys, every round
+	// extends the certification chains by one hop
+	for depth := 0; depth < MaxDepth; depth++ {
+		changed := false
+		for _, n := range nodes {
+			if !n.usable || n.Validity == ValidityUltimate {
+				continue
+			}
+
+			full, marginal := 0, 0
+			for fp := range n.signers {
+				signer := nodes[fp]
+				if signer.Validity != ValidityFull && signer.Validity != ValidityUltimate {
+					continue
+				}
+				switch signer.OwnerTrust {
+				case OwnerTrustFull, OwnerTrustUltimate:
+					full++
+				case OwnerTrustMarginal:
+					marginal++
+				}
+			}
+
+			validity := ValidityUnknown
+			switch {
+			case full > 0 || marginal >= MarginalsNeeded:
+				validity = ValidityFull
+			case marginal > 0:
+				validity = ValidityMarginal
+			}
+			if validity != n.Validity {
+				n.Validity = validity
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	result := make(map[string]KeyValidity, len(nodes))
+	for fp, n := range nodes {
+		for signer := range n.signers {
+			n.CertifiedBy = append(n.CertifiedBy, signer)
+		}
+		sort.Strings(n.CertifiedBy)
+		result[fp] = n.KeyValidity
+	}
+	return result, nil
+}
+
+// Current returns the validity of every key in the vault folder currently
+// selected
+func Current() (map[string]KeyValidity, error) {
+	return Compute(filesystem.GetFolderInstance().GetFolderPath())
+}
+
+// Of returns the validity of the key with the given fingerprint, keys that
+// are not in the vault are unknown
+func Of(fingerprint string) string {
+	validity, err := Current()
+	if err != nil {
+		return ValidityUnknown
+	}
+	if v, ok := validity[strings.ToUpper(fingerprint)]; ok {
+		return v.Validity
+	}
+	return ValidityUnknown
+}
+
+func loadKeys(folderPath string) (map[string]*node, error) {
+	nodes := map[string]*node{}
+
+	keysDir := filepath.Join(folderPath, "pgp-keys")
+	entries, err := os.ReadDir(keysDir)
+	if os.IsNotExist(err) {
+		return nodes, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pgp-keys folder: %v", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		pubKeyArmor, err := os.ReadFile(filepath.Join(keysDir, entry.Name(), "public.asc"))
+		if err != nil {
+			continue
+		}
+		key, err := crypto.NewKeyFromArmored(string(pubKeyArmor))
+		if err != nil {
+			continue
+		}
+
+		fp := strings.ToUpper(key.GetFingerprint())
+		n := &node{
+			KeyValidity: KeyValidity{
+				KeyName:     entry.Name(),
+				Fingerprint: fp,
+				Validity:    ValidityUnknown,
+				OwnerTrust:  OwnerTrustUnknown,
+				CertifiedBy: []string{},
+			},
+			key:     key,
+			signers: map[string]bool{},
+		}
+
+		// our own keys anchor the web of trust
+		if _, err := os.Stat(filepath.Join(keysDir, entry.Name(), "private.asc")); err == nil {
+			n.OwnerTrust = OwnerTrustUltimate
+			n.Validity = ValidityUltimate
+		}
+
+		nodes[fp] = n
+	}
+
+	return nodes, nil
+}
@@ -0,0 +1,403 @@
+package watcher
+
+import (
+	"MindLockr/server/filesystem"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// the vault stores whose listings follow the disk
+const (
+	StoreSymmetric = "sym_lockr"
+	StoreHybrid    = "hyb_lockr"
+	StorePgpKeys   = "pgp-keys"
+)
+
+// what happened to an item
+const (
+	KindAdded    = "added"
+	KindRemoved  = "removed"
+	KindModified = "modified"
+)
+
+const (
+	// ItemAddedEvent, ItemRemovedEvent and ItemModifiedEvent are emitted to
+	// the frontend with a Change once the changes of an item settle
+	ItemAddedEvent    = "vault:item-added"
+	ItemRemovedEvent  = "vault:item-removed"
+	ItemModifiedEvent = "vault:item-modified"
+	// ResyncEvent is emitted when changes were lost, every listing has to
+	// be reloaded
+	ResyncEvent = "vault:resync"
+	// WatchFailedEvent is emitted with the error when a new vault folder
+	// cannot be watched, its listings do not follow the disk until it is
+	// changed again
+	WatchFailedEvent = "vault:watch-failed"
+)
+
+// Debounce is how long the changes of an item have to settle before they
+// are reported. It is a variable so it can be shortened (e.g. in tests)
+var Debounce = 300 * time.Millisecond
+
+var (
+	handlersMu sync.Mutex
+	handlers   []func(Change)
+)
+
+var stores = []string{StoreSymmetric, StoreHybrid, StorePgpKeys}
+
+var eventOfKind = map[string]string{
+	KindAdded:    ItemAddedEvent,
+	KindRemoved:  ItemRemovedEvent,
+	KindModified: ItemModifiedEvent,
+}
+
+type (
+	// Watcher follows the vault folder and reports the items that are
+	// added, removed or modified behind the app's back, like by a sync tool
+	Watcher struct {
+		mu      sync.Mutex
+		ctx     context.Context // wails app runtime context
+		fsw     *fsnotify.Watcher
+		root    string
+		pending map[string]*pendingChange
+		timer   *time.Timer
+		closed  bool
+		// generation counts the Watch calls and folder changes, a watch
+		// started for an older one is dropped
+		generation uint64
+		err        error
+	}
+
+	Change struct {
+		Kind  string `json:"kind"`
+		Store string `json:"store"`
+		// Name is the file name for sym_lockr and hyb_lockr items and the
+		// key name for pgp-keys
+		Name string `json:"name"`
+		Path string `json:"path"`
+	}
+
+	Status struct {
+		Watching bool   `json:"watching"`
+		Root     string `json:"root,omitempty"`
+		// Error is why the last vault folder could not be watched
+		Error string `json:"error,omitempty"`
+	}
+
+	pendingChange struct {
+		store, name string
+		// existed tells whether the item was there before its first event
+		existed bool
+	}
+)
+
+// NewWatcher creates a watcher that follows the vault folder, and the new
+// one whenever it is changed
+func NewWatcher(folder *filesystem.Folder) *Watcher {
+	w := &Watcher{}
+	filesystem.OnFolderChange(func(folderPath string) {
+		// hooks must not block, adding the watches of a big vault takes a
+		// while. The generation is taken here, in the order of the changes
+		go w.watchGeneration(w.nextGeneration(), folderPath)
+	})
+	if folderPath := folder.GetFolderPath(); folderPath != "" {
+		go w.watchGeneration(w.nextGeneration(), folderPath)
+	}
+	return w
+}
+
+func (w *Watcher) nextGeneration() uint64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.generation++
+	return w.generation
+}
+
+// watchGeneration watches root unless a newer folder change or Watch call
+// came first. A failure is kept for Status and emitted
+func (w *Watcher) watchGeneration(generation uint64, root string) {
+	w.mu.Lock()
+	if generation != w.generation {
+		w.mu.Unlock()
+		return
+	}
+	err := w.watchLocked(root)
+	w.mu.Unlock()
+
+	if err != nil {
+		w.emit(WatchFailedEvent, err.Error())
+	}
+}
+
+// SetContext sets the wails runtime context used for emitting changes
+func (w *Watcher) SetContext(ctx context.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.ctx = ctx
+}
+
+// OnChange registers fn to be called with every settled change, after the
+// event is emitted. Handlers run on the watcher goroutine so they must not
+// block
+func OnChange(fn func(Change)) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers = append(handlers, fn)
+}
+
+// Status tells whether a vault is being watched
+func (w *Watcher) Status() Status {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	status := Status{Watching: w.fsw != nil, Root: w.root}
+	if w.err != nil {
+		status.Error = w.err.Error()
+	}
+	return status
+}
+
+// Watch follows root instead of the previous vault. An empty root only
+// stops watching, the vault already watched is left as is
+func (w *Watcher) Watch(root string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.generation++
+	return w.watchLocked(root)
+}
+
+func (w *Watcher) watchLocked(root string) error {
+	if w.closed || w.fsw != nil && w.root == root {
+		return nil
+	}
+	w.stopLocked()
+	w.err = nil
+	if root == "" {
+		return nil
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		w.err = fmt.Errorf("failed to watch the vault: %w", err)
+		return w.err
+	}
+	// the root is watched so stores created later are picked up
+	if err := fsw.Add(root); err != nil {
+		fsw.Close()
+		w.err = fmt.Errorf("failed to watch %s: %w", root, err)
+		return w.err
+	}
+	for _, store := range stores {
+		addStore(fsw, filepath.Join(root, store), store)
+	}
+
+	w.fsw, w.root = fsw, root
+	w.pending = map[string]*pendingChange{}
+	go w.loop(fsw, root)
+	return nil
+}
+
+// Close stops watching for good, it is called when the app shuts down
+func (w *Watcher) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	w.stopLocked()
+}
+
+func (w *Watcher) stopLocked() {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if w.fsw != nil {
+		w.fsw.Close()
+		w.fsw, w.root, w.pending = nil, "", nil
+	}
+}
+
+// addStore watches a store folder, and every key folder of pgp-keys so a
+// replaced public key counts as a modified key. Missing folders are fine
+func addStore(fsw *fsnotify.Watcher, dir, store string) {
+	if fsw.Add(dir) != nil || store != StorePgpKeys {
+		return
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			fsw.Add(filepath.Join(dir, entry.Name()))
+		}
+	}
+}
+
+func (w *Watcher) loop(fsw *fsnotify.Watcher, root string) {
+	for {
+		select {
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return
+			}
+			w.handle(fsw, root, event)
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return
+			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				w.emit(ResyncEvent, nil)
+			}
+		}
+	}
+}
+
+// handle maps a filesystem event to the item it touches and records it
+// until the changes settle
+func (w *Watcher) handle(fsw *fsnotify.Watcher, root string, event fsnotify.Event) {
+	if event.Op == fsnotify.Chmod {
+		return
+	}
+	rel, err := filepath.Rel(root, event.Name)
+	if err != nil {
+		return
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+
+	created := event.Has(fsnotify.Create)
+	if len(parts) == 1 {
+		// a store folder appeared, like pgp-keys on the first key. What
+		// was written into it before the watch was added counts as added
+		if created && isStore(parts[0]) {
+			addStore(fsw, event.Name, parts[0])
+			entries, _ := os.ReadDir(event.Name)
+			for _, entry := range entries {
+				if tracked(parts[0], entry.Name()) {
+					w.record(root, parts[0], entry.Name(), false)
+				}
+			}
+		}
+		return
+	}
+	store, name := parts[0], parts[1]
+	if !isStore(store) || !tracked(store, name) {
+		return
+	}
+
+	switch {
+	case store == StorePgpKeys && len(parts) == 2 && created:
+		fsw.Add(event.Name)
+	case store == StorePgpKeys && len(parts) == 3:
+		// a file of a key folder changed, the key was there already
+		created = false
+	case len(parts) > 2:
+		return
+	}
+	w.record(root, store, name, !created)
+}
+
+func (w *Watcher) record(root, store, name string, existed bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.root != root {
+		return
+	}
+
+	key := store + "/" + name
+	if _, ok := w.pending[key]; !ok {
+		w.pending[key] = &pendingChange{store: store, name: name, existed: existed}
+	}
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	w.timer = time.AfterFunc(Debounce, func() { w.flush(root) })
+}
+
+// flush reports the settled changes. What is reported depends on whether
+// the item was there before and is there now, so a file written in several
+// steps is reported once and a temporary file not at all
+func (w *Watcher) flush(root string) {
+	w.mu.Lock()
+	if w.root != root {
+		w.mu.Unlock()
+		return
+	}
+	pending := w.pending
+	w.pending = map[string]*pendingChange{}
+	w.timer = nil
+	w.mu.Unlock()
+
+	keys := make([]string, 0, len(pending))
+	for key := range pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		p := pending[key]
+		path := filepath.Join(root, p.store, p.name)
+		info, err := os.Stat(path)
+		exists := err == nil
+		if exists && info.IsDir() && p.store != StorePgpKeys {
+			continue
+		}
+
+		change := Change{Store: p.store, Name: p.name, Path: path}
+		switch {
+		case !p.existed && exists:
+			change.Kind = KindAdded
+		case p.existed && !exists:
+			change.Kind = KindRemoved
+		case p.existed && exists:
+			change.Kind = KindModified
+		default:
+			continue
+		}
+		w.emit(eventOfKind[change.Kind], change)
+		notify(change)
+	}
+}
+
+func (w *Watcher) emit(event string, data any) {
+	w.mu.Lock()
+	ctx := w.ctx
+	w.mu.Unlock()
+	if ctx != nil {
+		runtime.EventsEmit(ctx, event, data)
+	}
+}
+
+func notify(change Change) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	for _, fn := range handlers {
+		fn(change)
+	}
+}
+
+func isStore(name string) bool {
+	for _, store := range stores {
+		if name == store {
+			return true
+		}
+	}
+	return false
+}
+
+// tracked reports whether name is an item the listings of store show
+func tracked(store, name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return false
+	}
+	if store == StoreHybrid {
+		return filepath.Ext(name) == ".asc"
+	}
+	return true
+}
@@ -0,0 +1,363 @@
+package jobs
+
+import (
+	"MindLockr/server/apperr"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+)
+
+// states of a job and of its items
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusDone      = "done"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+const (
+	// ItemEvent is emitted to the frontend when an item of a job starts and
+	// when it finishes, with an ItemUpdate
+	ItemEvent = "jobs:item"
+	// ProgressEvent is emitted with a Progress when a job starts, after
+	// every finished item and when the job ends
+	ProgressEvent = "jobs:progress"
+
+	// MaxWorkers bounds the worker pool of a single job
+	MaxWorkers = 16
+	// keepFinished is how many finished jobs are remembered for GetJob
+	keepFinished = 50
+)
+
+type (
+	// Jobs exposes the running and finished jobs to the frontend, the jobs
+	// themselves are started by the features that need them
+	Jobs struct{}
+
+	Item struct {
+		Index  int    `json:"index"`
+		Input  string `json:"input"`
+		Status string `json:"status"`
+		// Output is what the item produced, like the path of a written file
+		Output string      `json:"output,omitempty"`
+		Error  string      `json:"error,omitempty"`
+		Code   apperr.Code `json:"code,omitempty"`
+		// Detail is the result of the item, specific to the kind of job
+		Detail any `json:"detail,omitempty"`
+	}
+
+	Job struct {
+		ID         string     `json:"id"`
+		Kind       string     `json:"kind"`
+		Status     string     `json:"status"`
+		Total      int        `json:"total"`
+		Completed  int        `json:"completed"`
+		Failed     int        `json:"failed"`
+		Cancelled  int        `json:"cancelled"`
+		CreatedAt  time.Time  `json:"createdAt"`
+		FinishedAt *time.Time `json:"finishedAt,omitempty"`
+		Items      []Item     `json:"items"`
+	}
+
+	Progress struct {
+		JobID     string `json:"jobId"`
+		Kind      string `json:"kind"`
+		Status    string `json:"status"`
+		Total     int    `json:"total"`
+		Completed int    `json:"completed"`
+		Failed    int    `json:"failed"`
+		Cancelled int    `json:"cancelled"`
+	}
+
+	ItemUpdate struct {
+		JobID string `json:"jobId"`
+		Item  Item   `json:"item"`
+	}
+
+	// Work processes one input of a job. It should give up once ctx is
+	// done, an error returned after that counts as a cancelled item
+	Work func(ctx context.Context, input string) (output string, detail any, err error)
+
+	job struct {
+		info   Job
+		cancel context.CancelFunc
+		done   chan struct{}
+	}
+)
+
+var (
+	mu       sync.Mutex
+	registry = map[string]*job{}
+	appCtx   context.Context // wails app runtime context, nil in tests
+)
+
+// SetContext sets the wails runtime context used for emitting progress
+func (j *Jobs) SetContext(ctx context.Context) {
+	mu.Lock()
+	defer mu.Unlock()
+	appCtx = ctx
+}
+
+// GetJob returns the state of a job along with the result of every item
+func (j *Jobs) GetJob(id string) (Job, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	jb, ok := registry[id]
+	if !ok {
+		return Job{}, fmt.Errorf("%w: job %s", apperr.ErrNotFound, id)
+	}
+	return jb.snapshot(), nil
+}
+
+// ListJobs returns the running and the remembered finished jobs, newest
+// first
+func (j *Jobs) ListJobs() []Job {
+	mu.Lock()
+	defer mu.Unlock()
+	list := make([]Job, 0, len(registry))
+	for _, jb := range registry {
+		list = append(list, jb.snapshot())
+	}
+	sort.Slice(list, func(a, b int) bool {
+		return list[a].CreatedAt.After(list[b].CreatedAt)
+	})
+	return list
+}
+
+// CancelJob stops a job: queued items are not started and running items
+// are asked to give up. Cancelling a finished job does nothing
+func (j *Jobs) CancelJob(id string) error {
+	mu.Lock()
+	jb, ok := registry[id]
+	mu.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: job %s", apperr.ErrNotFound, id)
+	}
+	jb.cancel()
+	return nil
+}
+
+// ClearFinished forgets every finished job
+func (j *Jobs) ClearFinished() {
+	mu.Lock()
+	defer mu.Unlock()
+	for id, jb := range registry {
+		if jb.info.FinishedAt != nil {
+			delete(registry, id)
+		}
+	}
+}
+
+// Start runs work for every input on a pool of at most workers goroutines
+// and returns right away, the frontend follows the job through events and
+// GetJob
+func Start(kind string, inputs []string, workers int, work Work) (Job, error) {
+	if len(inputs) == 0 {
+		return Job{}, fmt.Errorf("%w: nothing to do", apperr.ErrInvalidInput)
+	}
+	workers = max(1, min(workers, MaxWorkers, len(inputs)))
+
+	id, err := newID()
+	if err != nil {
+		return Job{}, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	jb := &job{
+		info: Job{
+			ID:        id,
+			Kind:      kind,
+			Status:    StatusQueued,
+			Total:     len(inputs),
+			CreatedAt: time.Now(),
+			Items:     make([]Item, len(inputs)),
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	for i, input := range inputs {
+		jb.info.Items[i] = Item{Index: i, Input: input, Status: StatusQueued}
+	}
+
+	mu.Lock()
+	registry[id] = jb
+	snapshot := jb.snapshot()
+	mu.Unlock()
+
+	go jb.run(ctx, workers, work)
+	return snapshot, nil
+}
+
+// Wait blocks until the job is finished and returns its final state
+func Wait(id string) (Job, error) {
+	mu.Lock()
+	jb, ok := registry[id]
+	mu.Unlock()
+	if !ok {
+		return Job{}, fmt.Errorf("%w: job %s", apperr.ErrNotFound, id)
+	}
+	<-jb.done
+
+	mu.Lock()
+	defer mu.Unlock()
+	return jb.snapshot(), nil
+}
+
+func (jb *job) run(ctx context.Context, workers int, work Work) {
+	defer close(jb.done)
+	defer jb.cancel()
+
+	jb.update(func() { jb.info.Status = StatusRunning })
+	jb.emitProgress()
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				jb.process(ctx, i, work)
+			}
+		}()
+	}
+	for i := range jb.info.Items {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	jb.update(func() {
+		switch {
+		case ctx.Err() != nil:
+			jb.info.Status = StatusCancelled
+		case jb.info.Failed == jb.info.Total:
+			jb.info.Status = StatusFailed
+		default:
+			jb.info.Status = StatusDone
+		}
+		now := time.Now()
+		jb.info.FinishedAt = &now
+	})
+	jb.emitProgress()
+	prune()
+}
+
+// process runs one item, or marks it cancelled when the job was cancelled
+// before the item got its turn
+func (jb *job) process(ctx context.Context, i int, work Work) {
+	if ctx.Err() != nil {
+		jb.finish(i, "", nil, ctx.Err())
+		return
+	}
+
+	var item Item
+	jb.update(func() {
+		jb.info.Items[i].Status = StatusRunning
+		item = jb.info.Items[i]
+	})
+	jb.emit(ItemEvent, ItemUpdate{JobID: jb.info.ID, Item: item})
+
+	output, detail, err := work(ctx, item.Input)
+	if err != nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	jb.finish(i, output, detail, err)
+}
+
+func (jb *job) finish(i int, output string, detail any, err error) {
+	var item Item
+	jb.update(func() {
+		it := &jb.info.Items[i]
+		it.Output, it.Detail = output, detail
+		switch {
+		case errors.Is(err, context.Canceled):
+			it.Status = StatusCancelled
+			jb.info.Cancelled++
+		case err != nil:
+			it.Status = StatusFailed
+			it.Error = err.Error()
+			it.Code = apperr.CodeOf(err)
+			jb.info.Failed++
+		default:
+			it.Status = StatusDone
+			jb.info.Completed++
+		}
+		item = *it
+	})
+	jb.emit(ItemEvent, ItemUpdate{JobID: jb.info.ID, Item: item})
+	jb.emitProgress()
+}
+
+func (jb *job) update(fn func()) {
+	mu.Lock()
+	defer mu.Unlock()
+	fn()
+}
+
+func (jb *job) emitProgress() {
+	mu.Lock()
+	progress := Progress{
+		JobID:     jb.info.ID,
+		Kind:      jb.info.Kind,
+		Status:    jb.info.Status,
+		Total:     jb.info.Total,
+		Completed: jb.info.Completed,
+		Failed:    jb.info.Failed,
+		Cancelled: jb.info.Cancelled,
+	}
+	mu.Unlock()
+	jb.emit(ProgressEvent, progress)
+}
+
+func (jb *job) emit(event string, data any) {
+	mu.Lock()
+	ctx := appCtx
+	mu.Unlock()
+	if ctx != nil {
+		runtime.EventsEmit(ctx, event, data)
+	}
+}
+
+// snapshot copies the state of the job, mu must be held
+func (jb *job) snapshot() Job {
+	info := jb.info
+	info.Items = append([]Item(nil), jb.info.Items...)
+	return info
+}
+
+// prune forgets the oldest finished jobs beyond keepFinished
+func prune() {
+	mu.Lock()
+	defer mu.Unlock()
+	var finished []*job
+	for _, jb := range registry {
+		if jb.info.FinishedAt != nil {
+			finished = append(finished, jb)
+		}
+	}
+	if len(finished) <= keepFinished {
+		return
+	}
+	sort.Slice(finished, func(a, b int) bool {
+		return finished[a].info.FinishedAt.Before(*finished[b].info.FinishedAt)
+	})
+	for _, jb := range finished[:len(finished)-keepFinished] {
+		delete(registry, jb.info.ID)
+	}
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to create a job id: %s", err)
+	}
+	return hex.EncodeToString(b), nil
+}
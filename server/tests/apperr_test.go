@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"MindLockr/server/apperr"
+	hybdec "MindLockr/server/cryptography/decryption/hyb_dec"
+	symmetricdecryption "MindLockr/server/cryptography/decryption/symmetric_decryption"
+	"MindLockr/server/cryptography/passphrase"
+	pgptrust "MindLockr/server/cryptography/pgp/pgp_trust"
+	"MindLockr/server/cryptography/session"
+	"MindLockr/server/filesystem/en"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+func TestErrorCodes(t *testing.T) {
+	hd := &hybdec.HybDec{}
+
+	req := createRequest(pubKey, privKey, pgpMessage)
+	req.PrivKeyPassphrase = "not the passphrase"
+	_, err := hd.Decrypt(req)
+	if !errors.Is(err, apperr.ErrWrongPassphrase) {
+		t.Fatalf("wrong key passphrase gave %v", err)
+	}
+
+	req = createRequest(pubKey, privKey, "-----BEGIN PGP MESSAGE-----\n\nbm90IGEgbWVzc2FnZQ==\n-----END PGP MESSAGE-----")
+	_, err = hd.Decrypt(req)
+	if apperr.CodeOf(err) != apperr.CodeCorruptMessage {
+		t.Fatalf("corrupt message gave code %s: %v", apperr.CodeOf(err), err)
+	}
+
+	encHandle, _ := crypto.PGP().Encryption().Password([]byte("right")).New()
+	msg, _ := encHandle.Encrypt([]byte("secret"))
+	armored, _ := msg.Armor()
+	_, err = (&symmetricdecryption.Cryptography{}).DecryptAES(symmetricdecryption.DataToDecrypt{
+		EncryptedData: armored,
+		Passphrase:    "wrong",
+	})
+	if !errors.Is(err, apperr.ErrWrongPassphrase) {
+		t.Fatalf("wrong message passphrase gave %v", err)
+	}
+
+	folder := newTestVault(t)
+	folder.UpdateFolderPath("")
+	_, err = pgpfs.VaultKeys()
+	if !errors.Is(err, apperr.ErrNoVaultFolder) {
+		t.Fatalf("missing folder gave %v", err)
+	}
+
+	wrapped := fmt.Errorf("failed to load key: %w", apperr.ErrKeyNotFound)
+	envelope, ok := apperr.Format(wrapped).(apperr.Envelope)
+	if !ok || envelope.Code != apperr.CodeKeyNotFound || envelope.Message != wrapped.Error() {
+		t.Fatalf("unexpected envelope: %+v", envelope)
+	}
+	if apperr.CodeOf(errors.New("boom")) != apperr.CodeInternal {
+		t.Fatalf("uncoded errors must be internal")
+	}
+}
+
+func TestErrorCodesOfSessionAndStorage(t *testing.T) {
+	folder := newTestVault(t, "alice", "bob")
+
+	s := &session.Session{}
+	_, err := s.Unlock(session.UnlockRequest{KeyName: "alice", Passphrase: "not the passphrase"})
+	if !errors.Is(err, apperr.ErrWrongPassphrase) {
+		t.Fatalf("wrong session passphrase gave %v", err)
+	}
+	if apperr.CodeOf(session.ErrLocked) != apperr.CodeSessionLocked {
+		t.Fatalf("locked session has code %s", apperr.CodeOf(session.ErrLocked))
+	}
+
+	_, err = (&pgptrust.PgpTrust{}).CertifyKey(pgptrust.CertifyRequest{SignerKeyName: "alice", Passphrase: "not the passphrase", TargetKeyName: "bob"})
+	if !errors.Is(err, apperr.ErrWrongPassphrase) {
+		t.Fatalf("wrong certifier passphrase gave %v", err)
+	}
+
+	if apperr.CodeOf(passphrase.Enforce("password")) != apperr.CodeWeakPassphrase {
+		t.Fatalf("weak passphrase gave %v", passphrase.Enforce("password"))
+	}
+	if !errors.Is(passphrase.Enforce(""), apperr.ErrPassphraseRequired) {
+		t.Fatalf("empty passphrase gave %v", passphrase.Enforce(""))
+	}
+
+	folder.UpdateFolderPath("")
+	if err := (&en.KeyStore{}).SaveSymEn(folder.GetFolderPath(), "bank", "data"); !errors.Is(err, apperr.ErrNoVaultFolder) {
+		t.Fatalf("SaveSymEn without a vault gave %v", err)
+	}
+}
@@ -0,0 +1,153 @@
+package tests
+
+import (
+	pgpdec "MindLockr/server/cryptography/pgp/pgp_dec"
+	"MindLockr/server/filesystem/audit"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+func TestAuditLogChainAndCheckpoints(t *testing.T) {
+	folder := newTestVault(t, "auditor")
+
+	audit.Record(audit.OpDecrypt, "hyb_lockr/note.asc", "", nil)
+	audit.Record(audit.OpDelete, "sym_lockr/old.key", "", os.ErrNotExist)
+
+	log := audit.NewAudit(folder)
+	if _, err := log.SignCheckpoint(audit.SignRequest{KeyName: "auditor", Passphrase: testPassphrase}); err != nil {
+		t.Fatalf("SignCheckpoint failed: %v", err)
+	}
+
+	entries, err := log.ListEntries(audit.Filter{Outcome: audit.OutcomeFailure})
+	if err != nil {
+		t.Fatalf("ListEntries failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Operation != audit.OpDelete {
+		t.Fatalf("expected the failed deletion only, got %+v", entries)
+	}
+
+	result, err := log.VerifyLog()
+	if err != nil {
+		t.Fatalf("VerifyLog failed: %v", err)
+	}
+	if !result.Valid || result.Entries != 3 || result.LastSignedSeq != 3 {
+		t.Fatalf("unexpected verification result: %+v", result)
+	}
+
+	// rewrite history: pretend the deletion succeeded
+	logPath := filepath.Join(folder.GetFolderPath(), "audit", "audit.log")
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Replace(string(content), `"outcome":"failure"`, `"outcome":"success"`, 1)
+	if err := os.WriteFile(logPath, []byte(tampered), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err = log.VerifyLog()
+	if err != nil {
+		t.Fatalf("VerifyLog failed: %v", err)
+	}
+	if result.Valid {
+		t.Fatalf("tampered log should not verify")
+	}
+}
+
+func TestAuditCheckpointKeepsUnlockedKey(t *testing.T) {
+	folder := newTestVault(t, "auditor")
+	defer func(interval int) { audit.CheckpointInterval = interval }(audit.CheckpointInterval)
+	audit.CheckpointInterval = 1
+
+	audit.Record(audit.OpDecrypt, "hyb_lockr/note.asc", "", nil)
+
+	// unlocking signs the pending entry, the key handed back must still be
+	// usable afterwards
+	armored, err := (&pgpdec.PgpDec{}).DecryptPgpPrivKey(testPassphrase, filepath.Join(folder.GetFolderPath(), "pgp-keys", "auditor"))
+	if err != nil {
+		t.Fatalf("DecryptPgpPrivKey failed: %v", err)
+	}
+	key, err := crypto.NewKeyFromArmored(armored)
+	if err != nil {
+		t.Fatalf("unlocked key does not parse: %v", err)
+	}
+	signer, err := crypto.PGP().Sign().SigningKey(key).New()
+	if err == nil {
+		_, err = signer.Sign([]byte("still unlocked"), crypto.Bytes)
+	}
+	if err != nil {
+		t.Fatalf("unlocked key cannot sign: %v", err)
+	}
+
+	result, err := audit.NewAudit(folder).VerifyLog()
+	if err != nil {
+		t.Fatalf("VerifyLog failed: %v", err)
+	}
+	if !result.Valid || result.LastSignedSeq == 0 {
+		t.Fatalf("expected a valid signed log, got %+v", result)
+	}
+}
+
+func TestAuditWriteFailureIsReported(t *testing.T) {
+	folder := newTestVault(t)
+	auditPath := filepath.Join(folder.GetFolderPath(), "audit")
+
+	// a file in place of the audit folder makes the write fail
+	if err := os.WriteFile(auditPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	audit.Record(audit.OpDelete, "sym_lockr/old.key", "", nil)
+	if err := os.Remove(auditPath); err != nil {
+		t.Fatal(err)
+	}
+	audit.Record(audit.OpDecrypt, "hyb_lockr/note.asc", "", nil)
+
+	result, err := audit.NewAudit(folder).VerifyLog()
+	if err != nil {
+		t.Fatalf("VerifyLog failed: %v", err)
+	}
+	if result.Valid || result.Entries != 1 || len(result.Problems) != 1 || !strings.Contains(result.Problems[0], audit.OpDelete) {
+		t.Fatalf("expected the failed write to be reported, got %+v", result)
+	}
+}
+
+func TestAuditLogAppendsAfterOutsideChanges(t *testing.T) {
+	folder := newTestVault(t)
+	log := audit.NewAudit(folder)
+
+	audit.Record(audit.OpDecrypt, "hyb_lockr/a.asc", "", nil)
+	audit.Record(audit.OpDecrypt, "hyb_lockr/b.asc", "", nil)
+
+	// the log is cut back to its first entry, like a restore from a backup
+	logPath := filepath.Join(folder.GetFolderPath(), "audit", "audit.log")
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := content[:strings.IndexByte(string(content), '\n')+1]
+	if err := os.WriteFile(logPath, first, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	audit.Record(audit.OpDecrypt, "hyb_lockr/c.asc", "", nil)
+	entries, err := log.ListEntries(audit.Filter{})
+	if err != nil {
+		t.Fatalf("ListEntries failed: %v", err)
+	}
+	if len(entries) != 2 || entries[1].Seq != 2 || entries[1].PrevHash != entries[0].Hash {
+		t.Fatalf("the entry was not chained to the changed log: %+v", entries)
+	}
+
+	// a vault opened later starts its own chain
+	other := t.TempDir()
+	folder.UpdateFolderPath(other)
+	audit.Record(audit.OpDecrypt, "hyb_lockr/d.asc", "", nil)
+	entries, err = log.ListEntries(audit.Filter{})
+	if err != nil || len(entries) != 1 || entries[0].Seq != 1 {
+		t.Fatalf("expected a new chain in the other vault, got %+v: %v", entries, err)
+	}
+}
@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/batch"
+	hybdec "MindLockr/server/cryptography/decryption/hyb_dec"
+	unifieddec "MindLockr/server/cryptography/decryption/unified_dec"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"MindLockr/server/jobs"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+func TestBatchEncryptDecrypt(t *testing.T) {
+	newTestVault(t, "alice", "bob")
+	b := &batch.Batch{}
+
+	src := t.TempDir()
+	var files []string
+	contents := map[string]string{}
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("note-%d.txt", i)
+		path := filepath.Join(src, name)
+		contents[name] = fmt.Sprintf("secret number %d", i)
+		if err := os.WriteFile(path, []byte(contents[name]), 0600); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, path)
+	}
+	files = append(files, filepath.Join(src, "missing.txt"))
+
+	encrypted := t.TempDir()
+	job, err := b.StartBatch(batch.Request{
+		Operation:        batch.OpEncryptKey,
+		Files:            files,
+		OutputDir:        encrypted,
+		Recipients:       []string{"bob"},
+		SignerKeyName:    "alice",
+		SignerPassphrase: testPassphrase,
+		Workers:          3,
+	})
+	if err != nil {
+		t.Fatalf("failed to start the batch: %v", err)
+	}
+	job, _ = jobs.Wait(job.ID)
+	if job.Status != jobs.StatusDone || job.Completed != 5 || job.Failed != 1 {
+		t.Fatalf("expected 5 encrypted files and 1 failure, got %+v", job)
+	}
+	if last := job.Items[5]; last.Status != jobs.StatusFailed || last.Code != apperr.CodeNotFound {
+		t.Fatalf("expected the missing file to fail as not found, got %+v", last)
+	}
+
+	var outputs []string
+	for _, item := range job.Items[:5] {
+		outputs = append(outputs, item.Output)
+	}
+	decrypted := t.TempDir()
+	job, err = b.StartBatch(batch.Request{
+		Operation:  batch.OpDecrypt,
+		Files:      outputs,
+		OutputDir:  decrypted,
+		Passphrase: testPassphrase,
+	})
+	if err != nil {
+		t.Fatalf("failed to start the batch: %v", err)
+	}
+	job, _ = jobs.Wait(job.ID)
+	if job.Status != jobs.StatusDone || job.Completed != 5 {
+		t.Fatalf("expected every file to be decrypted, got %+v", job)
+	}
+	for _, item := range job.Items {
+		ret, ok := item.Detail.(hybdec.AutoReturnType)
+		if !ok || !ret.Valid || ret.Signer == nil || ret.Signer.KeyName != "alice" {
+			t.Fatalf("expected a valid signature by alice, got %+v", item.Detail)
+		}
+		name := filepath.Base(item.Output)
+		got, err := os.ReadFile(item.Output)
+		if err != nil || string(got) != contents[name] {
+			t.Fatalf("unexpected content of %s: %q, %v", name, got, err)
+		}
+	}
+
+	if _, err := b.StartBatch(batch.Request{
+		Operation: batch.OpEncryptPassphrase,
+		Files:     files,
+	}); !errors.Is(err, apperr.ErrPassphraseRequired) {
+		t.Fatalf("expected a passphrase to be required, got %v", err)
+	}
+	if _, err := b.StartBatch(batch.Request{Operation: "shred", Files: files}); !errors.Is(err, apperr.ErrInvalidInput) {
+		t.Fatalf("expected an unknown operation to be refused, got %v", err)
+	}
+}
+
+func TestBatchVerify(t *testing.T) {
+	newTestVault(t, "alice")
+	vaultKeys, err := pgpfs.VaultKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, release, err := vaultKeys[0].PrivateKey(testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	signHandle, _ := crypto.PGP().Sign().SigningKey(key).New()
+	signed, err := signHandle.Sign([]byte("signed release notes"), crypto.Armor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	signedPath := filepath.Join(dir, "notes.txt.asc")
+	plainPath := filepath.Join(dir, "plain.txt")
+	os.WriteFile(signedPath, signed, 0600)
+	os.WriteFile(plainPath, []byte("not signed"), 0600)
+
+	job, err := (&batch.Batch{}).StartBatch(batch.Request{
+		Operation: batch.OpVerify,
+		Files:     []string{signedPath, plainPath},
+	})
+	if err != nil {
+		t.Fatalf("failed to start the batch: %v", err)
+	}
+	job, _ = jobs.Wait(job.ID)
+
+	result, ok := job.Items[0].Detail.(unifieddec.Result)
+	if job.Items[0].Status != jobs.StatusDone || !ok || !result.Valid || result.Data != "" {
+		t.Fatalf("expected a valid signature without the data, got %+v", job.Items[0])
+	}
+	if job.Items[1].Status != jobs.StatusFailed {
+		t.Fatalf("expected the plain file to fail verification, got %+v", job.Items[1])
+	}
+}
+
+func TestJobCancel(t *testing.T) {
+	started := make(chan struct{}, 1)
+	job, err := jobs.Start("test", []string{"a", "b", "c", "d"}, 1, func(ctx context.Context, input string) (string, any, error) {
+		started <- struct{}{}
+		<-ctx.Done()
+		return "", nil, errors.New("interrupted")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	j := &jobs.Jobs{}
+	if err := j.CancelJob(job.ID); err != nil {
+		t.Fatalf("failed to cancel: %v", err)
+	}
+	job, _ = jobs.Wait(job.ID)
+	if job.Status != jobs.StatusCancelled || job.Cancelled != 4 || job.Failed != 0 {
+		t.Fatalf("expected every item to be cancelled, got %+v", job)
+	}
+
+	if _, err := j.GetJob("unknown"); !errors.Is(err, apperr.ErrNotFound) {
+		t.Fatalf("expected an unknown job to be not found, got %v", err)
+	}
+}
@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"MindLockr/server/apperr"
+	direnc "MindLockr/server/cryptography/encryption/dir_enc"
+	"MindLockr/server/cryptography/pgpformat"
+	"archive/tar"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDirectoryEncryption(t *testing.T) {
+	newTestVault(t, "alice", "bob")
+	de := &direnc.DirEnc{}
+
+	src := filepath.Join(t.TempDir(), "project")
+	modTime := time.Date(2022, 8, 9, 10, 11, 12, 0, time.UTC)
+	files := map[string][]byte{
+		"a.txt":     []byte("alpha"),
+		"sub/b.bin": {0x00, 0xff, 0x10},
+	}
+	for name, content := range files {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, content, 0640); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(p, modTime, modTime)
+	}
+	os.MkdirAll(filepath.Join(src, "empty"), 0750)
+	if err := os.Symlink("a.txt", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "project.tar.pgp")
+	summary, err := de.EncryptDirectory(direnc.EncryptRequest{
+		SourceDir:        src,
+		OutputPath:       archive,
+		Recipients:       []string{"bob"},
+		SignerKeyName:    "alice",
+		SignerPassphrase: testPassphrase,
+	})
+	if err != nil {
+		t.Fatalf("failed to encrypt directory: %v", err)
+	}
+	if summary.Files != 2 || summary.Dirs != 3 || len(summary.Skipped) != 1 || summary.Skipped[0] != "project/link" {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	if _, err := de.EncryptDirectory(direnc.EncryptRequest{
+		SourceDir:  src,
+		OutputPath: filepath.Join(src, "inside.pgp"),
+		Passphrase: testPassphrase,
+	}); !errors.Is(err, apperr.ErrInvalidInput) {
+		t.Fatalf("expected an archive inside the source to be refused, got %v", err)
+	}
+
+	dest := t.TempDir()
+	result, err := de.DecryptDirectory(direnc.DecryptRequest{
+		ArchivePath: archive,
+		Destination: dest,
+		Passphrase:  testPassphrase,
+	})
+	if err != nil {
+		t.Fatalf("failed to restore directory: %v", err)
+	}
+	if !result.Valid || result.Signer == nil || result.Signer.KeyName != "alice" || result.Recipient == nil || result.Recipient.KeyName != "bob" {
+		t.Fatalf("expected bob to decrypt an archive signed by alice, got %+v", result.AutoReturnType)
+	}
+	if result.Files != 2 || len(result.Entries) != 1 || result.Entries[0] != filepath.Join(dest, "project") {
+		t.Fatalf("unexpected restore result %+v", result)
+	}
+
+	for name, content := range files {
+		p := filepath.Join(dest, "project", filepath.FromSlash(name))
+		restored, err := os.ReadFile(p)
+		if err != nil || !bytes.Equal(restored, content) {
+			t.Fatalf("unexpected content of %s: %v", name, err)
+		}
+		info, _ := os.Stat(p)
+		if info.Mode().Perm() != 0640 || !info.ModTime().Equal(modTime) {
+			t.Fatalf("%s restored with mode %v and time %v", name, info.Mode(), info.ModTime())
+		}
+	}
+	if info, err := os.Stat(filepath.Join(dest, "project", "empty")); err != nil || !info.IsDir() || info.Mode().Perm() != 0750 {
+		t.Fatalf("expected the empty directory to be restored: %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dest, "project", "link")); !os.IsNotExist(err) {
+		t.Fatal("expected the symbolic link to be skipped")
+	}
+
+	// restoring again never replaces the restored tree
+	if _, err := de.DecryptDirectory(direnc.DecryptRequest{ArchivePath: archive, Destination: dest, Passphrase: testPassphrase}); !errors.Is(err, apperr.ErrInvalidInput) {
+		t.Fatalf("expected an existing tree to be kept, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dest); len(entries) != 1 {
+		t.Fatalf("expected the staging folder to be removed, found %d entries", len(entries))
+	}
+}
+
+func TestDirectoryRestoreRejectsUnsafeArchives(t *testing.T) {
+	newTestVault(t)
+	de := &direnc.DirEnc{}
+
+	type entry struct {
+		name, linkname string
+		typeflag       byte
+	}
+	makeArchive := func(entries ...entry) string {
+		t.Helper()
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, e := range entries {
+			header := &tar.Header{Name: e.name, Linkname: e.linkname, Typeflag: e.typeflag, Mode: 0644}
+			if e.typeflag == tar.TypeReg {
+				header.Size = 4
+			}
+			tw.WriteHeader(header)
+			if e.typeflag == tar.TypeReg {
+				tw.Write([]byte("evil"))
+			}
+		}
+		tw.Close()
+
+		encrypted, err := pgpformat.Encrypt(buf.Bytes(), pgpformat.Metadata{Binary: true}, pgpformat.EncryptParams{Password: []byte(testPassphrase)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := filepath.Join(t.TempDir(), "evil.pgp")
+		os.WriteFile(p, encrypted, 0600)
+		return p
+	}
+
+	parent := t.TempDir()
+	dest := filepath.Join(parent, "restore")
+	cases := map[string]string{
+		"parent reference": makeArchive(entry{name: "../escaped.txt", typeflag: tar.TypeReg}),
+		"absolute path":    makeArchive(entry{name: "/tmp/escaped.txt", typeflag: tar.TypeReg}),
+		"through symlink": makeArchive(
+			entry{name: "x/", typeflag: tar.TypeDir},
+			entry{name: "x/sub/", typeflag: tar.TypeDir},
+			entry{name: "x/up", linkname: "sub", typeflag: tar.TypeSymlink},
+			entry{name: "x/up/escaped.txt", typeflag: tar.TypeReg},
+		),
+	}
+	for name, archive := range cases {
+		_, err := de.DecryptDirectory(direnc.DecryptRequest{
+			ArchivePath:     archive,
+			Destination:     dest,
+			Passphrase:      testPassphrase,
+			RestoreSymlinks: true,
+		})
+		if !errors.Is(err, apperr.ErrCorruptMessage) {
+			t.Errorf("%s: expected the archive to be refused, got %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(parent, "escaped.txt")); !os.IsNotExist(err) {
+		t.Fatal("a file escaped the destination")
+	}
+	if entries, _ := os.ReadDir(dest); len(entries) != 0 {
+		t.Fatalf("expected nothing to be restored, found %d entries", len(entries))
+	}
+
+	// links leaving the tree are skipped even when links are restored
+	result, err := de.DecryptDirectory(direnc.DecryptRequest{
+		ArchivePath:     makeArchive(entry{name: "y/", typeflag: tar.TypeDir}, entry{name: "y/etc", linkname: "../../etc", typeflag: tar.TypeSymlink}),
+		Destination:     dest,
+		Passphrase:      testPassphrase,
+		RestoreSymlinks: true,
+	})
+	if err != nil || len(result.Skipped) != 1 || result.Skipped[0] != "y/etc" {
+		t.Fatalf("expected the escaping link to be skipped, got %+v: %v", result.Skipped, err)
+	}
+
+	// each link stays inside on its own, together they leave the tree,
+	// whichever of them comes first
+	for _, links := range [][]entry{
+		{{name: "a", linkname: ".", typeflag: tar.TypeSymlink}, {name: "b", linkname: "a/..", typeflag: tar.TypeSymlink}},
+		{{name: "b", linkname: "a/..", typeflag: tar.TypeSymlink}, {name: "a", linkname: ".", typeflag: tar.TypeSymlink}},
+	} {
+		chained := filepath.Join(parent, "chained")
+		result, err := de.DecryptDirectory(direnc.DecryptRequest{
+			ArchivePath:     makeArchive(links...),
+			Destination:     chained,
+			Passphrase:      testPassphrase,
+			RestoreSymlinks: true,
+		})
+		if err != nil || len(result.Skipped) != 1 || result.Skipped[0] != links[1].name {
+			t.Fatalf("expected %s to be skipped, got %+v: %v", links[1].name, result.Skipped, err)
+		}
+		if _, err := os.Lstat(filepath.Join(chained, links[0].name)); err != nil {
+			t.Fatalf("expected %s to be restored: %v", links[0].name, err)
+		}
+		os.RemoveAll(chained)
+	}
+
+	if _, err := de.DecryptDirectory(direnc.DecryptRequest{
+		ArchivePath: cases["parent reference"],
+		Destination: dest,
+		Passphrase:  "wrong passphrase",
+	}); !errors.Is(err, apperr.ErrWrongPassphrase) {
+		t.Fatalf("expected a wrong passphrase error, got %v", err)
+	}
+}
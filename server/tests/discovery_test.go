@@ -0,0 +1,158 @@
+package tests
+
+import (
+	pgpdiscovery "MindLockr/server/cryptography/pgp/pgp_discovery"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+// keyserverStandIn answers WKD requests for example.com and HKP requests on
+// keys.example.com with the keys it holds
+type keyserverStandIn struct {
+	mu   sync.Mutex
+	wkd  map[string]*crypto.Key // by WKD path
+	hkp  map[string]*crypto.Key // by lowercase search term
+	hits []string
+}
+
+func (s *keyserverStandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hits = append(s.hits, r.Host+r.URL.Path)
+
+	if r.URL.Path == "/pks/lookup" && r.Host == "keys.example.com" {
+		key, ok := s.hkp[strings.ToLower(r.URL.Query().Get("search"))]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		armored, _ := key.GetArmoredPublicKey()
+		w.Write([]byte(armored))
+		return
+	}
+
+	key, ok := s.wkd[r.Host+r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	binary, _ := key.GetPublicKey()
+	w.Write(binary)
+}
+
+func TestKeyDiscovery(t *testing.T) {
+	folder := newTestVault(t)
+
+	newKey := func(name, email string) *crypto.Key {
+		t.Helper()
+		key, err := crypto.PGP().KeyGeneration().AddUserId(name, email).New().GenerateKey()
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		return key
+	}
+	bob := newKey("Bob", "Bob.Smith@Example.com")
+	carol := newKey("Carol", "carol@example.net")
+
+	standIn := &keyserverStandIn{
+		wkd: map[string]*crypto.Key{
+			"openpgpkey.example.com/.well-known/openpgpkey/example.com/hu/" + pgpdiscovery.WKDHash("bob.smith"): bob,
+		},
+		hkp: map[string]*crypto.Key{
+			"carol@example.net":                            carol,
+			"0x" + strings.ToLower(bob.GetFingerprint()):   bob,
+			"0x" + strings.ToLower(carol.GetFingerprint()): carol,
+		},
+	}
+	srv := httptest.NewTLSServer(standIn)
+	t.Cleanup(srv.Close)
+
+	// every host resolves to the stand-in, its certificate is valid for
+	// example.com and *.example.com
+	transport := srv.Client().Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, network, srv.Listener.Addr().String())
+	}
+	client := &pgpdiscovery.Client{
+		HTTP:       &http.Client{Transport: transport},
+		Keyservers: []string{"https://keys.example.com"},
+	}
+	pd := pgpdiscovery.NewPgpDiscovery(client)
+
+	found, err := pd.LookupKey(pgpdiscovery.LookupRequest{Email: "Bob.Smith@Example.com"})
+	if err != nil {
+		t.Fatalf("WKD lookup failed: %v", err)
+	}
+	if len(found) != 1 || found[0].Source != pgpdiscovery.SourceWKD || found[0].Fingerprint != strings.ToUpper(bob.GetFingerprint()) {
+		t.Fatalf("unexpected WKD result: %+v", found)
+	}
+	if len(found[0].UserIDs) != 1 || found[0].UserIDs[0].Email != "Bob.Smith@Example.com" {
+		t.Fatalf("unexpected user IDs: %+v", found[0].UserIDs)
+	}
+
+	found, err = pd.LookupKey(pgpdiscovery.LookupRequest{Email: "carol@example.net"})
+	if err != nil {
+		t.Fatalf("HKP lookup failed: %v", err)
+	}
+	if len(found) != 1 || found[0].Source != pgpdiscovery.SourceHKP || found[0].Server != "keys.example.com" {
+		t.Fatalf("unexpected HKP result: %+v", found)
+	}
+
+	if _, err := pd.LookupKey(pgpdiscovery.LookupRequest{Email: "nobody@example.com"}); err == nil {
+		t.Fatalf("lookup of an unknown address succeeded")
+	}
+
+	if _, err := pd.ImportFoundKey(pgpdiscovery.ImportFoundRequest{KeyName: "mallory", Fingerprint: strings.Repeat("A", 40)}); err == nil {
+		t.Fatalf("imported a key that was never looked up")
+	}
+	for name, key := range map[string]*crypto.Key{"bob": bob, "carol": carol} {
+		if _, err := pd.ImportFoundKey(pgpdiscovery.ImportFoundRequest{KeyName: name, Fingerprint: key.GetFingerprint()}); err != nil {
+			t.Fatalf("failed to import %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(folder.GetFolderPath(), "pgp-keys", "bob", "public.asc")); err != nil {
+		t.Fatalf("bob's key was not stored: %v", err)
+	}
+
+	results, err := pd.RefreshKeys()
+	if err != nil || len(results) != 2 {
+		t.Fatalf("RefreshKeys returned %+v, %v", results, err)
+	}
+	for _, r := range results {
+		if r.Updated || r.Revoked || r.Error != "" {
+			t.Fatalf("unchanged key refreshed as %+v", r)
+		}
+	}
+
+	// bob revokes his key and publishes the revocation
+	if err := bob.GetEntity().Revoke(packet.KeyCompromised, "laptop stolen", nil); err != nil {
+		t.Fatalf("failed to revoke: %v", err)
+	}
+	results, err = pd.RefreshKeys()
+	if err != nil {
+		t.Fatalf("RefreshKeys failed: %v", err)
+	}
+	for _, r := range results {
+		revoked := r.KeyName == "bob"
+		if r.Updated != revoked || r.Revoked != revoked {
+			t.Fatalf("unexpected refresh result: %+v", r)
+		}
+	}
+}
+
+func TestWKDHash(t *testing.T) {
+	// the example of the Web Key Directory draft
+	if got := pgpdiscovery.WKDHash("joe.doe"); got != "iy9q119eutrkn8s1mk4r39qejnbu3n5q" {
+		t.Fatalf("wkd hash is %s", got)
+	}
+}
@@ -0,0 +1,38 @@
+package tests
+
+import (
+	pgpgen "MindLockr/server/cryptography/pgp/pgp_gen"
+	"MindLockr/server/filesystem"
+	"testing"
+)
+
+// strong enough for the default passphrase policy
+const testPassphrase = "glacier-mantis-velvet-bonfire"
+
+// newTestVault points the folder singleton and the config directory at fresh
+// temporary directories and generates an ECC key pair for every name
+func newTestVault(t *testing.T, keyNames ...string) *filesystem.Folder {
+	t.Helper()
+
+	configDir := t.TempDir()
+	filesystem.ConfigDir = func() (string, error) { return configDir, nil }
+
+	folder := filesystem.GetFolderInstance()
+	folder.UpdateFolderPath(t.TempDir())
+
+	gen := &pgpgen.PgpKeysGen{}
+	for _, name := range keyNames {
+		_, err := gen.GenStoreECC(pgpgen.RequestData{
+			Name:       name,
+			Email:      name + "@example.com",
+			Usage:      name,
+			Passphrase: testPassphrase,
+			Curve:      "curve25519",
+		})
+		if err != nil {
+			t.Fatalf("failed to generate %s key: %v", name, err)
+		}
+	}
+
+	return folder
+}
@@ -0,0 +1,128 @@
+package tests
+
+import (
+	symmetricencryption "MindLockr/server/cryptography/encryption/symmetric_encryption"
+	"MindLockr/server/filesystem/en"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSymmetricItemHistory(t *testing.T) {
+	folder := newTestVault(t)
+	ks := &en.KeyStore{}
+	if err := ks.SetHistoryPolicy(en.HistoryPolicy{MaxRevisions: 2}); err != nil {
+		t.Fatalf("SetHistoryPolicy failed: %v", err)
+	}
+	defer ks.SetHistoryPolicy(en.HistoryPolicy{MaxRevisions: en.DefaultMaxRevisions})
+
+	aes := &symmetricencryption.Cryptography{}
+	for _, secret := range []string{"user: alice\npin: 1111", "user: alice\npin: 2222", "user: alice\npin: 3333", "user: alice\npin: 4444"} {
+		armored, err := aes.EncryptAES(symmetricencryption.RequestData{Data: secret, Passphrase: testPassphrase, Algorithm: "AES-256"})
+		if err != nil {
+			t.Fatalf("EncryptAES failed: %v", err)
+		}
+		if err := ks.SaveSymEn(folder.GetFolderPath(), "bank", armored); err != nil {
+			t.Fatalf("SaveSymEn failed: %v", err)
+		}
+	}
+
+	item := en.RevisionRequest{Store: en.StoreSymmetric, FileName: "bank.key"}
+	revisions, err := ks.ListRevisions(item)
+	if err != nil {
+		t.Fatalf("ListRevisions failed: %v", err)
+	}
+	// the live version plus the two revisions the policy keeps
+	if len(revisions) != 3 || !revisions[0].Current {
+		t.Fatalf("unexpected revisions: %+v", revisions)
+	}
+
+	access := en.RevisionAccess{Passphrase: testPassphrase}
+	diff, err := ks.DiffRevisions(en.DiffRevisionsRequest{RevisionRequest: item, RevisionAccess: access, From: revisions[2].ID, To: en.CurrentRevision})
+	if err != nil {
+		t.Fatalf("DiffRevisions failed: %v", err)
+	}
+	want := []en.DiffLine{
+		{Op: en.DiffEqual, Text: "user: alice"},
+		{Op: en.DiffDelete, Text: "pin: 2222"},
+		{Op: en.DiffInsert, Text: "pin: 4444"},
+	}
+	if len(diff.Lines) != len(want) {
+		t.Fatalf("unexpected diff: %+v", diff)
+	}
+	for i := range want {
+		if diff.Lines[i] != want[i] {
+			t.Fatalf("unexpected diff: %+v", diff)
+		}
+	}
+
+	if err := ks.RestoreRevision(en.RestoreRevisionRequest{RevisionRequest: item, RevisionID: revisions[2].ID}); err != nil {
+		t.Fatalf("RestoreRevision failed: %v", err)
+	}
+	restored, err := ks.DecryptRevision(en.DecryptRevisionRequest{RevisionRequest: item, RevisionAccess: access, RevisionID: en.CurrentRevision})
+	if err != nil {
+		t.Fatalf("DecryptRevision failed: %v", err)
+	}
+	if restored.Data != "user: alice\npin: 2222" {
+		t.Fatalf("unexpected restored content: %q", restored.Data)
+	}
+
+	revisions, err = ks.ListRevisions(item)
+	if err != nil {
+		t.Fatalf("ListRevisions failed: %v", err)
+	}
+	latest, err := ks.DecryptRevision(en.DecryptRevisionRequest{RevisionRequest: item, RevisionAccess: access, RevisionID: revisions[1].ID})
+	if err != nil {
+		t.Fatalf("DecryptRevision failed: %v", err)
+	}
+	if latest.Data != "user: alice\npin: 4444" {
+		t.Fatalf("restoring did not keep the replaced version, got %q", latest.Data)
+	}
+}
+
+func TestHistoryRetentionAndDelete(t *testing.T) {
+	folder := newTestVault(t)
+	ks := &en.KeyStore{}
+	// 0 keeps every revision instead of dropping the history
+	if err := ks.SetHistoryPolicy(en.HistoryPolicy{MaxRevisions: 0}); err != nil {
+		t.Fatalf("SetHistoryPolicy failed: %v", err)
+	}
+	defer ks.SetHistoryPolicy(en.HistoryPolicy{MaxRevisions: en.DefaultMaxRevisions})
+
+	itemPath := filepath.Join(folder.GetFolderPath(), "sym_lockr", "notes.key")
+	var savedAt []time.Time
+	for _, content := range []string{"first", "second", "third"} {
+		if err := ks.SaveSymEn(folder.GetFolderPath(), "notes", content); err != nil {
+			t.Fatalf("SaveSymEn failed: %v", err)
+		}
+		info, err := os.Stat(itemPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		savedAt = append(savedAt, info.ModTime().UTC())
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	item := en.RevisionRequest{Store: en.StoreSymmetric, FileName: "notes.key"}
+	revisions, err := ks.ListRevisions(item)
+	if err != nil {
+		t.Fatalf("ListRevisions failed: %v", err)
+	}
+	if len(revisions) != 3 {
+		t.Fatalf("expected the live version and two revisions, got %+v", revisions)
+	}
+	// a revision is dated when its content was saved, not when it was replaced
+	for i, rev := range revisions {
+		if want := savedAt[len(savedAt)-1-i]; !rev.SavedAt.Equal(want) {
+			t.Fatalf("revision %d saved at %v, want %v", i, rev.SavedAt, want)
+		}
+	}
+
+	if err := ks.DeleteSymEn("notes.key"); err != nil {
+		t.Fatalf("DeleteSymEn failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(folder.GetFolderPath(), "history", "sym_lockr", "notes.key")); !os.IsNotExist(err) {
+		t.Fatalf("history of a deleted item must be removed, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package tests
+
+import (
+	hybdec "MindLockr/server/cryptography/decryption/hyb_dec"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+func TestDecryptAuto(t *testing.T) {
+	newTestVault(t, "alice", "bob")
+
+	keys, err := pgpfs.VaultKeys()
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("VaultKeys returned %d keys: %v", len(keys), err)
+	}
+	alice, bob := keys[0], keys[1]
+
+	signer, err := alice.UnlockPrivateKey(testPassphrase)
+	if err != nil {
+		t.Fatalf("failed to unlock alice: %v", err)
+	}
+	encHandle, err := crypto.PGP().Encryption().
+		Recipient(bob.PublicKey).
+		SigningKey(signer).
+		New()
+	if err != nil {
+		t.Fatalf("failed to create encryption handle: %v", err)
+	}
+	msg, err := encHandle.Encrypt([]byte("meet at noon"))
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	armored, _ := msg.ArmorBytes()
+
+	hd := &hybdec.HybDec{}
+	result, err := hd.DecryptAuto(hybdec.AutoRequest{
+		PgpMessage: string(armored),
+		Passphrase: testPassphrase,
+	})
+	if err != nil {
+		t.Fatalf("DecryptAuto failed: %v", err)
+	}
+	if result.Data != "meet at noon" || !result.Valid {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.Recipient.KeyName != "bob" {
+		t.Fatalf("decrypted with %q, want bob", result.Recipient.KeyName)
+	}
+	if result.Signer == nil || result.Signer.KeyName != "alice" {
+		t.Fatalf("unexpected signer: %+v", result.Signer)
+	}
+
+	if _, err := hd.DecryptAuto(hybdec.AutoRequest{
+		PgpMessage: string(armored),
+		Passphrase: "wrong",
+	}); err == nil {
+		t.Fatalf("DecryptAuto succeeded with a wrong passphrase")
+	}
+
+	stranger, err := crypto.PGP().KeyGeneration().AddUserId("Eve", "eve@example.com").New().GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	strangerPub, _ := stranger.ToPublic()
+	strangerHandle, _ := crypto.PGP().Encryption().Recipient(strangerPub).New()
+	strangerMsg, _ := strangerHandle.Encrypt([]byte("not for you"))
+	strangerArmored, _ := strangerMsg.ArmorBytes()
+
+	_, err = hd.DecryptAuto(hybdec.AutoRequest{PgpMessage: string(strangerArmored)})
+	if err == nil || !strings.Contains(err.Error(), "none of your keys") {
+		t.Fatalf("unexpected error for a foreign message: %v", err)
+	}
+}
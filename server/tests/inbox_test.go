@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"MindLockr/server/apperr"
+	unifieddec "MindLockr/server/cryptography/decryption/unified_dec"
+	"MindLockr/server/filesystem/inbox"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInbox(t *testing.T) {
+	folder := newTestVault(t, "alice")
+
+	settle := inbox.Settle
+	inbox.Settle = 50 * time.Millisecond
+	t.Cleanup(func() { inbox.Settle = settle })
+
+	in := inbox.NewInbox(folder)
+	t.Cleanup(in.Close)
+	in.ClearLog()
+
+	drop := t.TempDir()
+	if err := in.SetSettings(inbox.Settings{Dir: drop, Recipient: "nobody"}); !errors.Is(err, apperr.ErrKeyNotFound) {
+		t.Fatalf("expected an unknown recipient to be refused, got %v", err)
+	}
+	if err := in.SetSettings(inbox.Settings{Dir: folder.GetFolderPath(), Recipient: "alice"}); !errors.Is(err, apperr.ErrInvalidInput) {
+		t.Fatalf("expected an inbox inside the vault to be refused, got %v", err)
+	}
+	if err := in.SetSettings(inbox.Settings{Dir: drop, Recipient: "alice"}); err != nil {
+		t.Fatalf("failed to set up the inbox: %v", err)
+	}
+
+	// waiting before the start, and dropped after it
+	os.WriteFile(filepath.Join(drop, "waiting.txt"), []byte("already there"), 0600)
+	if _, err := in.Start(inbox.StartRequest{}); err != nil {
+		t.Fatalf("failed to start the inbox: %v", err)
+	}
+	os.WriteFile(filepath.Join(drop, "scan.pdf"), []byte("%PDF scanned"), 0600)
+
+	var entries []inbox.LogEntry
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if entries = in.GetLog(); len(entries) == 2 {
+			break
+		}
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected both files to be processed, got %+v", entries)
+	}
+
+	contents := map[string]string{"waiting.txt": "already there", "scan.pdf": "%PDF scanned"}
+	ud := &unifieddec.UnifiedDec{}
+	for _, entry := range entries {
+		if entry.Status != inbox.StatusEncrypted || entry.EncryptedTo != "alice" {
+			t.Fatalf("unexpected log entry %+v", entry)
+		}
+		if _, err := os.Stat(entry.Source); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be removed, got %v", entry.Source, err)
+		}
+		if filepath.Dir(entry.Output) != filepath.Join(folder.GetFolderPath(), "hyb_lockr") {
+			t.Fatalf("expected the message in hyb_lockr, got %s", entry.Output)
+		}
+
+		armored, _ := os.ReadFile(entry.Output)
+		result, err := ud.Decrypt(unifieddec.Request{Data: string(armored), Passphrase: testPassphrase})
+		if err != nil {
+			t.Fatalf("failed to decrypt %s: %v", entry.Output, err)
+		}
+		name := filepath.Base(entry.Source)
+		if result.Data != contents[name] || result.Literal == nil || result.Literal.Filename != name {
+			t.Fatalf("unexpected decryption of %s: %+v", name, result)
+		}
+	}
+	if status := in.Status(); !status.Running || status.Processed != 2 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	if err := in.SetSettings(inbox.Settings{Dir: drop, UsePassphrase: true}); err != nil {
+		t.Fatalf("failed to switch to a passphrase: %v", err)
+	}
+	if in.Status().Running {
+		t.Fatal("expected new settings to stop the inbox")
+	}
+	if _, err := in.Start(inbox.StartRequest{}); !errors.Is(err, apperr.ErrPassphraseRequired) {
+		t.Fatalf("expected a passphrase to be required, got %v", err)
+	}
+}
+
+func TestInboxKeepsWritesMadeWhileEncrypting(t *testing.T) {
+	folder := newTestVault(t, "alice")
+
+	settle := inbox.Settle
+	inbox.Settle = 50 * time.Millisecond
+	t.Cleanup(func() { inbox.Settle = settle })
+
+	in := inbox.NewInbox(folder)
+	t.Cleanup(in.Close)
+	in.ClearLog()
+
+	drop := t.TempDir()
+	if err := in.SetSettings(inbox.Settings{Dir: drop, Recipient: "alice"}); err != nil {
+		t.Fatalf("failed to set up the inbox: %v", err)
+	}
+	if _, err := in.Start(inbox.StartRequest{}); err != nil {
+		t.Fatalf("failed to start the inbox: %v", err)
+	}
+
+	// a slow writer that pauses about as long as the inbox waits, so some
+	// of its writes land while the file is being encrypted
+	path := filepath.Join(drop, "capture.log")
+	var written strings.Builder
+	appendChunk := func(chunk string) {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(chunk)
+		f.Close()
+		written.WriteString(chunk)
+	}
+	appendChunk(strings.Repeat("header\n", 1<<18))
+	for i := 0; i < 20; i++ {
+		time.Sleep(time.Duration(30+(i*7)%50) * time.Millisecond)
+		appendChunk(fmt.Sprintf("line %d\n", i))
+	}
+
+	var entries []inbox.LogEntry
+	var size int64
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline) && size < int64(written.Len()); time.Sleep(20 * time.Millisecond) {
+		entries, size = in.GetLog(), 0
+		for _, entry := range entries {
+			size += entry.Size
+		}
+	}
+	if size != int64(written.Len()) {
+		t.Fatalf("expected %d bytes to be encrypted, got %d", written.Len(), size)
+	}
+
+	// the log is newest first
+	var encrypted strings.Builder
+	ud := &unifieddec.UnifiedDec{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		armored, _ := os.ReadFile(entries[i].Output)
+		result, err := ud.Decrypt(unifieddec.Request{Data: string(armored), Passphrase: testPassphrase})
+		if err != nil {
+			t.Fatalf("failed to decrypt %s: %v", entries[i].Output, err)
+		}
+		encrypted.WriteString(result.Data)
+	}
+	if encrypted.String() != written.String() {
+		t.Fatalf("the encrypted messages do not add up to what was written")
+	}
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	pgpgen "MindLockr/server/cryptography/pgp/pgp_gen"
+	"MindLockr/server/filesystem/integrity"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyVaultDetectsTampering(t *testing.T) {
+	folder := newTestVault(t, "vault", "friend")
+
+	in := integrity.NewIntegrity(folder)
+	report, err := in.SealVault(integrity.SealRequest{KeyName: "vault", Passphrase: testPassphrase})
+	if err != nil {
+		t.Fatalf("SealVault failed: %v", err)
+	}
+	if !report.SignatureValid || len(report.Modified) != 0 || len(report.Added) != 0 {
+		t.Fatalf("freshly sealed vault should verify cleanly: %+v", report)
+	}
+
+	// swap the friend's public key with a different one
+	gen := &pgpgen.PgpKeysGen{}
+	other, err := gen.GenECC(pgpgen.RequestData{Name: "mallory", Email: "mallory@example.com", Passphrase: testPassphrase, Curve: "curve25519"})
+	if err != nil {
+		t.Fatalf("failed to generate replacement key: %v", err)
+	}
+	friendPub := filepath.Join(folder.GetFolderPath(), "pgp-keys", "friend", "public.asc")
+	if err := os.WriteFile(friendPub, []byte(other.PubKey), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(folder.GetFolderPath(), "pgp-keys", "friend", "private.asc")); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = in.VerifyVault()
+	if err != nil {
+		t.Fatalf("VerifyVault failed: %v", err)
+	}
+	if !report.SignatureValid {
+		t.Fatalf("manifest signature should still be valid: %v", report.Warnings)
+	}
+	if len(report.Modified) != 1 || report.Modified[0] != "pgp-keys/friend/public.asc" {
+		t.Fatalf("expected friend public key to be modified, got %v", report.Modified)
+	}
+	if len(report.Missing) != 1 || report.Missing[0] != "pgp-keys/friend/private.asc" {
+		t.Fatalf("expected friend private key to be missing, got %v", report.Missing)
+	}
+	if len(report.ReplacedKeys) != 1 || report.ReplacedKeys[0].KeyName != "friend" {
+		t.Fatalf("expected friend key to be reported as replaced, got %v", report.ReplacedKeys)
+	}
+
+	if warnings := integrity.Warnings(); len(warnings) != 1 {
+		t.Fatalf("expected one decryption warning, got %v", warnings)
+	}
+	// the verified manifest is cached, a changed signature is still noticed
+	sigPath := filepath.Join(folder.GetFolderPath(), "integrity", "manifest.json.sig")
+	if err := os.WriteFile(sigPath, []byte("not a signature"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if warnings := integrity.Warnings(); len(warnings) != 1 || !strings.Contains(warnings[0], "could not be verified") {
+		t.Fatalf("expected the broken signature to be reported, got %v", warnings)
+	}
+}
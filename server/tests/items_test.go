@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"MindLockr/server/cryptography/session"
+	"MindLockr/server/filesystem/items"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+func TestBIP39Validation(t *testing.T) {
+	valid := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	if err := items.ValidateBIP39(valid); err != nil {
+		t.Fatalf("valid mnemonic rejected: %v", err)
+	}
+
+	badChecksum := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"
+	if err := items.ValidateBIP39(badChecksum); err == nil {
+		t.Fatalf("mnemonic with a wrong checksum accepted")
+	}
+}
+
+func TestItemsRequireUnlockedSession(t *testing.T) {
+	folder := newTestVault(t, "vault")
+	vaultItems := items.NewItems(folder)
+	s := &session.Session{}
+
+	note := items.Item{Type: items.TypeNote, Title: "wifi", Note: &items.Note{Content: "hunter2"}}
+	if _, err := vaultItems.CreateItem(note); !errors.Is(err, session.ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+
+	if _, err := s.Unlock(session.UnlockRequest{KeyName: "vault", Passphrase: testPassphrase}); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	defer s.Lock()
+
+	created, err := vaultItems.CreateItem(items.Item{
+		Type:  items.TypeCard,
+		Title: "Visa",
+		Card:  &items.Card{Cardholder: "Jane Doe", Number: "4111 1111 1111 1111", ExpiryMonth: 1, ExpiryYear: 2020},
+	})
+	if err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+
+	if _, err := vaultItems.CreateItem(items.Item{
+		Type:   items.TypeWallet,
+		Title:  "cold storage",
+		Wallet: &items.Wallet{SeedPhrase: "abandon abandon abandon"},
+	}); err == nil {
+		t.Fatalf("wallet with an invalid seed phrase accepted")
+	}
+
+	got, err := vaultItems.GetItem(created.ID)
+	if err != nil {
+		t.Fatalf("GetItem failed: %v", err)
+	}
+	if got.Card == nil || got.Card.Number != "4111111111111111" {
+		t.Fatalf("unexpected card: %+v", got.Card)
+	}
+
+	// an item the session key cannot open is reported, not fatal
+	unreadable := filepath.Join(folder.GetFolderPath(), "items", strings.Repeat("ab", 16)+".asc")
+	if err := os.WriteFile(unreadable, []byte("not a message"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := vaultItems.ListItems(items.TypeCard)
+	if err != nil {
+		t.Fatalf("ListItems failed: %v", err)
+	}
+	if len(list.Items) != 1 || !list.Items[0].Expired || list.Items[0].Subtitle != "•••• 1111" {
+		t.Fatalf("unexpected summaries: %+v", list.Items)
+	}
+	if len(list.Unreadable) != 1 || list.Unreadable[0].ID != strings.Repeat("ab", 16) {
+		t.Fatalf("expected the unreadable item to be reported, got %+v", list.Unreadable)
+	}
+
+	s.Lock()
+	if _, err := vaultItems.GetItem(created.ID); !errors.Is(err, session.ErrLocked) {
+		t.Fatalf("expected ErrLocked after locking, got %v", err)
+	}
+}
+
+func TestSessionKeySurvivesLock(t *testing.T) {
+	newTestVault(t, "vault")
+	s := &session.Session{}
+	if _, err := s.Unlock(session.UnlockRequest{KeyName: "vault", Passphrase: testPassphrase}); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+
+	key, err := session.Current()
+	if err != nil {
+		t.Fatalf("Current failed: %v", err)
+	}
+	defer key.ClearPrivateParams()
+
+	// the session locking, like on its timer, must not clear a key in use
+	s.Lock()
+	signer, err := crypto.PGP().Sign().SigningKey(key).New()
+	if err == nil {
+		_, err = signer.Sign([]byte("still unlocked"), crypto.Bytes)
+	}
+	if err != nil {
+		t.Fatalf("key handed out before the lock cannot sign: %v", err)
+	}
+}
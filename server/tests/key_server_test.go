@@ -0,0 +1,104 @@
+package tests
+
+import (
+	pgpdiscovery "MindLockr/server/cryptography/pgp/pgp_discovery"
+	pgpserver "MindLockr/server/cryptography/pgp/pgp_server"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+func TestKeyServer(t *testing.T) {
+	newTestVault(t, "alice", "bob")
+
+	ks := pgpserver.NewKeyServer()
+	keys, err := ks.ListKeys()
+	if err != nil {
+		t.Fatalf("failed to list keys: %v", err)
+	}
+	if len(keys) != 2 || keys[0].Published || keys[1].Published {
+		t.Fatalf("expected two unpublished keys, got %+v", keys)
+	}
+	alice := keys[0]
+	if err := ks.SetPublished(pgpserver.PublishRequest{Fingerprint: alice.Fingerprint, Published: true}); err != nil {
+		t.Fatalf("failed to publish key: %v", err)
+	}
+
+	status, err := ks.Start(pgpserver.StartRequest{Address: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("failed to start key server: %v", err)
+	}
+	t.Cleanup(func() { ks.Stop() })
+	if !status.Running || status.Published != 1 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	base := "http://" + status.Address
+
+	client := pgpdiscovery.NewClient()
+	found, err := client.HKP(context.Background(), base, "alice@example.com")
+	if err != nil {
+		t.Fatalf("HKP lookup failed: %v", err)
+	}
+	if len(found) != 1 || !strings.EqualFold(found[0].Key.GetFingerprint(), alice.Fingerprint) {
+		t.Fatalf("expected alice's key, got %d keys", len(found))
+	}
+	if found, err := client.HKP(context.Background(), base, "0x"+alice.Fingerprint); err != nil || len(found) != 1 {
+		t.Fatalf("HKP lookup by fingerprint failed: %v", err)
+	}
+
+	get := func(method, path string) (int, []byte) {
+		t.Helper()
+		req, _ := http.NewRequest(method, base+path, nil)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return res.StatusCode, body
+	}
+
+	// bob is in the vault but not on the allow-list
+	if code, _ := get(http.MethodGet, "/pks/lookup?op=get&search=bob@example.com"); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unpublished key, got %d", code)
+	}
+
+	code, body := get(http.MethodGet, "/pks/lookup?op=index&options=mr&search=alice@example.com")
+	if code != http.StatusOK || !strings.Contains(string(body), "pub:"+alice.Fingerprint+":") {
+		t.Fatalf("unexpected index %d: %s", code, body)
+	}
+
+	code, body = get(http.MethodGet, "/.well-known/openpgpkey/example.com/hu/"+pgpdiscovery.WKDHash("alice"))
+	if code != http.StatusOK {
+		t.Fatalf("WKD lookup failed with %d", code)
+	}
+	wkdKey, err := crypto.NewKey(body)
+	if err != nil || wkdKey.IsPrivate() || !strings.EqualFold(wkdKey.GetFingerprint(), alice.Fingerprint) {
+		t.Fatalf("WKD returned an unexpected key: %v", err)
+	}
+	if code, _ := get(http.MethodGet, "/.well-known/openpgpkey/example.com/hu/"+pgpdiscovery.WKDHash("bob")); code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unpublished WKD key, got %d", code)
+	}
+
+	if code, _ := get(http.MethodPost, "/pks/add"); code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected the server to be read-only, got %d", code)
+	}
+
+	if err := ks.SetPublished(pgpserver.PublishRequest{Fingerprint: alice.Fingerprint, Published: false}); err != nil {
+		t.Fatalf("failed to unpublish key: %v", err)
+	}
+	if code, _ := get(http.MethodGet, "/pks/lookup?op=get&search=alice@example.com"); code != http.StatusNotFound {
+		t.Fatalf("expected an unpublished key to disappear, got %d", code)
+	}
+
+	if err := ks.Stop(); err != nil {
+		t.Fatalf("failed to stop key server: %v", err)
+	}
+	if ks.Status().Running {
+		t.Fatal("expected the key server to be stopped")
+	}
+}
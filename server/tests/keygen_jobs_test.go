@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"MindLockr/server/apperr"
+	pgpgen "MindLockr/server/cryptography/pgp/pgp_gen"
+	"MindLockr/server/jobs"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+func TestKeyGenerationJob(t *testing.T) {
+	folder := newTestVault(t, "alice")
+	gen := &pgpgen.PgpKeysGen{}
+
+	request := func(name string) pgpgen.RequestData {
+		return pgpgen.RequestData{
+			Name:       name,
+			Email:      name + "@example.com",
+			EnType:     "ECC",
+			Usage:      name,
+			Passphrase: testPassphrase,
+			Curve:      "curve25519",
+		}
+	}
+
+	job, err := gen.StartKeyGeneration([]pgpgen.RequestData{request("bob"), request("carol")})
+	if err != nil {
+		t.Fatalf("failed to start key generation: %v", err)
+	}
+	job, _ = jobs.Wait(job.ID)
+	if job.Status != jobs.StatusDone || job.Completed != 2 {
+		t.Fatalf("expected both keys to be generated, got %+v", job)
+	}
+	for _, item := range job.Items {
+		key, ok := item.Detail.(pgpgen.GeneratedKey)
+		if !ok || key.KeyName != item.Input || key.Fingerprint == "" || item.Output != key.Fingerprint {
+			t.Fatalf("unexpected result %+v", item)
+		}
+		if _, err := os.Stat(filepath.Join(folder.GetFolderPath(), "pgp-keys", item.Input, "private.asc")); err != nil {
+			t.Fatalf("expected %s to be stored: %v", item.Input, err)
+		}
+	}
+
+	for _, reqs := range [][]pgpgen.RequestData{
+		{request("alice")},
+		{request("dave"), request("dave")},
+		{{Usage: "erin", EnType: "RSA", Bits: 2048, Passphrase: testPassphrase}},
+	} {
+		if _, err := gen.StartKeyGeneration(reqs); !errors.Is(err, apperr.ErrInvalidInput) {
+			t.Fatalf("expected %+v to be refused, got %v", reqs, err)
+		}
+	}
+
+	rsa := request("frank")
+	rsa.EnType, rsa.Bits = "RSA", 4096
+	job, err = gen.StartKeyGeneration([]pgpgen.RequestData{rsa})
+	if err != nil {
+		t.Fatalf("failed to start key generation: %v", err)
+	}
+	if err := (&jobs.Jobs{}).CancelJob(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	job, _ = jobs.Wait(job.ID)
+	if job.Status != jobs.StatusCancelled || job.Items[0].Status != jobs.StatusCancelled {
+		t.Fatalf("expected the generation to be cancelled, got %+v", job)
+	}
+	if _, err := os.Stat(filepath.Join(folder.GetFolderPath(), "pgp-keys", "frank")); !os.IsNotExist(err) {
+		t.Fatalf("expected a cancelled key not to be stored, got %v", err)
+	}
+}
+
+func TestConcurrentKeyGenerationOfOneName(t *testing.T) {
+	folder := newTestVault(t)
+	gen := &pgpgen.PgpKeysGen{}
+	req := pgpgen.RequestData{
+		Name:       "dave",
+		Email:      "dave@example.com",
+		EnType:     "ECC",
+		Usage:      "dave",
+		Passphrase: testPassphrase,
+		Curve:      "curve25519",
+	}
+
+	var started []jobs.Job
+	for i := 0; i < 4; i++ {
+		job, err := gen.StartKeyGeneration([]pgpgen.RequestData{req})
+		if err == nil {
+			started = append(started, job)
+		} else if !errors.Is(err, apperr.ErrInvalidInput) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	var stored []string
+	for _, job := range started {
+		job, _ = jobs.Wait(job.ID)
+		if job.Status == jobs.StatusDone {
+			stored = append(stored, job.Items[0].Output)
+		} else if job.Status != jobs.StatusFailed {
+			t.Fatalf("unexpected job %+v", job)
+		}
+	}
+	if len(stored) != 1 {
+		t.Fatalf("expected exactly one dave key to be stored, got %d", len(stored))
+	}
+
+	pubKey, err := os.ReadFile(filepath.Join(folder.GetFolderPath(), "pgp-keys", "dave", "public.asc"))
+	if err != nil {
+		t.Fatalf("failed to read the stored key: %v", err)
+	}
+	key, err := crypto.NewKeyFromArmored(string(pubKey))
+	if err != nil || key.GetFingerprint() != stored[0] {
+		t.Fatalf("the stored key is not the one of the finished job: %v", err)
+	}
+}
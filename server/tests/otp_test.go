@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"MindLockr/server/cryptography/otp"
+	"MindLockr/server/cryptography/session"
+	"bytes"
+	"encoding/base64"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/makiuchi-d/gozxing"
+	"github.com/makiuchi-d/gozxing/qrcode"
+)
+
+// RFC 4226, appendix D
+func TestHOTPVectors(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	expected := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	for counter, want := range expected {
+		got, err := otp.HOTP(secret, uint64(counter), 6, otp.AlgorithmSHA1)
+		if err != nil {
+			t.Fatalf("HOTP(%d) failed: %v", counter, err)
+		}
+		if got != want {
+			t.Errorf("HOTP(%d) = %s, want %s", counter, got, want)
+		}
+	}
+}
+
+// RFC 6238, appendix B
+func TestTOTPVectors(t *testing.T) {
+	secrets := map[string][]byte{
+		otp.AlgorithmSHA1:   []byte("12345678901234567890"),
+		otp.AlgorithmSHA256: []byte("12345678901234567890123456789012"),
+		otp.AlgorithmSHA512: []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	vectors := []struct {
+		unix int64
+		want map[string]string
+	}{
+		{59, map[string]string{otp.AlgorithmSHA1: "94287082", otp.AlgorithmSHA256: "46119246", otp.AlgorithmSHA512: "90693936"}},
+		{1111111109, map[string]string{otp.AlgorithmSHA1: "07081804", otp.AlgorithmSHA256: "68084774", otp.AlgorithmSHA512: "25091201"}},
+		{1111111111, map[string]string{otp.AlgorithmSHA1: "14050471", otp.AlgorithmSHA256: "67062674", otp.AlgorithmSHA512: "99943326"}},
+		{1234567890, map[string]string{otp.AlgorithmSHA1: "89005924", otp.AlgorithmSHA256: "91819424", otp.AlgorithmSHA512: "93441116"}},
+		{2000000000, map[string]string{otp.AlgorithmSHA1: "69279037", otp.AlgorithmSHA256: "90698825", otp.AlgorithmSHA512: "38618901"}},
+		{20000000000, map[string]string{otp.AlgorithmSHA1: "65353130", otp.AlgorithmSHA256: "77737706", otp.AlgorithmSHA512: "47863826"}},
+	}
+
+	for _, v := range vectors {
+		for alg, want := range v.want {
+			got, remaining, err := otp.TOTP(secrets[alg], time.Unix(v.unix, 0), 30, 8, alg)
+			if err != nil {
+				t.Fatalf("TOTP(%d, %s) failed: %v", v.unix, alg, err)
+			}
+			if got != want {
+				t.Errorf("TOTP(%d, %s) = %s, want %s", v.unix, alg, got, want)
+			}
+			if wantRemaining := time.Duration(30-v.unix%30) * time.Second; remaining != wantRemaining {
+				t.Errorf("TOTP(%d) remaining = %v, want %v", v.unix, remaining, wantRemaining)
+			}
+		}
+	}
+}
+
+func TestParseOTPAuthURI(t *testing.T) {
+	acc, err := otp.ParseURI("otpauth://totp/ACME%20Co:john.doe@email.com?secret=HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ&issuer=ACME%20Co&algorithm=SHA256&digits=8&period=60")
+	if err != nil {
+		t.Fatalf("ParseURI failed: %v", err)
+	}
+	if acc.Issuer != "ACME Co" || acc.Label != "john.doe@email.com" || acc.Algorithm != otp.AlgorithmSHA256 || acc.Digits != 8 || acc.Period != 60 {
+		t.Fatalf("unexpected account: %+v", acc)
+	}
+
+	if _, err := otp.ParseURI("otpauth://hotp/Example:alice?secret=JBSWY3DPEHPK3PXP"); err == nil {
+		t.Fatalf("hotp URI without a counter accepted")
+	}
+	if _, err := otp.ParseURI("otpauth://totp/Example:alice?secret=not-base32!"); err == nil {
+		t.Fatalf("URI with an invalid secret accepted")
+	}
+}
+
+func TestAuthenticatorStoresAccounts(t *testing.T) {
+	folder := newTestVault(t, "vault")
+	s := &session.Session{}
+	if _, err := s.Unlock(session.UnlockRequest{KeyName: "vault", Passphrase: testPassphrase}); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	defer s.Lock()
+
+	auth := otp.NewAuthenticator(folder)
+
+	// "12345678901234567890" in base32, so codes match RFC 4226
+	hotpURI := "otpauth://hotp/Example:alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=0"
+	hotp, err := auth.AddAccount(otp.AddRequest{QRImage: qrImage(t, hotpURI)})
+	if err != nil {
+		t.Fatalf("AddAccount from QR image failed: %v", err)
+	}
+
+	for _, want := range []string{"755224", "287082"} {
+		code, err := auth.GetCode(hotp.ID)
+		if err != nil {
+			t.Fatalf("GetCode failed: %v", err)
+		}
+		if code.Code != want {
+			t.Fatalf("HOTP code = %s, want %s", code.Code, want)
+		}
+	}
+
+	totp, err := auth.AddAccount(otp.AddRequest{URI: "otpauth://totp/Example:bob?secret=JBSWY3DPEHPK3PXP&issuer=Example"})
+	if err != nil {
+		t.Fatalf("AddAccount failed: %v", err)
+	}
+	code, err := auth.GetCode(totp.ID)
+	if err != nil {
+		t.Fatalf("GetCode failed: %v", err)
+	}
+	if len(code.Code) != 6 || code.Remaining < 1 || code.Remaining > 30 {
+		t.Fatalf("unexpected TOTP code: %+v", code)
+	}
+
+	// an account the session key cannot open is reported, not fatal
+	damaged := strings.Repeat("ef", 16)
+	if err := os.WriteFile(filepath.Join(folder.GetFolderPath(), "otp", damaged+".asc"), []byte("not a message"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts, err := auth.ListAccounts()
+	if err != nil {
+		t.Fatalf("ListAccounts failed: %v", err)
+	}
+	if len(accounts.Accounts) != 2 {
+		t.Fatalf("expected 2 accounts, got %d", len(accounts.Accounts))
+	}
+	if len(accounts.Unreadable) != 1 || accounts.Unreadable[0].ID != damaged {
+		t.Fatalf("expected the damaged account to be reported, got %+v", accounts.Unreadable)
+	}
+
+	export, err := auth.ExportURI(hotp.ID)
+	if err != nil {
+		t.Fatalf("ExportURI failed: %v", err)
+	}
+	if !strings.Contains(export.URI, "counter=2") {
+		t.Fatalf("HOTP counter was not persisted: %s", export.URI)
+	}
+}
+
+func qrImage(t *testing.T, text string) string {
+	t.Helper()
+
+	matrix, err := qrcode.NewQRCodeWriter().Encode(text, gozxing.BarcodeFormat_QR_CODE, 256, 256, nil)
+	if err != nil {
+		t.Fatalf("failed to encode QR code: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, matrix); err != nil {
+		t.Fatalf("failed to encode PNG: %v", err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
@@ -0,0 +1,50 @@
+package tests
+
+import (
+	symmetricencryption "MindLockr/server/cryptography/encryption/symmetric_encryption"
+	"MindLockr/server/cryptography/passphrase"
+	"strings"
+	"testing"
+)
+
+func TestPassphraseGenerators(t *testing.T) {
+	gen := &passphrase.Passphrase{}
+
+	words, err := gen.GenerateWords(passphrase.WordPolicy{Words: 6, Separator: "-"})
+	if err != nil {
+		t.Fatalf("GenerateWords failed: %v", err)
+	}
+	if n := len(strings.Split(words.Passphrase, "-")); n != 6 {
+		t.Fatalf("expected 6 words, got %d in %q", n, words.Passphrase)
+	}
+	if words.Strength.Entropy < 70 {
+		t.Fatalf("six diceware words should be estimated above 70 bits, got %v", words.Strength.Entropy)
+	}
+
+	random, err := gen.GenerateRandom(passphrase.CharPolicy{Length: 20, Lowercase: true, Digits: true, Symbols: true})
+	if err != nil {
+		t.Fatalf("GenerateRandom failed: %v", err)
+	}
+	if len(random.Passphrase) != 20 || !strings.ContainsAny(random.Passphrase, "0123456789") {
+		t.Fatalf("unexpected random passphrase %q", random.Passphrase)
+	}
+}
+
+func TestWeakPassphrasesAreRejected(t *testing.T) {
+	newTestVault(t)
+
+	for _, weak := range []string{"", "password", "qwerty123", "aaaaaaaaaaaaaaaa", "abcdef2024"} {
+		strength := passphrase.Estimate(weak)
+		if strength.Score > 1 {
+			t.Errorf("%q should be weak, got %+v", weak, strength)
+		}
+	}
+
+	aes := &symmetricencryption.Cryptography{}
+	if _, err := aes.EncryptAES(symmetricencryption.RequestData{Data: "secret", Passphrase: "password"}); err == nil {
+		t.Fatalf("EncryptAES must reject a weak passphrase")
+	}
+	if _, err := aes.EncryptAES(symmetricencryption.RequestData{Data: "secret", Passphrase: testPassphrase}); err != nil {
+		t.Fatalf("EncryptAES failed with a strong passphrase: %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package tests
+
+import (
+	pgpmime "MindLockr/server/cryptography/pgp/pgp_mime"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+const testEmail = "From: Alice <alice@example.com>\n" +
+	"To: Bob <bob@example.com>\n" +
+	"Subject: Quarterly numbers\n" +
+	"MIME-Version: 1.0\n" +
+	"Content-Type: multipart/mixed; boundary=\"outer\"\n" +
+	"\n" +
+	"--outer\n" +
+	"Content-Type: text/plain; charset=utf-8\n" +
+	"\n" +
+	"Hi Bob, the numbers are attached. Grüße\n" +
+	"--outer\n" +
+	"Content-Type: text/csv; name=\"numbers.csv\"\n" +
+	"Content-Disposition: attachment; filename=\"numbers.csv\"\n" +
+	"Content-Transfer-Encoding: base64\n" +
+	"\n" +
+	"cTEsMTIKcTIsMTkK\n" +
+	"--outer--\n"
+
+func TestPgpMime(t *testing.T) {
+	newTestVault(t, "alice", "bob")
+	pm := &pgpmime.PgpMime{}
+
+	checkContent := func(t *testing.T, result pgpmime.EmailResult) {
+		t.Helper()
+		if result.Subject != "Quarterly numbers" || result.From != "Alice <alice@example.com>" {
+			t.Fatalf("unexpected headers %q from %q", result.Subject, result.From)
+		}
+		if result.Content.Text != "Hi Bob, the numbers are attached. Grüße" {
+			t.Fatalf("unexpected text %q", result.Content.Text)
+		}
+		if len(result.Content.Attachments) != 1 {
+			t.Fatalf("expected one attachment, got %d", len(result.Content.Attachments))
+		}
+		attachment := result.Content.Attachments[0]
+		data, _ := base64.StdEncoding.DecodeString(attachment.Data)
+		if attachment.Filename != "numbers.csv" || string(data) != "q1,12\nq2,19\n" {
+			t.Fatalf("unexpected attachment %s: %q", attachment.Filename, data)
+		}
+	}
+
+	t.Run("encrypt and sign", func(t *testing.T) {
+		encrypted, err := pm.EncryptEmail(pgpmime.EmailRequest{
+			Message:       testEmail,
+			Encrypt:       true,
+			Sign:          true,
+			Recipients:    []string{"bob"},
+			SignerKeyName: "alice",
+			Passphrase:    testPassphrase,
+			Autocrypt:     true,
+		})
+		if err != nil {
+			t.Fatalf("failed to encrypt email: %v", err)
+		}
+		if !strings.Contains(encrypted, "multipart/encrypted") || strings.Contains(encrypted, "numbers are attached") {
+			t.Fatalf("expected a PGP/MIME encrypted message:\n%s", encrypted)
+		}
+
+		result, err := pm.DecryptEmail(pgpmime.OpenEmailRequest{Message: encrypted, Passphrase: testPassphrase})
+		if err != nil {
+			t.Fatalf("failed to decrypt email: %v", err)
+		}
+		if !result.Encrypted || !result.Valid || result.Signer == nil || result.Signer.KeyName != "alice" {
+			t.Fatalf("expected a valid signature by alice, got %+v", result.Verification)
+		}
+		if result.Recipient == nil || result.Recipient.KeyName != "alice" && result.Recipient.KeyName != "bob" {
+			t.Fatalf("unexpected recipient %+v", result.Recipient)
+		}
+		checkContent(t, result)
+
+		if result.Autocrypt == nil || result.Autocrypt.Addr != "alice@example.com" || result.Autocrypt.KeyName != "alice" {
+			t.Fatalf("expected alice's Autocrypt header, got %+v", result.Autocrypt)
+		}
+	})
+
+	t.Run("sign only", func(t *testing.T) {
+		signed, err := pm.EncryptEmail(pgpmime.EmailRequest{
+			Message:       testEmail,
+			Sign:          true,
+			SignerKeyName: "alice",
+			Passphrase:    testPassphrase,
+		})
+		if err != nil {
+			t.Fatalf("failed to sign email: %v", err)
+		}
+		if !strings.Contains(signed, "micalg=pgp-sha") {
+			t.Fatalf("expected a multipart/signed message:\n%s", signed)
+		}
+
+		result, err := pm.DecryptEmail(pgpmime.OpenEmailRequest{Message: signed})
+		if err != nil {
+			t.Fatalf("failed to verify email: %v", err)
+		}
+		if result.Encrypted || !result.Valid {
+			t.Fatalf("expected a valid signature, got %+v", result.Verification)
+		}
+		checkContent(t, result)
+
+		tampered := strings.Replace(signed, "cTEsMTIK", "cTEsOTkK", 1)
+		result, err = pm.DecryptEmail(pgpmime.OpenEmailRequest{Message: tampered})
+		if err != nil {
+			t.Fatalf("expected a warning in the default policy, got %v", err)
+		}
+		if result.Valid || len(result.Warnings) == 0 {
+			t.Fatal("expected the tampered attachment to invalidate the signature")
+		}
+	})
+
+	t.Run("autocrypt", func(t *testing.T) {
+		value, err := pm.AutocryptHeader(pgpmime.AutocryptRequest{KeyName: "bob", PreferEncrypt: pgpmime.PreferEncryptMutual})
+		if err != nil {
+			t.Fatalf("failed to make Autocrypt header: %v", err)
+		}
+		if !strings.HasPrefix(value, "addr=bob@example.com; prefer-encrypt=mutual; keydata=") {
+			t.Fatalf("unexpected Autocrypt header %q", value)
+		}
+
+		message := "From: bob@example.com\nAutocrypt: " + value + "\nSubject: hi\n\nhello\n"
+		info, err := pm.ParseAutocrypt(pgpmime.AutocryptMessageRequest{Message: message})
+		if err != nil {
+			t.Fatalf("failed to parse Autocrypt header: %v", err)
+		}
+		if info.PreferEncrypt != pgpmime.PreferEncryptMutual || info.KeyName != "bob" || len(info.UserIDs) != 1 {
+			t.Fatalf("unexpected Autocrypt info %+v", info)
+		}
+
+		spoofed := strings.Replace(message, "From: bob@example.com", "From: mallory@example.com", 1)
+		if _, err := pm.ParseAutocrypt(pgpmime.AutocryptMessageRequest{Message: spoofed}); err == nil {
+			t.Fatal("expected an Autocrypt header for another address to be ignored")
+		}
+	})
+}
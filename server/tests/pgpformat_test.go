@@ -0,0 +1,146 @@
+package tests
+
+import (
+	unifieddec "MindLockr/server/cryptography/decryption/unified_dec"
+	symmetricencryption "MindLockr/server/cryptography/encryption/symmetric_encryption"
+	"MindLockr/server/cryptography/pgpformat"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLiteralMetadata(t *testing.T) {
+	newTestVault(t)
+
+	src := filepath.Join(t.TempDir(), "report.pdf")
+	content := []byte{0x25, 0x50, 0x44, 0x46, 0xff, 0xfe, 0x00, 0x01}
+	if err := os.WriteFile(src, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+	if err := os.Chtimes(src, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	armored, err := (&symmetricencryption.Cryptography{}).EncryptAES(symmetricencryption.RequestData{
+		FilePath:   src,
+		Passphrase: testPassphrase,
+	})
+	if err != nil {
+		t.Fatalf("failed to encrypt file: %v", err)
+	}
+
+	ud := &unifieddec.UnifiedDec{}
+	outDir := t.TempDir()
+	for _, want := range []string{"report.pdf", "report (1).pdf"} {
+		result, err := ud.DecryptToFile(unifieddec.FileRequest{
+			Request:   unifieddec.Request{Data: armored, Passphrase: testPassphrase},
+			OutputDir: outDir,
+		})
+		if err != nil {
+			t.Fatalf("failed to decrypt to file: %v", err)
+		}
+		literal := result.Literal
+		if literal == nil || literal.Filename != "report.pdf" || literal.ModTime != modTime.Unix() || !literal.Binary {
+			t.Fatalf("unexpected literal metadata %+v", literal)
+		}
+		if result.Path != filepath.Join(outDir, want) {
+			t.Fatalf("expected %s, got %s", want, result.Path)
+		}
+
+		written, err := os.ReadFile(result.Path)
+		if err != nil || !bytes.Equal(written, content) {
+			t.Fatalf("unexpected file content %v: %v", written, err)
+		}
+		info, _ := os.Stat(result.Path)
+		if !info.ModTime().Equal(modTime) {
+			t.Fatalf("expected modification time %v, got %v", modTime, info.ModTime())
+		}
+	}
+
+	// text without a file carries no metadata
+	armored, err = (&symmetricencryption.Cryptography{}).EncryptAES(symmetricencryption.RequestData{
+		Data:       "just text",
+		Passphrase: testPassphrase,
+	})
+	if err != nil {
+		t.Fatalf("failed to encrypt text: %v", err)
+	}
+	result, err := ud.Decrypt(unifieddec.Request{Data: armored, Passphrase: testPassphrase})
+	if err != nil || result.Data != "just text" || result.Literal != nil {
+		t.Fatalf("unexpected text result %q %+v: %v", result.Data, result.Literal, err)
+	}
+}
+
+func TestSafeFilename(t *testing.T) {
+	cases := map[string]string{
+		"notes.txt":          "notes.txt",
+		"../../etc/passwd":   "passwd",
+		`..\..\boot.ini`:     "boot.ini",
+		"/abs/path/key.asc":  "key.asc",
+		".bashrc":            "bashrc",
+		"..":                 pgpformat.DefaultFilename,
+		"":                   pgpformat.DefaultFilename,
+		"CON.txt":            pgpformat.DefaultFilename,
+		"a\x00b\nc":          "a_b_c",
+		"what?.txt":          "what_.txt",
+		"trailing dots...  ": "trailing dots",
+	}
+	for in, want := range cases {
+		if got := pgpformat.SafeFilename(in); got != want {
+			t.Errorf("SafeFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	long := strings.Repeat("a", 300) + ".txt"
+	if got := pgpformat.SafeFilename(long); len(got) != 255 || !strings.HasSuffix(got, ".txt") {
+		t.Errorf("expected a long name to be cut to 255 bytes keeping its extension, got %d bytes", len(got))
+	}
+}
+
+func TestArmorHeaders(t *testing.T) {
+	newTestVault(t)
+	pf := &pgpformat.PgpFormat{}
+	t.Cleanup(func() { pf.SetArmorSettings(pgpformat.ArmorSettings{}) })
+
+	encrypt := func() string {
+		t.Helper()
+		armored, err := (&symmetricencryption.Cryptography{}).EncryptAES(symmetricencryption.RequestData{
+			Data:       "hello",
+			Passphrase: testPassphrase,
+		})
+		if err != nil {
+			t.Fatalf("failed to encrypt: %v", err)
+		}
+		return armored
+	}
+
+	pf.SetArmorSettings(pgpformat.ArmorSettings{})
+	if armored := encrypt(); strings.Contains(armored, "Version:") || strings.Contains(armored, "Comment:") {
+		t.Fatalf("expected no armor headers by default:\n%s", armored)
+	}
+
+	err := pf.SetArmorSettings(pgpformat.ArmorSettings{
+		Comment: "sent from my vault",
+		Headers: map[string]string{"Charset": "UTF-8"},
+	})
+	if err != nil {
+		t.Fatalf("failed to set armor settings: %v", err)
+	}
+	armored := encrypt()
+	if !strings.Contains(armored, "Comment: sent from my vault") || !strings.Contains(armored, "Charset: UTF-8") {
+		t.Fatalf("expected the configured headers:\n%s", armored)
+	}
+
+	result, err := (&unifieddec.UnifiedDec{}).Decrypt(unifieddec.Request{Data: armored, Passphrase: testPassphrase})
+	if err != nil || result.Data != "hello" {
+		t.Fatalf("failed to decrypt a message with armor headers: %v", err)
+	}
+
+	if err := pf.SetArmorSettings(pgpformat.ArmorSettings{Comment: "two\nlines"}); err == nil {
+		t.Fatal("expected a multi-line comment to be rejected")
+	}
+}
@@ -0,0 +1,237 @@
+package tests
+
+import (
+	"MindLockr/server/apperr"
+	hybdec "MindLockr/server/cryptography/decryption/hyb_dec"
+	symmetricdecryption "MindLockr/server/cryptography/decryption/symmetric_decryption"
+	"MindLockr/server/cryptography/session"
+	"MindLockr/server/filesystem/items"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+func TestVerificationPolicy(t *testing.T) {
+	newTestVault(t, "alice", "bob")
+	hd := &hybdec.HybDec{}
+	t.Cleanup(func() {
+		hd.SetVerificationPolicy(hybdec.VerificationPolicy{Mode: hybdec.PolicyWarn})
+	})
+
+	keys, err := pgpfs.VaultKeys()
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("VaultKeys returned %d keys: %v", len(keys), err)
+	}
+	alice, bob := keys[0], keys[1]
+	signer, err := alice.UnlockPrivateKey(testPassphrase)
+	if err != nil {
+		t.Fatalf("failed to unlock alice: %v", err)
+	}
+
+	encrypt := func(signer *crypto.Key) string {
+		t.Helper()
+		builder := crypto.PGP().Encryption().Recipient(bob.PublicKey)
+		if signer != nil {
+			builder = builder.SigningKey(signer)
+		}
+		encHandle, err := builder.New()
+		if err != nil {
+			t.Fatalf("failed to create encryption handle: %v", err)
+		}
+		msg, err := encHandle.Encrypt([]byte("launch codes"))
+		if err != nil {
+			t.Fatalf("failed to encrypt: %v", err)
+		}
+		armored, _ := msg.Armor()
+		return armored
+	}
+	signed, unsigned := encrypt(signer), encrypt(nil)
+
+	decrypt := func(policy hybdec.VerificationPolicy, message string) (hybdec.AutoReturnType, error) {
+		t.Helper()
+		if err := hd.SetVerificationPolicy(policy); err != nil {
+			t.Fatalf("SetVerificationPolicy failed: %v", err)
+		}
+		return hd.DecryptAuto(hybdec.AutoRequest{PgpMessage: message, Passphrase: testPassphrase})
+	}
+
+	strict := hybdec.VerificationPolicy{Mode: hybdec.PolicyStrict}
+	if ret, err := decrypt(strict, signed); err != nil || ret.Data != "launch codes" {
+		t.Fatalf("strict mode rejected a valid signature: %v", err)
+	}
+	ret, err := decrypt(strict, unsigned)
+	if !errors.Is(err, apperr.ErrSignatureInvalid) || ret.Data != "" {
+		t.Fatalf("strict mode released an unsigned message: %q, %v", ret.Data, err)
+	}
+
+	strict.AllowedSigners = []string{bob.PublicKey.GetFingerprint()}
+	ret, err = decrypt(strict, signed)
+	if err == nil || ret.Data != "" || ret.Verification.Failure != hybdec.FailureNotAllowed {
+		t.Fatalf("strict mode accepted a signer outside the allow-list: %+v, %v", ret.Verification, err)
+	}
+
+	ret, err = decrypt(hybdec.VerificationPolicy{Mode: hybdec.PolicyWarn}, unsigned)
+	if err != nil || ret.Data != "launch codes" || ret.Valid || len(ret.Warnings) == 0 {
+		t.Fatalf("warn mode gave %+v, %v", ret, err)
+	}
+	if ret.Verification == nil || ret.Verification.Failure != hybdec.FailureNoSignature {
+		t.Fatalf("warn mode lost the verification result: %+v", ret.Verification)
+	}
+
+	ret, err = decrypt(hybdec.VerificationPolicy{Mode: hybdec.PolicyIgnore}, unsigned)
+	if err != nil || ret.Data != "launch codes" || len(ret.Warnings) != 0 {
+		t.Fatalf("ignore mode gave %+v, %v", ret, err)
+	}
+
+	if err := hd.SetVerificationPolicy(hybdec.VerificationPolicy{Mode: "lenient"}); !errors.Is(err, apperr.ErrInvalidInput) {
+		t.Fatalf("unknown mode accepted: %v", err)
+	}
+}
+
+func TestVerificationPolicyOfPasswordMessages(t *testing.T) {
+	newTestVault(t, "alice", "bob")
+	hd := &hybdec.HybDec{}
+	t.Cleanup(func() {
+		hd.SetVerificationPolicy(hybdec.VerificationPolicy{Mode: hybdec.PolicyWarn})
+	})
+
+	keys, err := pgpfs.VaultKeys()
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("VaultKeys returned %d keys: %v", len(keys), err)
+	}
+	bob := keys[1]
+	signer, err := keys[0].UnlockPrivateKey(testPassphrase)
+	if err != nil {
+		t.Fatalf("failed to unlock alice: %v", err)
+	}
+
+	encrypt := func(signer *crypto.Key) string {
+		t.Helper()
+		builder := crypto.PGP().Encryption().Password([]byte(testPassphrase))
+		if signer != nil {
+			builder = builder.SigningKey(signer)
+		}
+		encHandle, err := builder.New()
+		if err != nil {
+			t.Fatalf("failed to create encryption handle: %v", err)
+		}
+		msg, err := encHandle.Encrypt([]byte("wifi: hunter2"))
+		if err != nil {
+			t.Fatalf("failed to encrypt: %v", err)
+		}
+		armored, _ := msg.Armor()
+		return armored
+	}
+	decrypt := func(policy hybdec.VerificationPolicy, message string) (hybdec.ReturnType, error) {
+		t.Helper()
+		if err := hd.SetVerificationPolicy(policy); err != nil {
+			t.Fatalf("SetVerificationPolicy failed: %v", err)
+		}
+		return (&symmetricdecryption.Cryptography{}).DecryptAES(symmetricdecryption.DataToDecrypt{
+			EncryptedData: message,
+			Passphrase:    testPassphrase,
+		})
+	}
+
+	// knowing the password is enough in warn mode without allowed signers
+	unsigned := encrypt(nil)
+	ret, err := decrypt(hybdec.VerificationPolicy{Mode: hybdec.PolicyWarn}, unsigned)
+	if err != nil || ret.Data != "wifi: hunter2" || ret.Valid || len(ret.Warnings) != 0 {
+		t.Fatalf("warn mode flagged an unsigned password message: %+v, %v", ret, err)
+	}
+
+	// but not in strict mode or with an allow-list
+	onlyBob := []string{bob.PublicKey.GetFingerprint()}
+	ret, err = decrypt(hybdec.VerificationPolicy{Mode: hybdec.PolicyStrict}, unsigned)
+	if !errors.Is(err, apperr.ErrSignatureInvalid) || ret.Data != "" {
+		t.Fatalf("strict mode returned an unsigned password message: %+v, %v", ret, err)
+	}
+	ret, err = decrypt(hybdec.VerificationPolicy{Mode: hybdec.PolicyStrict, AllowedSigners: onlyBob}, unsigned)
+	if !errors.Is(err, apperr.ErrSignatureInvalid) || ret.Data != "" {
+		t.Fatalf("strict mode with an allow-list returned an unsigned password message: %+v, %v", ret, err)
+	}
+	ret, err = decrypt(hybdec.VerificationPolicy{Mode: hybdec.PolicyWarn, AllowedSigners: onlyBob}, unsigned)
+	if err != nil || ret.Data != "wifi: hunter2" || len(ret.Warnings) == 0 {
+		t.Fatalf("warn mode with an allow-list dropped the warning: %+v, %v", ret, err)
+	}
+
+	// a signature that is there still has to pass the policy
+	signed := encrypt(signer)
+	ret, err = decrypt(hybdec.VerificationPolicy{Mode: hybdec.PolicyStrict, AllowedSigners: onlyBob}, signed)
+	if !errors.Is(err, apperr.ErrSignatureInvalid) || ret.Data != "" {
+		t.Fatalf("strict mode accepted a signer outside the allow-list: %+v, %v", ret, err)
+	}
+	ret, err = decrypt(hybdec.VerificationPolicy{Mode: hybdec.PolicyWarn, AllowedSigners: onlyBob}, signed)
+	if err != nil || ret.Data != "wifi: hunter2" || len(ret.Warnings) == 0 {
+		t.Fatalf("warn mode dropped the warning: %+v, %v", ret, err)
+	}
+}
+
+func TestVerificationPolicyOfSealedItems(t *testing.T) {
+	folder := newTestVault(t, "vault")
+	hd := &hybdec.HybDec{}
+	t.Cleanup(func() {
+		hd.SetVerificationPolicy(hybdec.VerificationPolicy{Mode: hybdec.PolicyWarn})
+	})
+
+	s := &session.Session{}
+	if _, err := s.Unlock(session.UnlockRequest{KeyName: "vault", Passphrase: testPassphrase}); err != nil {
+		t.Fatalf("Unlock failed: %v", err)
+	}
+	defer s.Lock()
+
+	// anyone with the public key can write an item, only the vault signs it
+	keys, err := pgpfs.VaultKeys()
+	if err != nil || len(keys) != 1 {
+		t.Fatalf("VaultKeys returned %d keys: %v", len(keys), err)
+	}
+	id := strings.Repeat("cd", 16)
+	encHandle, err := crypto.PGP().Encryption().Recipient(keys[0].PublicKey).New()
+	if err != nil {
+		t.Fatalf("failed to create encryption handle: %v", err)
+	}
+	msg, err := encHandle.Encrypt([]byte(`{"id":"` + id + `","type":"note","title":"planted","note":{"content":"x"},"warnings":["stored"]}`))
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	armored, _ := msg.ArmorBytes()
+	if err := os.MkdirAll(filepath.Join(folder.GetFolderPath(), "items"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(folder.GetFolderPath(), "items", id+".asc"), armored, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	vaultItems := items.NewItems(folder)
+	setMode := func(mode string) {
+		t.Helper()
+		if err := hd.SetVerificationPolicy(hybdec.VerificationPolicy{Mode: mode}); err != nil {
+			t.Fatalf("SetVerificationPolicy failed: %v", err)
+		}
+	}
+
+	setMode(hybdec.PolicyStrict)
+	if _, err := vaultItems.GetItem(id); !errors.Is(err, apperr.ErrSignatureInvalid) {
+		t.Fatalf("strict mode opened an unsigned item: %v", err)
+	}
+	list, err := vaultItems.ListItems("")
+	if err != nil || len(list.Items) != 0 || len(list.Unreadable) != 1 {
+		t.Fatalf("strict mode listed an unsigned item: %+v, %v", list, err)
+	}
+
+	setMode(hybdec.PolicyWarn)
+	item, err := vaultItems.GetItem(id)
+	if err != nil || item.Title != "planted" || len(item.Warnings) != 1 || item.Warnings[0] == "stored" {
+		t.Fatalf("warn mode gave %+v, %v", item, err)
+	}
+
+	setMode(hybdec.PolicyIgnore)
+	if item, err := vaultItems.GetItem(id); err != nil || len(item.Warnings) != 0 {
+		t.Fatalf("ignore mode gave %+v, %v", item, err)
+	}
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"MindLockr/server/apperr"
+	"MindLockr/server/cryptography/shamir"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestShamirSplitCombine(t *testing.T) {
+	secret := []byte("correct horse battery staple")
+
+	shares, err := shamir.Split(secret, 5, 3)
+	if err != nil {
+		t.Fatalf("Split failed: %v", err)
+	}
+
+	combined, err := shamir.Combine([][]byte{shares[4], shares[0], shares[2]})
+	if err != nil {
+		t.Fatalf("Combine failed: %v", err)
+	}
+	if !bytes.Equal(combined, secret) {
+		t.Fatalf("expected %q, got %q", secret, combined)
+	}
+
+	combined, err = shamir.Combine([][]byte{shares[1], shares[3]})
+	if err == nil && bytes.Equal(combined, secret) {
+		t.Fatalf("two shares must not be enough for a threshold of three")
+	}
+}
+
+func TestSplitAndRestorePrivateKey(t *testing.T) {
+	newTestVault(t, "owner", "trustee")
+
+	ss := &shamir.SecretSharing{}
+	shares, err := ss.SplitPrivateKey(shamir.SplitRequest{
+		KeyName:    "owner",
+		Passphrase: testPassphrase,
+		Shares:     3,
+		Threshold:  2,
+		Trustees:   []string{"trustee"},
+	})
+	if err != nil {
+		t.Fatalf("SplitPrivateKey failed: %v", err)
+	}
+
+	fingerprint, err := ss.CombinePrivateKey(shamir.CombineRequest{
+		Shares:        []string{shares[0].Armor, shares[2].Armor},
+		Unlocks:       []shamir.Unlock{{KeyName: "trustee", Passphrase: testPassphrase}},
+		KeyName:       "owner-restored",
+		NewPassphrase: testPassphrase + "-restored",
+	})
+	if err != nil {
+		t.Fatalf("CombinePrivateKey failed: %v", err)
+	}
+	if fingerprint == "" {
+		t.Fatalf("expected the fingerprint of the restored key")
+	}
+
+	_, err = ss.CombinePrivateKey(shamir.CombineRequest{
+		Shares:        []string{shares[0].Armor, shares[1].Armor},
+		KeyName:       "owner-restored-again",
+		NewPassphrase: testPassphrase + "-restored",
+	})
+	if err == nil {
+		t.Fatalf("encrypted share without a matching key must fail")
+	}
+
+	// the restored key must get a new folder of its own in pgp-keys/
+	for _, keyName := range []string{"owner", "../escaped", "."} {
+		_, err = ss.CombinePrivateKey(shamir.CombineRequest{
+			Shares:        []string{shares[0].Armor, shares[2].Armor},
+			Unlocks:       []shamir.Unlock{{KeyName: "trustee", Passphrase: testPassphrase}},
+			KeyName:       keyName,
+			NewPassphrase: testPassphrase + "-restored",
+		})
+		if !errors.Is(err, apperr.ErrInvalidInput) {
+			t.Fatalf("restoring to %q gave %v", keyName, err)
+		}
+	}
+}
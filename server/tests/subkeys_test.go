@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"MindLockr/server/apperr"
+	pgpedit "MindLockr/server/cryptography/pgp/pgp_edit"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+func TestSubkeyManagement(t *testing.T) {
+	folder := newTestVault(t, "alice")
+	edit := &pgpedit.PgpEdit{}
+	retrieve := pgpfs.NewPgpRetrieve(folder)
+	keyDir := filepath.Join(folder.GetFolderPath(), "pgp-keys", "alice")
+
+	original, err := retrieve.RetrieveSubkeys(keyDir)
+	if err != nil {
+		t.Fatalf("RetrieveSubkeys failed: %v", err)
+	}
+	if len(original) != 1 || original[0].State != pgpfs.SubkeyValid {
+		t.Fatalf("unexpected subkeys of a new key: %+v", original)
+	}
+
+	for _, usage := range []string{pgpfs.UsageEncrypt, pgpfs.UsageSign, pgpfs.UsageAuthenticate} {
+		if _, err := edit.AddSubkey(pgpedit.AddSubkeyRequest{KeyName: "alice", Passphrase: testPassphrase, Usage: usage, ExpiryDays: 365}); err != nil {
+			t.Fatalf("AddSubkey(%s) failed: %v", usage, err)
+		}
+	}
+
+	subkeys, err := edit.RevokeSubkey(pgpedit.RevokeSubkeyRequest{
+		KeyName:     "alice",
+		Passphrase:  testPassphrase,
+		Fingerprint: original[0].Fingerprint,
+		Reason:      "compromised",
+		Description: "laptop stolen",
+	})
+	if err != nil {
+		t.Fatalf("RevokeSubkey failed: %v", err)
+	}
+	if len(subkeys) != 4 || subkeys[0].State != pgpfs.SubkeyRevoked {
+		t.Fatalf("unexpected subkeys after revocation: %+v", subkeys)
+	}
+	if subkeys[3].Usages[0] != pgpfs.UsageAuthenticate {
+		t.Fatalf("unexpected usages of the authentication subkey: %v", subkeys[3].Usages)
+	}
+
+	subkeys, err = edit.SetSubkeyExpiry(pgpedit.SubkeyExpiryRequest{KeyName: "alice", Passphrase: testPassphrase, Fingerprint: subkeys[1].Fingerprint})
+	if err != nil {
+		t.Fatalf("SetSubkeyExpiry failed: %v", err)
+	}
+	if !subkeys[1].ExpiresAt.IsZero() || subkeys[1].State != pgpfs.SubkeyValid {
+		t.Fatalf("expiry was not removed: %+v", subkeys[1])
+	}
+
+	// a lifetime beyond 32 bit seconds must not wrap around to a near expiry
+	if _, err := edit.AddSubkey(pgpedit.AddSubkeyRequest{KeyName: "alice", Passphrase: testPassphrase, Usage: pgpfs.UsageSign, ExpiryDays: 50000}); !errors.Is(err, apperr.ErrInvalidInput) {
+		t.Fatalf("expected an out of range expiry to be rejected, got %v", err)
+	}
+	subkeys, err = edit.SetSubkeyExpiry(pgpedit.SubkeyExpiryRequest{KeyName: "alice", Passphrase: testPassphrase, Fingerprint: subkeys[1].Fingerprint, ExpiryDays: pgpedit.MaxExpiryDays})
+	if err != nil {
+		t.Fatalf("SetSubkeyExpiry failed: %v", err)
+	}
+	if want := time.Now().AddDate(0, 0, pgpedit.MaxExpiryDays); subkeys[1].ExpiresAt.Before(want.Add(-time.Hour)) {
+		t.Fatalf("expected an expiry around %v, got %v", want, subkeys[1].ExpiresAt)
+	}
+
+	// the re-exported public key encrypts to the new subkey only
+	pubKeyArmor, err := edit.ExportPublicKey("alice")
+	if err != nil {
+		t.Fatalf("ExportPublicKey failed: %v", err)
+	}
+	pubKey, err := crypto.NewKeyFromArmored(pubKeyArmor)
+	if err != nil {
+		t.Fatalf("failed to load exported key: %v", err)
+	}
+	if !pubKey.CanEncrypt(time.Now().Unix()) {
+		t.Fatalf("exported key cannot encrypt")
+	}
+
+	encHandle, err := crypto.PGP().Encryption().Recipient(pubKey).New()
+	if err != nil {
+		t.Fatalf("failed to create encryption handle: %v", err)
+	}
+	msg, err := encHandle.Encrypt([]byte("rotated"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	keyIDs, _ := msg.EncryptionKeyIDs()
+	if len(keyIDs) != 1 || keyIDs[0] == pubKey.GetEntity().Subkeys[0].PublicKey.KeyId {
+		t.Fatalf("message was encrypted to the revoked subkey")
+	}
+
+	privKeyArmor, err := retrieve.RetrievePgpPrivKey(keyDir)
+	if err != nil {
+		t.Fatalf("RetrievePgpPrivKey failed: %v", err)
+	}
+	privKey, err := crypto.NewPrivateKeyFromArmored(privKeyArmor, []byte(testPassphrase))
+	if err != nil {
+		t.Fatalf("edited private key does not unlock: %v", err)
+	}
+	decHandle, err := crypto.PGP().Decryption().DecryptionKey(privKey).New()
+	if err != nil {
+		t.Fatalf("failed to create decryption handle: %v", err)
+	}
+	decrypted, err := decHandle.Decrypt(msg.Bytes(), crypto.Bytes)
+	if err != nil || string(decrypted.Bytes()) != "rotated" {
+		t.Fatalf("failed to decrypt with the new subkey: %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"MindLockr/server/apperr"
+	hybdec "MindLockr/server/cryptography/decryption/hyb_dec"
+	pgptrust "MindLockr/server/cryptography/pgp/pgp_trust"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"MindLockr/server/filesystem/trust"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+func TestTofuPinning(t *testing.T) {
+	folder := newTestVault(t)
+	folderPath := folder.GetFolderPath()
+	pt := &pgptrust.PgpTrust{}
+
+	newKey := func() *crypto.Key {
+		t.Helper()
+		key, err := crypto.PGP().KeyGeneration().AddUserId("Bob", "Bob@Example.com").New().GenerateKey()
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		return key
+	}
+	observe := func(key *crypto.Key) trust.TofuResult {
+		t.Helper()
+		results, err := trust.Observe(folderPath, key)
+		if err != nil {
+			t.Fatalf("Observe failed: %v", err)
+		}
+		if len(results) != 1 || results[0].Email != "bob@example.com" {
+			t.Fatalf("unexpected results: %+v", results)
+		}
+		return results[0]
+	}
+
+	original, replacement, attacker := newKey(), newKey(), newKey()
+
+	if r := observe(original); r.Status != trust.TofuNew {
+		t.Fatalf("first use is %s, want new", r.Status)
+	}
+	pinsPath := filepath.Join(folderPath, "trust", "tofu.json")
+	before, err := os.ReadFile(pinsPath)
+	if err != nil {
+		t.Fatalf("failed to read the pins: %v", err)
+	}
+	if r := observe(original); r.Status != trust.TofuPinned {
+		t.Fatalf("second use is %s, want pinned", r.Status)
+	}
+	if after, _ := os.ReadFile(pinsPath); !bytes.Equal(before, after) {
+		t.Fatal("seeing the pinned key again rewrote the pins")
+	}
+
+	if r := observe(attacker); r.Status != trust.TofuConflict {
+		t.Fatalf("different key is %s, want conflict", r.Status)
+	}
+	// a second change must not replace the one waiting for a decision
+	if r := observe(replacement); r.Status != trust.TofuConflict {
+		t.Fatalf("second different key is %s, want conflict", r.Status)
+	}
+	pins, err := trust.Pins(folderPath)
+	if err != nil || len(pins) != 1 || pins[0].Pending != strings.ToUpper(attacker.GetFingerprint()) {
+		t.Fatalf("the pending key was replaced: %+v, %v", pins, err)
+	}
+	if _, err := pt.RejectKeyChange("bob@example.com"); err != nil {
+		t.Fatalf("RejectKeyChange failed: %v", err)
+	}
+	if r := observe(attacker); r.Status != trust.TofuRejected {
+		t.Fatalf("rejected key is %s, want rejected", r.Status)
+	}
+
+	if r := observe(replacement); r.Status != trust.TofuConflict {
+		t.Fatalf("new key is %s, want conflict", r.Status)
+	}
+	pin, err := pt.AcceptKeyChange("bob@example.com")
+	if err != nil {
+		t.Fatalf("AcceptKeyChange failed: %v", err)
+	}
+	if pin.Fingerprint != strings.ToUpper(replacement.GetFingerprint()) || len(pin.Superseded) != 1 {
+		t.Fatalf("unexpected pin after accepting: %+v", pin)
+	}
+	if r := observe(replacement); r.Status != trust.TofuPinned {
+		t.Fatalf("accepted key is %s, want pinned", r.Status)
+	}
+	if r := observe(original); r.Status != trust.TofuConflict {
+		t.Fatalf("superseded key is %s, want conflict", r.Status)
+	}
+}
+
+func TestTofuConflictFailsStrictMode(t *testing.T) {
+	folder := newTestVault(t, "alice", "bob")
+	hd := &hybdec.HybDec{}
+	t.Cleanup(func() {
+		hd.SetVerificationPolicy(hybdec.VerificationPolicy{Mode: hybdec.PolicyWarn})
+	})
+
+	// another key got pinned for alice's address first
+	impostor, err := crypto.PGP().KeyGeneration().AddUserId("Alice", "alice@example.com").New().GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	if _, err := trust.Observe(folder.GetFolderPath(), impostor); err != nil {
+		t.Fatalf("Observe failed: %v", err)
+	}
+
+	keys, err := pgpfs.VaultKeys()
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("VaultKeys returned %d keys: %v", len(keys), err)
+	}
+	alice, bob := keys[0], keys[1]
+	signer, err := alice.UnlockPrivateKey(testPassphrase)
+	if err != nil {
+		t.Fatalf("failed to unlock alice: %v", err)
+	}
+	encHandle, err := crypto.PGP().Encryption().Recipient(bob.PublicKey).SigningKey(signer).New()
+	if err != nil {
+		t.Fatalf("failed to create encryption handle: %v", err)
+	}
+	msg, err := encHandle.Encrypt([]byte("launch codes"))
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+	armored, _ := msg.Armor()
+
+	decrypt := func(mode string) (hybdec.AutoReturnType, error) {
+		t.Helper()
+		if err := hd.SetVerificationPolicy(hybdec.VerificationPolicy{Mode: mode}); err != nil {
+			t.Fatalf("SetVerificationPolicy failed: %v", err)
+		}
+		return hd.DecryptAuto(hybdec.AutoRequest{PgpMessage: armored, Passphrase: testPassphrase})
+	}
+
+	ret, err := decrypt(hybdec.PolicyStrict)
+	if !errors.Is(err, apperr.ErrSignatureInvalid) || ret.Data != "" || ret.Valid {
+		t.Fatalf("strict mode released a message from a changed key: %q, %v", ret.Data, err)
+	}
+
+	ret, err = decrypt(hybdec.PolicyWarn)
+	if err != nil || ret.Data != "launch codes" || ret.Valid || len(ret.Warnings) == 0 {
+		t.Fatalf("warn mode gave %+v, %v", ret, err)
+	}
+	if ret.Verification == nil || ret.Verification.Failure != hybdec.FailureKeyChanged {
+		t.Fatalf("the key change is missing from the verification: %+v", ret.Verification)
+	}
+
+	// verifying alone reports the key change as well
+	alicePub, _ := alice.PublicKey.GetArmoredPublicKey()
+	bobPriv, err := os.ReadFile(filepath.Join(folder.GetFolderPath(), "pgp-keys", "bob", "private.asc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := hd.ValidateSignature(hybdec.RequestData{
+		PgpMessage:        armored,
+		PubKey:            alicePub,
+		PrivKey:           string(bobPriv),
+		PrivKeyPassphrase: testPassphrase,
+	})
+	if !errors.Is(err, apperr.ErrSignatureInvalid) || v.Valid || v.Failure != hybdec.FailureKeyChanged {
+		t.Fatalf("ValidateSignature accepted a changed key: %+v, %v", v, err)
+	}
+}
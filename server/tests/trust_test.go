@@ -0,0 +1,84 @@
+package tests
+
+import (
+	pgptrust "MindLockr/server/cryptography/pgp/pgp_trust"
+	"MindLockr/server/filesystem/trust"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWebOfTrustValidity(t *testing.T) {
+	folder := newTestVault(t, "alice", "bob", "carol")
+	pt := &pgptrust.PgpTrust{}
+
+	// bob certifies carol while his private key is still around, afterwards
+	// bob and carol become other people's keys
+	if _, err := pt.CertifyKey(pgptrust.CertifyRequest{SignerKeyName: "bob", Passphrase: testPassphrase, TargetKeyName: "carol"}); err != nil {
+		t.Fatalf("CertifyKey(bob -> carol) failed: %v", err)
+	}
+	for _, name := range []string{"bob", "carol"} {
+		if err := os.Remove(filepath.Join(folder.GetFolderPath(), "pgp-keys", name, "private.asc")); err != nil {
+			t.Fatalf("failed to remove private key: %v", err)
+		}
+	}
+
+	validity := func(name string) trust.KeyValidity {
+		t.Helper()
+		list, err := pt.ListKeyValidity()
+		if err != nil {
+			t.Fatalf("ListKeyValidity failed: %v", err)
+		}
+		for _, v := range list {
+			if v.KeyName == name {
+				return v
+			}
+		}
+		t.Fatalf("key %s missing from validity list", name)
+		return trust.KeyValidity{}
+	}
+
+	if v := validity("alice"); v.Validity != trust.ValidityUltimate {
+		t.Fatalf("own key is %s, want ultimate", v.Validity)
+	}
+	if v := validity("bob"); v.Validity != trust.ValidityUnknown {
+		t.Fatalf("uncertified key is %s, want unknown", v.Validity)
+	}
+
+	bobPublic, err := os.ReadFile(filepath.Join(folder.GetFolderPath(), "pgp-keys", "bob", "public.asc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pt.CertifyKey(pgptrust.CertifyRequest{SignerKeyName: "alice", Passphrase: testPassphrase, TargetKeyName: "bob"}); err != nil {
+		t.Fatalf("CertifyKey(alice -> bob) failed: %v", err)
+	}
+	bob := validity("bob")
+	if bob.Validity != trust.ValidityFull {
+		t.Fatalf("key certified by an own key is %s, want full", bob.Validity)
+	}
+
+	// importing a copy without our certification keeps it
+	if _, err := pt.ImportPublicKey(pgptrust.ImportRequest{KeyName: "bob", PublicKey: string(bobPublic)}); err != nil {
+		t.Fatalf("ImportPublicKey failed: %v", err)
+	}
+	if v := validity("bob"); v.Validity != trust.ValidityFull {
+		t.Fatalf("re-imported key is %s, want full", v.Validity)
+	}
+
+	// carol is only certified by bob, whose owner trust decides
+	if v := validity("carol"); v.Validity != trust.ValidityUnknown {
+		t.Fatalf("carol is %s without owner trust in bob, want unknown", v.Validity)
+	}
+	if err := pt.SetOwnerTrust(pgptrust.OwnerTrustRequest{Fingerprint: bob.Fingerprint, Level: trust.OwnerTrustMarginal}); err != nil {
+		t.Fatalf("SetOwnerTrust failed: %v", err)
+	}
+	if v := validity("carol"); v.Validity != trust.ValidityMarginal {
+		t.Fatalf("carol is %s with marginal trust in bob, want marginal", v.Validity)
+	}
+	if err := pt.SetOwnerTrust(pgptrust.OwnerTrustRequest{Fingerprint: bob.Fingerprint, Level: trust.OwnerTrustFull}); err != nil {
+		t.Fatalf("SetOwnerTrust failed: %v", err)
+	}
+	if v := validity("carol"); v.Validity != trust.ValidityFull {
+		t.Fatalf("carol is %s with full trust in bob, want full", v.Validity)
+	}
+}
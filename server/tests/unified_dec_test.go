@@ -0,0 +1,115 @@
+package tests
+
+import (
+	unifieddec "MindLockr/server/cryptography/decryption/unified_dec"
+	"MindLockr/server/filesystem"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"encoding/base64"
+	"testing"
+
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+)
+
+func TestUnifiedDecrypt(t *testing.T) {
+	newTestVault(t, "alice", "bob")
+
+	keys, err := pgpfs.VaultKeys()
+	if err != nil || len(keys) != 2 {
+		t.Fatalf("VaultKeys returned %d keys: %v", len(keys), err)
+	}
+	alice, bob := keys[0], keys[1]
+	signer, err := alice.UnlockPrivateKey(testPassphrase)
+	if err != nil {
+		t.Fatalf("failed to unlock alice: %v", err)
+	}
+
+	pgp := crypto.PGP()
+	plaintext := []byte("the eagle has landed")
+
+	symHandle, _ := pgp.Encryption().Password([]byte("hunter2")).New()
+	symMsg, err := symHandle.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("failed to encrypt with a password: %v", err)
+	}
+	symArmored, _ := symMsg.Armor()
+
+	pubHandle, _ := pgp.Encryption().Recipient(bob.PublicKey).SigningKey(signer).New()
+	pubMsg, err := pubHandle.Encrypt(plaintext)
+	if err != nil {
+		t.Fatalf("failed to encrypt to bob: %v", err)
+	}
+	pubBinary := base64.StdEncoding.EncodeToString(pubMsg.Bytes())
+
+	signHandle, _ := pgp.Sign().SigningKey(signer).New()
+	inline, err := signHandle.Sign(plaintext, crypto.Armor)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	cleartext, err := signHandle.SignCleartext(plaintext)
+	if err != nil {
+		t.Fatalf("failed to sign cleartext: %v", err)
+	}
+
+	cases := []struct {
+		name       string
+		data       string
+		passphrase string
+		kind       string
+		needs      string
+		signed     bool
+	}{
+		{"symmetric", symArmored, "hunter2", unifieddec.KindSymmetric, unifieddec.NeedPassphrase, false},
+		{"public key binary", pubBinary, testPassphrase, unifieddec.KindPublicKey, unifieddec.NeedKeyPassphrase, true},
+		{"inline signed", string(inline), "", unifieddec.KindSigned, unifieddec.NeedNothing, true},
+		{"cleartext signed", string(cleartext), "", unifieddec.KindCleartextSigned, unifieddec.NeedNothing, true},
+	}
+
+	ud := &unifieddec.UnifiedDec{}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ins, err := ud.Inspect(c.data)
+			if err != nil {
+				t.Fatalf("Inspect failed: %v", err)
+			}
+			if ins.Kind != c.kind || ins.Needs != c.needs {
+				t.Fatalf("inspected as %s needing %s, want %s needing %s", ins.Kind, ins.Needs, c.kind, c.needs)
+			}
+
+			result, err := ud.Decrypt(unifieddec.Request{Data: c.data, Passphrase: c.passphrase})
+			if err != nil {
+				t.Fatalf("Decrypt failed: %v", err)
+			}
+			if result.Data != string(plaintext) || result.Kind != c.kind {
+				t.Fatalf("unexpected result: %+v", result)
+			}
+			if result.Signed != c.signed || (c.signed && (!result.Valid || result.Signer.KeyName != "alice")) {
+				t.Fatalf("unexpected signature result: %+v", result.AutoReturnType)
+			}
+		})
+	}
+
+	ins, _ := ud.Inspect(pubBinary)
+	if len(ins.RecipientKeys) != 1 || ins.RecipientKeys[0] != "bob" {
+		t.Fatalf("unexpected recipient keys: %v", ins.RecipientKeys)
+	}
+	ins, _ = ud.Inspect(string(inline))
+	if len(ins.SignerKeys) != 1 || ins.SignerKeys[0] != "alice" {
+		t.Fatalf("unexpected signer keys: %v", ins.SignerKeys)
+	}
+
+	if _, err := ud.Decrypt(unifieddec.Request{Data: "not a message at all"}); err == nil {
+		t.Fatalf("Decrypt accepted garbage input")
+	}
+	// password protected and signed messages need no vault
+	folder := filesystem.GetFolderInstance()
+	folder.UpdateFolderPath("")
+	for _, data := range []string{symArmored, string(cleartext)} {
+		if _, err := ud.Inspect(data); err != nil {
+			t.Fatalf("Inspect without a vault failed: %v", err)
+		}
+		result, err := ud.Decrypt(unifieddec.Request{Data: data, Passphrase: "hunter2"})
+		if err != nil || result.Data != string(plaintext) {
+			t.Fatalf("Decrypt without a vault gave %+v, %v", result, err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package tests
+
+import (
+	pgpedit "MindLockr/server/cryptography/pgp/pgp_edit"
+	pgpfs "MindLockr/server/filesystem/pgp_fs"
+	"path/filepath"
+	"testing"
+)
+
+func TestUserIDManagement(t *testing.T) {
+	folder := newTestVault(t, "alice")
+	edit := &pgpedit.PgpEdit{}
+	keyDir := filepath.Join(folder.GetFolderPath(), "pgp-keys", "alice")
+
+	if _, err := edit.AddUserID(pgpedit.AddUserIDRequest{KeyName: "alice", Passphrase: testPassphrase, Name: "Alice", Email: "alice@work.example"}); err != nil {
+		t.Fatalf("AddUserID failed: %v", err)
+	}
+
+	userIDs, err := edit.SetPrimaryUserID(pgpedit.UserIDRequest{KeyName: "alice", Passphrase: testPassphrase, UserID: "Alice <alice@work.example>"})
+	if err != nil {
+		t.Fatalf("SetPrimaryUserID failed: %v", err)
+	}
+	if len(userIDs) != 2 || userIDs[0].Email != "alice@work.example" || !userIDs[0].Primary {
+		t.Fatalf("unexpected user IDs: %+v", userIDs)
+	}
+
+	if _, err := edit.RevokeUserID(pgpedit.RevokeUserIDRequest{
+		UserIDRequest: pgpedit.UserIDRequest{KeyName: "alice", Passphrase: testPassphrase, UserID: userIDs[1].UserID},
+		Reason:        "invalid",
+		Description:   "left the old address",
+	}); err != nil {
+		t.Fatalf("RevokeUserID failed: %v", err)
+	}
+
+	// the stored public key carries the changes
+	userIDs, err = pgpfs.NewPgpRetrieve(folder).RetrieveUserIDs(keyDir)
+	if err != nil {
+		t.Fatalf("RetrieveUserIDs failed: %v", err)
+	}
+	if len(userIDs) != 2 || !userIDs[0].Primary || userIDs[0].State != pgpfs.UserIDValid || userIDs[1].State != pgpfs.UserIDRevoked {
+		t.Fatalf("unexpected user IDs after revocation: %+v", userIDs)
+	}
+
+	if _, err := edit.RevokeUserID(pgpedit.RevokeUserIDRequest{
+		UserIDRequest: pgpedit.UserIDRequest{KeyName: "alice", Passphrase: testPassphrase, UserID: userIDs[0].UserID},
+	}); err == nil {
+		t.Fatalf("revoking the last valid user ID was allowed")
+	}
+}
@@ -0,0 +1,93 @@
+package tests
+
+import (
+	hybdec "MindLockr/server/cryptography/decryption/hyb_dec"
+	"bytes"
+	gocrypto "crypto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/ProtonMail/gopenpgp/v3/crypto"
+	"github.com/ProtonMail/gopenpgp/v3/profile"
+)
+
+func TestVerificationDetails(t *testing.T) {
+	pgp := crypto.PGPWithProfile(profile.RFC4880())
+	newKey := func() *crypto.Key {
+		t.Helper()
+		key, err := pgp.KeyGeneration().AddUserId("Signer", "signer@example.com").New().GenerateKey()
+		if err != nil {
+			t.Fatalf("failed to generate key: %v", err)
+		}
+		return key
+	}
+	signer, stranger := newKey(), newKey()
+	data := []byte("quarterly report")
+
+	signHandle, _ := pgp.Sign().SigningKey(signer).Detached().New()
+	signature, err := signHandle.Sign(data, crypto.Bytes)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	verify := func(verifier *crypto.Key, data, signature []byte) hybdec.Verification {
+		t.Helper()
+		verifyHandle, err := pgp.Verify().VerificationKey(verifier).New()
+		if err != nil {
+			t.Fatalf("failed to create verify handle: %v", err)
+		}
+		result, err := verifyHandle.VerifyDetached(data, signature, crypto.Bytes)
+		if err != nil {
+			t.Fatalf("VerifyDetached failed: %v", err)
+		}
+		return hybdec.NewVerification(result, nil)
+	}
+
+	v := verify(signer, data, signature)
+	if !v.Valid || v.Failure != "" {
+		t.Fatalf("valid signature reported as %+v", v)
+	}
+	if v.SignerFingerprint != signer.GetFingerprint() || v.SignerKeyID != signer.GetHexKeyID() {
+		t.Fatalf("unexpected signer: %+v", v)
+	}
+	if v.CreatedAt.IsZero() || v.HashAlgorithm == "" || v.KeyExpiredAtSigning || v.KeyRevokedAtSigning {
+		t.Fatalf("unexpected signature details: %+v", v)
+	}
+
+	if v := verify(signer, []byte("tampered report"), signature); v.Valid || v.Failure != hybdec.FailureBadSignature {
+		t.Fatalf("tampered data reported as %+v", v)
+	}
+	if v := verify(stranger, data, signature); v.Valid || v.Failure != hybdec.FailureUnknownKey || v.SignerKeyID != signer.GetHexKeyID() {
+		t.Fatalf("unknown key reported as %+v", v)
+	}
+
+	// go-crypto picks a strong hash from the key preferences, so the
+	// SHA-1 signature is built by hand
+	primary := signer.GetEntity().PrivateKey
+	weakSig := &packet.Signature{
+		Version:      4,
+		SigType:      packet.SigTypeBinary,
+		PubKeyAlgo:   primary.PubKeyAlgo,
+		Hash:         gocrypto.SHA1,
+		CreationTime: time.Now(),
+		IssuerKeyId:  &primary.KeyId,
+	}
+	config := &packet.Config{NonDeterministicSignaturesViaNotation: packet.BoolPointer(false)}
+	h, err := weakSig.PrepareSign(config)
+	if err != nil {
+		t.Fatalf("failed to prepare the SHA-1 signature: %v", err)
+	}
+	h.Write(data)
+	if err := weakSig.Sign(h, primary, config); err != nil {
+		t.Fatalf("failed to sign with SHA-1: %v", err)
+	}
+	var weak bytes.Buffer
+	if err := weakSig.Serialize(&weak); err != nil {
+		t.Fatalf("failed to serialize the SHA-1 signature: %v", err)
+	}
+	if v := verify(signer, data, weak.Bytes()); v.Valid || v.Failure != hybdec.FailureWeakAlgorithm || !strings.Contains(v.HashAlgorithm, "SHA-1") {
+		t.Fatalf("SHA-1 signature reported as %+v", v)
+	}
+}
@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"MindLockr/server/filesystem/watcher"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestVaultWatcher(t *testing.T) {
+	folder := newTestVault(t, "alice")
+	root := folder.GetFolderPath()
+
+	debounce := watcher.Debounce
+	watcher.Debounce = 50 * time.Millisecond
+	t.Cleanup(func() { watcher.Debounce = debounce })
+
+	changes := make(chan watcher.Change, 16)
+	watcher.OnChange(func(c watcher.Change) {
+		select {
+		case changes <- c:
+		default:
+		}
+	})
+
+	w := watcher.NewWatcher(folder)
+	t.Cleanup(w.Close)
+	if err := w.Watch(root); err != nil {
+		t.Fatalf("failed to watch the vault: %v", err)
+	}
+	if status := w.Status(); !status.Watching || status.Root != root {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	expect := func(kind, store, name string) {
+		t.Helper()
+		select {
+		case c := <-changes:
+			if c.Kind != kind || c.Store != store || c.Name != name {
+				t.Fatalf("expected %s %s/%s, got %+v", kind, store, name, c)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("expected %s %s/%s, got nothing", kind, store, name)
+		}
+	}
+	write := func(rel, content string) {
+		t.Helper()
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the store folder and the file appear at once
+	write("sym_lockr/notes.key", "v1")
+	expect(watcher.KindAdded, watcher.StoreSymmetric, "notes.key")
+
+	write("sym_lockr/notes.key", "v2")
+	write("sym_lockr/notes.key", "v3")
+	expect(watcher.KindModified, watcher.StoreSymmetric, "notes.key")
+
+	// not listed, so not reported
+	write("hyb_lockr/readme.txt", "ignored")
+	write("hyb_lockr/message.asc", "armored")
+	expect(watcher.KindAdded, watcher.StoreHybrid, "message.asc")
+
+	os.Remove(filepath.Join(root, "sym_lockr", "notes.key"))
+	expect(watcher.KindRemoved, watcher.StoreSymmetric, "notes.key")
+
+	write("pgp-keys/alice/public.asc", "replaced")
+	expect(watcher.KindModified, watcher.StorePgpKeys, "alice")
+
+	os.RemoveAll(filepath.Join(root, "pgp-keys", "alice"))
+	expect(watcher.KindRemoved, watcher.StorePgpKeys, "alice")
+
+	// a file that comes and goes within the debounce is not reported
+	write("sym_lockr/tmp.key", "x")
+	os.Remove(filepath.Join(root, "sym_lockr", "tmp.key"))
+	select {
+	case c := <-changes:
+		t.Fatalf("expected no change, got %+v", c)
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestVaultWatcherFollowsTheLatestFolder(t *testing.T) {
+	folder := newTestVault(t)
+	root := folder.GetFolderPath()
+	w := watcher.NewWatcher(folder)
+	t.Cleanup(w.Close)
+
+	waitFor := func(what string, ok func(watcher.Status) bool) {
+		t.Helper()
+		deadline := time.Now().Add(3 * time.Second)
+		for !ok(w.Status()) {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %s, got %+v", what, w.Status())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	other := t.TempDir()
+	for i := 0; i < 20; i++ {
+		folder.UpdateFolderPath(other)
+		folder.UpdateFolderPath(root)
+	}
+	waitFor("the last folder to be watched", func(s watcher.Status) bool { return s.Watching && s.Root == root })
+	time.Sleep(100 * time.Millisecond)
+	if status := w.Status(); status.Root != root {
+		t.Fatalf("an older folder change replaced the watch: %+v", status)
+	}
+
+	folder.UpdateFolderPath(filepath.Join(root, "missing"))
+	waitFor("the failure to be reported", func(s watcher.Status) bool { return !s.Watching && s.Error != "" })
+
+	folder.UpdateFolderPath(root)
+	waitFor("the failure to be cleared", func(s watcher.Status) bool { return s.Watching && s.Error == "" })
+}